@@ -0,0 +1,162 @@
+// Command buktctl exports and imports buckets of a running Bukt server
+//
+//	buktctl export -bucket orders -token $TOKEN -format binary -o orders.bukt
+//	buktctl import -bucket orders -token $TOKEN -mode skip-existing -i orders.bukt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const usage = `usage: buktctl <command> [flags]
+
+commands:
+  export   stream a bucket to a file or stdout
+  import   load an archive into a bucket
+`
+
+type commonFlags struct {
+	addr   string
+	bucket string
+	token  string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.addr, "addr", envOr("BUKT_ADDR", "http://localhost:8080"), "server base URL")
+	fs.StringVar(&c.bucket, "bucket", "", "bucket name")
+	fs.StringVar(&c.token, "token", os.Getenv("BUKT_TOKEN"), "bucket auth token")
+}
+
+func (c *commonFlags) validate() error {
+	if c.bucket == "" || c.token == "" {
+		return fmt.Errorf("-bucket and -token are required")
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "buktctl:", err)
+		os.Exit(1)
+	}
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var c commonFlags
+	c.register(fs)
+	format := fs.String("format", "ndjson", "archive format: ndjson or binary")
+	output := fs.String("o", "-", "output file, - for stdout")
+	fs.Parse(args)
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/buckets/%s/export?format=%s", c.addr, url.PathEscape(c.bucket), url.QueryEscape(*format))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := do(req, c.token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	out := os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	n, err := io.Copy(out, resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %s (%d bytes)\n", c.bucket, n)
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var c commonFlags
+	c.register(fs)
+	mode := fs.String("mode", "merge", "import mode: merge, overwrite or skip-existing")
+	input := fs.String("i", "-", "input file, - for stdin")
+	fs.Parse(args)
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	in := os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	endpoint := fmt.Sprintf("%s/api/buckets/%s/import?mode=%s", c.addr, url.PathEscape(c.bucket), url.QueryEscape(*mode))
+	req, err := http.NewRequest(http.MethodPost, endpoint, in)
+	if err != nil {
+		return err
+	}
+
+	resp, err := do(req, c.token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+func do(req *http.Request, token string) (*http.Response, error) {
+	req.Header.Set("X-Bucket-Token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return resp, nil
+}
+
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
@@ -2,25 +2,55 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"key-value-store/internal/auth"
 	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
 	"key-value-store/internal/config"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/gc"
+	"key-value-store/internal/health"
+	"key-value-store/internal/lifecycle"
 	"key-value-store/internal/logger"
+	"key-value-store/internal/metrics"
 	"key-value-store/internal/service"
 	"key-value-store/internal/transport/http"
 	"key-value-store/internal/transport/tcp"
-	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
+// Process exit codes
+const (
+	exitOK             = 0
+	exitStartup        = 1 // invalid state or a listener could not bind
+	exitListenerFailed = 2 // a listener stopped while serving
+	exitShutdown       = 3 // the drain deadline was exceeded or a stop hook failed
+)
+
 func main() {
-	configs := config.NewConfig()
+	configs, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitOK)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", indent(err.Error()))
+		os.Exit(exitStartup)
+	}
+	if configs.PrintConfig {
+		if err := configs.Print(os.Stdout); err != nil {
+			os.Exit(exitStartup)
+		}
+		os.Exit(exitOK)
+	}
 
 	logger.Initialize(logger.Config{
 		Environment: configs.Logging.Environment,
@@ -32,65 +62,200 @@ func main() {
 			slog.Int("http_port", configs.Server.Port),
 			slog.Int("tcp_port", configs.Server.TCPPort),
 		),
+		slog.String("file", configs.File),
 		slog.Group("store",
 			slog.Int("shard_count", configs.Store.ShardCount),
+			slog.String("data_dir", configs.Store.DataDir),
 		),
 	)
 
+	// Readiness waits for restored state and both listeners
+	monitor := health.NewMonitor(health.ListenerHTTP, health.ListenerTCP)
+
 	slog.Info("Starting Lyko Key-Value Store.")
 	slog.Info("Server starting...", "port", configs.Server.Port, "environment", configs.Logging.Environment, "log level", configs.Logging.Level)
 
 	// Initialize singleton auth manager with secret key
-	auth.Initialize(configs.Auth.TokenSecret)
+	var authStorePath string
+	if configs.Store.DataDir != "" {
+		authStorePath = filepath.Join(configs.Store.DataDir, auth.StoreFileName)
+	}
+	if err := auth.Initialize(configs.Auth.TokenSecret, authStorePath); err != nil {
+		slog.Error("Failed to initialize auth manager", "path", authStorePath, "error", err)
+		os.Exit(exitStartup)
+	}
 	slog.Info("Auth manager initialized")
 	fmt.Println(auth.Manager().GenerateToken("default", 0))
 
+	// The admin token is handed over through a file, never through the logs
+	if configs.Store.DataDir != "" {
+		adminTokenPath := filepath.Join(configs.Store.DataDir, auth.AdminTokenFileName)
+		minted, err := auth.Manager().BootstrapAdminToken(adminTokenPath)
+		if err != nil {
+			slog.Error("Failed to write admin token", "path", adminTokenPath, "error", err)
+			os.Exit(exitStartup)
+		}
+		if minted {
+			slog.Info("Admin token written", "path", adminTokenPath)
+		}
+	} else {
+		slog.Warn("No data directory, admin token not issued, set DATA_DIR to use the admin API")
+	}
+
+	// Collectors read their wheel geometry when created, before the buckets are restored
+	gc.SetParams(gcParams(configs))
+
+	clk := clock.Real()
+
 	// Create bucket manager
-	bucketManager := bucket.NewBucketManager(configs)
+	bucketManager, err := bucket.NewBucketManager(configs, clk)
+	if err != nil {
+		slog.Error("Failed to initialize bucket manager", "error", err)
+		os.Exit(exitStartup)
+	}
+
+	monitor.MarkRestored()
+	metrics.Register(bucket.NewCollector(bucketManager))
 
 	// Create services
-	storageService := service.NewStorageService(bucketManager, configs)
+	storageService := service.NewStorageService(bucketManager, configs, clk)
 	bucketService := service.NewBucketService(bucketManager)
-
-	// Create HTTP router
-	httpRouter := http.NewRouter(storageService, bucketService)
+	authService := service.NewAuthService(bucketManager)
+	transferService := service.NewTransferService(bucketManager, clk)
+	healthService := service.NewHealthService(bucketManager, monitor)
+	hashService := service.NewHashService(bucketManager, clk)
+	listService := service.NewListService(bucketManager, clk)
+	setService := service.NewSetService(bucketManager, clk)
+	sortedSetService := service.NewSortedSetService(bucketManager, clk)
+	jsonService := service.NewJSONService(bucketManager, clk)
+	indexService := service.NewIndexService(bucketManager)
+	streamService := service.NewStreamService(bucketManager, clk)
+	hllService := service.NewHyperLogLogService(bucketManager, clk)
+	bloomService := service.NewBloomService(bucketManager, clk)
+	lockService := service.NewLockService(bucketManager, clk)
+	rateLimitService := service.NewRateLimitService(bucketManager, clk)
+	idempotencyService := service.NewIdempotencyService(bucketManager, clk, time.Duration(configs.Server.IdempotencyWindow)*time.Second)
 
 	// Create TCP handler and server
-	tcpHandler := tcp.NewHandler(storageService)
+	tcpHandler := tcp.NewHandler(storageService, bucketService, hashService, listService, setService, sortedSetService, jsonService, indexService, streamService, hllService, bloomService, lockService, rateLimitService)
 	tcpAddr := ":" + strconv.Itoa(configs.Server.TCPPort)
 	tcpServer := tcp.NewServer(tcpAddr, tcpHandler)
 
-	go func() {
-		slog.Info("TCP Server starting", "address", tcpAddr)
-		if err := tcpServer.Start(); err != nil {
-			log.Fatal("Failed to start TCP server", err)
-		}
-	}()
+	// Settings applied again on SIGHUP and POST /api/admin/config/reload
+	configService := service.NewConfigService(configs, bucketManager,
+		func(cfg *config.Configuration) { logger.SetLevel(cfg.Logging.Level) },
+		func(cfg *config.Configuration) {
+			tcpServer.SetTimeouts(
+				time.Duration(cfg.Server.TCPReadTimeout)*time.Second,
+				time.Duration(cfg.Server.TCPWriteTimeout)*time.Second,
+				time.Duration(cfg.Server.TCPAcceptDeadline)*time.Second,
+			)
+		},
+		func(cfg *config.Configuration) {
+			p := gc.CurrentParams()
+			p.NearTickMs = cfg.GC.NearTickMs
+			p.FlushMs = cfg.GC.FlushIntervalMs
+			gc.SetParams(p)
+		},
+	)
 
-	// Start HTTP server in goroutine
+	// Create HTTP router
 	httpAddr := ":" + strconv.Itoa(configs.Server.Port)
-	go func() {
-		slog.Info("HTTP Server starting", "address", httpAddr)
-		if err := httpRouter.Run(httpAddr); err != nil {
-			log.Fatal("Failed to start HTTP server", err)
-		}
-	}()
+	httpRouter := http.NewRouter(httpAddr, http.Services{
+		Storage:     storageService,
+		Bucket:      bucketService,
+		Auth:        authService,
+		Transfer:    transferService,
+		Health:      healthService,
+		Config:      configService,
+		Hash:        hashService,
+		List:        listService,
+		Set:         setService,
+		SortedSet:   sortedSetService,
+		JSON:        jsonService,
+		Index:       indexService,
+		Stream:      streamService,
+		HLL:         hllService,
+		Bloom:       bloomService,
+		Lock:        lockService,
+		RateLimit:   rateLimitService,
+		Idempotency: idempotencyService,
+	})
 
-	// Wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	server := lifecycle.New(monitor, time.Duration(configs.Server.ShutdownTimeout)*time.Second)
+	server.AddListener(health.ListenerTCP, tcpServer)
+	server.AddListener(health.ListenerHTTP, httpRouter)
+	server.OnDrain("blocking pops", listService.Close)
+	server.OnDrain("blocking stream reads", streamService.Close)
+	server.OnDrain("blocking lock acquires", lockService.Close)
+	server.OnStop("buckets", bucketManager.Shutdown)
 
-	slog.Info("Shutting down servers...")
+	go reloadOnHangup(configService)
 
-	// Create shutdown context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Run until interrupted or a listener fails
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err = server.Run(ctx)
+	stop()
 
-	// Stop TCP server
-	if err := tcpServer.Stop(ctx); err != nil {
-		slog.Error("TCP Server shutdown error", "error", err)
+	code := exitCode(err)
+	if code == exitOK {
+		slog.Info("Servers stopped gracefully")
+	} else {
+		slog.Error("Servers stopped", "error", err, "exit_code", code)
 	}
+	os.Exit(code)
+}
 
-	slog.Info("Servers stopped gracefully")
+// reloadOnHangup reloads the configuration on every SIGHUP
+func reloadOnHangup(configService service.IConfigService) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		slog.Info("SIGHUP received, reloading configuration")
+		result, err := configService.Reload(context.Background())
+		if err != nil {
+			slog.Error("Configuration reload rejected", "error", err)
+			continue
+		}
+		for _, c := range result.Applied {
+			slog.Info("Setting applied", "key", c.Key, "old", c.Old, "new", c.New)
+		}
+		for _, c := range result.Skipped {
+			slog.Info("Setting removed, bucket keeps its value", "key", c.Key, "old", c.Old)
+		}
+		for _, w := range result.Warnings {
+			slog.Warn("Setting not applied", "reason", w)
+		}
+	}
+}
+
+// gcParams maps the GC settings to the collector parameters
+func gcParams(cfg *config.Configuration) gc.Params {
+	return gc.Params{
+		Workers:      cfg.GC.Workers,
+		WheelSlots:   cfg.GC.WheelSlots,
+		WheelTickMs:  cfg.GC.WheelTickMs,
+		NearWindowMs: cfg.GC.NearWindowMs,
+		NearTickMs:   cfg.GC.NearTickMs,
+		FlushMs:      cfg.GC.FlushIntervalMs,
+	}
+}
+
+// indent prefixes every line of s, one configuration problem per line
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+// exitCode maps the lifecycle error to the process exit status
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errs.ErrListenerStart):
+		return exitStartup
+	case errors.Is(err, errs.ErrListenerFailed):
+		return exitListenerFailed
+	default:
+		return exitShutdown
+	}
 }
@@ -0,0 +1,115 @@
+// Package archive implements the portable bucket export format
+// Two encodings are supported: NDJSON (one JSON object per line, header first)
+// and a compact big-endian binary encoding
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatBinary = "binary"
+
+	ContentTypeNDJSON = "application/x-ndjson"
+	ContentTypeBinary = "application/octet-stream"
+
+	formatName = "bukt-export"
+	version    = 1
+)
+
+const (
+	// TypeStream marks a record whose value is the encoded state of a stream,
+	// plain values have no type
+	TypeStream = "stream"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown archive format")
+	ErrInvalidArchive  = errors.New("invalid archive")
+	ErrUnsupportedVers = errors.New("unsupported archive version")
+	ErrUnknownType     = errors.New("unknown record type")
+)
+
+// Header is written once at the start of every archive
+type Header struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Bucket     string    `json:"bucket"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// Record is a single exported entry
+type Record struct {
+	Key          string    `json:"key"`
+	Type         string    `json:"type,omitempty"`
+	Value        []byte    `json:"value"`
+	TTLRemaining int64     `json:"ttl_remaining,omitempty"` // seconds, 0 = no expiration
+	SingleRead   bool      `json:"single_read,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Encoder interface {
+	WriteHeader(h Header) error
+	WriteRecord(r Record) error
+	Close() error
+}
+
+type Decoder interface {
+	Header() Header
+	// Next returns io.EOF after the last record
+	Next() (Record, error)
+}
+
+func NewHeader(bucket string) Header {
+	return Header{
+		Format:     formatName,
+		Version:    version,
+		Bucket:     bucket,
+		ExportedAt: time.Now().UTC(),
+	}
+}
+
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatNDJSON, "":
+		return newNDJSONEncoder(w), nil
+	case FormatBinary:
+		return newBinaryEncoder(w), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// NewDecoder detects the encoding from the first bytes and reads the header
+func NewDecoder(r io.Reader) (Decoder, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	magic, err := br.Peek(len(binaryMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if string(magic) == binaryMagic {
+		return newBinaryDecoder(br)
+	}
+	return newNDJSONDecoder(br)
+}
+
+func ContentType(format string) string {
+	if format == FormatBinary {
+		return ContentTypeBinary
+	}
+	return ContentTypeNDJSON
+}
+
+func checkHeader(h Header) error {
+	if h.Format != formatName {
+		return ErrInvalidArchive
+	}
+	if h.Version != version {
+		return ErrUnsupportedVers
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	binaryMagic = "BUKT"
+
+	recordMarker byte = 0x01
+	endMarker    byte = 0x00
+
+	flagSingleRead byte = 0x01
+	flagStream     byte = 0x02
+
+	maxValueLen = 1 << 30
+)
+
+// Format: [Magic(4)][Version(1)][BucketLen(2)][Bucket][ExportedAt(8)]
+// then records: [Marker(1)][KeyLen(2)][Key][TTLRemaining(8)][CreatedAt(8)][Flags(1)][ValueLen(4)][Value]
+// and a final [EndMarker(1)]
+type binaryEncoder struct {
+	bw      *bufio.Writer
+	scratch [8]byte
+}
+
+func newBinaryEncoder(w io.Writer) *binaryEncoder {
+	return &binaryEncoder{bw: bufio.NewWriterSize(w, 32<<10)}
+}
+
+func (e *binaryEncoder) WriteHeader(h Header) error {
+	e.bw.WriteString(binaryMagic)
+	e.bw.WriteByte(byte(h.Version))
+	e.writeString(h.Bucket)
+	return e.writeInt64(h.ExportedAt.UnixNano())
+}
+
+func (e *binaryEncoder) WriteRecord(r Record) error {
+	e.bw.WriteByte(recordMarker)
+	e.writeString(r.Key)
+	e.writeInt64(r.TTLRemaining)
+	e.writeInt64(r.CreatedAt.UnixNano())
+
+	var flags byte
+	if r.SingleRead {
+		flags |= flagSingleRead
+	}
+	if r.Type == TypeStream {
+		flags |= flagStream
+	}
+	e.bw.WriteByte(flags)
+
+	binary.BigEndian.PutUint32(e.scratch[:4], uint32(len(r.Value)))
+	e.bw.Write(e.scratch[:4])
+	_, err := e.bw.Write(r.Value)
+	return err
+}
+
+func (e *binaryEncoder) Close() error {
+	e.bw.WriteByte(endMarker)
+	return e.bw.Flush()
+}
+
+func (e *binaryEncoder) writeString(s string) {
+	binary.BigEndian.PutUint16(e.scratch[:2], uint16(len(s)))
+	e.bw.Write(e.scratch[:2])
+	e.bw.WriteString(s)
+}
+
+func (e *binaryEncoder) writeInt64(v int64) error {
+	binary.BigEndian.PutUint64(e.scratch[:8], uint64(v))
+	_, err := e.bw.Write(e.scratch[:8])
+	return err
+}
+
+type binaryDecoder struct {
+	br      *bufio.Reader
+	header  Header
+	scratch [8]byte
+	done    bool
+}
+
+func newBinaryDecoder(br *bufio.Reader) (*binaryDecoder, error) {
+	d := &binaryDecoder{br: br}
+
+	if _, err := br.Discard(len(binaryMagic)); err != nil {
+		return nil, ErrInvalidArchive
+	}
+	v, err := br.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+	bucket, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	exportedAt, err := d.readInt64()
+	if err != nil {
+		return nil, err
+	}
+
+	d.header = Header{
+		Format:     formatName,
+		Version:    int(v),
+		Bucket:     bucket,
+		ExportedAt: time.Unix(0, exportedAt).UTC(),
+	}
+	if err := checkHeader(d.header); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *binaryDecoder) Header() Header { return d.header }
+
+func (d *binaryDecoder) Next() (Record, error) {
+	if d.done {
+		return Record{}, io.EOF
+	}
+
+	marker, err := d.br.ReadByte()
+	if err != nil {
+		return Record{}, ErrInvalidArchive
+	}
+	if marker == endMarker {
+		d.done = true
+		return Record{}, io.EOF
+	}
+	if marker != recordMarker {
+		return Record{}, ErrInvalidArchive
+	}
+
+	var r Record
+	if r.Key, err = d.readString(); err != nil {
+		return Record{}, err
+	}
+	if r.TTLRemaining, err = d.readInt64(); err != nil {
+		return Record{}, err
+	}
+	createdAt, err := d.readInt64()
+	if err != nil {
+		return Record{}, err
+	}
+	r.CreatedAt = time.Unix(0, createdAt).UTC()
+
+	flags, err := d.br.ReadByte()
+	if err != nil {
+		return Record{}, ErrInvalidArchive
+	}
+	r.SingleRead = flags&flagSingleRead != 0
+	if flags&flagStream != 0 {
+		r.Type = TypeStream
+	}
+
+	if _, err := io.ReadFull(d.br, d.scratch[:4]); err != nil {
+		return Record{}, ErrInvalidArchive
+	}
+	valueLen := binary.BigEndian.Uint32(d.scratch[:4])
+	if valueLen > maxValueLen {
+		return Record{}, ErrInvalidArchive
+	}
+	r.Value = make([]byte, valueLen)
+	if _, err := io.ReadFull(d.br, r.Value); err != nil {
+		return Record{}, ErrInvalidArchive
+	}
+	return r, nil
+}
+
+func (d *binaryDecoder) readString() (string, error) {
+	if _, err := io.ReadFull(d.br, d.scratch[:2]); err != nil {
+		return "", ErrInvalidArchive
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(d.scratch[:2]))
+	if _, err := io.ReadFull(d.br, buf); err != nil {
+		return "", ErrInvalidArchive
+	}
+	return string(buf), nil
+}
+
+func (d *binaryDecoder) readInt64() (int64, error) {
+	if _, err := io.ReadFull(d.br, d.scratch[:8]); err != nil {
+		return 0, ErrInvalidArchive
+	}
+	return int64(binary.BigEndian.Uint64(d.scratch[:8])), nil
+}
@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ndjsonEnd is the last line of an archive, an archive cut at a line
+// boundary is told apart from a complete one by its missing end
+type ndjsonEnd struct {
+	End   bool  `json:"end"`
+	Count int64 `json:"count"`
+}
+
+// ndjsonLine is a record or the end line
+type ndjsonLine struct {
+	Record
+	End   bool  `json:"end,omitempty"`
+	Count int64 `json:"count,omitempty"`
+}
+
+type ndjsonEncoder struct {
+	bw    *bufio.Writer
+	enc   *json.Encoder
+	count int64
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	return &ndjsonEncoder{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (e *ndjsonEncoder) WriteHeader(h Header) error { return e.enc.Encode(h) }
+
+func (e *ndjsonEncoder) WriteRecord(r Record) error {
+	if err := e.enc.Encode(r); err != nil {
+		return err
+	}
+	e.count++
+	return nil
+}
+
+func (e *ndjsonEncoder) Close() error {
+	if err := e.enc.Encode(ndjsonEnd{End: true, Count: e.count}); err != nil {
+		return err
+	}
+	return e.bw.Flush()
+}
+
+type ndjsonDecoder struct {
+	dec    *json.Decoder
+	header Header
+	count  int64
+	done   bool
+}
+
+func newNDJSONDecoder(r io.Reader) (*ndjsonDecoder, error) {
+	d := &ndjsonDecoder{dec: json.NewDecoder(r)}
+	if err := d.dec.Decode(&d.header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrInvalidArchive
+		}
+		return nil, err
+	}
+	if err := checkHeader(d.header); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *ndjsonDecoder) Header() Header { return d.header }
+
+func (d *ndjsonDecoder) Next() (Record, error) {
+	if d.done {
+		return Record{}, io.EOF
+	}
+
+	var line ndjsonLine
+	if err := d.dec.Decode(&line); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, ErrInvalidArchive
+		}
+		return Record{}, err
+	}
+	if line.End {
+		if line.Count != d.count {
+			return Record{}, ErrInvalidArchive
+		}
+		d.done = true
+		return Record{}, io.EOF
+	}
+	if line.Key == "" {
+		return Record{}, ErrInvalidArchive
+	}
+	d.count++
+	return line.Record, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"errors"
+	"key-value-store/internal/util"
+	"os"
+	"strings"
+)
+
+const (
+	// AdminTokenFileName is the admin token file name inside the data directory
+	AdminTokenFileName = "admin.token"
+	// AdminTokenTTL is the validity of a bootstrapped admin token, in seconds
+	AdminTokenTTL = 30 * 24 * 3600
+)
+
+// BootstrapAdminToken writes an admin token to path, readable by the owner
+// only, unless the file already holds a valid one
+// A token that expired, was revoked or whose key was removed is replaced, so
+// restarts do not mint more admin tokens; minted reports a new token
+func (tm *TokenManager) BootstrapAdminToken(path string) (minted bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err == nil && tm.ValidateToken(strings.TrimSpace(string(data)), AdminSubject) {
+		return false, nil
+	}
+
+	token := tm.GenerateToken(AdminSubject, AdminTokenTTL)
+	if err := util.WriteFileAtomic(path, []byte(token+"\n"), 0o600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
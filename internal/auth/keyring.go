@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// SigningKey is an HMAC key identified by the key ID embedded in tokens
+type SigningKey struct {
+	ID        uint32
+	Secret    []byte
+	CreatedAt time.Time
+	RetireAt  time.Time // zero = accepted until removed
+}
+
+func (k *SigningKey) acceptsAt(now time.Time) bool {
+	return k.RetireAt.IsZero() || now.Before(k.RetireAt)
+}
+
+// KeyInfo describes a signing key without exposing its secret
+type KeyInfo struct {
+	ID        uint32
+	Active    bool
+	CreatedAt time.Time
+	RetireAt  time.Time
+}
+
+// Revocation holds the revoked tokens of a single subject
+type Revocation struct {
+	IssuedBefore int64            // unix nanoseconds, tokens issued before are rejected
+	TokenIDs     map[string]int64 // token id -> token expiry (0 = never expires)
+}
+
+// Alias rebinds the tokens of a renamed subject
+// Tokens issued before the rename validate for Target instead of their own subject
+type Alias struct {
+	Target string
+	Before int64 // unix nanoseconds
+}
+
+type tokenState struct {
+	activeKID   uint32
+	keys        map[uint32]*SigningKey
+	revocations map[string]*Revocation
+	aliases     map[string]Alias
+}
+
+func newTokenState(secretKey []byte) *tokenState {
+	key := &SigningKey{ID: 1, Secret: secretKey, CreatedAt: time.Now()}
+	return &tokenState{
+		activeKID:   key.ID,
+		keys:        map[uint32]*SigningKey{key.ID: key},
+		revocations: make(map[string]*Revocation),
+		aliases:     make(map[string]Alias),
+	}
+}
+
+func (st *tokenState) clone() *tokenState {
+	ns := &tokenState{
+		activeKID:   st.activeKID,
+		keys:        make(map[uint32]*SigningKey, len(st.keys)+1),
+		revocations: make(map[string]*Revocation, len(st.revocations)+1),
+		aliases:     make(map[string]Alias, len(st.aliases)+1),
+	}
+	for id, k := range st.keys {
+		ns.keys[id] = k
+	}
+	for subject, r := range st.revocations {
+		ns.revocations[subject] = r
+	}
+	for subject, a := range st.aliases {
+		ns.aliases[subject] = a
+	}
+	return ns
+}
+
+// hasSecret reports whether any signing key uses secret
+func (st *tokenState) hasSecret(secret []byte) bool {
+	for _, k := range st.keys {
+		if hmac.Equal(k.Secret, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the subject the token currently validates for
+func (st *tokenState) resolve(claims TokenClaims) string {
+	if a, ok := st.aliases[claims.Subject]; ok && claims.IssuedAt < a.Before {
+		return a.Target
+	}
+	return claims.Subject
+}
+
+// isRevoked checks the revocations of the token subject and of the subject it resolves to
+func (st *tokenState) isRevoked(claims TokenClaims, resolved string) bool {
+	if st.revokedBy(claims.Subject, claims) {
+		return true
+	}
+	return resolved != claims.Subject && st.revokedBy(resolved, claims)
+}
+
+func (st *tokenState) revokedBy(subject string, claims TokenClaims) bool {
+	r, ok := st.revocations[subject]
+	if !ok {
+		return false
+	}
+	if claims.IssuedAt < r.IssuedBefore {
+		return true
+	}
+	if claims.TokenID == "" {
+		return false
+	}
+	_, revoked := r.TokenIDs[claims.TokenID]
+	return revoked
+}
+
+// Keys lists the signing keys ordered by ID
+func (tm *TokenManager) Keys() []KeyInfo {
+	st := tm.snapshot()
+	result := make([]KeyInfo, 0, len(st.keys))
+	for _, k := range st.keys {
+		result = append(result, KeyInfo{
+			ID:        k.ID,
+			Active:    k.ID == st.activeKID,
+			CreatedAt: k.CreatedAt,
+			RetireAt:  k.RetireAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// RotateKey generates a new active signing key
+// The previous active key keeps validating tokens for the grace period (0 = until removed)
+func (tm *TokenManager) RotateKey(grace time.Duration) (KeyInfo, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return KeyInfo{}, err
+	}
+
+	tm.writeMu.Lock()
+	defer tm.writeMu.Unlock()
+
+	old := tm.snapshot()
+	ns := old.clone()
+	now := time.Now()
+
+	var nextID uint32
+	for id := range ns.keys {
+		if id > nextID {
+			nextID = id
+		}
+	}
+	nextID++
+
+	if prev, ok := ns.keys[old.activeKID]; ok && grace > 0 {
+		retired := *prev
+		retired.RetireAt = now.Add(grace)
+		ns.keys[prev.ID] = &retired
+	}
+
+	key := &SigningKey{ID: nextID, Secret: secret, CreatedAt: now}
+	ns.keys[key.ID] = key
+	ns.activeKID = key.ID
+
+	if err := tm.persist(ns); err != nil {
+		return KeyInfo{}, err
+	}
+	tm.ptr.Store(ns)
+
+	slog.Info("TokenManager: Rotated signing key", "kid", key.ID, "previous_kid", old.activeKID, "grace", grace)
+	return KeyInfo{ID: key.ID, Active: true, CreatedAt: key.CreatedAt}, nil
+}
+
+// RemoveKey removes a signing key, every token signed with it stops validating
+func (tm *TokenManager) RemoveKey(id uint32) error {
+	tm.writeMu.Lock()
+	defer tm.writeMu.Unlock()
+
+	old := tm.snapshot()
+	if _, ok := old.keys[id]; !ok {
+		return errs.ErrSigningKeyNotFound
+	}
+	if id == old.activeKID {
+		return errs.ErrActiveSigningKey
+	}
+
+	ns := old.clone()
+	delete(ns.keys, id)
+
+	if err := tm.persist(ns); err != nil {
+		return err
+	}
+	tm.ptr.Store(ns)
+
+	slog.Info("TokenManager: Removed signing key", "kid", id)
+	return nil
+}
+
+// RevokeToken revokes a single token after verifying it was issued by this manager
+func (tm *TokenManager) RevokeToken(tokenStr string) (TokenClaims, error) {
+	claims, err := tm.ParseToken(tokenStr)
+	if err != nil {
+		return TokenClaims{}, err
+	}
+	if claims.TokenID == "" {
+		// Legacy tokens have no ID, they can only be revoked by issue time
+		return TokenClaims{}, errs.ErrInvalidToken
+	}
+	return claims, tm.RevokeTokenID(claims.Subject, claims.TokenID, claims.ExpiresAt)
+}
+
+// RevokeTokenID revokes a token by ID
+// expiresAt lets the entry be pruned once the token would have expired anyway (0 = keep)
+func (tm *TokenManager) RevokeTokenID(subject, tokenID string, expiresAt int64) error {
+	return tm.updateRevocation(subject, func(r *Revocation) {
+		r.TokenIDs[tokenID] = expiresAt
+	})
+}
+
+// RevokeIssuedBefore revokes every token of subject issued before t
+// Aliases to subject whose tokens are all revoked by this are dropped
+func (tm *TokenManager) RevokeIssuedBefore(subject string, t time.Time) error {
+	return tm.update(func(ns *tokenState) {
+		ns.revoke(subject, func(r *Revocation) {
+			if ts := t.UnixNano(); ts > r.IssuedBefore {
+				r.IssuedBefore = ts
+			}
+		})
+		for from, a := range ns.aliases {
+			if a.Target == subject && a.Before <= t.UnixNano() {
+				delete(ns.aliases, from)
+			}
+		}
+	})
+}
+
+// AliasSubject moves the tokens of from issued before t to subject to
+// Existing aliases to from are retargeted, so tokens never resolve over more than one hop
+func (tm *TokenManager) AliasSubject(from, to string, t time.Time) error {
+	return tm.update(func(ns *tokenState) {
+		for subject, a := range ns.aliases {
+			if a.Target != from {
+				continue
+			}
+			if subject == to {
+				// Renamed back, these tokens carry their own subject again
+				delete(ns.aliases, subject)
+				continue
+			}
+			a.Target = to
+			ns.aliases[subject] = a
+		}
+		ns.aliases[from] = Alias{Target: to, Before: t.UnixNano()}
+	})
+}
+
+// Aliases returns the subjects whose tokens resolve to target
+func (tm *TokenManager) Aliases(target string) map[string]Alias {
+	out := make(map[string]Alias)
+	for subject, a := range tm.snapshot().aliases {
+		if a.Target == target {
+			out[subject] = a
+		}
+	}
+	return out
+}
+
+// Revocations returns a copy of the revocations of subject
+func (tm *TokenManager) Revocations(subject string) Revocation {
+	r, ok := tm.snapshot().revocations[subject]
+	if !ok {
+		return Revocation{TokenIDs: map[string]int64{}}
+	}
+	return r.copy()
+}
+
+func (r *Revocation) copy() Revocation {
+	ids := make(map[string]int64, len(r.TokenIDs)+1)
+	for id, exp := range r.TokenIDs {
+		ids[id] = exp
+	}
+	return Revocation{IssuedBefore: r.IssuedBefore, TokenIDs: ids}
+}
+
+func (tm *TokenManager) updateRevocation(subject string, fn func(r *Revocation)) error {
+	return tm.update(func(ns *tokenState) {
+		ns.revoke(subject, fn)
+	})
+}
+
+// update applies fn to a copy of the state, persists and publishes it
+func (tm *TokenManager) update(fn func(ns *tokenState)) error {
+	tm.writeMu.Lock()
+	defer tm.writeMu.Unlock()
+
+	ns := tm.snapshot().clone()
+	fn(ns)
+
+	if err := tm.persist(ns); err != nil {
+		return err
+	}
+	tm.ptr.Store(ns)
+	return nil
+}
+
+func (st *tokenState) revoke(subject string, fn func(r *Revocation)) {
+	var r Revocation
+	if cur, ok := st.revocations[subject]; ok {
+		r = cur.copy()
+	} else {
+		r = Revocation{TokenIDs: make(map[string]int64)}
+	}
+	fn(&r)
+
+	// Drop revoked IDs whose tokens have expired on their own
+	now := time.Now().Unix()
+	for id, exp := range r.TokenIDs {
+		if exp > 0 && exp < now {
+			delete(r.TokenIDs, id)
+		}
+	}
+	st.revocations[subject] = &r
+}
+
+// Persistence
+
+type storedKey struct {
+	ID        uint32    `json:"id"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	RetireAt  int64     `json:"retire_at,omitempty"`
+}
+
+type storedRevocation struct {
+	IssuedBefore int64            `json:"issued_before_ns,omitempty"`
+	TokenIDs     map[string]int64 `json:"token_ids,omitempty"`
+}
+
+type storedAlias struct {
+	Target string `json:"target"`
+	Before int64  `json:"before_ns"`
+}
+
+type storedState struct {
+	ActiveKeyID uint32                      `json:"active_key_id"`
+	Keys        []storedKey                 `json:"keys"`
+	Revocations map[string]storedRevocation `json:"revocations,omitempty"`
+	Aliases     map[string]storedAlias      `json:"aliases,omitempty"`
+}
+
+func (tm *TokenManager) persist(st *tokenState) error {
+	if tm.storePath == "" {
+		return nil
+	}
+
+	out := storedState{
+		ActiveKeyID: st.activeKID,
+		Keys:        make([]storedKey, 0, len(st.keys)),
+		Revocations: make(map[string]storedRevocation, len(st.revocations)),
+		Aliases:     make(map[string]storedAlias, len(st.aliases)),
+	}
+	for _, k := range st.keys {
+		sk := storedKey{
+			ID:        k.ID,
+			Secret:    base64.StdEncoding.EncodeToString(k.Secret),
+			CreatedAt: k.CreatedAt,
+		}
+		if !k.RetireAt.IsZero() {
+			sk.RetireAt = k.RetireAt.Unix()
+		}
+		out.Keys = append(out.Keys, sk)
+	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].ID < out.Keys[j].ID })
+	for subject, r := range st.revocations {
+		out.Revocations[subject] = storedRevocation{IssuedBefore: r.IssuedBefore, TokenIDs: r.TokenIDs}
+	}
+	for subject, a := range st.aliases {
+		out.Aliases[subject] = storedAlias{Target: a.Target, Before: a.Before}
+	}
+
+	return util.WriteJSONFile(tm.storePath, out, 0o600)
+}
+
+func loadTokenState(path string) (*tokenState, bool, error) {
+	if path == "" {
+		return nil, false, nil
+	}
+
+	var in storedState
+	found, err := util.ReadJSONFile(path, &in)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	st := &tokenState{
+		activeKID:   in.ActiveKeyID,
+		keys:        make(map[uint32]*SigningKey, len(in.Keys)),
+		revocations: make(map[string]*Revocation, len(in.Revocations)),
+		aliases:     make(map[string]Alias, len(in.Aliases)),
+	}
+	for _, sk := range in.Keys {
+		secret, err := base64.StdEncoding.DecodeString(sk.Secret)
+		if err != nil {
+			return nil, true, err
+		}
+		key := &SigningKey{ID: sk.ID, Secret: secret, CreatedAt: sk.CreatedAt}
+		if sk.RetireAt > 0 {
+			key.RetireAt = time.Unix(sk.RetireAt, 0)
+		}
+		st.keys[key.ID] = key
+	}
+	if _, ok := st.keys[st.activeKID]; !ok {
+		return nil, true, errs.ErrSigningKeyNotFound
+	}
+	for subject, r := range in.Revocations {
+		ids := r.TokenIDs
+		if ids == nil {
+			ids = make(map[string]int64)
+		}
+		st.revocations[subject] = &Revocation{IssuedBefore: r.IssuedBefore, TokenIDs: ids}
+	}
+	for subject, a := range in.Aliases {
+		st.aliases[subject] = Alias{Target: a.Target, Before: a.Before}
+	}
+
+	slog.Info("TokenManager: Loaded key ring", "path", path, "keys", len(st.keys), "active_kid", st.activeKID)
+	return st, true, nil
+}
@@ -2,6 +2,9 @@ package auth
 
 import "sync"
 
+// StoreFileName is the key ring file name inside the data directory
+const StoreFileName = "auth.json"
+
 var (
 	instance     *TokenManager
 	instanceOnce sync.Once
@@ -9,10 +12,17 @@ var (
 
 // Initialize sets up the singleton token manager
 // Must be called once at application startup
-func Initialize(secretKey []byte) {
+// storePath persists signing keys and revocations (empty = in-memory only)
+func Initialize(secretKey []byte, storePath string) error {
+	var err error
 	instanceOnce.Do(func() {
-		instance = NewTokenManager(secretKey)
+		if storePath == "" {
+			instance = NewTokenManager(secretKey)
+			return
+		}
+		instance, err = LoadTokenManager(secretKey, storePath)
 	})
+	return err
 }
 
 // Manager returns the singleton token manager instance
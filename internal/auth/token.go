@@ -2,136 +2,225 @@ package auth
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
+	"key-value-store/internal/errs"
 	"key-value-store/internal/util"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// AdminSubject is the reserved token subject for admin endpoints
+// Bucket names cannot contain underscores, so it never collides with a bucket
+const AdminSubject = "__admin__"
+
+const (
+	tokenVersion   = 1
+	tokenIDSize    = 16
+	signatureSize  = sha256.Size
+	legacyBodySize = 8                                  // expiry
+	tokenBodySize  = 1 + 4 + tokenIDSize + 8 + 8        // version + kid + tid + iat + exp
+	legacyTailSize = legacyBodySize + 1 + signatureSize // body + dot + signature
+	tokenTailSize  = tokenBodySize + 1 + signatureSize
+)
+
+// TokenClaims is the decoded, signature-verified content of a token
+type TokenClaims struct {
+	Subject   string
+	KeyID     uint32
+	TokenID   string // hex encoded, empty for legacy tokens
+	IssuedAt  int64  // unix nanoseconds, 0 for legacy tokens
+	ExpiresAt int64  // unix seconds, 0 = no expiration
+}
+
 type TokenManager struct {
-	secretKey []byte
+	ptr       atomic.Pointer[tokenState]
+	writeMu   sync.Mutex
+	storePath string
 }
 
+// NewTokenManager creates an in-memory token manager with a single signing key
 func NewTokenManager(secretKey []byte) *TokenManager {
-	return &TokenManager{
-		secretKey: secretKey,
+	tm := &TokenManager{}
+	tm.ptr.Store(newTokenState(secretKey))
+	return tm
+}
+
+// LoadTokenManager creates a token manager backed by the state file at storePath
+// The persisted key ring takes precedence; secretKey only seeds a fresh store
+// and is ignored, with a warning, once none of the persisted keys match it
+func LoadTokenManager(secretKey []byte, storePath string) (*TokenManager, error) {
+	tm := &TokenManager{storePath: storePath}
+
+	st, found, err := loadTokenState(storePath)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if !st.hasSecret(secretKey) {
+			slog.Warn("TokenManager: Token secret does not match the persisted key ring, ignoring it", "path", storePath)
+		}
+		tm.ptr.Store(st)
+		return tm, nil
 	}
+
+	st = newTokenState(secretKey)
+	if err := tm.persist(st); err != nil {
+		return nil, err
+	}
+	tm.ptr.Store(st)
+	return tm, nil
 }
 
-// GenerateToken creates a token with expiration
-// Token format: base64url(bucket_name + "." + expiry_unix + "." + signature)
+func (tm *TokenManager) snapshot() *tokenState { return tm.ptr.Load() }
+
+// GenerateToken creates a token signed with the active key
+// Token format: base64url(subject + "." + version + kid + token_id + issued_at + expiry + "." + signature)
 // ttlSeconds: token validity duration in seconds (0 = no expiration)
-func (tm *TokenManager) GenerateToken(bucketName string, ttlSeconds int64) string {
-	nameBytes := util.StringToBytes(bucketName)
+func (tm *TokenManager) GenerateToken(subject string, ttlSeconds int64) string {
+	st := tm.snapshot()
+	key := st.keys[st.activeKID]
 
+	now := time.Now()
 	var expiryUnix int64
 	if ttlSeconds > 0 {
-		expiryUnix = time.Now().Unix() + ttlSeconds
-	} else {
-		expiryUnix = 0 // No expiration
+		expiryUnix = now.Unix() + ttlSeconds
 	}
 
-	// Convert expiry to 8-byte representation
-	expiryBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(expiryBytes, uint64(expiryUnix))
+	nameBytes := util.StringToBytes(subject)
+	token := make([]byte, len(nameBytes)+1+tokenTailSize)
 
-	// Build data to sign: bucket_name.expiry
-	dataToSign := make([]byte, len(nameBytes)+1+8)
-	pos := 0
-	pos += copy(dataToSign[pos:], nameBytes)
-	dataToSign[pos] = '.'
-	pos++
-	copy(dataToSign[pos:], expiryBytes)
-
-	signature := tm.sign(dataToSign)
-
-	// Build final token: bucket_name.expiry.signature
-	tokenLen := len(nameBytes) + 1 + 8 + 1 + len(signature)
-	token := make([]byte, tokenLen)
-
-	pos = 0
-	pos += copy(token[pos:], nameBytes)
+	pos := copy(token, nameBytes)
 	token[pos] = '.'
 	pos++
-	pos += copy(token[pos:], expiryBytes)
+	token[pos] = tokenVersion
+	pos++
+	binary.BigEndian.PutUint32(token[pos:], key.ID)
+	pos += 4
+	_, _ = rand.Read(token[pos : pos+tokenIDSize])
+	pos += tokenIDSize
+	binary.BigEndian.PutUint64(token[pos:], uint64(now.UnixNano()))
+	pos += 8
+	binary.BigEndian.PutUint64(token[pos:], uint64(expiryUnix))
+	pos += 8
+
+	signature := sign(key.Secret, token[:pos])
 	token[pos] = '.'
 	pos++
 	copy(token[pos:], signature)
 
-	encoded := base64.RawURLEncoding.EncodeToString(token)
-	return encoded
+	return base64.RawURLEncoding.EncodeToString(token)
 }
 
-// ValidateToken verifies token signature, expiration, and bucket name match
-// Returns true if token is valid, not expired, and matches expected bucket
+// ValidateToken verifies token signature, expiration, revocation and subject match
+// Returns true if token is valid, not expired, not revoked and matches expected subject
 func (tm *TokenManager) ValidateToken(tokenStr, expected string) bool {
 	if tokenStr == "" || expected == "" {
 		return false
 	}
 
-	tokenBytes, err := base64.RawURLEncoding.DecodeString(tokenStr)
+	st := tm.snapshot()
+	claims, err := st.parse(tokenStr, time.Now())
 	if err != nil {
 		return false
 	}
 
-	// Find first separator (after bucket name)
-	firstSep := -1
-	for i := 0; i < len(tokenBytes); i++ {
-		if tokenBytes[i] == '.' {
-			firstSep = i
-			break
-		}
+	// Tokens of a renamed subject validate for its new name only
+	resolved := st.resolve(claims)
+	if resolved != expected {
+		return false
 	}
 
-	if firstSep == -1 || firstSep == 0 {
-		return false
+	if claims.ExpiresAt > 0 && time.Now().Unix() > claims.ExpiresAt {
+		return false // Token expired
 	}
 
-	// Find second separator (after expiry)
-	secondSep := -1
-	for i := firstSep + 1; i < len(tokenBytes); i++ {
+	return !st.isRevoked(claims, resolved)
+}
+
+// ResolveSubject returns the subject a token validates for, following renames
+func (tm *TokenManager) ResolveSubject(claims TokenClaims) string {
+	return tm.snapshot().resolve(claims)
+}
+
+// ParseToken verifies the token signature and returns its claims
+// Expiration and revocation are not checked
+func (tm *TokenManager) ParseToken(tokenStr string) (TokenClaims, error) {
+	return tm.snapshot().parse(tokenStr, time.Now())
+}
+
+func (st *tokenState) parse(tokenStr string, now time.Time) (TokenClaims, error) {
+	tokenBytes, err := base64.RawURLEncoding.DecodeString(tokenStr)
+	if err != nil {
+		return TokenClaims{}, errs.ErrInvalidToken
+	}
+
+	// Subject never contains a dot, everything after it has a fixed layout
+	firstSep := -1
+	for i := 0; i < len(tokenBytes); i++ {
 		if tokenBytes[i] == '.' {
-			secondSep = i
+			firstSep = i
 			break
 		}
 	}
-
-	if secondSep == -1 || secondSep-firstSep != 9 { // 1 (dot) + 8 (expiry bytes)
-		return false
+	if firstSep <= 0 {
+		return TokenClaims{}, errs.ErrInvalidToken
 	}
 
-	nameBytes := tokenBytes[:firstSep]
-	expiryBytes := tokenBytes[firstSep+1 : secondSep]
-	providedSig := tokenBytes[secondSep+1:]
-
-	// Zero-copy string comparison for bucket name
-	bucketName := util.BytesToString(nameBytes)
-	if bucketName != expected {
-		return false
-	}
+	subject := util.BytesToString(tokenBytes[:firstSep])
+	tail := tokenBytes[firstSep+1:]
+
+	switch {
+	case len(tail) == tokenTailSize && tail[0] == tokenVersion && tail[tokenBodySize] == '.':
+		signed := tokenBytes[:firstSep+1+tokenBodySize]
+		body := tail[1:tokenBodySize]
+		claims := TokenClaims{
+			Subject:   subject,
+			KeyID:     binary.BigEndian.Uint32(body[0:4]),
+			TokenID:   hex.EncodeToString(body[4 : 4+tokenIDSize]),
+			IssuedAt:  int64(binary.BigEndian.Uint64(body[4+tokenIDSize:])),
+			ExpiresAt: int64(binary.BigEndian.Uint64(body[4+tokenIDSize+8:])),
+		}
 
-	// Check expiration (fast integer comparison)
-	expiryUnix := int64(binary.BigEndian.Uint64(expiryBytes))
-	if expiryUnix > 0 && time.Now().Unix() > expiryUnix {
-		return false // Token expired
+		key, ok := st.keys[claims.KeyID]
+		if !ok || !key.acceptsAt(now) {
+			return TokenClaims{}, errs.ErrInvalidToken
+		}
+		// Constant-time signature verification
+		if !hmac.Equal(tail[tokenBodySize+1:], sign(key.Secret, signed)) {
+			return TokenClaims{}, errs.ErrInvalidToken
+		}
+		return claims, nil
+
+	case len(tail) == legacyTailSize && tail[legacyBodySize] == '.':
+		// Tokens issued before key IDs existed carry no kid, try every accepted key
+		signed := tokenBytes[:firstSep+1+legacyBodySize]
+		providedSig := tail[legacyBodySize+1:]
+		for _, key := range st.keys {
+			if !key.acceptsAt(now) {
+				continue
+			}
+			if hmac.Equal(providedSig, sign(key.Secret, signed)) {
+				return TokenClaims{
+					Subject:   subject,
+					KeyID:     key.ID,
+					ExpiresAt: int64(binary.BigEndian.Uint64(tail[:legacyBodySize])),
+				}, nil
+			}
+		}
+		return TokenClaims{}, errs.ErrInvalidToken
 	}
 
-	// Build data that was signed
-	dataToSign := make([]byte, len(nameBytes)+1+8)
-	pos := 0
-	pos += copy(dataToSign[pos:], nameBytes)
-	dataToSign[pos] = '.'
-	pos++
-	copy(dataToSign[pos:], expiryBytes)
-
-	// Constant-time signature verification
-	expectedSig := tm.sign(dataToSign)
-	return hmac.Equal(providedSig, expectedSig)
+	return TokenClaims{}, errs.ErrInvalidToken
 }
 
-func (tm *TokenManager) sign(data []byte) []byte {
-	mac := hmac.New(sha256.New, tm.secretKey)
+func sign(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
 	mac.Write(data)
 	return mac.Sum(nil)
 }
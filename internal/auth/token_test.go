@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestTokenRoundTrip(t *testing.T) {
+	tm := NewTokenManager(testSecret)
+	before := time.Now()
+	token := tm.GenerateToken("orders", 60)
+
+	if !tm.ValidateToken(token, "orders") {
+		t.Fatal("fresh token rejected")
+	}
+	if tm.ValidateToken(token, "users") {
+		t.Error("token validated for another subject")
+	}
+
+	claims, err := tm.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "orders" || claims.KeyID != 1 || len(claims.TokenID) != 2*tokenIDSize {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.IssuedAt < before.UnixNano() || claims.IssuedAt > time.Now().UnixNano() {
+		t.Errorf("issued at %d, want nanoseconds after %d", claims.IssuedAt, before.UnixNano())
+	}
+	if want := before.Unix() + 60; claims.ExpiresAt < want || claims.ExpiresAt > want+1 {
+		t.Errorf("expires at %d, want %d", claims.ExpiresAt, want)
+	}
+
+	if noTTL := tm.GenerateToken("orders", -1); !tm.ValidateToken(noTTL, "orders") {
+		t.Error("token without a positive ttl should not expire")
+	}
+}
+
+func TestTokenTampering(t *testing.T) {
+	tm := NewTokenManager(testSecret)
+	raw, _ := base64.RawURLEncoding.DecodeString(tm.GenerateToken("orders", 0))
+
+	for name, mutate := range map[string]func(b []byte) []byte{
+		"subject":   func(b []byte) []byte { b[0] ^= 1; return b },
+		"token id":  func(b []byte) []byte { b[len("orders")+6] ^= 1; return b },
+		"expiry":    func(b []byte) []byte { b[len("orders")+1+tokenBodySize-1] ^= 1; return b },
+		"signature": func(b []byte) []byte { b[len(b)-1] ^= 1; return b },
+		"version":   func(b []byte) []byte { b[len("orders")+1] = tokenVersion + 1; return b },
+		"truncated": func(b []byte) []byte { return b[:len(b)-1] },
+	} {
+		b := mutate(append([]byte(nil), raw...))
+		token := base64.RawURLEncoding.EncodeToString(b)
+		if _, err := tm.ParseToken(token); err == nil {
+			t.Errorf("%s: tampered token parsed", name)
+		}
+	}
+
+	other := NewTokenManager([]byte("fedcba9876543210fedcba9876543210"))
+	if other.ValidateToken(tm.GenerateToken("orders", 0), "orders") {
+		t.Error("token signed with another secret validated")
+	}
+}
+
+func TestRetiredKeys(t *testing.T) {
+	tm := NewTokenManager(testSecret)
+	old := tm.GenerateToken("orders", 0)
+
+	if _, err := tm.RotateKey(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !tm.ValidateToken(old, "orders") {
+		t.Error("token of the previous key rejected during the grace period")
+	}
+	fresh := tm.GenerateToken("orders", 0)
+	if claims, _ := tm.ParseToken(fresh); claims.KeyID != 2 {
+		t.Errorf("new token signed with key %d, want 2", claims.KeyID)
+	}
+
+	if _, err := tm.RotateKey(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if tm.ValidateToken(fresh, "orders") {
+		t.Error("token of a retired key validated after the grace period")
+	}
+	if !tm.ValidateToken(old, "orders") {
+		t.Error("key 1 is still in its grace period")
+	}
+
+	if err := tm.RemoveKey(1); err != nil {
+		t.Fatal(err)
+	}
+	if tm.ValidateToken(old, "orders") {
+		t.Error("token of a removed key validated")
+	}
+	if err := tm.RemoveKey(3); err == nil {
+		t.Error("removed the active key")
+	}
+}
+
+func TestRevokeIssuedBefore(t *testing.T) {
+	tm := NewTokenManager(testSecret)
+	old := tm.GenerateToken("orders", 0)
+	other := tm.GenerateToken("users", 0)
+
+	// The cutoff falls inside the second the old token was issued in
+	if err := tm.RevokeIssuedBefore("orders", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	fresh := tm.GenerateToken("orders", 0)
+
+	if tm.ValidateToken(old, "orders") {
+		t.Error("token issued before the cutoff validated")
+	}
+	if !tm.ValidateToken(fresh, "orders") {
+		t.Error("token issued after the cutoff rejected")
+	}
+	if !tm.ValidateToken(other, "users") {
+		t.Error("revocation leaked to another subject")
+	}
+
+	// An earlier cutoff never moves it back
+	if err := tm.RevokeIssuedBefore("orders", time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if tm.ValidateToken(old, "orders") {
+		t.Error("earlier cutoff restored a revoked token")
+	}
+
+	if _, err := tm.RevokeToken(fresh); err != nil {
+		t.Fatal(err)
+	}
+	if tm.ValidateToken(fresh, "orders") {
+		t.Error("token revoked by id validated")
+	}
+}
+
+func TestAliasCutoffs(t *testing.T) {
+	tm := NewTokenManager(testSecret)
+	old := tm.GenerateToken("orders", 0)
+
+	if err := tm.AliasSubject("orders", "sales", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	fresh := tm.GenerateToken("orders", 0)
+
+	if tm.ValidateToken(old, "orders") || !tm.ValidateToken(old, "sales") {
+		t.Error("token issued before the rename should validate for the new name only")
+	}
+	if !tm.ValidateToken(fresh, "orders") || tm.ValidateToken(fresh, "sales") {
+		t.Error("token issued after the rename should keep its own subject")
+	}
+
+	// A second rename retargets the alias instead of chaining it
+	if err := tm.AliasSubject("sales", "billing", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !tm.ValidateToken(old, "billing") {
+		t.Error("alias not retargeted on the second rename")
+	}
+	if a := tm.Aliases("billing"); len(a) != 2 {
+		t.Errorf("aliases to billing = %v, want orders and sales", a)
+	}
+
+	// Revoking everything issued before the rename drops the alias
+	if err := tm.RevokeIssuedBefore("billing", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if tm.ValidateToken(old, "billing") {
+		t.Error("aliased token survived the revocation of its target")
+	}
+	if a := tm.Aliases("billing"); len(a) != 0 {
+		t.Errorf("aliases to billing = %v after revocation, want none", a)
+	}
+}
+
+func TestLoadTokenManagerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StoreFileName)
+	tm, err := LoadTokenManager(testSecret, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := tm.GenerateToken("orders", 0)
+	if err := tm.RevokeIssuedBefore("orders", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.AliasSubject("users", "people", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.RotateKey(0); err != nil {
+		t.Fatal(err)
+	}
+	token := tm.GenerateToken("orders", 0)
+
+	// The persisted key ring wins over a changed secret
+	loaded, err := LoadTokenManager([]byte("fedcba9876543210fedcba9876543210"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.ValidateToken(token, "orders") {
+		t.Error("token rejected after reload")
+	}
+	if loaded.ValidateToken(revoked, "orders") {
+		t.Error("revocation cutoff lost on reload")
+	}
+	if got, want := loaded.Revocations("orders").IssuedBefore, tm.Revocations("orders").IssuedBefore; got != want {
+		t.Errorf("cutoff = %d after reload, want %d", got, want)
+	}
+	if got, want := loaded.Aliases("people"), tm.Aliases("people"); got["users"] != want["users"] {
+		t.Errorf("alias = %v after reload, want %v", got, want)
+	}
+	if keys := loaded.Keys(); len(keys) != 2 || !keys[1].Active {
+		t.Errorf("keys = %+v after reload", keys)
+	}
+}
@@ -1,11 +1,17 @@
 package bucket
 
 import (
+	"errors"
+	"fmt"
 	"key-value-store/internal/auth"
+	"key-value-store/internal/clock"
 	"key-value-store/internal/config"
 	"key-value-store/internal/engine"
 	"key-value-store/internal/errs"
 	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,46 +25,138 @@ type BucketMetadata struct {
 	Description string
 	CreatedAt   time.Time
 	ShardCount  int
+	Settings    BucketSettings
+	Indexes     []engine.IndexDef
 	KeyCount    int64
 	MemoryUsage int64
 	store       *engine.ShardContainer
 }
 
+// gcInterval is handed to the collectors of every bucket store
+const gcInterval = 500 * time.Millisecond
+
+// IdempotencyBucket holds the responses replayed for Idempotency-Key headers
+const IdempotencyBucket = "_idempotency"
+
+// internalNames are the hidden buckets of the server itself, they are not in
+// the catalog, have no tokens and are kept in memory only
+var internalNames = []string{IdempotencyBucket}
+
+// reservedNames cannot be used as bucket names, they collide with HTTP routes
+var reservedNames = map[string]struct{}{
+	"buckets": {},
+}
+
 type BucketIndex struct {
 	buckets map[string]*BucketMetadata
 }
 
 type BucketManager interface {
-	CreateBucket(name, description string, shardCount int) (string, error)
+	CreateBucket(name, description string, shardCount int, settings BucketSettings) (string, error)
 	GetBucket(name string) (*BucketMetadata, bool)
+	UpdateSettings(name string, patch SettingsPatch) (*BucketMetadata, error)
+	// SetQuotas replaces the config file quotas applied to the buckets created
+	// from now on, existing buckets are left as they are
+	SetQuotas(quotas map[string]config.BucketQuota)
+	CloneBucket(source, target, description string) (string, error)
+	RenameBucket(name, newName string, reissue bool) (string, error)
+	FlushBucket(name string) (int64, error)
 	DeleteBucket(name, token string) error
 	ListBuckets() []*BucketMetadata
 	BucketExists(name string) bool
 	GetStore(name string) (*engine.ShardContainer, bool)
-	Shutdown()
+	GetStoreAndSettings(name string) (*engine.ShardContainer, BucketSettings, bool)
+	// InternalStore retrieves the store of a hidden bucket, see internalNames
+	InternalStore(name string) (*engine.ShardContainer, bool)
+	Reshard(name string, shardCount int) error
+	CreateIndex(name string, def engine.IndexDef) error
+	DropIndex(name, index string) error
+	ReshardProgress(name string) (engine.ReshardProgress, bool, error)
+	Shutdown() error
 }
 
 type bucketManager struct {
-	ptr     atomic.Pointer[BucketIndex]
-	writeMu sync.Mutex
-	cfg     *config.Configuration
+	ptr         atomic.Pointer[BucketIndex]
+	writeMu     sync.Mutex
+	cfg         *config.Configuration
+	quotas      atomic.Pointer[map[string]config.BucketQuota]
+	catalogPath string
+	clock       clock.Clock
+	internal    map[string]*engine.ShardContainer
 }
 
-func NewBucketManager(cfg *config.Configuration) BucketManager {
+// NewBucketManager restores the buckets of the catalog in cfg.Store.DataDir
+// and creates the default bucket if it does not exist yet
+// Restored buckets keep their stored settings, the quotas of cfg apply to the
+// buckets created afterwards
+// Keys of every bucket expire by clk
+func NewBucketManager(cfg *config.Configuration, clk clock.Clock) (BucketManager, error) {
 	bm := &bucketManager{
-		cfg: cfg,
+		cfg:      cfg,
+		clock:    clk,
+		internal: make(map[string]*engine.ShardContainer, len(internalNames)),
+	}
+	bm.SetQuotas(cfg.Buckets)
+	if cfg.Store.DataDir != "" {
+		bm.catalogPath = filepath.Join(cfg.Store.DataDir, CatalogFileName)
 	}
-	idx := &BucketIndex{buckets: make(map[string]*BucketMetadata)}
-	bm.ptr.Store(idx)
 
-	token, err := bm.CreateBucket("default", "Default bucket", cfg.Store.ShardCount)
+	entries, err := loadCatalog(bm.catalogPath)
 	if err != nil {
-		slog.Error("Failed to create default bucket", "error", err)
-	} else {
+		return nil, err
+	}
+
+	idx := &BucketIndex{buckets: make(map[string]*BucketMetadata, len(entries)+1)}
+	for _, e := range entries {
+		shardCount := e.ShardCount
+		if shardCount <= 0 {
+			shardCount = cfg.Store.ShardCount
+		}
+		settings := DefaultBucketSettings()
+		if e.Settings != nil {
+			settings = *e.Settings
+		}
+		store := bm.newStore(shardCount)
+		for _, def := range e.Indexes {
+			if err := store.AddIndex(def); err != nil {
+				return nil, fmt.Errorf("restore index %s of bucket %s: %w", def.Name, e.Name, err)
+			}
+		}
+		idx.buckets[e.Name] = &BucketMetadata{
+			ID:          e.ID,
+			Name:        e.Name,
+			Description: e.Description,
+			CreatedAt:   e.CreatedAt,
+			ShardCount:  shardCount,
+			Settings:    settings,
+			Indexes:     e.Indexes,
+			store:       store,
+		}
+	}
+	bm.ptr.Store(idx)
+	for _, name := range internalNames {
+		bm.internal[name] = bm.newStore(cfg.Store.ShardCount)
+	}
+	if len(entries) > 0 {
+		slog.Info("BucketManager: Restored buckets from catalog", "path", bm.catalogPath, "count", len(entries))
+	}
+
+	if !bm.BucketExists("default") {
+		token, err := bm.CreateBucket("default", "Default bucket", cfg.Store.ShardCount, DefaultBucketSettings())
+		if err != nil {
+			return nil, err
+		}
 		slog.Info("Created default bucket", "token", token, "shard_count", cfg.Store.ShardCount)
 	}
 
-	return bm
+	return bm, nil
+}
+
+// newStore creates the store of a bucket with its expiration collectors running
+func (bm *bucketManager) newStore(shardCount int) *engine.ShardContainer {
+	sc := engine.NewShardContainer(shardCount, bm.clock)
+	sc.StartGC(gcInterval)
+	return sc
 }
 
 func (bm *bucketManager) snapshot() *BucketIndex {
@@ -78,6 +176,8 @@ func (bm *bucketManager) GetBucket(name string) (*BucketMetadata, bool) {
 		Description: b.Description,
 		CreatedAt:   b.CreatedAt,
 		ShardCount:  b.ShardCount,
+		Settings:    b.Settings,
+		Indexes:     b.Indexes,
 	}
 	if b.store != nil {
 		meta.KeyCount = b.store.Count()
@@ -97,9 +197,59 @@ func (bm *bucketManager) GetStore(name string) (*engine.ShardContainer, bool) {
 	return b.store, true
 }
 
-func (bm *bucketManager) CreateBucket(name, description string, shardCount int) (string, error) {
-	if name == "" {
-		return "", errs.ErrInvalidBucketName
+// GetStoreAndSettings retrieves the storage engine and the settings of a bucket
+// from the same index snapshot
+func (bm *bucketManager) GetStoreAndSettings(name string) (*engine.ShardContainer, BucketSettings, bool) {
+	idx := bm.snapshot()
+	b, ok := idx.buckets[name]
+	if !ok {
+		return nil, BucketSettings{}, false
+	}
+	return b.store, b.Settings, true
+}
+
+func (bm *bucketManager) InternalStore(name string) (*engine.ShardContainer, bool) {
+	store, ok := bm.internal[name]
+	return store, ok
+}
+
+// Names with an underscore belong to the server, such as the admin token
+// subject and the internal buckets
+func checkName(name string) error {
+	if _, reserved := reservedNames[name]; name == "" || reserved || strings.Contains(name, "_") {
+		return errs.ErrInvalidBucketName
+	}
+	return nil
+}
+
+// withBuckets copies the buckets of idx into a new index, applies fn and
+// persists the result before publishing it
+// Must be called with writeMu held
+func (bm *bucketManager) withBuckets(idx *BucketIndex, fn func(buckets map[string]*BucketMetadata)) error {
+	newIdx := &BucketIndex{
+		buckets: make(map[string]*BucketMetadata, len(idx.buckets)+1),
+	}
+	for k, v := range idx.buckets {
+		newIdx.buckets[k] = v
+	}
+	fn(newIdx.buckets)
+
+	if err := bm.saveCatalog(newIdx); err != nil {
+		return err
+	}
+	bm.ptr.Store(newIdx)
+	return nil
+}
+
+func (bm *bucketManager) CreateBucket(name, description string, shardCount int, settings BucketSettings) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+
+	// The config file quota of the bucket wins over the requested settings
+	settings = settings.Apply(QuotaPatch((*bm.quotas.Load())[name]))
+	if err := settings.Validate(); err != nil {
+		return "", err
 	}
 
 	if shardCount <= 0 {
@@ -114,27 +264,25 @@ func (bm *bucketManager) CreateBucket(name, description string, shardCount int)
 		return "", errs.ErrBucketAlreadyExists
 	}
 
-	newIdx := &BucketIndex{
-		buckets: make(map[string]*BucketMetadata, len(old.buckets)+1),
-	}
-
-	for k, v := range old.buckets {
-		newIdx.buckets[k] = v
-	}
-
-	shardContainer := engine.NewShardContainer(shardCount)
+	shardContainer := bm.newStore(shardCount)
 
 	meta := &BucketMetadata{
 		ID:          generateBucketID(),
 		Name:        name,
 		Description: description,
-		CreatedAt:   time.Now(),
+		CreatedAt:   bm.clock.Now(),
 		ShardCount:  shardCount,
+		Settings:    settings,
 		store:       shardContainer,
 	}
 
-	newIdx.buckets[name] = meta
-	bm.ptr.Store(newIdx)
+	err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		buckets[name] = meta
+	})
+	if err != nil {
+		shardContainer.Close()
+		return "", err
+	}
 
 	// Generate token with no expiration (0 = never expires)
 	token := auth.Manager().GenerateToken(name, 0)
@@ -143,6 +291,277 @@ func (bm *bucketManager) CreateBucket(name, description string, shardCount int)
 	return token, nil
 }
 
+func (bm *bucketManager) UpdateSettings(name string, patch SettingsPatch) (*BucketMetadata, error) {
+	bm.writeMu.Lock()
+
+	old := bm.snapshot()
+	b, exists := old.buckets[name]
+	if !exists {
+		bm.writeMu.Unlock()
+		return nil, errs.ErrBucketNotFound
+	}
+
+	settings := b.Settings.Apply(patch)
+	if err := settings.Validate(); err != nil {
+		bm.writeMu.Unlock()
+		return nil, err
+	}
+
+	updated := *b
+	updated.Settings = settings
+
+	err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		buckets[name] = &updated
+	})
+	bm.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("BucketManager: Updated bucket settings", "name", name, "id", b.ID)
+	meta, _ := bm.GetBucket(name)
+	return meta, nil
+}
+
+func (bm *bucketManager) SetQuotas(quotas map[string]config.BucketQuota) {
+	bm.quotas.Store(&quotas)
+}
+
+// CloneBucket copies the definition and the live entries of source into a new bucket
+// Values are shared with the source, writes to either bucket do not affect the other
+func (bm *bucketManager) CloneBucket(source, target, description string) (string, error) {
+	if err := checkName(target); err != nil {
+		return "", err
+	}
+
+	src, ok := bm.snapshot().buckets[source]
+	if !ok {
+		return "", errs.ErrBucketNotFound
+	}
+	if bm.BucketExists(target) {
+		return "", errs.ErrBucketAlreadyExists
+	}
+
+	// Copying happens outside writeMu, other bucket operations are not blocked
+	store, err := src.store.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	bm.writeMu.Lock()
+	defer bm.writeMu.Unlock()
+
+	old := bm.snapshot()
+	if _, exists := old.buckets[target]; exists {
+		store.Close()
+		return "", errs.ErrBucketAlreadyExists
+	}
+	if description == "" {
+		description = src.Description
+	}
+
+	meta := &BucketMetadata{
+		ID:          generateBucketID(),
+		Name:        target,
+		Description: description,
+		CreatedAt:   bm.clock.Now(),
+		ShardCount:  src.ShardCount,
+		Settings:    src.Settings,
+		Indexes:     store.Indexes(),
+		store:       store,
+	}
+	err = bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		buckets[target] = meta
+	})
+	if err != nil {
+		store.Close()
+		return "", err
+	}
+
+	token := auth.Manager().GenerateToken(target, 0)
+
+	slog.Info("BucketManager: Cloned bucket", "source", source, "name", target, "id", meta.ID, "keys", store.Count())
+	return token, nil
+}
+
+// RenameBucket moves a bucket and its data to newName and returns a token for it
+// Tokens of the old name keep working for the new one unless reissue is set,
+// in which case they are revoked
+func (bm *bucketManager) RenameBucket(name, newName string, reissue bool) (string, error) {
+	if name == "default" {
+		return "", errs.ErrCannotRenameDefault
+	}
+	if err := checkName(newName); err != nil {
+		return "", err
+	}
+
+	bm.writeMu.Lock()
+	defer bm.writeMu.Unlock()
+
+	old := bm.snapshot()
+	b, exists := old.buckets[name]
+	if !exists {
+		return "", errs.ErrBucketNotFound
+	}
+	if _, taken := old.buckets[newName]; taken {
+		return "", errs.ErrBucketAlreadyExists
+	}
+
+	renamed := *b
+	renamed.Name = newName
+	err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		delete(buckets, name)
+		buckets[newName] = &renamed
+	})
+	if err != nil {
+		return "", err
+	}
+
+	now := bm.clock.Now()
+	if reissue {
+		err = auth.Manager().RevokeIssuedBefore(name, now)
+	} else {
+		err = auth.Manager().AliasSubject(name, newName, now)
+	}
+	if err != nil {
+		slog.Error("BucketManager: Failed to rebind tokens of renamed bucket", "name", name, "new_name", newName, "error", err)
+	}
+
+	token := auth.Manager().GenerateToken(newName, 0)
+
+	slog.Info("BucketManager: Renamed bucket", "name", name, "new_name", newName, "id", b.ID, "reissue", reissue)
+	return token, nil
+}
+
+// FlushBucket removes every key of a bucket, its definition and tokens are kept
+func (bm *bucketManager) FlushBucket(name string) (int64, error) {
+	store, ok := bm.GetStore(name)
+	if !ok {
+		return 0, errs.ErrBucketNotFound
+	}
+
+	removed, err := store.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	slog.Info("BucketManager: Flushed bucket", "name", name, "removed", removed)
+	return removed, nil
+}
+
+// Reshard starts migrating a bucket to a new shard count
+// The catalog is updated once the migration completes
+func (bm *bucketManager) Reshard(name string, shardCount int) error {
+	idx := bm.snapshot()
+	b, ok := idx.buckets[name]
+	if !ok {
+		return errs.ErrBucketNotFound
+	}
+
+	store := b.store
+	err := store.Reshard(shardCount, func() {
+		bm.writeMu.Lock()
+		defer bm.writeMu.Unlock()
+
+		// The bucket may have been renamed while migrating, find it by its store
+		old := bm.snapshot()
+		var cur *BucketMetadata
+		for _, b := range old.buckets {
+			if b.store == store {
+				cur = b
+				break
+			}
+		}
+		if cur == nil {
+			return // Deleted while migrating
+		}
+
+		updated := *cur
+		updated.ShardCount = shardCount
+		err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+			buckets[updated.Name] = &updated
+		})
+		if err != nil {
+			slog.Error("BucketManager: Failed to save catalog after resharding", "name", updated.Name, "error", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("BucketManager: Resharding bucket", "name", name, "id", b.ID, "from", b.ShardCount, "to", shardCount)
+	return nil
+}
+
+// CreateIndex builds a secondary index on the JSON documents of a bucket and
+// saves its definition, it is maintained by every write from then on
+func (bm *bucketManager) CreateIndex(name string, def engine.IndexDef) error {
+	bm.writeMu.Lock()
+	defer bm.writeMu.Unlock()
+
+	old := bm.snapshot()
+	b, exists := old.buckets[name]
+	if !exists {
+		return errs.ErrBucketNotFound
+	}
+	if slices.ContainsFunc(b.Indexes, func(d engine.IndexDef) bool { return d.Name == def.Name }) {
+		return errs.ErrIndexExists
+	}
+
+	if err := b.store.AddIndex(def); err != nil {
+		return err
+	}
+	updated := *b
+	updated.Indexes = append(slices.Clip(b.Indexes), def)
+	err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		buckets[name] = &updated
+	})
+	if err != nil {
+		b.store.DropIndex(def.Name)
+		return err
+	}
+
+	slog.Info("BucketManager: Created index", "name", name, "index", def.Name, "path", def.Path)
+	return nil
+}
+
+func (bm *bucketManager) DropIndex(name, index string) error {
+	bm.writeMu.Lock()
+	defer bm.writeMu.Unlock()
+
+	old := bm.snapshot()
+	b, exists := old.buckets[name]
+	if !exists {
+		return errs.ErrBucketNotFound
+	}
+	i := slices.IndexFunc(b.Indexes, func(d engine.IndexDef) bool { return d.Name == index })
+	if i < 0 {
+		return errs.ErrIndexNotFound
+	}
+
+	updated := *b
+	updated.Indexes = slices.Delete(slices.Clone(b.Indexes), i, i+1)
+	err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		buckets[name] = &updated
+	})
+	if err != nil {
+		return err
+	}
+	b.store.DropIndex(index)
+
+	slog.Info("BucketManager: Dropped index", "name", name, "index", index)
+	return nil
+}
+
+func (bm *bucketManager) ReshardProgress(name string) (engine.ReshardProgress, bool, error) {
+	store, ok := bm.GetStore(name)
+	if !ok {
+		return engine.ReshardProgress{}, false, errs.ErrBucketNotFound
+	}
+	p, found := store.ReshardProgress()
+	return p, found, nil
+}
+
 func (bm *bucketManager) DeleteBucket(name, token string) error {
 	if name == "default" {
 		return errs.ErrCannotDeleteDefault
@@ -162,22 +581,22 @@ func (bm *bucketManager) DeleteBucket(name, token string) error {
 		return errs.ErrBucketNotFound
 	}
 
-	newIdx := &BucketIndex{
-		buckets: make(map[string]*BucketMetadata, len(old.buckets)-1),
-	}
-
-	for k, v := range old.buckets {
-		if k != name {
-			newIdx.buckets[k] = v
-		}
+	err := bm.withBuckets(old, func(buckets map[string]*BucketMetadata) {
+		delete(buckets, name)
+	})
+	if err != nil {
+		return err
 	}
 
-	bm.ptr.Store(newIdx)
-
 	if b.store != nil {
 		b.store.StopGC()
 	}
 
+	// A bucket recreated under the same name must not accept the old tokens
+	if err := auth.Manager().RevokeIssuedBefore(name, bm.clock.Now()); err != nil {
+		slog.Error("BucketManager: Failed to revoke tokens of deleted bucket", "name", name, "error", err)
+	}
+
 	slog.Info("BucketManager: Deleted bucket", "name", name, "id", b.ID)
 	return nil
 }
@@ -193,6 +612,8 @@ func (bm *bucketManager) ListBuckets() []*BucketMetadata {
 			Description: b.Description,
 			CreatedAt:   b.CreatedAt,
 			ShardCount:  b.ShardCount,
+			Settings:    b.Settings,
+			Indexes:     b.Indexes,
 		}
 		if b.store != nil {
 			meta.KeyCount = b.store.Count()
@@ -210,21 +631,32 @@ func (bm *bucketManager) BucketExists(name string) bool {
 	return exists
 }
 
-func (bm *bucketManager) Shutdown() {
+// Shutdown stops the collectors of every bucket once their pending deletes are applied
+// The catalog and the key ring are written on every change, nothing else is buffered
+func (bm *bucketManager) Shutdown() error {
 	slog.Info("BucketManager: Shutting down")
 
 	bm.writeMu.Lock()
 	defer bm.writeMu.Unlock()
 
+	var failed []error
 	idx := bm.snapshot()
 	for name, b := range idx.buckets {
 		slog.Debug("BucketManager: Closing bucket", "name", name, "id", b.ID)
 		if b.store != nil {
 			if err := b.store.Close(); err != nil {
 				slog.Error("BucketManager: Failed to close bucket", "name", name, "error", err)
+				failed = append(failed, fmt.Errorf("bucket %s: %w", name, err))
 			}
 		}
 	}
+	for name, store := range bm.internal {
+		if err := store.Close(); err != nil {
+			slog.Error("BucketManager: Failed to close internal bucket", "name", name, "error", err)
+			failed = append(failed, fmt.Errorf("internal bucket %s: %w", name, err))
+		}
+	}
+	return errors.Join(failed...)
 }
 
 func generateBucketID() string {
@@ -0,0 +1,76 @@
+package bucket
+
+import (
+	"errors"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/config"
+	"key-value-store/internal/errs"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T) (BucketManager, *clock.Fake) {
+	t.Helper()
+	if err := auth.Initialize([]byte("0123456789abcdef0123456789abcdef"), ""); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Store.DataDir = ""
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bm, err := NewBucketManager(cfg, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bm.Shutdown() })
+	return bm, clk
+}
+
+func TestBucketTimesFollowClock(t *testing.T) {
+	bm, clk := newTestManager(t)
+
+	if _, err := bm.CreateBucket("orders", "", 0, DefaultBucketSettings()); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Hour)
+	if _, err := bm.CloneBucket("orders", "orders-copy", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]time.Time{
+		"default":     clk.Now().Add(-time.Hour),
+		"orders":      clk.Now().Add(-time.Hour),
+		"orders-copy": clk.Now(),
+	} {
+		b, ok := bm.GetBucket(name)
+		if !ok {
+			t.Fatalf("bucket %s missing", name)
+		}
+		if !b.CreatedAt.Equal(want) {
+			t.Errorf("%s created at %v, want %v", name, b.CreatedAt, want)
+		}
+	}
+}
+
+func TestBucketNamesWithUnderscoreRejected(t *testing.T) {
+	bm, _ := newTestManager(t)
+	if _, err := bm.CreateBucket("orders", "", 0, DefaultBucketSettings()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{auth.AdminSubject, IdempotencyBucket, "my_bucket", "buckets", ""} {
+		if _, err := bm.CreateBucket(name, "", 0, DefaultBucketSettings()); !errors.Is(err, errs.ErrInvalidBucketName) {
+			t.Errorf("create %q: %v, want ErrInvalidBucketName", name, err)
+		}
+		if _, err := bm.CloneBucket("orders", name, ""); !errors.Is(err, errs.ErrInvalidBucketName) {
+			t.Errorf("clone to %q: %v, want ErrInvalidBucketName", name, err)
+		}
+		if _, err := bm.RenameBucket("orders", name, false); !errors.Is(err, errs.ErrInvalidBucketName) {
+			t.Errorf("rename to %q: %v, want ErrInvalidBucketName", name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package bucket
+
+import (
+	"fmt"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/util"
+	"sort"
+	"time"
+)
+
+// CatalogFileName is the bucket catalog file name inside the data directory
+const CatalogFileName = "buckets.json"
+
+const catalogVersion = 1
+
+// catalogEntry is the durable definition of a bucket, values are not included
+type catalogEntry struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ShardCount  int               `json:"shard_count"`
+	Settings    *BucketSettings   `json:"settings,omitempty"`
+	Indexes     []engine.IndexDef `json:"indexes,omitempty"`
+}
+
+type catalogFile struct {
+	Version int            `json:"version"`
+	Buckets []catalogEntry `json:"buckets"`
+}
+
+func catalogEntryOf(b *BucketMetadata) catalogEntry {
+	settings := b.Settings
+	return catalogEntry{
+		ID:          b.ID,
+		Name:        b.Name,
+		Description: b.Description,
+		CreatedAt:   b.CreatedAt,
+		ShardCount:  b.ShardCount,
+		Settings:    &settings,
+		Indexes:     b.Indexes,
+	}
+}
+
+// saveCatalog writes the bucket definitions of idx
+// Must be called with writeMu held, before idx is published
+func (bm *bucketManager) saveCatalog(idx *BucketIndex) error {
+	if bm.catalogPath == "" {
+		return nil
+	}
+
+	out := catalogFile{
+		Version: catalogVersion,
+		Buckets: make([]catalogEntry, 0, len(idx.buckets)),
+	}
+	for _, b := range idx.buckets {
+		out.Buckets = append(out.Buckets, catalogEntryOf(b))
+	}
+	sort.Slice(out.Buckets, func(i, j int) bool { return out.Buckets[i].Name < out.Buckets[j].Name })
+
+	return util.WriteJSONFile(bm.catalogPath, out, 0o644)
+}
+
+func loadCatalog(path string) ([]catalogEntry, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	var in catalogFile
+	found, err := util.ReadJSONFile(path, &in)
+	if err != nil {
+		return nil, fmt.Errorf("read bucket catalog %s: %w", path, err)
+	}
+	if !found {
+		return nil, nil
+	}
+	if in.Version != catalogVersion {
+		return nil, fmt.Errorf("read bucket catalog %s: unsupported version %d", path, in.Version)
+	}
+	return in.Buckets, nil
+}
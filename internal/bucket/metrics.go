@@ -0,0 +1,59 @@
+package bucket
+
+import (
+	"key-value-store/internal/metrics"
+	"sort"
+)
+
+// NewCollector reports the per-bucket gauges, read at scrape time
+func NewCollector(bm BucketManager) metrics.Collector {
+	return metrics.CollectorFunc(func(w *metrics.Writer) {
+		buckets := bm.ListBuckets()
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+
+		w.Header("bukt_bucket_keys", "Keys stored per bucket", "gauge")
+		for _, b := range buckets {
+			w.Sample("bukt_bucket_keys", float64(b.KeyCount), "bucket", b.Name)
+		}
+
+		w.Header("bukt_bucket_memory_bytes", "Key and value bytes stored per bucket", "gauge")
+		for _, b := range buckets {
+			w.Sample("bukt_bucket_memory_bytes", float64(b.MemoryUsage), "bucket", b.Name)
+		}
+
+		w.Header("bukt_bucket_shards", "Shard count per bucket", "gauge")
+		for _, b := range buckets {
+			w.Sample("bukt_bucket_shards", float64(b.ShardCount), "bucket", b.Name)
+		}
+
+		type gcSample struct {
+			name        string
+			wheel, near int
+			pending     int
+		}
+		samples := make([]gcSample, 0, len(buckets))
+		for _, b := range buckets {
+			store, ok := bm.GetStore(b.Name)
+			if !ok {
+				continue
+			}
+			st := store.GCStats()
+			samples = append(samples, gcSample{b.Name, st.WheelEntries, st.NearQueueSize, st.PendingDeletes})
+		}
+
+		w.Header("bukt_gc_wheel_entries", "Expirations scheduled on the timing wheel per bucket", "gauge")
+		for _, s := range samples {
+			w.Sample("bukt_gc_wheel_entries", float64(s.wheel), "bucket", s.name)
+		}
+
+		w.Header("bukt_gc_near_queue_size", "Expirations due within the near window per bucket", "gauge")
+		for _, s := range samples {
+			w.Sample("bukt_gc_near_queue_size", float64(s.near), "bucket", s.name)
+		}
+
+		w.Header("bukt_gc_pending_deletes", "Deletes waiting for the next coalescer flush per bucket", "gauge")
+		for _, s := range samples {
+			w.Sample("bukt_gc_pending_deletes", float64(s.pending), "bucket", s.name)
+		}
+	})
+}
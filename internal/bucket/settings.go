@@ -0,0 +1,108 @@
+package bucket
+
+import (
+	"fmt"
+	"key-value-store/internal/config"
+	"key-value-store/internal/errs"
+)
+
+const (
+	DefaultMaxKeyLength = 255
+	// MaxKeyLengthLimit is bounded by the 2-byte key length of the TCP protocol
+	MaxKeyLengthLimit = 1<<16 - 1
+)
+
+// BucketSettings are the per-bucket limits enforced by the storage service
+// Zero values mean "no limit" unless noted otherwise
+type BucketSettings struct {
+	DefaultTTL      int64 `json:"default_ttl,omitempty"` // seconds, applied when a write has no TTL
+	MaxTTL          int64 `json:"max_ttl,omitempty"`     // seconds
+	MaxKeyLength    int   `json:"max_key_length"`        // bytes, always set
+	MaxValueSize    int64 `json:"max_value_size,omitempty"`
+	MaxKeys         int64 `json:"max_keys,omitempty"`
+	AllowSingleRead bool  `json:"allow_single_read"`
+}
+
+// SettingsPatch is a partial update of BucketSettings, nil fields are left untouched
+type SettingsPatch struct {
+	DefaultTTL      *int64
+	MaxTTL          *int64
+	MaxKeyLength    *int
+	MaxValueSize    *int64
+	MaxKeys         *int64
+	AllowSingleRead *bool
+}
+
+func DefaultBucketSettings() BucketSettings {
+	return BucketSettings{
+		MaxKeyLength:    DefaultMaxKeyLength,
+		AllowSingleRead: true,
+	}
+}
+
+func (s BucketSettings) Apply(p SettingsPatch) BucketSettings {
+	if p.DefaultTTL != nil {
+		s.DefaultTTL = *p.DefaultTTL
+	}
+	if p.MaxTTL != nil {
+		s.MaxTTL = *p.MaxTTL
+	}
+	if p.MaxKeyLength != nil {
+		s.MaxKeyLength = *p.MaxKeyLength
+	}
+	if p.MaxValueSize != nil {
+		s.MaxValueSize = *p.MaxValueSize
+	}
+	if p.MaxKeys != nil {
+		s.MaxKeys = *p.MaxKeys
+	}
+	if p.AllowSingleRead != nil {
+		s.AllowSingleRead = *p.AllowSingleRead
+	}
+	return s
+}
+
+// QuotaPatch returns the fields set in the config file quota q as a patch
+func QuotaPatch(q config.BucketQuota) SettingsPatch {
+	return SettingsPatch{
+		DefaultTTL:      q.DefaultTTL,
+		MaxTTL:          q.MaxTTL,
+		MaxKeyLength:    q.MaxKeyLength,
+		MaxValueSize:    q.MaxValueSize,
+		MaxKeys:         q.MaxKeys,
+		AllowSingleRead: q.AllowSingleRead,
+	}
+}
+
+func (s BucketSettings) Validate() error {
+	if s.DefaultTTL < 0 || s.MaxTTL < 0 || s.MaxValueSize < 0 || s.MaxKeys < 0 {
+		return fmt.Errorf("%w: limits must be non-negative", errs.ErrInvalidSettings)
+	}
+	if s.MaxKeyLength < 1 || s.MaxKeyLength > MaxKeyLengthLimit {
+		return fmt.Errorf("%w: max_key_length must be between 1 and %d", errs.ErrInvalidSettings, MaxKeyLengthLimit)
+	}
+	if s.MaxTTL > 0 && s.DefaultTTL > s.MaxTTL {
+		return fmt.Errorf("%w: default_ttl exceeds max_ttl", errs.ErrInvalidSettings)
+	}
+	return nil
+}
+
+// ResolveTTL applies the default and maximum TTL to a requested TTL
+func (s BucketSettings) ResolveTTL(ttl int64) (int64, error) {
+	if ttl < 0 {
+		return 0, errs.ErrInvalidTTL
+	}
+	if ttl == 0 {
+		ttl = s.DefaultTTL
+	}
+	if s.MaxTTL > 0 {
+		if ttl == 0 {
+			// Entries of a bucket with a max TTL never live forever
+			ttl = s.MaxTTL
+		}
+		if ttl > s.MaxTTL {
+			return 0, errs.ErrTTLExceedsLimit
+		}
+	}
+	return ttl, nil
+}
@@ -2,28 +2,78 @@ package config
 
 import (
 	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 const (
+	EnvConfigFile         = "CONFIG_FILE"
 	EnvTokenSecret        = "TOKEN_SECRET"
 	EnvServerPort         = "SERVER_PORT"
 	EnvTCPPort            = "TCP_PORT"
+	EnvShutdownTimeout    = "SHUTDOWN_TIMEOUT"
+	EnvTCPReadTimeout     = "TCP_READ_TIMEOUT"
+	EnvTCPWriteTimeout    = "TCP_WRITE_TIMEOUT"
+	EnvTCPAcceptDeadline  = "TCP_ACCEPT_DEADLINE"
+	EnvIdempotencyWindow  = "IDEMPOTENCY_WINDOW"
 	EnvLoggingEnvironment = "LOGGING_ENVIRONMENT"
 	EnvLoggingLevel       = "LOGGING_LEVEL"
 	EnvShardCount         = "SHARD_COUNT"
+	EnvDataDir            = "DATA_DIR"
+	EnvGCWorkers          = "GC_WORKERS"
+	EnvGCWheelSlots       = "GC_WHEEL_SLOTS"
+	EnvGCWheelTickMs      = "GC_WHEEL_TICK_MS"
+	EnvGCNearWindowMs     = "GC_NEAR_WINDOW_MS"
+	EnvGCNearTickMs       = "GC_NEAR_TICK_MS"
+	EnvGCFlushIntervalMs  = "GC_FLUSH_INTERVAL_MS"
 )
 
 const (
 	DefaultTokenSecret        = "" // Will be generated at startup if not provided
 	DefaultServerPort         = 8080
 	DefaultTCPPort            = 9090
+	DefaultShutdownTimeout    = 10    // in seconds
+	DefaultIdempotencyWindow  = 86400 // in seconds
 	DefaultLoggingEnvironment = "production"
 	DefaultLoggingLevel       = "info"
 	DefaultShardCount         = 64
+	DefaultDataDir            = "" // in memory only
+	DefaultGCWorkers          = 4
+	DefaultGCWheelSlots       = 2048
+	DefaultGCWheelTickMs      = 500
+	DefaultGCNearWindowMs     = 2000
+	DefaultGCNearTickMs       = 50
+	DefaultGCFlushIntervalMs  = 10
+)
+
+// Validation bounds
+const (
+	MinTokenSecretLen    = 32
+	MaxShardCount        = 1024
+	MaxShutdownTimeout   = 300    // in seconds
+	MaxTCPTimeout        = 3600   // in seconds
+	MaxIdempotencyWindow = 604800 // in seconds
+)
+
+var (
+	loggingEnvironments = []string{"production", "development"}
+	loggingLevels       = []string{"debug", "info", "warn", "error"}
+)
+
+// Sources of a setting, from the lowest to the highest precedence
+const (
+	SourceDefault   = "default"
+	SourceFile      = "file"
+	SourceEnv       = "env"
+	SourceFlag      = "flag"
+	SourceGenerated = "generated"
 )
 
 type Configuration struct {
@@ -31,6 +81,13 @@ type Configuration struct {
 	Server  ServerConfig
 	Logging LoggingConfig
 	Store   StoreConfig
+	GC      GCConfig
+	Buckets map[string]BucketQuota // by bucket name, only set from the config file
+
+	File        string // config file the settings were read from, empty if none
+	PrintConfig bool   // print the effective configuration and exit
+	args        []string
+	sources     map[string]string
 }
 
 type AuthConfig struct {
@@ -38,8 +95,15 @@ type AuthConfig struct {
 }
 
 type ServerConfig struct {
-	Port    int
-	TCPPort int
+	Port            int
+	TCPPort         int
+	ShutdownTimeout int64 // in seconds
+	// TCP connection deadlines in seconds, 0 = none
+	TCPReadTimeout    int64
+	TCPWriteTimeout   int64
+	TCPAcceptDeadline int64
+	// Seconds a response is replayed for requests sent again with its Idempotency-Key
+	IdempotencyWindow int64
 }
 
 type LoggingConfig struct {
@@ -49,62 +113,346 @@ type LoggingConfig struct {
 
 type StoreConfig struct {
 	ShardCount int
+	DataDir    string // Durable state (key ring, catalog), empty = in-memory only
+}
+
+type GCConfig struct {
+	Workers         int // 0 = goroutines per collector
+	WheelSlots      int
+	WheelTickMs     int64
+	NearWindowMs    int64
+	NearTickMs      int64
+	FlushIntervalMs int64
 }
 
-func NewConfig() *Configuration {
-	tokenSecret := getEnv(EnvTokenSecret, DefaultTokenSecret)
-	var tokenSecretBytes []byte
+// setting describes one configuration value and where it can be set
+// key is the "section.name" used in config files, flag is empty for secrets
+// which must not show up in the process list
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	quoted bool // written as a string in config files
+	reload bool // applied by a reload, other changes need a restart
+	set    func(c *Configuration, v string) error
+	get    func(c *Configuration) string
+}
 
-	if tokenSecret == "" {
-		// Generate a random 32-byte secret at startup
-		tokenSecretBytes = generateRandomSecret()
-	} else {
-		tokenSecretBytes = []byte(tokenSecret)
+var settings = []setting{
+	{
+		key: "auth.token_secret", env: EnvTokenSecret, secret: true, quoted: true,
+		set: func(c *Configuration, v string) error { c.Auth.TokenSecret = []byte(v); return nil },
+		get: func(c *Configuration) string { return string(c.Auth.TokenSecret) },
+	},
+	{
+		key: "server.port", env: EnvServerPort, flag: "port", usage: "HTTP port",
+		set: intSetter(func(c *Configuration, n int64) { c.Server.Port = int(n) }),
+		get: func(c *Configuration) string { return strconv.Itoa(c.Server.Port) },
+	},
+	{
+		key: "server.tcp_port", env: EnvTCPPort, flag: "tcp-port", usage: "TCP port",
+		set: intSetter(func(c *Configuration, n int64) { c.Server.TCPPort = int(n) }),
+		get: func(c *Configuration) string { return strconv.Itoa(c.Server.TCPPort) },
+	},
+	{
+		key: "server.shutdown_timeout", env: EnvShutdownTimeout, flag: "shutdown-timeout", usage: "drain deadline in seconds",
+		set: intSetter(func(c *Configuration, n int64) { c.Server.ShutdownTimeout = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.Server.ShutdownTimeout, 10) },
+	},
+	{
+		key: "server.tcp_read_timeout", env: EnvTCPReadTimeout, flag: "tcp-read-timeout", usage: "TCP read deadline in seconds, 0 for none", reload: true,
+		set: intSetter(func(c *Configuration, n int64) { c.Server.TCPReadTimeout = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.Server.TCPReadTimeout, 10) },
+	},
+	{
+		key: "server.tcp_write_timeout", env: EnvTCPWriteTimeout, flag: "tcp-write-timeout", usage: "TCP write deadline in seconds, 0 for none", reload: true,
+		set: intSetter(func(c *Configuration, n int64) { c.Server.TCPWriteTimeout = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.Server.TCPWriteTimeout, 10) },
+	},
+	{
+		key: "server.tcp_accept_deadline", env: EnvTCPAcceptDeadline, flag: "tcp-accept-deadline", usage: "TCP accept deadline in seconds, 0 for none", reload: true,
+		set: intSetter(func(c *Configuration, n int64) { c.Server.TCPAcceptDeadline = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.Server.TCPAcceptDeadline, 10) },
+	},
+	{
+		key: "server.idempotency_window", env: EnvIdempotencyWindow, flag: "idempotency-window", usage: "seconds responses are replayed for a repeated Idempotency-Key",
+		set: intSetter(func(c *Configuration, n int64) { c.Server.IdempotencyWindow = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.Server.IdempotencyWindow, 10) },
+	},
+	{
+		key: "logging.environment", env: EnvLoggingEnvironment, flag: "log-env", usage: "production or development", quoted: true,
+		set: func(c *Configuration, v string) error { c.Logging.Environment = v; return nil },
+		get: func(c *Configuration) string { return c.Logging.Environment },
+	},
+	{
+		key: "logging.level", env: EnvLoggingLevel, flag: "log-level", usage: "debug, info, warn or error", quoted: true, reload: true,
+		set: func(c *Configuration, v string) error { c.Logging.Level = v; return nil },
+		get: func(c *Configuration) string { return c.Logging.Level },
+	},
+	{
+		key: "store.shard_count", env: EnvShardCount, flag: "shard-count", usage: "shards of new buckets",
+		set: intSetter(func(c *Configuration, n int64) { c.Store.ShardCount = int(n) }),
+		get: func(c *Configuration) string { return strconv.Itoa(c.Store.ShardCount) },
+	},
+	{
+		key: "store.data_dir", env: EnvDataDir, flag: "data-dir", usage: "directory of the durable state, empty for in-memory only", quoted: true,
+		set: func(c *Configuration, v string) error { c.Store.DataDir = v; return nil },
+		get: func(c *Configuration) string { return c.Store.DataDir },
+	},
+	{
+		key: "gc.workers", env: EnvGCWorkers, flag: "gc-workers", usage: "shared expiry scheduler workers, 0 for goroutines per collector",
+		set: intSetter(func(c *Configuration, n int64) { c.GC.Workers = int(n) }),
+		get: func(c *Configuration) string { return strconv.Itoa(c.GC.Workers) },
+	},
+	{
+		key: "gc.wheel_slots", env: EnvGCWheelSlots, flag: "gc-wheel-slots", usage: "timing wheel slots per level of new collectors",
+		set: intSetter(func(c *Configuration, n int64) { c.GC.WheelSlots = int(n) }),
+		get: func(c *Configuration) string { return strconv.Itoa(c.GC.WheelSlots) },
+	},
+	{
+		key: "gc.wheel_tick_ms", env: EnvGCWheelTickMs, flag: "gc-wheel-tick-ms", usage: "timing wheel tick of new collectors",
+		set: intSetter(func(c *Configuration, n int64) { c.GC.WheelTickMs = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.GC.WheelTickMs, 10) },
+	},
+	{
+		key: "gc.near_window_ms", env: EnvGCNearWindowMs, flag: "gc-near-window-ms", usage: "expirations closer than this skip the wheel",
+		set: intSetter(func(c *Configuration, n int64) { c.GC.NearWindowMs = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.GC.NearWindowMs, 10) },
+	},
+	{
+		key: "gc.near_tick_ms", env: EnvGCNearTickMs, flag: "gc-near-tick-ms", usage: "near queue drain interval", reload: true,
+		set: intSetter(func(c *Configuration, n int64) { c.GC.NearTickMs = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.GC.NearTickMs, 10) },
+	},
+	{
+		key: "gc.flush_interval_ms", env: EnvGCFlushIntervalMs, flag: "gc-flush-interval-ms", usage: "delete coalescer flush interval", reload: true,
+		set: intSetter(func(c *Configuration, n int64) { c.GC.FlushIntervalMs = n }),
+		get: func(c *Configuration) string { return strconv.FormatInt(c.GC.FlushIntervalMs, 10) },
+	},
+}
+
+func intSetter(fn func(c *Configuration, n int64)) func(c *Configuration, v string) error {
+	return func(c *Configuration, v string) error {
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		fn(c, n)
+		return nil
 	}
+}
 
+func defaults() *Configuration {
 	return &Configuration{
 		Auth: AuthConfig{
-			TokenSecret: tokenSecretBytes,
+			TokenSecret: []byte(DefaultTokenSecret),
 		},
 		Server: ServerConfig{
-			Port:    getEnvAsInt(EnvServerPort, DefaultServerPort),
-			TCPPort: getEnvAsInt(EnvTCPPort, DefaultTCPPort),
+			Port:              DefaultServerPort,
+			TCPPort:           DefaultTCPPort,
+			ShutdownTimeout:   DefaultShutdownTimeout,
+			IdempotencyWindow: DefaultIdempotencyWindow,
 		},
 		Logging: LoggingConfig{
-			Environment: getEnv(EnvLoggingEnvironment, DefaultLoggingEnvironment),
-			Level:       getEnv(EnvLoggingLevel, DefaultLoggingLevel),
+			Environment: DefaultLoggingEnvironment,
+			Level:       DefaultLoggingLevel,
 		},
 		Store: StoreConfig{
-			ShardCount: getEnvAsInt(EnvShardCount, DefaultShardCount),
+			ShardCount: DefaultShardCount,
+			DataDir:    DefaultDataDir,
+		},
+		GC: GCConfig{
+			Workers:         DefaultGCWorkers,
+			WheelSlots:      DefaultGCWheelSlots,
+			WheelTickMs:     DefaultGCWheelTickMs,
+			NearWindowMs:    DefaultGCNearWindowMs,
+			NearTickMs:      DefaultGCNearTickMs,
+			FlushIntervalMs: DefaultGCFlushIntervalMs,
 		},
+		sources: make(map[string]string, len(settings)),
+	}
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and the command-line args, each overriding the previous one
+// Every problem is reported at once, the returned error joins them
+func Load(args []string) (*Configuration, error) {
+	c, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Auth.TokenSecret) == 0 && !c.PrintConfig {
+		c.Auth.TokenSecret = generateRandomSecret()
+		c.sources["auth.token_secret"] = SourceGenerated
 	}
+	return c, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// Reload loads the configuration again with the args of c
+// A secret generated for c is kept, tokens signed with it must stay valid
+func (c *Configuration) Reload() (*Configuration, error) {
+	next, err := load(c.args)
+	if err != nil {
+		return nil, err
+	}
+	if len(next.Auth.TokenSecret) == 0 && c.sources["auth.token_secret"] == SourceGenerated {
+		next.Auth.TokenSecret = c.Auth.TokenSecret
+		next.sources["auth.token_secret"] = SourceGenerated
 	}
-	return defaultValue
+	return next, nil
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		intValue, err := strconv.Atoi(strings.TrimSpace(value))
-		if err == nil {
-			return intValue
+func load(args []string) (*Configuration, error) {
+	c := defaults()
+	c.args = args
+	for _, s := range settings {
+		c.sources[s.key] = SourceDefault
+	}
+
+	fs := flag.NewFlagSet("kvstore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		if s.flag != "" {
+			flagValues[s.key] = fs.String(s.flag, "", s.usage+" ("+s.env+", "+s.key+")")
+		}
+	}
+	path := fs.String("config", os.Getenv(EnvConfigFile), "config file, TOML ("+EnvConfigFile+")")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	setByFlag := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setByFlag[f.Name] = true })
+
+	var problems []error
+	apply := func(s setting, v, source string) {
+		if err := s.set(c, v); err != nil {
+			problems = append(problems, fmt.Errorf("%s (%s): %w", s.key, source, err))
+			return
+		}
+		c.sources[s.key] = source
+	}
+
+	var file map[string]fileValue
+	if *path != "" {
+		var err error
+		if file, err = readFile(*path); err != nil {
+			return nil, err
 		}
+		c.File = *path
+	}
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	keys := make([]string, 0, len(file))
+	for key := range file {
+		keys = append(keys, key)
 	}
-	return defaultValue
+	slices.SortFunc(keys, func(a, b string) int { return file[a].line - file[b].line })
+	for _, key := range keys {
+		v := file[key]
+		if name, field, ok := cutQuotaKey(key); ok {
+			if err := c.setQuota(name, field, v); err != nil {
+				problems = append(problems, fmt.Errorf("%s:%d: %s: %w", *path, v.line, key, err))
+			}
+			continue
+		}
+		if !known[key] {
+			problems = append(problems, fmt.Errorf("%s:%d: unknown setting %q", *path, v.line, key))
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := file[s.key]; ok {
+			if v.quoted != s.quoted {
+				kind := "an integer"
+				if s.quoted {
+					kind = "a string"
+				}
+				problems = append(problems, fmt.Errorf("%s:%d: %s must be %s", *path, v.line, s.key, kind))
+			} else {
+				apply(s, v.raw, SourceFile)
+			}
+		}
+		if v, ok := os.LookupEnv(s.env); ok {
+			apply(s, v, SourceEnv)
+		}
+		if setByFlag[s.flag] {
+			apply(s, *flagValues[s.key], SourceFlag)
+		}
+	}
+
+	problems = append(problems, c.validate()...)
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	return c, nil
 }
 
-func getEnvAsInt64(key string, defaultValue int64) int64 {
-	if value, exists := os.LookupEnv(key); exists {
-		intValue, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
-		if err == nil {
-			return intValue
+// validate returns every invalid setting
+func (c *Configuration) validate() []error {
+	var problems []error
+	invalid := func(key, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s (%s): %s", key, c.sources[key], fmt.Sprintf(format, args...)))
+	}
+
+	if n := len(c.Auth.TokenSecret); n > 0 && n < MinTokenSecretLen {
+		invalid("auth.token_secret", "must be at least %d bytes, got %d", MinTokenSecretLen, n)
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		invalid("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.TCPPort < 1 || c.Server.TCPPort > 65535 {
+		invalid("server.tcp_port", "must be between 1 and 65535, got %d", c.Server.TCPPort)
+	}
+	if c.Server.Port == c.Server.TCPPort {
+		invalid("server.tcp_port", "must differ from server.port (%d)", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout < 1 || c.Server.ShutdownTimeout > MaxShutdownTimeout {
+		invalid("server.shutdown_timeout", "must be between 1 and %d seconds, got %d", MaxShutdownTimeout, c.Server.ShutdownTimeout)
+	}
+	if !slices.Contains(loggingEnvironments, c.Logging.Environment) {
+		invalid("logging.environment", "must be one of %s, got %q", strings.Join(loggingEnvironments, ", "), c.Logging.Environment)
+	}
+	if !slices.Contains(loggingLevels, c.Logging.Level) {
+		invalid("logging.level", "must be one of %s, got %q", strings.Join(loggingLevels, ", "), c.Logging.Level)
+	}
+	if c.Store.ShardCount < 1 || c.Store.ShardCount > MaxShardCount {
+		invalid("store.shard_count", "must be between 1 and %d, got %d", MaxShardCount, c.Store.ShardCount)
+	}
+	for _, b := range []struct {
+		key      string
+		v        int64
+		min, max int64
+	}{
+		{"server.tcp_read_timeout", c.Server.TCPReadTimeout, 0, MaxTCPTimeout},
+		{"server.tcp_write_timeout", c.Server.TCPWriteTimeout, 0, MaxTCPTimeout},
+		{"server.tcp_accept_deadline", c.Server.TCPAcceptDeadline, 0, MaxTCPTimeout},
+		{"server.idempotency_window", c.Server.IdempotencyWindow, 1, MaxIdempotencyWindow},
+		{"gc.workers", int64(c.GC.Workers), 0, 64},
+		{"gc.wheel_slots", int64(c.GC.WheelSlots), 64, 8192},
+		{"gc.wheel_tick_ms", c.GC.WheelTickMs, 100, 1000},
+		{"gc.near_window_ms", c.GC.NearWindowMs, 100, 60000},
+		{"gc.near_tick_ms", c.GC.NearTickMs, 10, 1000},
+		{"gc.flush_interval_ms", c.GC.FlushIntervalMs, 5, 50},
+	} {
+		if b.v < b.min || b.v > b.max {
+			invalid(b.key, "must be between %d and %d, got %d", b.min, b.max, b.v)
 		}
 	}
-	return defaultValue
+
+	return problems
 }
 
 // generateRandomSecret creates a cryptographically secure random 32-byte secret
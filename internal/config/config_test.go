@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kvstore.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+[server]
+port = 7001
+tcp_port = 7002
+shutdown_timeout = 20
+
+[logging]
+level = "warn"
+`)
+	t.Setenv(EnvTCPPort, "7102")
+	t.Setenv(EnvShutdownTimeout, "30")
+	t.Setenv(EnvLoggingLevel, "error")
+
+	c, err := Load([]string{"-config", path, "-shutdown-timeout", "40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		key    string
+		got    any
+		want   any
+		source string
+	}{
+		{"server.port", c.Server.Port, 7001, SourceFile},
+		{"server.tcp_port", c.Server.TCPPort, 7102, SourceEnv},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout, int64(40), SourceFlag},
+		{"logging.level", c.Logging.Level, "error", SourceEnv},
+		{"store.shard_count", c.Store.ShardCount, DefaultShardCount, SourceDefault},
+	} {
+		if tc.got != tc.want || c.sources[tc.key] != tc.source {
+			t.Errorf("%s = %v from %s, want %v from %s", tc.key, tc.got, c.sources[tc.key], tc.want, tc.source)
+		}
+	}
+	if c.sources["auth.token_secret"] != SourceGenerated || len(c.Auth.TokenSecret) != MinTokenSecretLen {
+		t.Errorf("token secret from %s with %d bytes", c.sources["auth.token_secret"], len(c.Auth.TokenSecret))
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+[server]
+port = "7001"
+colour = 3
+
+[logging]
+level = 2
+`)
+	t.Setenv(EnvShardCount, "0")
+
+	_, err := Load([]string{"-config", path, "-tcp-port", "70000"})
+	if err == nil {
+		t.Fatal("Load accepted an invalid configuration")
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		t.Fatalf("error %v does not join the problems", err)
+	}
+	if n := len(joined.Unwrap()); n != 5 {
+		t.Errorf("%d problems, want 5:\n%v", n, err)
+	}
+	for _, want := range []string{
+		path + `:3: server.port must be an integer`,
+		path + `:4: unknown setting "server.colour"`,
+		path + `:7: logging.level must be a string`,
+		`store.shard_count (env): must be between 1 and 1024, got 0`,
+		`server.tcp_port (flag): must be between 1 and 65535, got 70000`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := writeConfig(t, `
+# comment
+[server]
+port = 8_081 # trailing comment
+[store]
+data_dir = 'C:\data'   # literal string
+[auth]
+token_secret = "a#b\"c"
+`)
+	values, err := readFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]fileValue{
+		"server.port":       {raw: "8081", line: 4},
+		"store.data_dir":    {raw: `C:\data`, quoted: true, line: 6},
+		"auth.token_secret": {raw: `a#b"c`, quoted: true, line: 8},
+	} {
+		if got := values[key]; got != want {
+			t.Errorf("%s = %+v, want %+v", key, got, want)
+		}
+	}
+
+	_, err = readFile(writeConfig(t, `
+[server
+port = 1
+port = 2
+tcp_port =
+timeout = 1.5
+name = "open
+`))
+	for _, want := range []string{
+		":2: malformed section header",
+		":4: port already set on line 3",
+		":5: tcp_port: missing value",
+		":6: timeout: unsupported value 1.5",
+		":7: name: malformed string",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestPrintRedactsSecret(t *testing.T) {
+	t.Setenv(EnvTokenSecret, strings.Repeat("s", MinTokenSecretLen))
+	c, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := c.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if strings.Contains(out, strings.Repeat("s", MinTokenSecretLen)) {
+		t.Errorf("secret printed:\n%s", out)
+	}
+	if !strings.Contains(out, `token_secret = "<redacted>" # env`) {
+		t.Errorf("secret not redacted:\n%s", out)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+// Unset is the value reported for a bucket quota field missing from the file
+const Unset = "unset"
+
+// Change is a setting whose value differs between two configurations
+type Change struct {
+	Key     string
+	Old     string
+	New     string
+	Restart bool // the running server keeps the old value until restarted
+}
+
+// Diff returns the settings changed from old to next, in the config file order
+// Secret values are redacted, missing bucket quota fields are reported as Unset
+func Diff(old, next *Configuration) []Change {
+	var changes []Change
+	for _, s := range settings {
+		o, n := s.get(old), s.get(next)
+		if o == n {
+			continue
+		}
+		if s.secret {
+			o, n = redacted, redacted
+		}
+		changes = append(changes, Change{Key: s.key, Old: o, New: n, Restart: !s.reload})
+	}
+
+	names := old.BucketNames()
+	for _, name := range next.BucketNames() {
+		if _, ok := old.Buckets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		o, n := old.Buckets[name].values(), next.Buckets[name].values()
+		for _, field := range quotaFields {
+			ov, oldSet := o[field]
+			nv, newSet := n[field]
+			if ov == nv && oldSet == newSet {
+				continue
+			}
+			if !oldSet {
+				ov = Unset
+			}
+			if !newSet {
+				nv = Unset
+			}
+			changes = append(changes, Change{Key: bucketsSection + name + "." + field, Old: ov, New: nv})
+		}
+	}
+	return changes
+}
+
+// KeepRestartSettings copies the settings that need a restart from running into c,
+// so that c describes what is in effect and later diffs keep reporting them
+func (c *Configuration) KeepRestartSettings(running *Configuration) {
+	for _, s := range settings {
+		if s.reload {
+			continue
+		}
+		// Values of running were validated, they parse back
+		_ = s.set(c, s.get(running))
+		c.sources[s.key] = running.sources[s.key]
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fileValue is a scalar read from a config file
+type fileValue struct {
+	raw    string
+	quoted bool
+	line   int
+}
+
+// readFile parses the TOML subset used by config files: [section] headers and
+// key = value pairs, where a value is a quoted string, an integer or a boolean
+// Keys are returned as "section.key"
+func readFile(path string) (map[string]fileValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]fileValue)
+	var problems []error
+	section := ""
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				problems = append(problems, fmt.Errorf("%s:%d: malformed section header", path, n))
+				continue
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		name, raw, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			problems = append(problems, fmt.Errorf("%s:%d: expected key = value", path, n))
+			continue
+		}
+		v, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s:%d: %s: %w", path, n, name, err))
+			continue
+		}
+		v.line = n
+
+		key := name
+		if section != "" {
+			key = section + "." + name
+		}
+		if prev, dup := values[key]; dup {
+			problems = append(problems, fmt.Errorf("%s:%d: %s already set on line %d", path, n, key, prev.line))
+			continue
+		}
+		values[key] = v
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	return values, nil
+}
+
+func parseValue(raw string) (fileValue, error) {
+	switch {
+	case raw == "":
+		return fileValue{}, errors.New("missing value")
+	case strings.HasPrefix(raw, `"`):
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return fileValue{}, errors.New("malformed string")
+		}
+		return fileValue{raw: s, quoted: true}, nil
+	case strings.HasPrefix(raw, "'"):
+		// Literal strings have no escapes
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return fileValue{}, errors.New("malformed string")
+		}
+		return fileValue{raw: raw[1 : len(raw)-1], quoted: true}, nil
+	case raw == "true" || raw == "false":
+		return fileValue{raw: raw}, nil
+	default:
+		if _, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 10, 64); err != nil {
+			return fileValue{}, fmt.Errorf("unsupported value %s", raw)
+		}
+		return fileValue{raw: strings.ReplaceAll(raw, "_", "")}, nil
+	}
+}
+
+// stripComment removes a trailing # comment that is not inside a string
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
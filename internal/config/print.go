@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+// Print writes the effective configuration in the config file format
+// Secrets are redacted and every value is annotated with its source
+func (c *Configuration) Print(w io.Writer) error {
+	var b strings.Builder
+	if c.File != "" {
+		fmt.Fprintf(&b, "# config file: %s\n", c.File)
+	}
+
+	section := ""
+	for _, s := range settings {
+		sec, name, _ := strings.Cut(s.key, ".")
+		if sec != section {
+			if section != "" || c.File != "" {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "[%s]\n", sec)
+			section = sec
+		}
+
+		v := s.get(c)
+		source := c.sources[s.key]
+		switch {
+		case s.secret && v != "":
+			v = redacted
+		case s.secret:
+			source = "generated at startup"
+		}
+		if s.quoted {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&b, "%s = %s # %s\n", name, v, source)
+	}
+
+	for _, name := range c.BucketNames() {
+		fmt.Fprintf(&b, "\n[%s%s]\n", bucketsSection, name)
+		values := c.Buckets[name].values()
+		for _, field := range quotaFields {
+			if v, ok := values[field]; ok {
+				fmt.Fprintf(&b, "%s = %s # %s\n", field, v, SourceFile)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
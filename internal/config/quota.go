@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const bucketsSection = "buckets."
+
+// BucketQuota overrides the settings of one bucket, nil fields are left untouched
+// Quotas come from [buckets.<name>] sections of the config file
+type BucketQuota struct {
+	DefaultTTL      *int64 // seconds
+	MaxTTL          *int64 // seconds
+	MaxKeyLength    *int
+	MaxValueSize    *int64
+	MaxKeys         *int64
+	AllowSingleRead *bool
+}
+
+// quotaFields lists the keys of a [buckets.<name>] section in output order
+var quotaFields = []string{"default_ttl", "max_ttl", "max_key_length", "max_value_size", "max_keys", "allow_single_read"}
+
+// cutQuotaKey splits "buckets.<name>.<field>"
+func cutQuotaKey(key string) (name, field string, ok bool) {
+	rest, ok := strings.CutPrefix(key, bucketsSection)
+	if !ok {
+		return "", "", false
+	}
+	name, field, ok = strings.Cut(rest, ".")
+	return name, field, ok && name != ""
+}
+
+func (c *Configuration) setQuota(name, field string, v fileValue) error {
+	if c.Buckets == nil {
+		c.Buckets = make(map[string]BucketQuota)
+	}
+	q := c.Buckets[name]
+
+	if field == "allow_single_read" {
+		if v.quoted || (v.raw != "true" && v.raw != "false") {
+			return errors.New("must be a boolean")
+		}
+		b := v.raw == "true"
+		q.AllowSingleRead = &b
+		c.Buckets[name] = q
+		return nil
+	}
+
+	n, err := strconv.ParseInt(v.raw, 10, 64)
+	if v.quoted || err != nil {
+		return errors.New("must be an integer")
+	}
+	if n < 0 {
+		return fmt.Errorf("must be non-negative, got %d", n)
+	}
+	switch field {
+	case "default_ttl":
+		q.DefaultTTL = &n
+	case "max_ttl":
+		q.MaxTTL = &n
+	case "max_key_length":
+		l := int(n)
+		q.MaxKeyLength = &l
+	case "max_value_size":
+		q.MaxValueSize = &n
+	case "max_keys":
+		q.MaxKeys = &n
+	default:
+		return errors.New("unknown bucket setting")
+	}
+	c.Buckets[name] = q
+	return nil
+}
+
+// values returns the fields set in q, formatted as in the config file
+func (q BucketQuota) values() map[string]string {
+	out := make(map[string]string)
+	put := func(field string, v *int64) {
+		if v != nil {
+			out[field] = strconv.FormatInt(*v, 10)
+		}
+	}
+	put("default_ttl", q.DefaultTTL)
+	put("max_ttl", q.MaxTTL)
+	if q.MaxKeyLength != nil {
+		out["max_key_length"] = strconv.Itoa(*q.MaxKeyLength)
+	}
+	put("max_value_size", q.MaxValueSize)
+	put("max_keys", q.MaxKeys)
+	if q.AllowSingleRead != nil {
+		out["allow_single_read"] = strconv.FormatBool(*q.AllowSingleRead)
+	}
+	return out
+}
+
+// BucketNames returns the names of the buckets with a quota, sorted
+func (c *Configuration) BucketNames() []string {
+	names := make([]string, 0, len(c.Buckets))
+	for name := range c.Buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
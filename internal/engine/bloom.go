@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"key-value-store/internal/errs"
+	"math"
+	"math/bits"
+)
+
+const (
+	bloomOverhead = 96
+	// MaxBloomBits bounds the bit array of a filter to 32 MiB
+	MaxBloomBits = 1 << 28
+)
+
+// BloomFilter tells whether an item may have been added to it, with false
+// positives at the rate it was reserved with but no false negatives
+// It is never modified once stored, Add returns a new version that shares
+// the pages of bits it leaves unchanged
+type BloomFilter struct {
+	bits      pagedArray[uint64]
+	m         uint64 // number of bits
+	k         int    // number of hash functions
+	capacity  int64
+	errorRate float64
+	count     int64
+}
+
+// BloomInfo describes a filter, Count is the number of items added that
+// were not found in it already
+type BloomInfo struct {
+	Capacity  int64
+	ErrorRate float64
+	Bits      uint64
+	Hashes    int
+	Count     int64
+}
+
+// NewBloomFilter sizes a filter to keep false positives at errorRate until
+// capacity items were added
+func NewBloomFilter(capacity int64, errorRate float64) (*BloomFilter, error) {
+	if capacity < 1 || !(errorRate > 0 && errorRate < 1) {
+		return nil, errs.ErrInvalidFilter
+	}
+	m := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	if m > MaxBloomBits {
+		return nil, errs.ErrValueTooLarge
+	}
+	return &BloomFilter{
+		bits:      newPagedArray[uint64](int(m+63) / 64),
+		m:         uint64(m),
+		k:         max(1, int(math.Round(m/float64(capacity)*math.Ln2))),
+		capacity:  capacity,
+		errorRate: errorRate,
+	}, nil
+}
+
+func (f *BloomFilter) Kind() Kind { return KindBloom }
+
+// Size counts the whole bit array, pages are only allocated when written to
+// but a filter must not outgrow the value size limit once reserved
+func (f *BloomFilter) Size() int64 {
+	if f == nil {
+		return 0
+	}
+	return bloomOverhead + int64(len(f.bits.pages))*pageLen*8
+}
+
+// Len is 1 for any filter, an empty one is kept
+func (f *BloomFilter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return 1
+}
+
+func (f *BloomFilter) Info() BloomInfo {
+	if f == nil {
+		return BloomInfo{}
+	}
+	return BloomInfo{Capacity: f.capacity, ErrorRate: f.errorRate, Bits: f.m, Hashes: f.k, Count: f.count}
+}
+
+// positions calls fn with the bits of item, until fn returns false
+// The k hashes are derived from a single one by double hashing
+func (f *BloomFilter) positions(item string, fn func(bit uint64) bool) {
+	h1 := probHasher.Sum64String(item)
+	h2 := bits.RotateLeft64(h1, 32) | 1
+	for i := range f.k {
+		if !fn((h1 + uint64(i)*h2) % f.m) {
+			return
+		}
+	}
+}
+
+// Add returns f with items added, and for each item whether it was new,
+// false meaning it may have been added before; f comes from NewBloomFilter
+func (f *BloomFilter) Add(items ...string) (*BloomFilter, []bool) {
+	added := make([]bool, len(items))
+	w := f.bits.writer()
+	next := *f
+	for i, item := range items {
+		f.positions(item, func(bit uint64) bool {
+			word, mask := int(bit/64), uint64(1)<<(bit%64)
+			if v := w.get(word); v&mask == 0 {
+				w.set(word, v|mask)
+				added[i] = true
+			}
+			return true
+		})
+		if added[i] {
+			next.count++
+		}
+	}
+	if next.count == f.count {
+		return f, added
+	}
+	next.bits = w.pagedArray
+	return &next, added
+}
+
+// Has reports for each item whether it may have been added
+func (f *BloomFilter) Has(items ...string) []bool {
+	out := make([]bool, len(items))
+	if f == nil {
+		return out
+	}
+	for i, item := range items {
+		out[i] = true
+		f.positions(item, func(bit uint64) bool {
+			if f.bits.get(int(bit/64))&(1<<(bit%64)) == 0 {
+				out[i] = false
+			}
+			return out[i]
+		})
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"errors"
+	"key-value-store/internal/errs"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilterAddAndHas(t *testing.T) {
+	f, err := NewBloomFilter(1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := f.Info(); info.Bits != 9586 || info.Hashes != 7 {
+		t.Errorf("sized to %d bits and %d hashes", info.Bits, info.Hashes)
+	}
+
+	next, added := f.Add("a", "b", "a")
+	if !slices.Equal(added, []bool{true, true, false}) || next.Info().Count != 2 {
+		t.Errorf("Add = %v, count %d", added, next.Info().Count)
+	}
+	if f.Info().Count != 0 || slices.Contains(f.Has("a", "b"), true) {
+		t.Errorf("adding changed the original filter")
+	}
+	if again, added := next.Add("a"); again != next || added[0] {
+		t.Errorf("adding a known item changed the filter")
+	}
+	if got := next.Has("a", "b", "c"); !slices.Equal(got, []bool{true, true, false}) {
+		t.Errorf("Has = %v", got)
+	}
+}
+
+func TestBloomFilterErrorRate(t *testing.T) {
+	f, _ := NewBloomFilter(10000, 0.01)
+	items := make([]string, 10000)
+	for i := range items {
+		items[i] = "in" + strconv.Itoa(i)
+	}
+	f, _ = f.Add(items...)
+	if slices.Contains(f.Has(items...), false) {
+		t.Fatal("an added item is missing")
+	}
+
+	positives := 0
+	for i := range 10000 {
+		if f.Has("out" + strconv.Itoa(i))[0] {
+			positives++
+		}
+	}
+	if positives > 200 {
+		t.Errorf("%d false positives out of 10000, want about 100", positives)
+	}
+}
+
+func TestBloomFilterLimits(t *testing.T) {
+	for _, c := range []struct {
+		capacity int64
+		rate     float64
+	}{{0, 0.01}, {10, 0}, {10, 1}, {10, -0.5}} {
+		if _, err := NewBloomFilter(c.capacity, c.rate); !errors.Is(err, errs.ErrInvalidFilter) {
+			t.Errorf("NewBloomFilter(%d, %v): %v", c.capacity, c.rate, err)
+		}
+	}
+	if _, err := NewBloomFilter(1e9, 0.001); !errors.Is(err, errs.ErrValueTooLarge) {
+		t.Errorf("oversized filter: %v", err)
+	}
+}
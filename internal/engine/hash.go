@@ -0,0 +1,85 @@
+package engine
+
+import "maps"
+
+// hashFieldOverhead approximates the map bookkeeping of a field
+const hashFieldOverhead = 16
+
+// Hash is a field map value
+// It is never modified once stored, With and Without return modified copies
+// The nil Hash is empty
+type Hash struct {
+	fields map[string][]byte
+	size   int64
+}
+
+func (h *Hash) Kind() Kind { return KindHash }
+
+// Size is the sum of the field names and values plus a per-field overhead
+func (h *Hash) Size() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.size
+}
+
+func (h *Hash) Len() int {
+	if h == nil {
+		return 0
+	}
+	return len(h.fields)
+}
+
+func (h *Hash) Get(field string) ([]byte, bool) {
+	if h == nil {
+		return nil, false
+	}
+	v, ok := h.fields[field]
+	return v, ok
+}
+
+// Fields returns a copy of the field map
+func (h *Hash) Fields() map[string][]byte {
+	if h == nil {
+		return map[string][]byte{}
+	}
+	return maps.Clone(h.fields)
+}
+
+// With returns a copy of h with fields set, and how many of them are new
+func (h *Hash) With(fields map[string][]byte) (*Hash, int) {
+	next := &Hash{fields: make(map[string][]byte, h.Len()+len(fields)), size: h.Size()}
+	if h != nil {
+		maps.Copy(next.fields, h.fields)
+	}
+
+	added := 0
+	for f, v := range fields {
+		if old, ok := next.fields[f]; ok {
+			next.size -= fieldSize(f, old)
+		} else {
+			added++
+		}
+		next.fields[f] = v
+		next.size += fieldSize(f, v)
+	}
+	return next, added
+}
+
+// Without returns a copy of h without fields, and how many of them were removed
+func (h *Hash) Without(fields ...string) (*Hash, int) {
+	next := &Hash{fields: h.Fields(), size: h.Size()}
+	removed := 0
+	for _, f := range fields {
+		if old, ok := next.fields[f]; ok {
+			next.size -= fieldSize(f, old)
+			delete(next.fields, f)
+			removed++
+		}
+	}
+	return next, removed
+}
+
+func fieldSize(field string, value []byte) int64 {
+	return int64(len(field)+len(value)) + hashFieldOverhead
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"key-value-store/internal/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashWithWithoutCopy(t *testing.T) {
+	var empty *Hash
+	h, added := empty.With(map[string][]byte{"a": []byte("1"), "b": []byte("22")})
+	if added != 2 || h.Len() != 2 || empty.Len() != 0 {
+		t.Fatalf("added %d, Len() = %d", added, h.Len())
+	}
+
+	h2, added := h.With(map[string][]byte{"a": []byte("333"), "c": nil})
+	if added != 1 {
+		t.Errorf("added %d, want 1", added)
+	}
+	if v, _ := h.Get("a"); string(v) != "1" {
+		t.Errorf("With modified the original hash, a = %q", v)
+	}
+
+	h3, removed := h2.Without("a", "missing")
+	if removed != 1 || h3.Len() != 2 || h2.Len() != 3 {
+		t.Errorf("removed %d, Len() = %d, original Len() = %d", removed, h3.Len(), h2.Len())
+	}
+
+	want := fieldSize("b", []byte("22")) + fieldSize("c", nil)
+	if h3.Size() != want {
+		t.Errorf("Size() = %d, want %d", h3.Size(), want)
+	}
+}
+
+func TestUpdateAccountsData(t *testing.T) {
+	s, _ := newTestStore()
+	set := func(fields map[string][]byte) {
+		t.Helper()
+		err := s.Update("h", func(cur StorageEntry, found bool) (StorageEntry, bool, error) {
+			h, _ := cur.Data.(*Hash)
+			cur.Data, _ = h.With(fields)
+			return cur, false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set(map[string][]byte{"name": []byte("ada")})
+	set(map[string][]byte{"city": []byte("london")})
+	e, ok := s.Get("h")
+	if !ok || e.Kind() != KindHash || e.Data.(*Hash).Len() != 2 {
+		t.Fatalf("Get returned %+v", e)
+	}
+	if want := int64(len("h")) + e.Data.Size(); s.Usage() != want {
+		t.Errorf("Usage() = %d, want %d", s.Usage(), want)
+	}
+
+	if err := s.Update("h", func(cur StorageEntry, _ bool) (StorageEntry, bool, error) {
+		return cur, true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 0 || s.Usage() != 0 {
+		t.Errorf("Count() = %d, Usage() = %d after removal", s.Count(), s.Usage())
+	}
+}
+
+func TestUpdateErrorKeepsEntry(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "v", 0, false))
+
+	boom := errors.New("boom")
+	err := s.Update("k", func(cur StorageEntry, _ bool) (StorageEntry, bool, error) {
+		return StorageEntry{}, true, boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Update returned %v", err)
+	}
+	if e, ok := s.Get("k"); !ok || string(e.Value) != "v" {
+		t.Error("failed update changed the entry")
+	}
+}
+
+func TestUpdateConcurrentDuringReshard(t *testing.T) {
+	s := NewShardContainer(2, clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	incr := func(key string) {
+		s.Update(key, func(cur StorageEntry, _ bool) (StorageEntry, bool, error) {
+			h, _ := cur.Data.(*Hash)
+			v, _ := h.Get("n")
+			cur.Data, _ = h.With(map[string][]byte{"n": append(v, 'x')})
+			return cur, false, nil
+		})
+	}
+
+	// Keys written before the reshard are moved by the first update
+	for i := range 4 {
+		incr(fmt.Sprint("k", i))
+	}
+	if err := s.Reshard(8, nil); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				incr(fmt.Sprint("k", i%4))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range 4 {
+		e, ok := s.Get(fmt.Sprint("k", i))
+		if !ok {
+			t.Fatalf("k%d missing", i)
+		}
+		if v, _ := e.Data.(*Hash).Get("n"); len(v) != 101 {
+			t.Errorf("k%d holds %d updates, want 101", i, len(v))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"key-value-store/internal/util"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision is the number of hash bits selecting a register
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+	hllOverhead  = 64
+)
+
+// probHasher hashes the members of HyperLogLogs and Bloom filters, it must
+// not change as it would invalidate the stored values
+var probHasher = util.NewDefaultHasher()
+
+// HyperLogLog estimates the number of distinct members added to it, with a
+// standard error of 0.81%, without keeping the members
+// It is never modified once stored, Add and Merge return new versions that
+// share the registers they leave unchanged
+type HyperLogLog struct {
+	registers pagedArray[uint8]
+}
+
+func (h *HyperLogLog) Kind() Kind { return KindHyperLogLog }
+
+// Size counts the register pages in use, so that a HyperLogLog of a few
+// members stays small
+func (h *HyperLogLog) Size() int64 {
+	if h == nil {
+		return 0
+	}
+	return hllOverhead + int64(h.registers.allocated())*pageLen
+}
+
+// Len is 1 for any HyperLogLog, one without members is kept
+func (h *HyperLogLog) Len() int {
+	if h == nil {
+		return 0
+	}
+	return 1
+}
+
+func (h *HyperLogLog) writer() *pageWriter[uint8] {
+	if h == nil {
+		return newPagedArray[uint8](hllRegisters).writer()
+	}
+	return h.registers.writer()
+}
+
+// Add returns h with members added, and whether the estimate may have changed
+func (h *HyperLogLog) Add(members ...string) (*HyperLogLog, bool) {
+	w := h.writer()
+	changed := h == nil
+	for _, m := range members {
+		x := probHasher.Sum64String(m)
+		i := int(x >> (64 - hllPrecision))
+		// The guard bit bounds the rank when the remaining bits are all zero
+		rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+		if rank > w.get(i) {
+			w.set(i, rank)
+			changed = true
+		}
+	}
+	if !changed {
+		return h, false
+	}
+	return &HyperLogLog{registers: w.pagedArray}, true
+}
+
+// Merge returns h holding the members of others as well
+func (h *HyperLogLog) Merge(others ...*HyperLogLog) *HyperLogLog {
+	w := h.writer()
+	for _, o := range others {
+		if o == nil {
+			continue
+		}
+		for pi, op := range o.registers.pages {
+			if op == nil {
+				continue
+			}
+			// Pages are immutable, an empty one is replaced by the other
+			if w.pages[pi] == nil {
+				w.pages[pi] = op
+				continue
+			}
+			for j, v := range op {
+				if v > w.pages[pi][j] {
+					w.set(pi*pageLen+j, v)
+				}
+			}
+		}
+	}
+	return &HyperLogLog{registers: w.pagedArray}
+}
+
+// Count estimates the number of distinct members
+func (h *HyperLogLog) Count() int64 {
+	if h == nil {
+		return 0
+	}
+	sum, zeros := 0.0, 0
+	for _, p := range h.registers.pages {
+		if p == nil {
+			sum += pageLen
+			zeros += pageLen
+			continue
+		}
+		for _, r := range p {
+			sum += math.Ldexp(1, -int(r))
+			if r == 0 {
+				zeros++
+			}
+		}
+	}
+
+	m := float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	// Small cardinalities are counted from the empty registers instead
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(estimate + 0.5)
+}
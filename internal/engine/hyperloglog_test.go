@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func addRange(h *HyperLogLog, prefix string, n int) *HyperLogLog {
+	members := make([]string, n)
+	for i := range members {
+		members[i] = prefix + strconv.Itoa(i)
+	}
+	h, _ = h.Add(members...)
+	return h
+}
+
+func TestHyperLogLogCount(t *testing.T) {
+	var h *HyperLogLog
+	if h.Count() != 0 || h.Len() != 0 {
+		t.Fatalf("nil HyperLogLog counts %d", h.Count())
+	}
+
+	h, changed := h.Add("a", "b", "c", "a")
+	if !changed || h.Count() != 3 {
+		t.Errorf("Add = %v, count %d", changed, h.Count())
+	}
+	if next, changed := h.Add("b"); changed || next != h {
+		t.Errorf("adding a known member changed the HyperLogLog")
+	}
+	// A few members only take the pages of their registers
+	if h.Size() > hllOverhead+3*pageLen {
+		t.Errorf("size %d for 3 members", h.Size())
+	}
+
+	for _, n := range []int{1000, 100000} {
+		got := addRange(nil, "m", n).Count()
+		if e := math.Abs(float64(got-int64(n))) / float64(n); e > 0.03 {
+			t.Errorf("count of %d members %d, error %.3f", n, got, e)
+		}
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a := addRange(nil, "m", 20000)
+	b := addRange(nil, "m", 10000)
+	b = addRange(b, "n", 10000)
+	before := a.Count()
+
+	merged := a.Merge(b, nil)
+	if e := math.Abs(float64(merged.Count()-30000)) / 30000; e > 0.03 {
+		t.Errorf("merged count %d", merged.Count())
+	}
+	if a.Count() != before {
+		t.Errorf("merging changed the source, %d then %d", before, a.Count())
+	}
+	if got := (*HyperLogLog)(nil).Merge(a); got.Count() != a.Count() {
+		t.Errorf("merge into nil counts %d, want %d", got.Count(), a.Count())
+	}
+}
@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"key-value-store/internal/clock"
+	"key-value-store/internal/errs"
 	"key-value-store/internal/gc"
 	"key-value-store/internal/util"
+	"maps"
 	"slices"
 	"sync"
 	"sync/atomic"
@@ -11,6 +14,12 @@ import (
 
 type entry = StorageEntry
 
+const (
+	initialSegments = 64
+	// maxSegmentLoad is the average keys per segment that triggers a segment split
+	maxSegmentLoad = 128
+)
+
 type Segment struct {
 	keys []string
 	vals []*entry
@@ -18,6 +27,8 @@ type Segment struct {
 
 type Index struct {
 	segments []Segment
+	// secondary holds the secondary indexes by name, it is replaced as a whole
+	secondary map[string]*secondaryIndex
 }
 
 type COWIndexStore struct {
@@ -26,30 +37,35 @@ type COWIndexStore struct {
 	usedBytes int64
 	keyCount  int64
 	hasher    util.Hasher
+	clock     clock.Clock
 	*gc.GarbageCollector
 }
 
-func NewMemoryStore() Store {
-	idx := &Index{segments: make([]Segment, 64)}
+// NewMemoryStore creates a store whose entries expire by clk
+func NewMemoryStore(clk clock.Clock) Store {
+	idx := &Index{segments: make([]Segment, initialSegments)}
 	s := &COWIndexStore{
 		hasher: util.NewDefaultHasher(),
+		clock:  clk,
 	}
 	s.ptr.Store(idx)
-	s.GarbageCollector = gc.NewGarbageCollector(s.deleteBatch)
+	s.GarbageCollector = gc.NewGarbageCollector(s.reclaim, clk)
 	return s
 }
 
 func (s *COWIndexStore) snapshot() *Index { return s.ptr.Load() }
 
-func (s *COWIndexStore) segmentIndexOf(key string) int {
-	t := s.ptr.Load()
-	hash := s.hasher.Sum64String(key)
-	return int(hash % uint64(len(t.segments)))
+// segmentIndexOf maps a key to a segment of idx
+// The upper hash bits are used because ShardContainer routes on the lower ones,
+// and the power-of-two segment count lets a split move keys only from i to i+n
+func (s *COWIndexStore) segmentIndexOf(idx *Index, key string) int {
+	hash := s.hasher.Sum64String(key) >> 32
+	return int(hash & uint64(len(idx.segments)-1))
 }
 
 func (s *COWIndexStore) Get(key string) (StorageEntry, bool) {
 	idx := s.snapshot()
-	seg := &idx.segments[s.segmentIndexOf(key)]
+	seg := &idx.segments[s.segmentIndexOf(idx, key)]
 
 	i, found := slices.BinarySearch(seg.keys, key)
 	if !found {
@@ -57,39 +73,73 @@ func (s *COWIndexStore) Get(key string) (StorageEntry, bool) {
 	}
 	e := seg.vals[i]
 
-	if e.IsExpired() {
+	now := s.clock.Now()
+	if e.IsExpired(now) {
 		s.GarbageCollector.ScheduleDelete(key)
 		return StorageEntry{}, false
 	}
 	if e.SingleRead {
 		if atomic.CompareAndSwapInt32(&e.AccessCount, 0, 1) {
 			s.GarbageCollector.ScheduleDelete(key)
-			return *e, true
+			return loadEntry(e), true
 		}
 		return StorageEntry{}, false
 	}
 
-	// Update access stats with cached time (no syscall overhead)
 	atomic.AddInt32(&e.AccessCount, 1)
-	atomic.StoreInt64(&e.LastAccess, util.CachedNow())
+	atomic.StoreInt64(&e.LastAccess, now.UnixNano())
 
-	return *e, true
+	return loadEntry(e), true
 }
 
 func (s *COWIndexStore) Set(key string, val StorageEntry) {
-	val.LastAccess = util.CachedNow()
-	val.AccessCount = 0
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.setLocked(key, val)
+}
 
+// Update replaces the entry of key by the result of fn, both under the write lock
+// so concurrent updates of a key never lose each other's changes
+func (s *COWIndexStore) Update(key string, fn UpdateFunc) error {
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
 
+	idx := s.snapshot()
+	seg := &idx.segments[s.segmentIndexOf(idx, key)]
+
+	var cur StorageEntry
+	i, exists := slices.BinarySearch(seg.keys, key)
+	found := exists && !isDead(seg.vals[i], s.clock.Now())
+	if found {
+		cur = loadEntry(seg.vals[i])
+	}
+
+	next, remove, err := fn(cur, found)
+	if err != nil {
+		return err
+	}
+	if remove {
+		if exists {
+			s.deleteLocked([]string{key}, false)
+		}
+		return nil
+	}
+	s.setLocked(key, next)
+	return nil
+}
+
+func (s *COWIndexStore) setLocked(key string, val StorageEntry) {
+	val.LastAccess = s.clock.Now().UnixNano()
+	val.AccessCount = 0
+
 	old := s.snapshot()
 	n := len(old.segments)
 
 	newIdx := &Index{segments: make([]Segment, n)}
 	copy(newIdx.segments, old.segments)
 
-	si := s.segmentIndexOf(key)
+	var prev *entry
+	si := s.segmentIndexOf(old, key)
 	oldSeg := old.segments[si]
 
 	ns := Segment{
@@ -100,7 +150,7 @@ func (s *COWIndexStore) Set(key string, val StorageEntry) {
 	i, found := slices.BinarySearch(ns.keys, key)
 	var delta int64
 	if found {
-		prev := ns.vals[i]
+		prev = ns.vals[i]
 		delta -= sizeOf(key, prev)
 		ns.vals[i] = &val
 	} else {
@@ -116,30 +166,65 @@ func (s *COWIndexStore) Set(key string, val StorageEntry) {
 	delta += sizeOf(key, &val)
 
 	newIdx.segments[si] = ns
+	newIdx.secondary = reindex(old.secondary, key, prev, &val)
+	if atomic.LoadInt64(&s.keyCount) > int64(n)*maxSegmentLoad {
+		newIdx = s.split(newIdx)
+	}
 	s.ptr.Store(newIdx)
 	atomic.AddInt64(&s.usedBytes, delta)
 
-	s.GarbageCollector.Schedule(key, val.TTL, val.CreatedAt)
+	s.GarbageCollector.Schedule(key, val.ExpiresAt)
+}
+
+// split doubles the segment count of idx
+// Keys of segment i end up in segment i or i+n, both stay sorted
+func (s *COWIndexStore) split(idx *Index) *Index {
+	n := len(idx.segments)
+	grown := &Index{segments: make([]Segment, n*2), secondary: idx.secondary}
+
+	for i := range idx.segments {
+		seg := &idx.segments[i]
+		lo, hi := &grown.segments[i], &grown.segments[i+n]
+		for j, k := range seg.keys {
+			target := lo
+			if s.segmentIndexOf(grown, k) != i {
+				target = hi
+			}
+			target.keys = append(target.keys, k)
+			target.vals = append(target.vals, seg.vals[j])
+		}
+	}
+	return grown
 }
 
-func (s *COWIndexStore) Delete(key string) { s.deleteBatch([]string{key}) }
+func (s *COWIndexStore) Delete(key string) { s.deleteBatch([]string{key}, false) }
 
-func (s *COWIndexStore) deleteBatch(keys []string) {
+// reclaim is the delete coalescer callback
+// A key may have been overwritten since it was queued, only dead entries are removed
+func (s *COWIndexStore) reclaim(keys []string) { s.deleteBatch(keys, true) }
+
+// deleteBatch removes keys from the index, or only their expired and consumed
+// entries when deadOnly is set
+func (s *COWIndexStore) deleteBatch(keys []string, deadOnly bool) {
 	if len(keys) == 0 {
 		return
 	}
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
+	s.deleteLocked(keys, deadOnly)
+}
 
+func (s *COWIndexStore) deleteLocked(keys []string, deadOnly bool) {
 	old := s.snapshot()
 	n := len(old.segments)
-	newIdx := &Index{segments: make([]Segment, n)}
+	newIdx := &Index{segments: make([]Segment, n), secondary: old.secondary}
 	copy(newIdx.segments, old.segments)
 
+	now := s.clock.Now()
 	// Pre-allocate map with capacity hint to reduce allocations
 	group := make(map[int][]string, len(keys)/4+1)
 	for _, k := range keys {
-		si := s.segmentIndexOf(k)
+		si := s.segmentIndexOf(old, k)
 		group[si] = append(group[si], k)
 	}
 
@@ -156,11 +241,15 @@ func (s *COWIndexStore) deleteBatch(keys []string) {
 				continue
 			}
 			ent := ns.vals[i]
+			if deadOnly && !isDead(ent, now) {
+				continue
+			}
 			delta -= sizeOf(k, ent)
 			ns.keys = append(ns.keys[:i], ns.keys[i+1:]...)
 			ns.vals = append(ns.vals[:i], ns.vals[i+1:]...)
 			atomic.AddInt64(&s.keyCount, -1)
 			s.GarbageCollector.Cancel(k)
+			newIdx.secondary = reindex(newIdx.secondary, k, ent, nil)
 		}
 		newIdx.segments[si] = ns
 	}
@@ -173,7 +262,7 @@ func (s *COWIndexStore) deleteBatch(keys []string) {
 
 func (s *COWIndexStore) Exists(key string) bool {
 	idx := s.snapshot()
-	seg := &idx.segments[s.segmentIndexOf(key)]
+	seg := &idx.segments[s.segmentIndexOf(idx, key)]
 	_, found := slices.BinarySearch(seg.keys, key)
 	return found
 }
@@ -191,10 +280,214 @@ func (s *COWIndexStore) Keys() []string {
 	return out
 }
 
+// Take removes a live entry and returns it
+// The lookup and the removal share the write lock, so an entry set in between is never dropped
+// Unread single-read entries are claimed first, so a concurrent Get cannot also consume them
+func (s *COWIndexStore) Take(key string) (StorageEntry, bool) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	idx := s.snapshot()
+	seg := &idx.segments[s.segmentIndexOf(idx, key)]
+
+	i, found := slices.BinarySearch(seg.keys, key)
+	if !found {
+		return StorageEntry{}, false
+	}
+	e := seg.vals[i]
+
+	live := !e.IsExpired(s.clock.Now())
+	if live && e.SingleRead {
+		live = atomic.CompareAndSwapInt32(&e.AccessCount, 0, 1)
+	}
+	s.deleteLocked([]string{key}, false)
+
+	if !live {
+		return StorageEntry{}, false
+	}
+	return loadEntry(e), true
+}
+
+// Range calls fn for every live entry of a single index snapshot
+// It never blocks writers and stops early when fn returns false
+func (s *COWIndexStore) Range(fn func(key string, entry StorageEntry) bool) {
+	s.Snapshot()(fn)
+}
+
+// Snapshot loads the index now, entries are checked for liveness once ranged
+func (s *COWIndexStore) Snapshot() func(fn func(key string, entry StorageEntry) bool) {
+	idx := s.snapshot()
+	return func(fn func(key string, entry StorageEntry) bool) {
+		now := s.clock.Now()
+		for i := range idx.segments {
+			seg := &idx.segments[i]
+			for j, k := range seg.keys {
+				e := seg.vals[j]
+				if isDead(e, now) {
+					continue
+				}
+				if !fn(k, loadEntry(e)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Clone returns an independent store holding the live entries of a single index snapshot
+// Key slices and values are shared with s, neither is ever modified in place
+func (s *COWIndexStore) Clone() Store {
+	idx := s.snapshot()
+	c := NewMemoryStore(s.clock).(*COWIndexStore)
+	now := s.clock.Now()
+	cloned := &Index{segments: make([]Segment, len(idx.segments))}
+
+	var count, used int64
+	for i := range idx.segments {
+		seg := &idx.segments[i]
+		ns := Segment{
+			keys: make([]string, 0, len(seg.keys)),
+			vals: make([]*entry, 0, len(seg.vals)),
+		}
+		for j, k := range seg.keys {
+			e := seg.vals[j]
+			if isDead(e, now) {
+				continue
+			}
+			// Entries carry mutable access stats, they cannot be shared
+			copied := loadEntry(e)
+			copied.AccessCount = 0
+			ns.keys = append(ns.keys, k)
+			ns.vals = append(ns.vals, &copied)
+			used += sizeOf(k, &copied)
+			c.GarbageCollector.Schedule(k, copied.ExpiresAt)
+		}
+		if len(ns.keys) == len(seg.keys) {
+			ns.keys = seg.keys
+		}
+		count += int64(len(ns.keys))
+		cloned.segments[i] = ns
+	}
+
+	for name, x := range idx.secondary {
+		if cloned.secondary == nil {
+			cloned.secondary = make(map[string]*secondaryIndex, len(idx.secondary))
+		}
+		cloned.secondary[name] = buildIndex(x.def, x.path, cloned)
+	}
+
+	c.ptr.Store(cloned)
+	c.keyCount = count
+	c.usedBytes = used
+	return c
+}
+
+// Flush replaces the index with an empty one and returns the number of removed keys
+// Pending expirations of the old keys are dropped, they must not hit keys written afterwards
+func (s *COWIndexStore) Flush() int64 {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	flushed := &Index{segments: make([]Segment, initialSegments)}
+	for name, x := range s.snapshot().secondary {
+		if flushed.secondary == nil {
+			flushed.secondary = make(map[string]*secondaryIndex)
+		}
+		flushed.secondary[name] = &secondaryIndex{def: x.def, path: x.path}
+	}
+	s.ptr.Store(flushed)
+	s.GarbageCollector.Reset()
+	atomic.StoreInt64(&s.usedBytes, 0)
+	return atomic.SwapInt64(&s.keyCount, 0)
+}
+
+// AddIndex builds the secondary index def over the current entries, it is
+// maintained by every write from then on and replaces an index of the same name
+func (s *COWIndexStore) AddIndex(def IndexDef) error {
+	x, err := newSecondaryIndex(def)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	old := s.snapshot()
+	newIdx := &Index{segments: old.segments, secondary: maps.Clone(old.secondary)}
+	if newIdx.secondary == nil {
+		newIdx.secondary = make(map[string]*secondaryIndex, 1)
+	}
+	newIdx.secondary[def.Name] = buildIndex(def, x.path, old)
+	s.ptr.Store(newIdx)
+	return nil
+}
+
+// DropIndex stops maintaining the secondary index name
+func (s *COWIndexStore) DropIndex(name string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	old := s.snapshot()
+	if _, ok := old.secondary[name]; !ok {
+		return
+	}
+	newIdx := &Index{segments: old.segments, secondary: maps.Clone(old.secondary)}
+	delete(newIdx.secondary, name)
+	s.ptr.Store(newIdx)
+}
+
+// Lookup returns the live entries of the secondary index name matching q,
+// read from a single snapshot without locking
+func (s *COWIndexStore) Lookup(name string, q IndexQuery) ([]IndexHit, error) {
+	idx := s.snapshot()
+	x, ok := idx.secondary[name]
+	if !ok {
+		return nil, errs.ErrIndexNotFound
+	}
+	return x.lookup(s, idx, q), nil
+}
+
+// buildIndex indexes every entry of idx, dead ones included since their
+// removal by the collector goes through the index too
+func buildIndex(def IndexDef, path JSONPath, idx *Index) *secondaryIndex {
+	x := &secondaryIndex{def: def, path: path}
+	for i := range idx.segments {
+		seg := &idx.segments[i]
+		for j, k := range seg.keys {
+			if h, ok := x.hitOf(k, seg.vals[j]); ok {
+				x.root, _ = tput(x.root, h, struct{}{}, compareHits)
+			}
+		}
+	}
+	return x
+}
+
 func (s *COWIndexStore) Usage() int64 { return atomic.LoadInt64(&s.usedBytes) }
 
 func (s *COWIndexStore) Count() int64 { return atomic.LoadInt64(&s.keyCount) }
 
+// loadEntry copies a shared entry, reading the access stats atomically
+// since concurrent readers update them in place
+func loadEntry(e *entry) StorageEntry {
+	return StorageEntry{
+		Key:          e.Key,
+		Value:        e.Value,
+		OriginalSize: e.OriginalSize,
+		TTL:          e.TTL,
+		CreatedAt:    e.CreatedAt,
+		ExpiresAt:    e.ExpiresAt,
+		SingleRead:   e.SingleRead,
+		AccessCount:  atomic.LoadInt32(&e.AccessCount),
+		LastAccess:   atomic.LoadInt64(&e.LastAccess),
+		Data:         e.Data,
+	}
+}
+
+// isDead reports whether e is expired at now or a consumed single-read entry
+func isDead(e *entry, now time.Time) bool {
+	return e.IsExpired(now) || (e.SingleRead && atomic.LoadInt32(&e.AccessCount) > 0)
+}
+
 func sizeOf(key string, e *StorageEntry) int64 {
 	var v int64
 	if e.OriginalSize > 0 {
@@ -202,8 +495,13 @@ func sizeOf(key string, e *StorageEntry) int64 {
 	} else {
 		v = int64(len(e.Value))
 	}
+	if e.Data != nil {
+		v += e.Data.Size()
+	}
 	return int64(len(key)) + v
 }
 
+func (s *COWIndexStore) GCStats() gc.Stats { return s.GarbageCollector.Stats() }
+
 func (s *COWIndexStore) StartGC(d time.Duration) { s.GarbageCollector.Start(d) }
 func (s *COWIndexStore) StopGC()                 { s.GarbageCollector.Stop() }
@@ -0,0 +1,309 @@
+package engine
+
+import (
+	"fmt"
+	"key-value-store/internal/clock"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestStore() (*COWIndexStore, *clock.Fake) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return NewMemoryStore(clk).(*COWIndexStore), clk
+}
+
+// newEntry builds an entry the way the storage service does, ttl in seconds
+func newEntry(clk clock.Clock, value string, ttl int64, singleRead bool) StorageEntry {
+	now := clk.Now()
+	e := StorageEntry{
+		Value:        []byte(value),
+		OriginalSize: int64(len(value)),
+		TTL:          ttl,
+		CreatedAt:    now,
+		SingleRead:   singleRead,
+	}
+	if ttl > 0 {
+		e.ExpiresAt = now.Add(time.Duration(ttl) * time.Second)
+	}
+	return e
+}
+
+func TestTTLExpiresStrictlyAfterExpiresAt(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "v", 10, false))
+
+	clk.Advance(10 * time.Second)
+	if _, ok := s.Get("k"); !ok {
+		t.Fatal("key gone at its expiration instant")
+	}
+
+	clk.Advance(time.Nanosecond)
+	if _, ok := s.Get("k"); ok {
+		t.Fatal("expired key still readable")
+	}
+	if s.Count() != 1 {
+		t.Fatalf("Count() = %d, expired keys stay until collected", s.Count())
+	}
+
+	s.Collect()
+	if s.Count() != 0 || s.Usage() != 0 {
+		t.Errorf("Count() = %d, Usage() = %d after collection", s.Count(), s.Usage())
+	}
+}
+
+func TestTTLCollectedWithoutRead(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("near", newEntry(clk, "v", 1, false))
+	s.Set("far", newEntry(clk, "v", 86400, false))
+	s.Set("forever", newEntry(clk, "v", 0, false))
+
+	clk.Advance(2 * time.Second)
+	s.Collect()
+	if s.Exists("near") || !s.Exists("far") {
+		t.Fatalf("after 2 s: near exists %v, far exists %v", s.Exists("near"), s.Exists("far"))
+	}
+
+	clk.Advance(24 * time.Hour)
+	s.Collect()
+	if s.Exists("far") {
+		t.Error("key with a one day TTL not collected after a day")
+	}
+
+	clk.Advance(100 * 365 * 24 * time.Hour)
+	s.Collect()
+	if _, ok := s.Get("forever"); !ok {
+		t.Error("key without TTL expired")
+	}
+}
+
+func TestTTLOverwriteReplacesExpiration(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("longer", newEntry(clk, "v", 1, false))
+	s.Set("removed", newEntry(clk, "v", 1, false))
+	s.Set("longer", newEntry(clk, "v2", 60, false))
+	s.Set("removed", newEntry(clk, "v2", 0, false))
+
+	clk.Advance(2 * time.Second)
+	s.Collect()
+	if e, ok := s.Get("longer"); !ok || string(e.Value) != "v2" {
+		t.Errorf("overwritten key collected by its previous TTL")
+	}
+	if _, ok := s.Get("removed"); !ok {
+		t.Errorf("key collected after its TTL was removed")
+	}
+
+	clk.Advance(time.Minute)
+	s.Collect()
+	if s.Exists("longer") || !s.Exists("removed") {
+		t.Errorf("after the new TTL: longer exists %v, removed exists %v", s.Exists("longer"), s.Exists("removed"))
+	}
+}
+
+func TestTTLRangeCloneAndTakeSkipExpired(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("short", newEntry(clk, "v", 1, false))
+	s.Set("long", newEntry(clk, "v", 100, false))
+	clk.Advance(2 * time.Second)
+
+	var keys []string
+	s.Range(func(key string, _ StorageEntry) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 1 || keys[0] != "long" {
+		t.Errorf("Range returned %v, want [long]", keys)
+	}
+
+	c := s.Clone().(*COWIndexStore)
+	if c.Count() != 1 || c.Exists("short") {
+		t.Errorf("clone holds %d keys, expired key copied: %v", c.Count(), c.Exists("short"))
+	}
+	clk.Advance(100 * time.Second)
+	c.Collect()
+	if c.Count() != 0 {
+		t.Error("clone did not schedule the expiration of its keys")
+	}
+
+	if _, ok := s.Take("short"); ok {
+		t.Error("Take returned an expired entry")
+	}
+	if s.Exists("short") {
+		t.Error("Take left the expired entry")
+	}
+}
+
+func TestSingleReadConsumedOnce(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "secret", 0, true))
+
+	if e, ok := s.Get("k"); !ok || string(e.Value) != "secret" {
+		t.Fatal("first read failed")
+	}
+	if _, ok := s.Get("k"); ok {
+		t.Fatal("second read succeeded")
+	}
+	if _, ok := s.Take("k"); ok {
+		t.Fatal("Take returned a consumed entry")
+	}
+
+	s.Set("unread", newEntry(clk, "v", 0, true))
+	var seen []string
+	s.Range(func(key string, _ StorageEntry) bool {
+		seen = append(seen, key)
+		return true
+	})
+	if len(seen) != 1 || seen[0] != "unread" {
+		t.Errorf("Range returned %v, want the unread entry only", seen)
+	}
+}
+
+func TestSingleReadCollectedAfterRead(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "v", 0, true))
+	s.Get("k")
+
+	s.Collect()
+	if s.Count() != 0 || s.Usage() != 0 {
+		t.Errorf("Count() = %d, Usage() = %d after the consumed entry was collected", s.Count(), s.Usage())
+	}
+}
+
+func TestSingleReadConcurrentReaders(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "v", 0, true))
+
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := s.Get("k"); ok {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if wins.Load() != 1 {
+		t.Errorf("%d readers got the single-read entry, want 1", wins.Load())
+	}
+}
+
+func TestSingleReadExpiresUnread(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "v", 5, true))
+
+	clk.Advance(6 * time.Second)
+	if _, ok := s.Get("k"); ok {
+		t.Error("expired single-read entry readable")
+	}
+	s.Collect()
+	if s.Count() != 0 {
+		t.Error("expired single-read entry not collected")
+	}
+}
+
+func TestFlushDropsPendingExpirations(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("k", newEntry(clk, "v", 1, false))
+	if n := s.Flush(); n != 1 {
+		t.Fatalf("Flush() = %d, want 1", n)
+	}
+	s.Set("k", newEntry(clk, "v", 0, false))
+
+	clk.Advance(time.Minute)
+	s.Collect()
+	if !s.Exists("k") {
+		t.Error("key written after Flush collected by an expiration from before it")
+	}
+}
+
+func TestShardContainerUsesClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sc := NewShardContainer(4, clk)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		sc.Set(k, newEntry(clk, "v", 30, false))
+	}
+
+	clone, err := sc.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(31 * time.Second)
+	for _, c := range []*ShardContainer{sc, clone} {
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			if _, ok := c.Get(k); ok {
+				t.Fatalf("key %s readable after its TTL", k)
+			}
+		}
+	}
+}
+
+func TestTakeKeepsConcurrentSet(t *testing.T) {
+	s, clk := newTestStore()
+	for range 500 {
+		s.Set("k", newEntry(clk, "old", 0, false))
+
+		var taken StorageEntry
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			taken, _ = s.Take("k")
+		}()
+		go func() {
+			defer wg.Done()
+			s.Set("k", newEntry(clk, "new", 0, false))
+		}()
+		wg.Wait()
+
+		// The new value is either taken or still stored, never both or neither
+		e, stored := s.Get("k")
+		if (string(taken.Value) == "new") == (stored && string(e.Value) == "new") {
+			t.Fatalf("took %q, stored %v %q", taken.Value, stored, e.Value)
+		}
+		s.Delete("k")
+	}
+}
+
+func TestRangeDuringReshard(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sc := NewShardContainer(2, clk)
+	const n = 20000
+	for i := range n {
+		sc.Set(fmt.Sprint("k", i), newEntry(clk, "v", 0, false))
+	}
+
+	done := make(chan struct{})
+	if err := sc.Reshard(7, func() { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	for migrating := true; migrating; {
+		select {
+		case <-done:
+			migrating = false
+		default:
+		}
+
+		seen := make(map[string]int, n)
+		sc.Range(func(key string, _ StorageEntry) bool {
+			seen[key]++
+			return true
+		})
+		if len(seen) != n {
+			t.Fatalf("Range returned %d keys, want %d", len(seen), n)
+		}
+		for k, c := range seen {
+			if c != 1 {
+				t.Fatalf("Range returned %s %d times", k, c)
+			}
+		}
+		for _, k := range []string{"k0", "k4999", "k19999"} {
+			if _, ok := sc.Get(k); !ok {
+				t.Fatalf("%s missing during the migration", k)
+			}
+		}
+	}
+}
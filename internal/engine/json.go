@@ -0,0 +1,356 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"key-value-store/internal/errs"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Approximate sizes of the decoded JSON nodes
+const (
+	jsonScalarOverhead = 16
+	jsonArrayOverhead  = 24
+	jsonObjectOverhead = 48
+	jsonMemberOverhead = 16
+)
+
+// JSON is a value holding a JSON document, validated when written
+// The decoded tree is never modified once stored, updates copy the objects and
+// arrays on the path to the change and share the rest; the nil JSON is empty,
+// unlike a document holding null
+type JSON struct {
+	root any
+	size int64
+}
+
+// jsonUpdate gets the value at the end of a path, found is false when the
+// last segment is missing, and returns its replacement or asks for its removal
+type jsonUpdate func(cur any, found bool) (next any, remove bool, err error)
+
+func (j *JSON) Kind() Kind { return KindJSON }
+
+// Size approximates the memory of the decoded document
+func (j *JSON) Size() int64 {
+	if j == nil {
+		return 0
+	}
+	return j.size
+}
+
+// Len is 1 for a document and 0 for the empty value
+func (j *JSON) Len() int {
+	if j == nil {
+		return 0
+	}
+	return 1
+}
+
+// ParseJSON decodes a document, numbers keep their text so that large integers
+// are not rounded
+func ParseJSON(data []byte) (*JSON, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return &JSON{root: v, size: jsonSize(v)}, nil
+}
+
+// Get returns the encoded value at path
+func (j *JSON) Get(path JSONPath) ([]byte, error) {
+	v, ok := j.lookup(path)
+	if !ok {
+		return nil, errs.ErrPathNotFound
+	}
+	return encodeJSON(v)
+}
+
+// lookup returns the decoded value at path
+func (j *JSON) lookup(path JSONPath) (any, bool) {
+	if j == nil {
+		return nil, false
+	}
+	v := j.root
+	for _, seg := range path {
+		var ok bool
+		if v, ok = child(v, seg); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Set returns j with the value at path replaced by data, the last segment may
+// name a new object member or the end of an array
+func (j *JSON) Set(path JSONPath, data []byte) (*JSON, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return &JSON{root: v, size: jsonSize(v)}, nil
+	}
+	return j.update(path, func(any, bool) (any, bool, error) { return v, false, nil })
+}
+
+// Delete returns j without the value at path and whether it existed
+// Deleting the whole document returns the empty value
+func (j *JSON) Delete(path JSONPath) (*JSON, bool, error) {
+	if len(path) == 0 {
+		return nil, j != nil, nil
+	}
+	existed := false
+	next, err := j.update(path, func(cur any, found bool) (any, bool, error) {
+		existed = found
+		return cur, true, nil
+	})
+	if errors.Is(err, errs.ErrPathNotFound) {
+		return j, false, nil
+	}
+	return next, existed, err
+}
+
+// Append returns j with values added to the array at path, and its new length
+func (j *JSON) Append(path JSONPath, values [][]byte) (*JSON, int, error) {
+	items := make([]any, len(values))
+	for i, data := range values {
+		v, err := decodeJSON(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		items[i] = v
+	}
+
+	var length int
+	next, err := j.update(path, func(cur any, found bool) (any, bool, error) {
+		arr, ok := cur.([]any)
+		if !found {
+			return nil, false, errs.ErrPathNotFound
+		}
+		if !ok {
+			return nil, false, errs.ErrPathType
+		}
+		out := make([]any, 0, len(arr)+len(items))
+		out = append(append(out, arr...), items...)
+		length = len(out)
+		return out, false, nil
+	})
+	return next, length, err
+}
+
+// Incr returns j with delta added to the number at path, and the new number
+// Integers stay exact as long as the result fits in 64 bits
+func (j *JSON) Incr(path JSONPath, delta json.Number) (*JSON, json.Number, error) {
+	if v, err := decodeJSON([]byte(delta)); err != nil {
+		return nil, "", err
+	} else if _, ok := v.(json.Number); !ok {
+		return nil, "", errs.ErrInvalidJSON
+	}
+
+	var result json.Number
+	next, err := j.update(path, func(cur any, found bool) (any, bool, error) {
+		n, ok := cur.(json.Number)
+		if !found {
+			return nil, false, errs.ErrPathNotFound
+		}
+		if !ok {
+			return nil, false, errs.ErrPathType
+		}
+		var err error
+		result, err = addNumbers(n, delta)
+		return result, false, err
+	})
+	return next, result, err
+}
+
+// update applies fn at path, removing the whole document returns the empty value
+func (j *JSON) update(path JSONPath, fn jsonUpdate) (*JSON, error) {
+	if j == nil {
+		return nil, errs.ErrPathNotFound
+	}
+	if len(path) == 0 {
+		next, remove, err := fn(j.root, true)
+		if err != nil || remove {
+			return nil, err
+		}
+		return &JSON{root: next, size: jsonSize(next)}, nil
+	}
+	root, delta, err := updateNode(j.root, path, fn)
+	if err != nil {
+		return nil, err
+	}
+	return &JSON{root: root, size: j.size + delta}, nil
+}
+
+// updateNode returns node with fn applied at path and the change of size,
+// copying the containers on the way
+func updateNode(node any, path JSONPath, fn jsonUpdate) (any, int64, error) {
+	seg, last := path[0], len(path) == 1
+	switch n := node.(type) {
+	case map[string]any:
+		cur, found := n[seg]
+		if !last {
+			if !found {
+				return nil, 0, errs.ErrPathNotFound
+			}
+			next, delta, err := updateNode(cur, path[1:], fn)
+			if err != nil {
+				return nil, 0, err
+			}
+			out := maps.Clone(n)
+			out[seg] = next
+			return out, delta, nil
+		}
+
+		next, remove, err := fn(cur, found)
+		if err != nil {
+			return nil, 0, err
+		}
+		member := int64(len(seg)) + jsonMemberOverhead
+		out := maps.Clone(n)
+		switch {
+		case remove && !found:
+			return n, 0, nil
+		case remove:
+			delete(out, seg)
+			return out, -jsonSize(cur) - member, nil
+		case found:
+			out[seg] = next
+			return out, jsonSize(next) - jsonSize(cur), nil
+		default:
+			out[seg] = next
+			return out, jsonSize(next) + member, nil
+		}
+
+	case []any:
+		i, ok := index(seg, len(n), last)
+		if !ok {
+			return nil, 0, errs.ErrPathNotFound
+		}
+		found := i < len(n)
+		if !last {
+			next, delta, err := updateNode(n[i], path[1:], fn)
+			if err != nil {
+				return nil, 0, err
+			}
+			out := slices.Clone(n)
+			out[i] = next
+			return out, delta, nil
+		}
+
+		var cur any
+		if found {
+			cur = n[i]
+		}
+		next, remove, err := fn(cur, found)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case remove && !found:
+			return n, 0, nil
+		case remove:
+			return slices.Delete(slices.Clone(n), i, i+1), -jsonSize(cur), nil
+		case found:
+			out := slices.Clone(n)
+			out[i] = next
+			return out, jsonSize(next) - jsonSize(cur), nil
+		default:
+			return append(slices.Clip(n), next), jsonSize(next), nil
+		}
+
+	default:
+		return nil, 0, errs.ErrPathNotFound
+	}
+}
+
+func child(node any, seg string) (any, bool) {
+	switch n := node.(type) {
+	case map[string]any:
+		v, ok := n[seg]
+		return v, ok
+	case []any:
+		i, ok := index(seg, len(n), false)
+		if !ok {
+			return nil, false
+		}
+		return n[i], true
+	default:
+		return nil, false
+	}
+}
+
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, errs.ErrInvalidJSON
+	}
+	// A document is a single value
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errs.ErrInvalidJSON
+	}
+	return v, nil
+}
+
+func encodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func jsonSize(v any) int64 {
+	switch n := v.(type) {
+	case map[string]any:
+		size := int64(jsonObjectOverhead)
+		for k, c := range n {
+			size += int64(len(k)) + jsonMemberOverhead + jsonSize(c)
+		}
+		return size
+	case []any:
+		size := int64(jsonArrayOverhead)
+		for _, c := range n {
+			size += jsonSize(c)
+		}
+		return size
+	case string:
+		return int64(len(n)) + jsonScalarOverhead
+	case json.Number:
+		return int64(len(n)) + jsonScalarOverhead
+	default:
+		return jsonScalarOverhead
+	}
+}
+
+// addNumbers adds two JSON numbers, as integers when both are
+func addNumbers(a, b json.Number) (json.Number, error) {
+	x, errX := a.Int64()
+	y, errY := b.Int64()
+	if errX == nil && errY == nil {
+		if (y > 0 && x > math.MaxInt64-y) || (y < 0 && x < math.MinInt64-y) {
+			return "", errs.ErrNumberOverflow
+		}
+		return json.Number(strconv.FormatInt(x+y, 10)), nil
+	}
+
+	fx, errX := a.Float64()
+	fy, errY := b.Float64()
+	if errX != nil || errY != nil {
+		return "", errs.ErrNumberOverflow
+	}
+	sum := fx + fy
+	if math.IsInf(sum, 0) {
+		return "", errs.ErrNumberOverflow
+	}
+	return json.Number(strconv.FormatFloat(sum, 'g', -1, 64)), nil
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"key-value-store/internal/errs"
+	"strconv"
+	"strings"
+)
+
+// JSONPath addresses a value inside a JSON document, one segment per object
+// member or array index; the empty path is the whole document
+type JSONPath []string
+
+// ParseJSONPath accepts a JSON Pointer such as /items/0/name or the JSONPath
+// subset of $ followed by .name, ['name'] and [index] selectors
+// Negative JSONPath indexes count from the end of an array
+func ParseJSONPath(s string) (JSONPath, error) {
+	switch {
+	case s == "" || s == "$":
+		return JSONPath{}, nil
+	case s[0] == '/':
+		return parsePointer(s)
+	case s[0] == '$':
+		return parseDollarPath(s[1:])
+	default:
+		return nil, errs.ErrInvalidPath
+	}
+}
+
+func parsePointer(s string) (JSONPath, error) {
+	parts := strings.Split(s[1:], "/")
+	for i, p := range parts {
+		// ~1 is decoded first so that ~01 stays ~1
+		p = strings.ReplaceAll(p, "~1", "/")
+		p = strings.ReplaceAll(p, "~0", "~")
+		parts[i] = p
+	}
+	return parts, nil
+}
+
+func parseDollarPath(s string) (JSONPath, error) {
+	path := JSONPath{}
+	for s != "" {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[")
+			if end < 0 {
+				end = len(s) - 1
+			}
+			name := s[1 : 1+end]
+			if name == "" || name == "*" {
+				return nil, errs.ErrInvalidPath
+			}
+			path = append(path, name)
+			s = s[1+end:]
+		case '[':
+			seg, rest, err := parseBracket(s[1:])
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, seg)
+			s = rest
+		default:
+			return nil, errs.ErrInvalidPath
+		}
+	}
+	return path, nil
+}
+
+// parseBracket reads a quoted name or an index and the closing bracket
+func parseBracket(s string) (string, string, error) {
+	if s != "" && (s[0] == '\'' || s[0] == '"') {
+		quote := s[0]
+		var name strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s):
+				i++
+				name.WriteByte(s[i])
+			case c == quote:
+				if i+1 >= len(s) || s[i+1] != ']' {
+					return "", "", errs.ErrInvalidPath
+				}
+				return name.String(), s[i+2:], nil
+			default:
+				name.WriteByte(c)
+			}
+		}
+		return "", "", errs.ErrInvalidPath
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", "", errs.ErrInvalidPath
+	}
+	if _, err := strconv.Atoi(s[:end]); err != nil {
+		return "", "", errs.ErrInvalidPath
+	}
+	return s[:end], s[end+1:], nil
+}
+
+// index resolves seg against an array of n items, "-" and n stand for the
+// position after the last item and are only valid when end is set
+func index(seg string, n int, end bool) (int, bool) {
+	if seg == "-" {
+		return n, end
+	}
+	i, err := strconv.Atoi(seg)
+	if err != nil {
+		return 0, false
+	}
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i > n || (i == n && !end) {
+		return 0, false
+	}
+	return i, true
+}
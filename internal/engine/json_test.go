@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"key-value-store/internal/errs"
+	"slices"
+	"testing"
+)
+
+func mustPath(t *testing.T, s string) JSONPath {
+	t.Helper()
+	p, err := ParseJSONPath(s)
+	if err != nil {
+		t.Fatalf("ParseJSONPath(%q): %v", s, err)
+	}
+	return p
+}
+
+func TestParseJSONPath(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want JSONPath
+	}{
+		{"", JSONPath{}},
+		{"$", JSONPath{}},
+		{"/a/0/b", JSONPath{"a", "0", "b"}},
+		{"/a~1b/c~0d/~01", JSONPath{"a/b", "c~d", "~1"}},
+		{"/", JSONPath{""}},
+		{"$.a[0].b", JSONPath{"a", "0", "b"}},
+		{"$['a.b'][\"c\"][-1]", JSONPath{"a.b", "c", "-1"}},
+		{`$['it\'s']`, JSONPath{"it's"}},
+	} {
+		if got := mustPath(t, c.in); !slices.Equal(got, c.want) {
+			t.Errorf("ParseJSONPath(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+
+	for _, in := range []string{"a", "$a", "$.", "$.*", "$[x]", "$['a'", "$[0"} {
+		if _, err := ParseJSONPath(in); !errors.Is(err, errs.ErrInvalidPath) {
+			t.Errorf("ParseJSONPath(%q): %v", in, err)
+		}
+	}
+}
+
+func TestJSONGetSetDelete(t *testing.T) {
+	doc, err := ParseJSON([]byte(`{"user":{"name":"ada","tags":["a","b"]},"n":12345678901234567890}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"$.user.name":     `"ada"`,
+		"/user/tags/1":    `"b"`,
+		"$.user.tags[-1]": `"b"`,
+		"$.n":             `12345678901234567890`,
+	} {
+		if got, err := doc.Get(mustPath(t, path)); err != nil || string(got) != want {
+			t.Errorf("Get(%s) = %s, %v, want %s", path, got, err, want)
+		}
+	}
+	if _, err := doc.Get(mustPath(t, "$.user.age")); !errors.Is(err, errs.ErrPathNotFound) {
+		t.Errorf("Get of a missing member: %v", err)
+	}
+
+	next, err := doc.Set(mustPath(t, "$.user.age"), []byte(`36`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err = next.Set(mustPath(t, "/user/tags/-"), []byte(`"c"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := next.Get(mustPath(t, "$.user")); string(got) != `{"age":36,"name":"ada","tags":["a","b","c"]}` {
+		t.Errorf("user = %s", got)
+	}
+	if got, _ := doc.Get(mustPath(t, "$.user")); string(got) != `{"name":"ada","tags":["a","b"]}` {
+		t.Errorf("Set changed the previous version: %s", got)
+	}
+	if _, err := doc.Set(mustPath(t, "$.missing.x"), []byte(`1`)); !errors.Is(err, errs.ErrPathNotFound) {
+		t.Errorf("Set below a missing member: %v", err)
+	}
+	if _, err := doc.Set(mustPath(t, "$.user"), []byte(`{"a":`)); !errors.Is(err, errs.ErrInvalidJSON) {
+		t.Errorf("Set of invalid JSON: %v", err)
+	}
+
+	next, existed, err := next.Delete(mustPath(t, "$.user.tags[0]"))
+	if err != nil || !existed {
+		t.Fatalf("Delete = %v, %v", existed, err)
+	}
+	if got, _ := next.Get(mustPath(t, "$.user.tags")); string(got) != `["b","c"]` {
+		t.Errorf("tags = %s after Delete", got)
+	}
+	if _, existed, _ := next.Delete(mustPath(t, "$.nope.x")); existed {
+		t.Error("Delete of a missing path reported a removal")
+	}
+}
+
+func TestJSONAppendIncr(t *testing.T) {
+	doc, _ := ParseJSON([]byte(`{"list":[1],"n":1,"f":1.5,"s":"x"}`))
+
+	doc, n, err := doc.Append(mustPath(t, "$.list"), [][]byte{[]byte(`2`), []byte(`{"a":3}`)})
+	if err != nil || n != 3 {
+		t.Fatalf("Append = %d, %v", n, err)
+	}
+	if _, _, err := doc.Append(mustPath(t, "$.s"), [][]byte{[]byte(`1`)}); !errors.Is(err, errs.ErrPathType) {
+		t.Errorf("Append to a string: %v", err)
+	}
+
+	for _, c := range []struct {
+		path, delta, want string
+	}{
+		{"$.n", "41", "42"},
+		{"$.f", "1", "2.5"},
+		{"$.n", "0.5", "42.5"},
+		{"$.list[2].a", "-3", "0"},
+	} {
+		var got string
+		next, num, err := doc.Incr(mustPath(t, c.path), json.Number(c.delta))
+		if err == nil {
+			doc, got = next, string(num)
+		}
+		if got != c.want {
+			t.Errorf("Incr(%s, %s) = %s, %v, want %s", c.path, c.delta, got, err, c.want)
+		}
+	}
+
+	num, _ := ParseJSON([]byte(`9223372036854775806`))
+	if num, n, err := num.Incr(JSONPath{}, "1"); err != nil || n != "9223372036854775807" {
+		t.Errorf("Incr of the whole document = %s, %v", n, err)
+	} else if _, _, err := num.Incr(JSONPath{}, "1"); !errors.Is(err, errs.ErrNumberOverflow) {
+		t.Errorf("Incr past the largest integer: %v", err)
+	}
+	if _, _, err := doc.Incr(mustPath(t, "$.s"), "1"); !errors.Is(err, errs.ErrPathType) {
+		t.Errorf("Incr of a string: %v", err)
+	}
+	if _, _, err := doc.Incr(mustPath(t, "$.n"), "1e999"); err == nil {
+		t.Error("Incr by an out of range number succeeded")
+	}
+}
+
+func TestJSONSizeTracksUpdates(t *testing.T) {
+	doc, _ := ParseJSON([]byte(`{"a":{"b":[1,2]},"c":"xyz"}`))
+	steps := []func(*JSON) (*JSON, error){
+		func(d *JSON) (*JSON, error) { return d.Set(mustPath(t, "$.a.b[0]"), []byte(`"longer value"`)) },
+		func(d *JSON) (*JSON, error) { return d.Set(mustPath(t, "$.d"), []byte(`{"e":null}`)) },
+		func(d *JSON) (*JSON, error) { d, _, err := d.Delete(mustPath(t, "$.c")); return d, err },
+		func(d *JSON) (*JSON, error) {
+			d, _, err := d.Append(mustPath(t, "$.a.b"), [][]byte{[]byte(`true`)})
+			return d, err
+		},
+	}
+	for i, step := range steps {
+		var err error
+		if doc, err = step(doc); err != nil {
+			t.Fatal(err)
+		}
+		if want := jsonSize(doc.root); doc.Size() != want {
+			t.Errorf("step %d: Size() = %d, recomputed %d", i, doc.Size(), want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package engine
+
+import "sync/atomic"
+
+const (
+	// listItemOverhead approximates the slice header kept for an item
+	listItemOverhead = 24
+	minListCap       = 8
+)
+
+// listBuf is the backing array shared by the versions of a list
+// A version only reads its own window, pushes write past it in place when
+// no other version claimed those slots yet, otherwise they copy
+type listBuf struct {
+	items [][]byte
+	// front and back are the widest window claimed on the buffer
+	front, back atomic.Int64
+}
+
+// List is a sequence value
+// It is never modified once stored, every operation returns a new version
+// The nil List is empty
+type List struct {
+	buf        *listBuf
+	start, end int
+	size       int64
+}
+
+func (l *List) Kind() Kind { return KindList }
+
+// Size is the sum of the item lengths plus a per-item overhead
+func (l *List) Size() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.size
+}
+
+func (l *List) Len() int {
+	if l == nil {
+		return 0
+	}
+	return l.end - l.start
+}
+
+// Range returns the items from start to stop excluded, both within [0, Len()]
+func (l *List) Range(start, stop int) [][]byte {
+	if l == nil || start >= stop {
+		return nil
+	}
+	return append([][]byte(nil), l.buf.items[l.start+start:l.start+stop]...)
+}
+
+// PushBack appends vals in order
+func (l *List) PushBack(vals ...[]byte) *List {
+	n := len(vals)
+	if l != nil && l.end+n <= len(l.buf.items) && l.buf.back.CompareAndSwap(int64(l.end), int64(l.end+n)) {
+		copy(l.buf.items[l.end:], vals)
+		return &List{buf: l.buf, start: l.start, end: l.end + n, size: l.size + itemsSize(vals)}
+	}
+
+	next := l.grow(0, n)
+	copy(next.buf.items[next.end:], vals)
+	next.end += n
+	next.buf.back.Store(int64(next.end))
+	next.size += itemsSize(vals)
+	return next
+}
+
+// PushFront prepends vals one after the other, the last one ends up first
+func (l *List) PushFront(vals ...[]byte) *List {
+	n := len(vals)
+	var next *List
+	if l != nil && l.start >= n && l.buf.front.CompareAndSwap(int64(l.start), int64(l.start-n)) {
+		next = &List{buf: l.buf, start: l.start, end: l.end, size: l.size}
+	} else {
+		next = l.grow(n, 0)
+		next.buf.front.Store(int64(next.start - n))
+	}
+	for _, v := range vals {
+		next.start--
+		next.buf.items[next.start] = v
+	}
+	next.size += itemsSize(vals)
+	return next
+}
+
+// PopFront removes up to n items from the front and returns them
+func (l *List) PopFront(n int) (*List, [][]byte) {
+	n = min(n, l.Len())
+	if n <= 0 {
+		return l, nil
+	}
+	out := l.Range(0, n)
+	return l.slice(l.start+n, l.end, l.size-itemsSize(out)), out
+}
+
+// PopBack removes up to n items from the back and returns them, last item first
+func (l *List) PopBack(n int) (*List, [][]byte) {
+	n = min(n, l.Len())
+	if n <= 0 {
+		return l, nil
+	}
+	out := l.Range(l.Len()-n, l.Len())
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return l.slice(l.start, l.end-n, l.size-itemsSize(out)), out
+}
+
+// Trim keeps the items from start to stop excluded, both within [0, Len()]
+func (l *List) Trim(start, stop int) *List {
+	if l == nil || start >= stop {
+		return nil
+	}
+	kept := l.buf.items[l.start+start : l.start+stop]
+	return l.slice(l.start+start, l.start+stop, itemsSize(kept))
+}
+
+// slice returns the window [start, end) of the buffer
+// Popped items stay referenced by the buffer, it is compacted once mostly unused
+func (l *List) slice(start, end int, size int64) *List {
+	if start == end {
+		return nil
+	}
+	next := &List{buf: l.buf, start: start, end: end, size: size}
+	if len(l.buf.items) > minListCap && next.Len()*4 < len(l.buf.items) {
+		next = next.grow(0, 0)
+	}
+	return next
+}
+
+// grow copies l into a new buffer with room for front and back more items
+// The window of the copy is empty at both ends, the caller claims what it fills
+func (l *List) grow(front, back int) *List {
+	n := l.Len()
+	free := max(n+front+back, minListCap)
+	items := make([][]byte, n+front+back+free)
+
+	start := front + free/2
+	if l != nil {
+		copy(items[start:], l.buf.items[l.start:l.end])
+	}
+	buf := &listBuf{items: items}
+	buf.front.Store(int64(start))
+	buf.back.Store(int64(start + n))
+	return &List{buf: buf, start: start, end: start + n, size: l.Size()}
+}
+
+func itemsSize(items [][]byte) int64 {
+	var size int64
+	for _, v := range items {
+		size += int64(len(v)) + listItemOverhead
+	}
+	return size
+}
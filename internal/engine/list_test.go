@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func values(l *List) []string {
+	var out []string
+	for _, v := range l.Range(0, l.Len()) {
+		out = append(out, string(v))
+	}
+	return out
+}
+
+func bs(items ...string) [][]byte {
+	out := make([][]byte, len(items))
+	for i, s := range items {
+		out[i] = []byte(s)
+	}
+	return out
+}
+
+func TestListPushPopBothEnds(t *testing.T) {
+	var l *List
+	l = l.PushBack(bs("c", "d")...)
+	l = l.PushFront(bs("b", "a")...)
+	if got := values(l); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("list = %v", got)
+	}
+
+	l, front := l.PopFront(1)
+	l, back := l.PopBack(2)
+	if string(front[0]) != "a" || len(back) != 2 || string(back[0]) != "d" || string(back[1]) != "c" {
+		t.Errorf("popped %q from the front and %q from the back", front, back)
+	}
+	if got := values(l); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("list = %v after the pops", got)
+	}
+
+	l, rest := l.PopFront(5)
+	if l != nil || len(rest) != 1 {
+		t.Errorf("popping past the end left %v and returned %d values", values(l), len(rest))
+	}
+}
+
+func TestListVersionsAreImmutable(t *testing.T) {
+	var base *List
+	base = base.PushBack(bs("a", "b")...)
+
+	// Both versions push onto the same buffer, only the first one may do it in place
+	x := base.PushBack(bs("x")...)
+	y := base.PushBack(bs("y")...)
+	z := base.PushFront(bs("z")...)
+	w := base.PushFront(bs("w")...)
+	popped, _ := base.PopFront(1)
+	p := popped.PushFront(bs("p")...)
+
+	for _, c := range []struct {
+		l    *List
+		want []string
+	}{
+		{base, []string{"a", "b"}},
+		{x, []string{"a", "b", "x"}},
+		{y, []string{"a", "b", "y"}},
+		{z, []string{"z", "a", "b"}},
+		{w, []string{"w", "a", "b"}},
+		{p, []string{"p", "b"}},
+	} {
+		if got := values(c.l); !slices.Equal(got, c.want) {
+			t.Errorf("list = %v, want %v", got, c.want)
+		}
+	}
+}
+
+func TestListTrimAndSize(t *testing.T) {
+	var l *List
+	for i := range 100 {
+		l = l.PushBack([]byte(fmt.Sprint(i)))
+	}
+	l = l.Trim(90, 100)
+	if got := values(l); len(got) != 10 || got[0] != "90" {
+		t.Fatalf("list = %v after the trim", got)
+	}
+	if want := itemsSize(bs(values(l)...)); l.Size() != want {
+		t.Errorf("Size() = %d, want %d", l.Size(), want)
+	}
+	// The trimmed items are released with the buffer
+	if len(l.buf.items) > 4*l.Len() {
+		t.Errorf("buffer of %d slots kept for %d items", len(l.buf.items), l.Len())
+	}
+	if l.Trim(3, 3) != nil {
+		t.Error("empty trim did not return the empty list")
+	}
+}
+
+func TestListQueueReusesBuffer(t *testing.T) {
+	var l *List
+	l = l.PushBack(bs("seed")...)
+	buf := l.buf
+	for i := range 3 {
+		l = l.PushBack([]byte(fmt.Sprint(i)))
+	}
+	if l.buf != buf {
+		t.Error("pushes with free room copied the list")
+	}
+	l, _ = l.PopFront(1)
+	if got := values(l); !slices.Equal(got, []string{"0", "1", "2"}) {
+		t.Errorf("list = %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package engine
+
+import "time"
+
+// lockOverhead approximates the memory of a lock besides its owner
+const lockOverhead = 48
+
+// Lock is a lease held by Owner, the entry holding it expires with the lease
+// so that a lock nobody renews is freed by the collector
+type Lock struct {
+	Owner string
+	// Token fences the writes of the owner, it grows with every acquire
+	Token      uint64
+	AcquiredAt time.Time
+}
+
+func (l *Lock) Kind() Kind { return KindLock }
+
+func (l *Lock) Size() int64 {
+	if l == nil {
+		return 0
+	}
+	return lockOverhead + int64(len(l.Owner))
+}
+
+// Len is 1 for a held lock
+func (l *Lock) Len() int {
+	if l == nil {
+		return 0
+	}
+	return 1
+}
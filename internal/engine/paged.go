@@ -0,0 +1,63 @@
+package engine
+
+import "slices"
+
+// pageLen is the number of elements of a page of a pagedArray
+const pageLen = 256
+
+// pagedArray is a fixed-length array split into pages, copies share the
+// pages neither of them wrote to; a nil page reads as zero values
+type pagedArray[T comparable] struct {
+	pages []*[pageLen]T
+}
+
+func newPagedArray[T comparable](n int) pagedArray[T] {
+	return pagedArray[T]{pages: make([]*[pageLen]T, (n+pageLen-1)/pageLen)}
+}
+
+func (a pagedArray[T]) get(i int) T {
+	p := a.pages[i/pageLen]
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return p[i%pageLen]
+}
+
+// allocated is the number of pages holding data
+func (a pagedArray[T]) allocated() int {
+	n := 0
+	for _, p := range a.pages {
+		if p != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// pageWriter builds a modified copy of a pagedArray, copying each page the
+// first time it is written to
+type pageWriter[T comparable] struct {
+	pagedArray[T]
+	copied map[int]struct{}
+}
+
+func (a pagedArray[T]) writer() *pageWriter[T] {
+	return &pageWriter[T]{
+		pagedArray: pagedArray[T]{pages: slices.Clone(a.pages)},
+		copied:     make(map[int]struct{}),
+	}
+}
+
+func (w *pageWriter[T]) set(i int, v T) {
+	pi := i / pageLen
+	if _, ok := w.copied[pi]; !ok {
+		p := new([pageLen]T)
+		if old := w.pages[pi]; old != nil {
+			*p = *old
+		}
+		w.pages[pi] = p
+		w.copied[pi] = struct{}{}
+	}
+	w.pages[pi][i%pageLen] = v
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"key-value-store/internal/errs"
+	"math"
+	"time"
+)
+
+// rateLimiterOverhead approximates the memory of a rate limiter
+const rateLimiterOverhead = 32
+
+// RateLimit is a token bucket holding up to Capacity tokens, Rate of which
+// are refilled every Period
+type RateLimit struct {
+	Capacity int64
+	Rate     int64
+	Period   time.Duration
+}
+
+// interval is the time a single token takes to refill
+func (l RateLimit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// Refill is the time an empty bucket takes to fill up
+func (l RateLimit) Refill() time.Duration {
+	return l.interval() * time.Duration(l.Capacity)
+}
+
+// Validate checks that a token refills in a nanosecond at least and the
+// bucket in a duration that does not overflow
+func (l RateLimit) Validate() error {
+	if l.Capacity <= 0 || l.Rate <= 0 || l.Period <= 0 {
+		return errs.ErrInvalidRateLimit
+	}
+	if interval := l.interval(); interval <= 0 || l.Capacity > math.MaxInt64/int64(interval) {
+		return errs.ErrInvalidRateLimit
+	}
+	return nil
+}
+
+// RateResult tells whether tokens were taken, how many are left, how long
+// until the denied cost is available and until the bucket is full again
+type RateResult struct {
+	Allowed    bool
+	Remaining  int64
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// RateLimiter is the state of a token bucket, kept as the time it is full
+// again so that refilling takes no timer and no write
+type RateLimiter struct {
+	full time.Time
+}
+
+func (r *RateLimiter) Kind() Kind { return KindRateLimiter }
+
+func (r *RateLimiter) Size() int64 {
+	if r == nil {
+		return 0
+	}
+	return rateLimiterOverhead
+}
+
+// Len is 1 for a limiter holding less than its capacity
+func (r *RateLimiter) Len() int {
+	if r == nil {
+		return 0
+	}
+	return 1
+}
+
+// Take removes cost tokens at now when the bucket holds them, the nil
+// limiter being a full bucket; a denied cost leaves the limiter unchanged
+// The limit must be valid and cost at most its capacity
+func (r *RateLimiter) Take(now time.Time, limit RateLimit, cost int64) (*RateLimiter, RateResult) {
+	interval, burst := limit.interval(), limit.Refill()
+	full := now
+	if r != nil && r.full.After(now) {
+		full = r.full
+	}
+
+	next := full.Add(interval * time.Duration(cost))
+	if wait := next.Sub(now) - burst; wait > 0 {
+		return r, RateResult{
+			Remaining:  int64(max(burst-full.Sub(now), 0) / interval),
+			RetryAfter: wait,
+			ResetAfter: full.Sub(now),
+		}
+	}
+	return &RateLimiter{full: next}, RateResult{
+		Allowed:    true,
+		Remaining:  int64((burst - next.Sub(now)) / interval),
+		ResetAfter: next.Sub(now),
+	}
+}
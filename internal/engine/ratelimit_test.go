@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"errors"
+	"key-value-store/internal/errs"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	// 10 tokens, one refilled every 100ms
+	limit := RateLimit{Capacity: 10, Rate: 10, Period: time.Second}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var r *RateLimiter
+	r, res := r.Take(now, limit, 4)
+	if !res.Allowed || res.Remaining != 6 || res.ResetAfter != 400*time.Millisecond {
+		t.Fatalf("first Take = %+v", res)
+	}
+	r, res = r.Take(now, limit, 6)
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("Take of the rest = %+v", res)
+	}
+
+	denied, res := r.Take(now.Add(50*time.Millisecond), limit, 2)
+	if res.Allowed || denied != r || res.Remaining != 0 || res.RetryAfter != 150*time.Millisecond {
+		t.Errorf("Take of an empty bucket = %+v", res)
+	}
+	if _, res := r.Take(now.Add(200*time.Millisecond), limit, 2); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Take after refilling 2 tokens = %+v", res)
+	}
+
+	// Refilling stops at the capacity
+	_, res = r.Take(now.Add(time.Hour), limit, 1)
+	if !res.Allowed || res.Remaining != 9 || res.ResetAfter != 100*time.Millisecond {
+		t.Errorf("Take of a bucket refilled long ago = %+v", res)
+	}
+	if _, res := r.Take(now.Add(time.Hour), limit, 0); !res.Allowed || res.Remaining != 10 || res.ResetAfter != 0 {
+		t.Errorf("Take of nothing = %+v", res)
+	}
+}
+
+func TestRateLimitValidate(t *testing.T) {
+	for _, l := range []RateLimit{
+		{Capacity: 0, Rate: 1, Period: time.Second},
+		{Capacity: 1, Rate: 0, Period: time.Second},
+		{Capacity: 1, Rate: 1, Period: 0},
+		{Capacity: 1, Rate: 2, Period: time.Nanosecond},
+		{Capacity: math.MaxInt64, Rate: 1, Period: time.Second},
+	} {
+		if err := l.Validate(); !errors.Is(err, errs.ErrInvalidRateLimit) {
+			t.Errorf("Validate(%+v) = %v", l, err)
+		}
+	}
+	if err := (RateLimit{Capacity: 5, Rate: 1, Period: time.Minute}).Validate(); err != nil {
+		t.Errorf("Validate of a valid limit: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/json"
+	"key-value-store/internal/errs"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IndexDef declares a secondary index over the value at Path of the JSON
+// documents of a bucket, documents without a scalar there are not indexed
+type IndexDef struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type indexType uint8
+
+// Values of different types sort in this order
+const (
+	indexNull indexType = iota
+	indexFalse
+	indexTrue
+	indexNumber
+	indexString
+)
+
+// IndexValue is an indexed JSON scalar
+// Numbers compare as 64-bit floats, integers above 2^53 may collide
+type IndexValue struct {
+	typ indexType
+	num float64
+	str string
+}
+
+// IndexHit is an entry of a secondary index, ordered by value then key
+type IndexHit struct {
+	Key   string
+	Value IndexValue
+}
+
+// IndexQuery selects the entries of an index whose value lies between Min and
+// Max, both included, a nil bound is open
+// After resumes a previous page past its last hit, Limit 0 means no limit
+type IndexQuery struct {
+	Min, Max *IndexValue
+	After    *IndexHit
+	Limit    int
+}
+
+// secondaryIndex is immutable, writers publish a modified copy with the
+// segments of the same Index
+type secondaryIndex struct {
+	def  IndexDef
+	path JSONPath
+	root *tnode[IndexHit, struct{}]
+}
+
+// ParseIndexValue decodes a JSON scalar to look up in an index
+func ParseIndexValue(data []byte) (IndexValue, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return IndexValue{}, err
+	}
+	iv, ok := indexValueOf(v)
+	if !ok {
+		return IndexValue{}, errs.ErrIndexValue
+	}
+	return iv, nil
+}
+
+func indexValueOf(v any) (IndexValue, bool) {
+	switch n := v.(type) {
+	case nil:
+		return IndexValue{typ: indexNull}, true
+	case bool:
+		if n {
+			return IndexValue{typ: indexTrue}, true
+		}
+		return IndexValue{typ: indexFalse}, true
+	case json.Number:
+		// Out of range numbers parse to an infinity, which still sorts
+		f, _ := strconv.ParseFloat(string(n), 64)
+		return IndexValue{typ: indexNumber, num: f}, true
+	case string:
+		return IndexValue{typ: indexString, str: n}, true
+	default:
+		return IndexValue{}, false
+	}
+}
+
+func (v IndexValue) MarshalJSON() ([]byte, error) {
+	switch v.typ {
+	case indexFalse:
+		return []byte("false"), nil
+	case indexTrue:
+		return []byte("true"), nil
+	case indexNumber:
+		return json.Marshal(v.num)
+	case indexString:
+		return encodeJSON(v.str)
+	default:
+		return []byte("null"), nil
+	}
+}
+
+func (v *IndexValue) UnmarshalJSON(data []byte) error {
+	iv, err := ParseIndexValue(data)
+	if err != nil {
+		return err
+	}
+	*v = iv
+	return nil
+}
+
+func compareIndexValues(a, b IndexValue) int {
+	if c := cmp.Compare(a.typ, b.typ); c != 0 {
+		return c
+	}
+	switch a.typ {
+	case indexNumber:
+		return cmp.Compare(a.num, b.num)
+	case indexString:
+		return strings.Compare(a.str, b.str)
+	default:
+		return 0
+	}
+}
+
+func compareHits(a, b IndexHit) int {
+	if c := compareIndexValues(a.Value, b.Value); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Key, b.Key)
+}
+
+// sortHits orders hits gathered from several shards and drops the duplicates
+// of keys seen in both layouts while resharding
+func sortHits(hits []IndexHit) []IndexHit {
+	slices.SortFunc(hits, compareHits)
+	return slices.CompactFunc(hits, func(a, b IndexHit) bool { return compareHits(a, b) == 0 })
+}
+
+func newSecondaryIndex(def IndexDef) (*secondaryIndex, error) {
+	if def.Name == "" {
+		return nil, errs.ErrInvalidIndex
+	}
+	path, err := ParseJSONPath(def.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &secondaryIndex{def: def, path: path}, nil
+}
+
+// hitOf returns the index entry of key, false when e holds no scalar at the path
+func (x *secondaryIndex) hitOf(key string, e *entry) (IndexHit, bool) {
+	doc, ok := e.Data.(*JSON)
+	if !ok {
+		return IndexHit{}, false
+	}
+	v, ok := doc.lookup(x.path)
+	if !ok {
+		return IndexHit{}, false
+	}
+	iv, ok := indexValueOf(v)
+	return IndexHit{Key: key, Value: iv}, ok
+}
+
+// move returns x with key indexed by next instead of prev, either may be nil
+func (x *secondaryIndex) move(key string, prev, next *entry) *secondaryIndex {
+	var from, to IndexHit
+	hadPrev, hasNext := false, false
+	if prev != nil {
+		from, hadPrev = x.hitOf(key, prev)
+	}
+	if next != nil {
+		to, hasNext = x.hitOf(key, next)
+	}
+	if (hadPrev && hasNext && compareHits(from, to) == 0) || (!hadPrev && !hasNext) {
+		return x
+	}
+
+	root := x.root
+	if hadPrev {
+		root = tdelete(root, from, compareHits)
+	}
+	if hasNext {
+		root, _ = tput(root, to, struct{}{}, compareHits)
+	}
+	return &secondaryIndex{def: x.def, path: x.path, root: root}
+}
+
+// reindex returns the indexes of secondary with key moved from prev to next
+// The map is only copied when an index changes
+func reindex(secondary map[string]*secondaryIndex, key string, prev, next *entry) map[string]*secondaryIndex {
+	out := secondary
+	copied := false
+	for name, x := range secondary {
+		moved := x.move(key, prev, next)
+		if moved == x {
+			continue
+		}
+		if !copied {
+			out = maps.Clone(secondary)
+			copied = true
+		}
+		out[name] = moved
+	}
+	return out
+}
+
+// lookup returns the live entries of idx matching q, at most q.Limit
+func (x *secondaryIndex) lookup(s *COWIndexStore, idx *Index, q IndexQuery) []IndexHit {
+	from := 0
+	if q.Min != nil {
+		from = tcount(x.root, func(h IndexHit) bool { return compareIndexValues(h.Value, *q.Min) < 0 })
+	}
+	if q.After != nil {
+		from = max(from, tcount(x.root, func(h IndexHit) bool { return compareHits(h, *q.After) <= 0 }))
+	}
+
+	now := s.clock.Now()
+	var hits []IndexHit
+	tscan(x.root, from, func(h IndexHit, _ struct{}) bool {
+		if q.Max != nil && compareIndexValues(h.Value, *q.Max) > 0 {
+			return false
+		}
+		// Expired entries stay indexed until the collector removes them
+		seg := &idx.segments[s.segmentIndexOf(idx, h.Key)]
+		if i, found := slices.BinarySearch(seg.keys, h.Key); found && !isDead(seg.vals[i], now) {
+			hits = append(hits, h)
+		}
+		return q.Limit <= 0 || len(hits) < q.Limit
+	})
+	return hits
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/errs"
+	"slices"
+	"testing"
+	"time"
+)
+
+func jsonEntry(t *testing.T, clk clock.Clock, doc string, ttl int64) StorageEntry {
+	t.Helper()
+	j, err := ParseJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("ParseJSON(%s): %v", doc, err)
+	}
+	e := newEntry(clk, "", ttl, false)
+	e.Data = j
+	return e
+}
+
+func mustValue(t *testing.T, s string) *IndexValue {
+	t.Helper()
+	v, err := ParseIndexValue([]byte(s))
+	if err != nil {
+		t.Fatalf("ParseIndexValue(%s): %v", s, err)
+	}
+	return &v
+}
+
+func hitKeys(hits []IndexHit) []string {
+	keys := make([]string, len(hits))
+	for i, h := range hits {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+func TestIndexMaintainedByWrites(t *testing.T) {
+	s, clk := newTestStore()
+	s.Set("s1", jsonEntry(t, clk, `{"user":"ada","n":1}`, 0))
+	s.Set("s2", jsonEntry(t, clk, `{"user":"bob","n":2}`, 0))
+	s.Set("plain", newEntry(clk, "v", 0, false))
+	s.Set("nouser", jsonEntry(t, clk, `{"n":3}`, 0))
+	s.Set("object", jsonEntry(t, clk, `{"user":{"id":1}}`, 0))
+
+	// Existing entries are indexed when the index is added
+	if err := s.AddIndex(IndexDef{Name: "by_user", Path: "$.user"}); err != nil {
+		t.Fatal(err)
+	}
+	ada := mustValue(t, `"ada"`)
+	eq := IndexQuery{Min: ada, Max: ada}
+	lookup := func() []string {
+		t.Helper()
+		hits, err := s.Lookup("by_user", eq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hitKeys(hits)
+	}
+	if got := lookup(); !slices.Equal(got, []string{"s1"}) {
+		t.Fatalf("user = ada: %v", got)
+	}
+
+	s.Set("s3", jsonEntry(t, clk, `{"user":"ada"}`, 0))
+	s.Set("s2", jsonEntry(t, clk, `{"user":"ada"}`, 0))
+	if got := lookup(); !slices.Equal(got, []string{"s1", "s2", "s3"}) {
+		t.Errorf("after writes: %v", got)
+	}
+
+	// Overwrites drop the previous value
+	s.Set("s1", jsonEntry(t, clk, `{"user":"eve"}`, 0))
+	s.Delete("s3")
+	err := s.Update("s2", func(cur StorageEntry, found bool) (StorageEntry, bool, error) {
+		return cur, true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lookup(); len(got) != 0 {
+		t.Errorf("after overwrite and deletes: %v", got)
+	}
+
+	if _, err := s.Lookup("missing", eq); !errors.Is(err, errs.ErrIndexNotFound) {
+		t.Errorf("Lookup of a missing index: %v", err)
+	}
+	s.DropIndex("by_user")
+	if _, err := s.Lookup("by_user", eq); !errors.Is(err, errs.ErrIndexNotFound) {
+		t.Errorf("Lookup of a dropped index: %v", err)
+	}
+}
+
+func TestIndexExpiryAndFlush(t *testing.T) {
+	s, clk := newTestStore()
+	if err := s.AddIndex(IndexDef{Name: "by_user", Path: "/user"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("short", jsonEntry(t, clk, `{"user":"ada"}`, 10))
+	s.Set("long", jsonEntry(t, clk, `{"user":"ada"}`, 0))
+
+	clk.Advance(11 * time.Second)
+	hits, _ := s.Lookup("by_user", IndexQuery{})
+	if got := hitKeys(hits); !slices.Equal(got, []string{"long"}) {
+		t.Errorf("expired entries are hidden before collection: %v", got)
+	}
+	s.Collect()
+	if n := s.snapshot().secondary["by_user"].root.count(); n != 1 {
+		t.Errorf("index holds %d entries after collection", n)
+	}
+
+	s.Flush()
+	if hits, err := s.Lookup("by_user", IndexQuery{}); err != nil || len(hits) != 0 {
+		t.Errorf("after Flush: %v, %v", hits, err)
+	}
+	s.Set("new", jsonEntry(t, clk, `{"user":"bob"}`, 0))
+	if hits, _ := s.Lookup("by_user", IndexQuery{}); !slices.Equal(hitKeys(hits), []string{"new"}) {
+		t.Errorf("index not kept by Flush: %v", hitKeys(hits))
+	}
+}
+
+func TestIndexRangeOrderAndPages(t *testing.T) {
+	s, clk := newTestStore()
+	if err := s.AddIndex(IndexDef{Name: "by_v", Path: "$.v"}); err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]string{
+		"null": `{"v":null}`, "false": `{"v":false}`, "true": `{"v":true}`,
+		"neg": `{"v":-1.5}`, "two": `{"v":2}`, "ten": `{"v":1e1}`,
+		"a": `{"v":"a"}`, "b": `{"v":"b"}`,
+	}
+	for k, doc := range docs {
+		s.Set(k, jsonEntry(t, clk, doc, 0))
+	}
+
+	hits, _ := s.Lookup("by_v", IndexQuery{})
+	want := []string{"null", "false", "true", "neg", "two", "ten", "a", "b"}
+	if got := hitKeys(hits); !slices.Equal(got, want) {
+		t.Errorf("index order %v, want %v", got, want)
+	}
+
+	hits, _ = s.Lookup("by_v", IndexQuery{Min: mustValue(t, "0"), Max: mustValue(t, "10.0")})
+	if got := hitKeys(hits); !slices.Equal(got, []string{"two", "ten"}) {
+		t.Errorf("0 <= v <= 10: %v", got)
+	}
+
+	// Pages resume after the last hit, keys sharing a value are split by key
+	for i := range 5 {
+		s.Set(fmt.Sprintf("dup%d", i), jsonEntry(t, clk, `{"v":"same"}`, 0))
+	}
+	same := mustValue(t, `"same"`)
+	var got []string
+	q := IndexQuery{Min: same, Max: same, Limit: 2}
+	for {
+		page, _ := s.Lookup("by_v", q)
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, hitKeys(page)...)
+		q.After = &page[len(page)-1]
+	}
+	if !slices.Equal(got, []string{"dup0", "dup1", "dup2", "dup3", "dup4"}) {
+		t.Errorf("paged lookup: %v", got)
+	}
+
+	if _, err := ParseIndexValue([]byte(`[1]`)); !errors.Is(err, errs.ErrIndexValue) {
+		t.Errorf("ParseIndexValue of an array: %v", err)
+	}
+	if err := s.AddIndex(IndexDef{Name: "bad", Path: "user"}); !errors.Is(err, errs.ErrInvalidPath) {
+		t.Errorf("AddIndex with an invalid path: %v", err)
+	}
+}
+
+func TestIndexAcrossShardsAndReshard(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sc := NewShardContainer(4, clk)
+	for i := range 50 {
+		sc.Set(fmt.Sprintf("k%02d", i), jsonEntry(t, clk, fmt.Sprintf(`{"n":%d}`, i%5), 0))
+	}
+	if err := sc.AddIndex(IndexDef{Name: "by_n", Path: "$.n"}); err != nil {
+		t.Fatal(err)
+	}
+
+	three := mustValue(t, "3")
+	count := func() int {
+		t.Helper()
+		hits, err := sc.Lookup("by_n", IndexQuery{Min: three, Max: three})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(hits)
+	}
+	if n := count(); n != 10 {
+		t.Fatalf("n = 3 matched %d keys, want 10", n)
+	}
+	hits, _ := sc.Lookup("by_n", IndexQuery{Limit: 7})
+	if len(hits) != 7 || hits[6].Key != "k30" {
+		t.Errorf("first page across shards: %v", hitKeys(hits))
+	}
+
+	done := make(chan struct{})
+	if err := sc.Reshard(7, func() { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if n := count(); n != 10 {
+		t.Errorf("after resharding n = 3 matched %d keys", n)
+	}
+	sc.Set("k99", jsonEntry(t, clk, `{"n":3}`, 0))
+	if n := count(); n != 11 {
+		t.Errorf("new shards do not maintain the index: %d keys", n)
+	}
+
+	clone, err := sc.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Delete("k99")
+	if hits, _ := clone.Lookup("by_n", IndexQuery{Min: three, Max: three}); len(hits) != 11 {
+		t.Errorf("clone matched %d keys", len(hits))
+	}
+	if defs := clone.Indexes(); len(defs) != 1 || defs[0].Name != "by_n" {
+		t.Errorf("clone indexes %v", defs)
+	}
+}
@@ -0,0 +1,82 @@
+package engine
+
+import "strings"
+
+// setMemberOverhead approximates the tree node kept for a member
+const setMemberOverhead = 48
+
+// Set is a value holding distinct members, kept in lexicographic order
+// It is never modified once stored, Add and Remove return new versions that
+// share the unchanged part of the tree; the nil Set is empty
+type Set struct {
+	root *tnode[string, struct{}]
+	size int64
+}
+
+func (s *Set) Kind() Kind { return KindSet }
+
+// Size is the sum of the member lengths plus a per-member overhead
+func (s *Set) Size() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.root.count()
+}
+
+func (s *Set) Has(member string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := tget(s.root, member, strings.Compare)
+	return ok
+}
+
+// Members returns every member in order
+func (s *Set) Members() []string {
+	out := make([]string, 0, s.Len())
+	if s != nil {
+		twalk(s.root, 0, s.Len(), func(m string, _ struct{}) { out = append(out, m) })
+	}
+	return out
+}
+
+// Add returns s with members added, and how many of them are new
+func (s *Set) Add(members ...string) (*Set, int) {
+	next := &Set{}
+	if s != nil {
+		*next = *s
+	}
+	added := 0
+	for _, m := range members {
+		var isNew bool
+		if next.root, isNew = tput(next.root, m, struct{}{}, strings.Compare); isNew {
+			next.size += int64(len(m)) + setMemberOverhead
+			added++
+		}
+	}
+	return next, added
+}
+
+// Remove returns s without members, and how many of them were removed
+func (s *Set) Remove(members ...string) (*Set, int) {
+	if s == nil {
+		return nil, 0
+	}
+	next := *s
+	removed := 0
+	for _, m := range members {
+		if root := tdelete(next.root, m, strings.Compare); root != next.root {
+			next.root = root
+			next.size -= int64(len(m)) + setMemberOverhead
+			removed++
+		}
+	}
+	return &next, removed
+}
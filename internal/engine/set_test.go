@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSetAddRemove(t *testing.T) {
+	var s *Set
+	s, added := s.Add("b", "a", "c", "a")
+	if added != 3 || !slices.Equal(s.Members(), []string{"a", "b", "c"}) {
+		t.Fatalf("Add = %v, %d", s.Members(), added)
+	}
+	if want := int64(3 * (1 + setMemberOverhead)); s.Size() != want {
+		t.Errorf("Size = %d, want %d", s.Size(), want)
+	}
+
+	next, removed := s.Remove("a", "x")
+	if removed != 1 || next.Has("a") || !next.Has("b") {
+		t.Errorf("Remove = %v, %d", next.Members(), removed)
+	}
+	if !s.Has("a") || s.Len() != 3 {
+		t.Error("Remove changed the previous version")
+	}
+	if want := int64(2 * (1 + setMemberOverhead)); next.Size() != want {
+		t.Errorf("Size = %d after Remove, want %d", next.Size(), want)
+	}
+}
+
+func TestSetMatchesMap(t *testing.T) {
+	var s *Set
+	ref := make(map[string]bool)
+	for i := range 2000 {
+		m := fmt.Sprintf("m%03d", (i*7919)%500)
+		if i%3 == 0 {
+			s, _ = s.Remove(m)
+			delete(ref, m)
+		} else {
+			s, _ = s.Add(m)
+			ref[m] = true
+		}
+	}
+
+	want := make([]string, 0, len(ref))
+	for m := range ref {
+		want = append(want, m)
+	}
+	slices.Sort(want)
+	if got := s.Members(); !slices.Equal(got, want) || s.Len() != len(want) {
+		t.Errorf("set holds %d members, want %d", len(got), len(want))
+	}
+}
+
+func TestSetUsage(t *testing.T) {
+	s, _ := newTestStore()
+	add := func(members ...string) {
+		t.Helper()
+		err := s.Update("k", func(cur StorageEntry, found bool) (StorageEntry, bool, error) {
+			set, _ := cur.Data.(*Set)
+			cur.Key = "k"
+			cur.Data, _ = set.Add(members...)
+			return cur, false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add("a", "bb")
+	add("bb", "ccc")
+	e, ok := s.Get("k")
+	if !ok || e.Kind() != KindSet || e.Data.(*Set).Len() != 3 {
+		t.Fatalf("Get returned %+v", e)
+	}
+	if want := int64(len("k")+6) + 3*setMemberOverhead; s.Usage() != want {
+		t.Errorf("Usage() = %d, want %d", s.Usage(), want)
+	}
+}
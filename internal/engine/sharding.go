@@ -1,64 +1,211 @@
 package engine
 
 import (
+	"key-value-store/internal/clock"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/gc"
 	"key-value-store/internal/util"
+	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const migrationStripes = 256
+
+// shardLayout is an immutable view of the shards
+// While resharding, keys move from the shards of `from` to `shards`
+type shardLayout struct {
+	shards []Store
+	from   []Store
+	// readers counts the scans pinned to this layout, see pin
+	readers sync.WaitGroup
+}
+
 type ShardContainer struct {
-	shards     []Store
-	shardCount int
+	layout     atomic.Pointer[shardLayout]
+	layoutMu   sync.RWMutex // writers hold RLock, layout swaps hold Lock
+	stripes    [migrationStripes]sync.Mutex
+	migration  atomic.Pointer[migration]
+	gcInterval atomic.Int64
+	indexMu    sync.Mutex // guards indexes, held while they are built on the shards
+	indexes    []IndexDef
 	hasher     util.Hasher
+	clock      clock.Clock
+}
+
+// ReshardProgress reports the state of the current or last migration
+type ReshardProgress struct {
+	From       int
+	To         int
+	Total      int64
+	Moved      int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Done       bool
 }
 
-func NewShardContainer(shardCount int) *ShardContainer {
+type migration struct {
+	from, to   int
+	source     []Store
+	total      int64
+	startedAt  time.Time
+	finishedAt atomic.Pointer[time.Time]
+}
+
+// NewShardContainer creates shardCount shards whose entries expire by clk
+func NewShardContainer(shardCount int, clk clock.Clock) *ShardContainer {
+	sc := &ShardContainer{
+		hasher: util.NewDefaultHasher(),
+		clock:  clk,
+	}
+	sc.layout.Store(&shardLayout{shards: newShards(shardCount, clk)})
+	return sc
+}
+
+func newShards(shardCount int, clk clock.Clock) []Store {
 	if shardCount < 1 {
 		shardCount = 1
 	}
 
 	shards := make([]Store, shardCount)
 	for i := 0; i < shardCount; i++ {
-		shards[i] = NewMemoryStore()
-	}
-
-	return &ShardContainer{
-		shards:     shards,
-		shardCount: shardCount,
-		hasher:     util.NewDefaultHasher(),
+		shards[i] = NewMemoryStore(clk)
 	}
+	return shards
 }
 
-func (sc *ShardContainer) getShard(key string) Store {
+func (sc *ShardContainer) shardOf(shards []Store, key string) Store {
 	hash := sc.hasher.Sum64String(key)
-	return sc.shards[hash%uint64(sc.shardCount)]
+	return shards[hash%uint64(len(shards))]
+}
+
+func (sc *ShardContainer) stripe(key string) *sync.Mutex {
+	return &sc.stripes[sc.hasher.Sum64String(key)%migrationStripes]
+}
+
+// ShardCount returns the target shard count, including during a migration
+func (sc *ShardContainer) ShardCount() int {
+	return len(sc.layout.Load().shards)
 }
 
 func (sc *ShardContainer) Set(key string, entry StorageEntry) {
-	shard := sc.getShard(key)
-	shard.Set(key, entry)
+	sc.layoutMu.RLock()
+	defer sc.layoutMu.RUnlock()
+
+	l := sc.layout.Load()
+	if l.from == nil {
+		sc.shardOf(l.shards, key).Set(key, entry)
+		return
+	}
+
+	// Forward the write to the new layout and drop the stale copy
+	mu := sc.stripe(key)
+	mu.Lock()
+	sc.shardOf(l.shards, key).Set(key, entry)
+	sc.shardOf(l.from, key).Delete(key)
+	mu.Unlock()
+}
+
+// Update applies fn atomically to the entry of key, see Store.Update
+func (sc *ShardContainer) Update(key string, fn UpdateFunc) error {
+	sc.layoutMu.RLock()
+	defer sc.layoutMu.RUnlock()
+
+	l := sc.layout.Load()
+	if l.from == nil {
+		return sc.shardOf(l.shards, key).Update(key, fn)
+	}
+
+	// Move the key to the new layout first, fn must see its current value
+	mu := sc.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+	target := sc.shardOf(l.shards, key)
+	if e, ok := sc.shardOf(l.from, key).Take(key); ok && !target.Exists(key) {
+		target.Set(key, e)
+	}
+	return target.Update(key, fn)
 }
 
+// Get looks the key up again when the layout changed meanwhile, a migration
+// may have moved it out of the shard it was looked for in
 func (sc *ShardContainer) Get(key string) (StorageEntry, bool) {
-	shard := sc.getShard(key)
-	return shard.Get(key)
+	l := sc.layout.Load()
+	for {
+		if e, ok := sc.getIn(l, key); ok {
+			return e, true
+		}
+		next := sc.layout.Load()
+		if next == l {
+			return StorageEntry{}, false
+		}
+		l = next
+	}
+}
+
+func (sc *ShardContainer) getIn(l *shardLayout, key string) (StorageEntry, bool) {
+	target := sc.shardOf(l.shards, key)
+	if e, ok := target.Get(key); ok || l.from == nil {
+		return e, ok
+	}
+
+	if e, ok := sc.shardOf(l.from, key).Get(key); ok {
+		return e, true
+	}
+	// The migrator may have moved the key between both lookups
+	return target.Get(key)
 }
 
 func (sc *ShardContainer) Delete(key string) {
-	shard := sc.getShard(key)
-	shard.Delete(key)
+	sc.layoutMu.RLock()
+	defer sc.layoutMu.RUnlock()
+
+	l := sc.layout.Load()
+	if l.from == nil {
+		sc.shardOf(l.shards, key).Delete(key)
+		return
+	}
+
+	mu := sc.stripe(key)
+	mu.Lock()
+	sc.shardOf(l.shards, key).Delete(key)
+	sc.shardOf(l.from, key).Delete(key)
+	mu.Unlock()
 }
 
+// Exists retries on a changed layout like Get
 func (sc *ShardContainer) Exists(key string) bool {
-	shard := sc.getShard(key)
-	return shard.Exists(key)
+	l := sc.layout.Load()
+	for !sc.existsIn(l, key) {
+		next := sc.layout.Load()
+		if next == l {
+			return false
+		}
+		l = next
+	}
+	return true
+}
+
+func (sc *ShardContainer) existsIn(l *shardLayout, key string) bool {
+	if sc.shardOf(l.shards, key).Exists(key) {
+		return true
+	}
+	if l.from == nil {
+		return false
+	}
+	return sc.shardOf(l.from, key).Exists(key) || sc.shardOf(l.shards, key).Exists(key)
 }
 
 func (sc *ShardContainer) Keys() []string {
-	resultChan := make(chan []string, len(sc.shards))
+	l, release := sc.pin()
+	defer release()
+	stores := append(append([]Store(nil), l.shards...), l.from...)
+	resultChan := make(chan []string, len(stores))
 	var wg sync.WaitGroup
 
-	for _, shard := range sc.shards {
+	for _, shard := range stores {
 		wg.Add(1)
 		go func(s Store) {
 			defer wg.Done()
@@ -76,31 +223,354 @@ func (sc *ShardContainer) Keys() []string {
 		allKeys = append(allKeys, keys...)
 	}
 
+	if l.from != nil {
+		allKeys = dedupe(allKeys)
+	}
 	return allKeys
 }
 
+func dedupe(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	out := keys[:0]
+	for _, k := range keys {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, k)
+	}
+	return out
+}
+
+// Reshard migrates the container to shardCount shards in the background
+// Reads and writes keep working, onDone is called once every key has moved
+func (sc *ShardContainer) Reshard(shardCount int, onDone func()) error {
+	if shardCount < 1 {
+		return errs.ErrInvalidShardCount
+	}
+
+	sc.layoutMu.Lock()
+	old := sc.layout.Load()
+	if old.from != nil {
+		sc.layoutMu.Unlock()
+		return errs.ErrReshardInProgress
+	}
+	if len(old.shards) == shardCount {
+		sc.layoutMu.Unlock()
+		return errs.ErrInvalidShardCount
+	}
+
+	next := &shardLayout{shards: newShards(shardCount, sc.clock), from: old.shards}
+	sc.indexMu.Lock()
+	for _, def := range sc.indexes {
+		for _, shard := range next.shards {
+			_ = shard.AddIndex(def) // Validated when first added
+		}
+	}
+	sc.indexMu.Unlock()
+	if interval := time.Duration(sc.gcInterval.Load()); interval > 0 {
+		for _, shard := range next.shards {
+			shard.StartGC(interval)
+		}
+	}
+
+	m := &migration{from: len(old.shards), to: shardCount, source: old.shards, startedAt: sc.clock.Now()}
+	for _, shard := range old.shards {
+		m.total += shard.Count()
+	}
+	sc.migration.Store(m)
+	sc.layout.Store(next)
+	sc.layoutMu.Unlock()
+
+	go sc.migrate(old, next, m, onDone)
+	return nil
+}
+
+// pin loads the layout for a scan over every shard
+// The migrator does not move keys out of a layout before the scans pinned to it
+// are released, they would miss the moved keys
+func (sc *ShardContainer) pin() (*shardLayout, func()) {
+	// Held by the layout swaps, no scan pins a layout once it has been replaced
+	sc.layoutMu.RLock()
+	l := sc.layout.Load()
+	l.readers.Add(1)
+	sc.layoutMu.RUnlock()
+	return l, l.readers.Done
+}
+
+func (sc *ShardContainer) migrate(prev, l *shardLayout, m *migration, onDone func()) {
+	prev.readers.Wait()
+	slog.Info("ShardContainer: Resharding started", "from", m.from, "to", m.to, "keys", m.total)
+
+	for _, shard := range l.from {
+		for _, key := range shard.Keys() {
+			mu := sc.stripe(key)
+			mu.Lock()
+			if e, ok := shard.Take(key); ok && !sc.shardOf(l.shards, key).Exists(key) {
+				sc.shardOf(l.shards, key).Set(key, e)
+			}
+			mu.Unlock()
+		}
+	}
+
+	sc.layoutMu.Lock()
+	sc.layout.Store(&shardLayout{shards: l.shards})
+	sc.layoutMu.Unlock()
+
+	for _, shard := range l.from {
+		shard.StopGC()
+	}
+
+	now := sc.clock.Now()
+	m.finishedAt.Store(&now)
+	slog.Info("ShardContainer: Resharding finished", "from", m.from, "to", m.to, "duration", now.Sub(m.startedAt))
+
+	if onDone != nil {
+		onDone()
+	}
+}
+
+// Clone copies every shard into a new container with the same layout
+// It fails while resharding, keys would be split between two layouts
+func (sc *ShardContainer) Clone() (*ShardContainer, error) {
+	sc.layoutMu.RLock()
+	defer sc.layoutMu.RUnlock()
+
+	l := sc.layout.Load()
+	if l.from != nil {
+		return nil, errs.ErrReshardInProgress
+	}
+
+	shards := make([]Store, len(l.shards))
+	for i, shard := range l.shards {
+		shards[i] = shard.Clone()
+	}
+
+	clone := &ShardContainer{
+		indexes: sc.Indexes(),
+		hasher:  util.NewDefaultHasher(),
+		clock:   sc.clock,
+	}
+	clone.layout.Store(&shardLayout{shards: shards})
+	if interval := time.Duration(sc.gcInterval.Load()); interval > 0 {
+		clone.StartGC(interval)
+	}
+	return clone, nil
+}
+
+// Flush removes every key and returns how many were removed
+// Writers are held off while the shards are swapped, so a write lands either
+// before the flush or after it on every shard
+func (sc *ShardContainer) Flush() (int64, error) {
+	sc.layoutMu.Lock()
+	defer sc.layoutMu.Unlock()
+
+	l := sc.layout.Load()
+	if l.from != nil {
+		return 0, errs.ErrReshardInProgress
+	}
+
+	var removed int64
+	for _, shard := range l.shards {
+		removed += shard.Flush()
+	}
+	return removed, nil
+}
+
+// AddIndex builds the secondary index def on every shard and maintains it
+// from then on, an index of the same name is replaced
+// Writes are not blocked while the shards are indexed one after the other
+func (sc *ShardContainer) AddIndex(def IndexDef) error {
+	if _, err := newSecondaryIndex(def); err != nil {
+		return err
+	}
+
+	sc.layoutMu.RLock()
+	defer sc.layoutMu.RUnlock()
+	sc.indexMu.Lock()
+	defer sc.indexMu.Unlock()
+
+	// Keys moved by a migration meanwhile are indexed by the write to their new shard
+	for _, shard := range sc.stores() {
+		if err := shard.AddIndex(def); err != nil {
+			return err
+		}
+	}
+	sc.indexes = append(slices.DeleteFunc(sc.indexes, func(d IndexDef) bool { return d.Name == def.Name }), def)
+	return nil
+}
+
+// DropIndex removes the secondary index name from every shard
+func (sc *ShardContainer) DropIndex(name string) {
+	sc.layoutMu.RLock()
+	defer sc.layoutMu.RUnlock()
+	sc.indexMu.Lock()
+	defer sc.indexMu.Unlock()
+
+	for _, shard := range sc.stores() {
+		shard.DropIndex(name)
+	}
+	sc.indexes = slices.DeleteFunc(sc.indexes, func(d IndexDef) bool { return d.Name == name })
+}
+
+// Indexes returns the definitions of the secondary indexes
+func (sc *ShardContainer) Indexes() []IndexDef {
+	sc.indexMu.Lock()
+	defer sc.indexMu.Unlock()
+	return slices.Clone(sc.indexes)
+}
+
+// Lookup merges the matches of q on every shard, in index order
+// Each shard is read from a single snapshot, none of them is locked
+func (sc *ShardContainer) Lookup(name string, q IndexQuery) ([]IndexHit, error) {
+	l, release := sc.pin()
+	defer release()
+
+	var hits []IndexHit
+	for _, shard := range append(append([]Store(nil), l.shards...), l.from...) {
+		part, err := shard.Lookup(name, q)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, part...)
+	}
+
+	hits = sortHits(hits)
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// ReshardProgress returns the current or last migration, false if there was none
+func (sc *ShardContainer) ReshardProgress() (ReshardProgress, bool) {
+	m := sc.migration.Load()
+	if m == nil {
+		return ReshardProgress{}, false
+	}
+
+	p := ReshardProgress{
+		From:      m.from,
+		To:        m.to,
+		Total:     m.total,
+		Moved:     m.total,
+		StartedAt: m.startedAt,
+	}
+	if finished := m.finishedAt.Load(); finished != nil {
+		p.FinishedAt = *finished
+		p.Done = true
+		return p, true
+	}
+
+	// Keys are moved by the migrator or by writes, count what is left behind
+	var remaining int64
+	for _, shard := range m.source {
+		remaining += shard.Count()
+	}
+	p.Moved = max(m.total-remaining, 0)
+	return p, true
+}
+
+// Range calls fn for every live entry, each shard is read from a single snapshot
+// While resharding, the snapshots are taken with every stripe held, a key being
+// moved is then in the shards being emptied or in the new ones; keys found in
+// both are passed once
+func (sc *ShardContainer) Range(fn func(key string, entry StorageEntry) bool) {
+	l, release := sc.pin()
+	defer release()
+
+	if l.from == nil {
+		for _, shard := range l.shards {
+			stop := false
+			shard.Range(func(key string, entry StorageEntry) bool {
+				stop = !fn(key, entry)
+				return !stop
+			})
+			if stop {
+				return
+			}
+		}
+		return
+	}
+
+	stores := append(append([]Store(nil), l.from...), l.shards...)
+	snapshots := make([]func(fn func(key string, entry StorageEntry) bool), len(stores))
+	for i := range sc.stripes {
+		sc.stripes[i].Lock()
+	}
+	for i, shard := range stores {
+		snapshots[i] = shard.Snapshot()
+	}
+	for i := range sc.stripes {
+		sc.stripes[i].Unlock()
+	}
+
+	seen := make(map[string]struct{})
+	stop := false
+	for _, rangeOver := range snapshots {
+		rangeOver(func(key string, entry StorageEntry) bool {
+			if _, dup := seen[key]; dup {
+				return true
+			}
+			seen[key] = struct{}{}
+			stop = !fn(key, entry)
+			return !stop
+		})
+		if stop {
+			return
+		}
+	}
+}
+
+func (sc *ShardContainer) stores() []Store {
+	l := sc.layout.Load()
+	return append(append([]Store(nil), l.shards...), l.from...)
+}
+
 func (sc *ShardContainer) StartGC(interval time.Duration) {
-	for _, shard := range sc.shards {
+	sc.gcInterval.Store(int64(interval))
+	for _, shard := range sc.stores() {
 		shard.StartGC(interval)
 	}
 }
 
 func (sc *ShardContainer) StopGC() {
-	for _, shard := range sc.shards {
+	for _, shard := range sc.stores() {
 		shard.StopGC()
 	}
 }
 
 func (sc *ShardContainer) Close() error {
-	for _, shard := range sc.shards {
+	for _, shard := range sc.stores() {
 		shard.StopGC()
 	}
 	return nil
 }
 
+// Collect removes the expired entries of every shard without waiting for the collectors
+func (sc *ShardContainer) Collect() {
+	for _, shard := range sc.stores() {
+		shard.Collect()
+	}
+}
+
+// GCStats sums the collector queues of every shard
+func (sc *ShardContainer) GCStats() gc.Stats {
+	var total gc.Stats
+	for _, shard := range sc.stores() {
+		st := shard.GCStats()
+		total.Collectors += st.Collectors
+		total.Running += st.Running
+		total.WheelEntries += st.WheelEntries
+		total.NearQueueSize += st.NearQueueSize
+		total.PendingDeletes += st.PendingDeletes
+	}
+	return total
+}
+
 func (sc *ShardContainer) Count() int64 {
 	var total int64
-	for _, shard := range sc.shards {
+	for _, shard := range sc.stores() {
 		total += shard.Count()
 	}
 	return total
@@ -108,7 +578,7 @@ func (sc *ShardContainer) Count() int64 {
 
 func (sc *ShardContainer) Usage() int64 {
 	var totalUsage int64
-	for _, shard := range sc.shards {
+	for _, shard := range sc.stores() {
 		totalUsage += shard.Usage()
 	}
 	return totalUsage
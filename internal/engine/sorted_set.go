@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"cmp"
+	"strings"
+)
+
+// sortedMemberOverhead approximates the two tree nodes and the score kept for a member
+const sortedMemberOverhead = 104
+
+// ScoredMember is a member of a sorted set with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// compareScored orders by score, then by member for equal scores
+func compareScored(a, b ScoredMember) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Member, b.Member)
+}
+
+// SortedSet is a value holding distinct members ordered by score
+// Members are indexed by name for lookups and by score for ranges, both trees
+// are persistent like the one of Set; the nil SortedSet is empty
+type SortedSet struct {
+	scores *tnode[string, float64]
+	order  *tnode[ScoredMember, struct{}]
+	size   int64
+}
+
+func (z *SortedSet) Kind() Kind { return KindSortedSet }
+
+// Size is the sum of the member lengths plus a per-member overhead
+func (z *SortedSet) Size() int64 {
+	if z == nil {
+		return 0
+	}
+	return z.size
+}
+
+func (z *SortedSet) Len() int {
+	if z == nil {
+		return 0
+	}
+	return z.order.count()
+}
+
+func (z *SortedSet) Score(member string) (float64, bool) {
+	if z == nil {
+		return 0, false
+	}
+	return tget(z.scores, member, strings.Compare)
+}
+
+// Rank returns the 0-based position of member by ascending score
+func (z *SortedSet) Rank(member string) (int, bool) {
+	score, ok := z.Score(member)
+	if !ok {
+		return 0, false
+	}
+	key := ScoredMember{Member: member, Score: score}
+	return tcount(z.order, func(k ScoredMember) bool { return compareScored(k, key) < 0 }), true
+}
+
+// Add returns z with member set to score, and whether member is new
+func (z *SortedSet) Add(member string, score float64) (*SortedSet, bool) {
+	next := &SortedSet{}
+	if z != nil {
+		*next = *z
+	}
+	old, exists := next.Score(member)
+	if exists {
+		if old == score {
+			return next, false
+		}
+		next.order = tdelete(next.order, ScoredMember{Member: member, Score: old}, compareScored)
+	} else {
+		next.size += int64(len(member)) + sortedMemberOverhead
+	}
+	next.scores, _ = tput(next.scores, member, score, strings.Compare)
+	next.order, _ = tput(next.order, ScoredMember{Member: member, Score: score}, struct{}{}, compareScored)
+	return next, !exists
+}
+
+// Remove returns z without members, and how many of them were removed
+func (z *SortedSet) Remove(members ...string) (*SortedSet, int) {
+	if z == nil {
+		return nil, 0
+	}
+	next := *z
+	removed := 0
+	for _, m := range members {
+		score, ok := next.Score(m)
+		if !ok {
+			continue
+		}
+		next.scores = tdelete(next.scores, m, strings.Compare)
+		next.order = tdelete(next.order, ScoredMember{Member: m, Score: score}, compareScored)
+		next.size -= int64(len(m)) + sortedMemberOverhead
+		removed++
+	}
+	return &next, removed
+}
+
+// Range returns the members of rank from to to excluded, both within [0, Len()]
+func (z *SortedSet) Range(from, to int) []ScoredMember {
+	if z == nil || from >= to {
+		return nil
+	}
+	out := make([]ScoredMember, 0, to-from)
+	twalk(z.order, from, to, func(k ScoredMember, _ struct{}) { out = append(out, k) })
+	return out
+}
+
+// ScoreSpan returns the rank range [from, to) of the members scored within lo and hi included
+func (z *SortedSet) ScoreSpan(lo, hi float64) (int, int) {
+	if z == nil {
+		return 0, 0
+	}
+	from := tcount(z.order, func(k ScoredMember) bool { return k.Score < lo })
+	to := tcount(z.order, func(k ScoredMember) bool { return k.Score <= hi })
+	return from, max(from, to)
+}
+
+// RemoveRange returns z without the members of rank from to to excluded, and how many they were
+func (z *SortedSet) RemoveRange(from, to int) (*SortedSet, int) {
+	if z == nil || from >= to {
+		return z, 0
+	}
+	head, rest := tsplitAt(z.order, from)
+	mid, tail := tsplitAt(rest, to-from)
+
+	next := &SortedSet{scores: z.scores, order: tmerge(head, tail), size: z.size}
+	twalk(mid, 0, mid.count(), func(k ScoredMember, _ struct{}) {
+		next.scores = tdelete(next.scores, k.Member, strings.Compare)
+		next.size -= int64(len(k.Member)) + sortedMemberOverhead
+	})
+	return next, mid.count()
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func members(ms []ScoredMember) []string {
+	out := make([]string, len(ms))
+	for i, m := range ms {
+		out[i] = m.Member
+	}
+	return out
+}
+
+func TestSortedSetOrder(t *testing.T) {
+	var z *SortedSet
+	z, _ = z.Add("c", 2)
+	z, _ = z.Add("b", 1)
+	z, _ = z.Add("a", 2)
+	z, _ = z.Add("d", 0.5)
+	if got := members(z.Range(0, z.Len())); !slices.Equal(got, []string{"d", "b", "a", "c"}) {
+		t.Fatalf("order = %v", got)
+	}
+
+	// A new score moves the member
+	moved, isNew := z.Add("d", 3)
+	if isNew || moved.Len() != 4 {
+		t.Errorf("Add of an existing member: new %v, len %d", isNew, moved.Len())
+	}
+	if rank, ok := moved.Rank("d"); !ok || rank != 3 {
+		t.Errorf("Rank(d) = %d, %v after the move", rank, ok)
+	}
+	if rank, _ := z.Rank("d"); rank != 0 {
+		t.Error("Add changed the previous version")
+	}
+	if score, ok := moved.Score("d"); !ok || score != 3 {
+		t.Errorf("Score(d) = %v, %v", score, ok)
+	}
+	if z.Size() != moved.Size() {
+		t.Errorf("Size changed from %d to %d with a score", z.Size(), moved.Size())
+	}
+}
+
+func TestSortedSetScoreRanges(t *testing.T) {
+	var z *SortedSet
+	for i, m := range []string{"a", "b", "c", "d", "e"} {
+		z, _ = z.Add(m, float64(i))
+	}
+
+	from, to := z.ScoreSpan(1, 3)
+	if got := members(z.Range(from, to)); !slices.Equal(got, []string{"b", "c", "d"}) {
+		t.Errorf("ScoreSpan(1, 3) = %v", got)
+	}
+	if from, to := z.ScoreSpan(3, 1); from != to {
+		t.Errorf("ScoreSpan(3, 1) = [%d, %d)", from, to)
+	}
+
+	next, n := z.RemoveRange(z.ScoreSpan(1, 3))
+	if n != 3 || !slices.Equal(members(next.Range(0, next.Len())), []string{"a", "e"}) {
+		t.Errorf("RemoveRange = %v, %d", members(next.Range(0, next.Len())), n)
+	}
+	if _, ok := next.Score("c"); ok {
+		t.Error("removed member still has a score")
+	}
+	if want := int64(2 * (1 + sortedMemberOverhead)); next.Size() != want {
+		t.Errorf("Size = %d, want %d", next.Size(), want)
+	}
+}
+
+func TestSortedSetMatchesSlice(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var z *SortedSet
+	ref := make(map[string]float64)
+	for range 3000 {
+		m := fmt.Sprintf("m%d", r.IntN(300))
+		switch r.IntN(4) {
+		case 0:
+			z, _ = z.Remove(m)
+			delete(ref, m)
+		case 1:
+			from := r.IntN(z.Len() + 1)
+			to := from + r.IntN(3)
+			var removed int
+			z, removed = z.RemoveRange(from, min(to, z.Len()))
+			for _, k := range sortedRef(ref)[from:min(to, len(ref))] {
+				delete(ref, k.Member)
+				removed--
+			}
+			if removed != 0 {
+				t.Fatalf("RemoveRange count off by %d", removed)
+			}
+		default:
+			score := float64(r.IntN(50))
+			z, _ = z.Add(m, score)
+			ref[m] = score
+		}
+	}
+
+	want := sortedRef(ref)
+	if got := z.Range(0, z.Len()); !slices.Equal(got, want) {
+		t.Fatalf("sorted set holds %d members, want %d", len(got), len(want))
+	}
+	for i, m := range want {
+		if rank, ok := z.Rank(m.Member); !ok || rank != i {
+			t.Fatalf("Rank(%s) = %d, %v, want %d", m.Member, rank, ok, i)
+		}
+	}
+}
+
+func sortedRef(ref map[string]float64) []ScoredMember {
+	out := make([]ScoredMember, 0, len(ref))
+	for m, s := range ref {
+		out = append(out, ScoredMember{Member: m, Score: s})
+	}
+	slices.SortFunc(out, func(a, b ScoredMember) int {
+		if c := cmp.Compare(a.Score, b.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Member, b.Member)
+	})
+	return out
+}
@@ -0,0 +1,507 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/json"
+	"key-value-store/internal/errs"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// streamEntryOverhead approximates the tree node and ID kept for an entry
+	streamEntryOverhead = 64
+	// streamPendingOverhead approximates the tree node and delivery record of a pending entry
+	streamPendingOverhead = 80
+	streamGroupOverhead   = 48
+)
+
+// StreamID identifies a stream entry by its append time in milliseconds and a
+// sequence number ordering the entries appended within the same millisecond
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID sorts after every ID
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// ParseStreamID reads an ID written as ms-seq, or ms alone for the first ID of
+// that millisecond
+func ParseStreamID(s string) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, errs.ErrInvalidStreamID
+	}
+	id := StreamID{Ms: ms}
+	if hasSeq {
+		if id.Seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return StreamID{}, errs.ErrInvalidStreamID
+		}
+	}
+	return id, nil
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Next returns the smallest ID after id, MaxStreamID has none and is returned
+func (id StreamID) Next() StreamID {
+	switch {
+	case id == MaxStreamID:
+		return id
+	case id.Seq == math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}
+	default:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}
+	}
+}
+
+func (id StreamID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+func (id *StreamID) UnmarshalText(data []byte) error {
+	v, err := ParseStreamID(string(data))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+func compareStreamIDs(a, b StreamID) int {
+	if c := cmp.Compare(a.Ms, b.Ms); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Seq, b.Seq)
+}
+
+// StreamEntry is a record of a stream, its fields are shared and must not be modified
+type StreamEntry struct {
+	ID     StreamID
+	Fields map[string][]byte
+}
+
+// PendingEntry is an entry delivered to a consumer of a group and not acknowledged yet
+type PendingEntry struct {
+	ID          StreamID  `json:"id"`
+	Consumer    string    `json:"consumer"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Deliveries  int       `json:"deliveries"`
+}
+
+// GroupInfo describes a consumer group
+type GroupInfo struct {
+	Name          string
+	LastDelivered StreamID
+	Pending       int
+}
+
+// streamGroup is a consumer group, never modified once stored like its stream
+type streamGroup struct {
+	last    StreamID // last entry delivered to the group
+	pending *tnode[StreamID, PendingEntry]
+}
+
+// Stream is an append-only log value
+// Entries are kept by ID in a persistent tree like the one of SortedSet, and
+// consumer groups track the entries they delivered until they are acknowledged
+// The nil Stream is empty
+type Stream struct {
+	entries *tnode[StreamID, map[string][]byte]
+	// last is the ID of the last entry added, kept when it is trimmed so
+	// IDs never repeat
+	last   StreamID
+	groups map[string]*streamGroup
+	size   int64
+}
+
+func (s *Stream) Kind() Kind { return KindStream }
+
+// Size is the sum of the field names and values plus per-entry and per-delivery overheads
+func (s *Stream) Size() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+// Len is 1 for a stream, with or without entries, and 0 for the empty value
+// Streams are kept once created, like the groups reading them
+func (s *Stream) Len() int {
+	if s == nil {
+		return 0
+	}
+	return 1
+}
+
+// Count returns the number of entries
+func (s *Stream) Count() int {
+	if s == nil {
+		return 0
+	}
+	return s.entries.count()
+}
+
+// LastID returns the ID of the last entry added, trimmed or not
+func (s *Stream) LastID() StreamID {
+	if s == nil {
+		return StreamID{}
+	}
+	return s.last
+}
+
+func (s *Stream) clone() *Stream {
+	next := &Stream{}
+	if s != nil {
+		*next = *s
+	}
+	return next
+}
+
+// Add appends an entry with fields stamped with now and returns its ID
+// IDs keep growing when entries share a millisecond or the clock goes back
+func (s *Stream) Add(now time.Time, fields map[string][]byte) (*Stream, StreamID) {
+	next := s.clone()
+	id := StreamID{Ms: uint64(max(now.UnixMilli(), 0))}
+	if compareStreamIDs(id, next.last) <= 0 {
+		id = next.last.Next()
+	}
+	next.entries, _ = tput(next.entries, id, maps.Clone(fields), compareStreamIDs)
+	next.last = id
+	next.size += entrySize(fields)
+	return next, id
+}
+
+// Range returns up to count entries from lo to hi included, all of them when
+// count is 0
+func (s *Stream) Range(lo, hi StreamID, count int) []StreamEntry {
+	if s == nil || compareStreamIDs(lo, hi) > 0 {
+		return nil
+	}
+	from := tcount(s.entries, func(id StreamID) bool { return compareStreamIDs(id, lo) < 0 })
+	var out []StreamEntry
+	tscan(s.entries, from, func(id StreamID, fields map[string][]byte) bool {
+		if compareStreamIDs(id, hi) > 0 || (count > 0 && len(out) == count) {
+			return false
+		}
+		out = append(out, StreamEntry{ID: id, Fields: fields})
+		return true
+	})
+	return out
+}
+
+// TrimLen removes the oldest entries beyond maxLen and returns how many
+func (s *Stream) TrimLen(maxLen int) (*Stream, int) {
+	n := s.Count() - max(maxLen, 0)
+	if n <= 0 {
+		return s, 0
+	}
+	removed, kept := tsplitAt(s.entries, n)
+	return s.drop(removed, kept), n
+}
+
+// TrimBefore removes the entries with an ID before minID and returns how many
+func (s *Stream) TrimBefore(minID StreamID) (*Stream, int) {
+	if s == nil {
+		return s, 0
+	}
+	removed, kept := tsplit(s.entries, minID, compareStreamIDs)
+	if removed == nil {
+		return s, 0
+	}
+	return s.drop(removed, kept), removed.count()
+}
+
+// drop replaces the entries of s with kept, the pending deliveries of the
+// removed entries go with them
+func (s *Stream) drop(removed, kept *tnode[StreamID, map[string][]byte]) *Stream {
+	next := s.clone()
+	next.entries = kept
+	twalk(removed, 0, removed.count(), func(_ StreamID, fields map[string][]byte) {
+		next.size -= entrySize(fields)
+	})
+	if len(next.groups) == 0 {
+		return next
+	}
+
+	// Every pending entry before the first one kept was removed
+	cut := next.last.Next()
+	tscan(kept, 0, func(id StreamID, _ map[string][]byte) bool {
+		cut = id
+		return false
+	})
+	groups := make(map[string]*streamGroup, len(next.groups))
+	for name, g := range next.groups {
+		gone, pending := tsplit(g.pending, cut, compareStreamIDs)
+		if gone != nil {
+			next.size -= pendingSize(gone)
+			g = &streamGroup{last: g.last, pending: pending}
+		}
+		groups[name] = g
+	}
+	next.groups = groups
+	return next
+}
+
+func (s *Stream) group(name string) (*streamGroup, bool) {
+	if s == nil {
+		return nil, false
+	}
+	g, ok := s.groups[name]
+	return g, ok
+}
+
+// withGroup returns s with group name replaced by g, or removed when g is nil
+func (s *Stream) withGroup(name string, g *streamGroup) *Stream {
+	next := s.clone()
+	next.groups = maps.Clone(next.groups)
+	if g == nil {
+		delete(next.groups, name)
+		return next
+	}
+	if next.groups == nil {
+		next.groups = make(map[string]*streamGroup)
+	}
+	next.groups[name] = g
+	return next
+}
+
+// CreateGroup adds a group delivering the entries after start
+func (s *Stream) CreateGroup(name string, start StreamID) (*Stream, error) {
+	if name == "" {
+		return s, errs.ErrInvalidGroup
+	}
+	if _, ok := s.group(name); ok {
+		return s, errs.ErrGroupExists
+	}
+	next := s.withGroup(name, &streamGroup{last: start})
+	next.size += int64(len(name)) + streamGroupOverhead
+	return next, nil
+}
+
+// DestroyGroup removes a group with its pending entries, and reports whether it existed
+func (s *Stream) DestroyGroup(name string) (*Stream, bool) {
+	g, ok := s.group(name)
+	if !ok {
+		return s, false
+	}
+	next := s.withGroup(name, nil)
+	next.size -= int64(len(name)) + streamGroupOverhead + pendingSize(g.pending)
+	return next, true
+}
+
+// Groups describes the consumer groups ordered by name
+func (s *Stream) Groups() []GroupInfo {
+	if s == nil {
+		return nil
+	}
+	out := make([]GroupInfo, 0, len(s.groups))
+	for name, g := range s.groups {
+		out = append(out, GroupInfo{Name: name, LastDelivered: g.last, Pending: g.pending.count()})
+	}
+	slices.SortFunc(out, func(a, b GroupInfo) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// ReadGroup delivers to consumer up to count entries the group did not deliver
+// yet, all of them when count is 0; they stay pending until acknowledged
+func (s *Stream) ReadGroup(group, consumer string, count int, now time.Time) (*Stream, []StreamEntry, error) {
+	if consumer == "" {
+		return s, nil, errs.ErrInvalidGroup
+	}
+	g, ok := s.group(group)
+	if !ok {
+		return s, nil, errs.ErrGroupNotFound
+	}
+	out := s.Range(g.last.Next(), MaxStreamID, count)
+	if len(out) == 0 {
+		return s, nil, nil
+	}
+
+	next := &streamGroup{last: out[len(out)-1].ID, pending: g.pending}
+	for _, e := range out {
+		next.pending, _ = tput(next.pending, e.ID, PendingEntry{ID: e.ID, Consumer: consumer, DeliveredAt: now, Deliveries: 1}, compareStreamIDs)
+	}
+	ns := s.withGroup(group, next)
+	ns.size += int64(len(out)) * (int64(len(consumer)) + streamPendingOverhead)
+	return ns, out, nil
+}
+
+// Ack removes ids from the pending entries of group and returns how many were pending
+func (s *Stream) Ack(group string, ids ...StreamID) (*Stream, int, error) {
+	g, ok := s.group(group)
+	if !ok {
+		return s, 0, errs.ErrGroupNotFound
+	}
+	next := &streamGroup{last: g.last, pending: g.pending}
+	acked := 0
+	var freed int64
+	for _, id := range ids {
+		p, ok := tget(next.pending, id, compareStreamIDs)
+		if !ok {
+			continue
+		}
+		next.pending = tdelete(next.pending, id, compareStreamIDs)
+		freed += int64(len(p.Consumer)) + streamPendingOverhead
+		acked++
+	}
+	if acked == 0 {
+		return s, 0, nil
+	}
+	ns := s.withGroup(group, next)
+	ns.size -= freed
+	return ns, acked, nil
+}
+
+// Pending returns up to count pending entries of group from start on in ID
+// order, all of them when count is 0, only those of consumer unless it is empty
+func (s *Stream) Pending(group, consumer string, start StreamID, count int) ([]PendingEntry, error) {
+	g, ok := s.group(group)
+	if !ok {
+		return nil, errs.ErrGroupNotFound
+	}
+	from := tcount(g.pending, func(id StreamID) bool { return compareStreamIDs(id, start) < 0 })
+	var out []PendingEntry
+	tscan(g.pending, from, func(_ StreamID, p PendingEntry) bool {
+		if consumer == "" || p.Consumer == consumer {
+			out = append(out, p)
+		}
+		return count == 0 || len(out) < count
+	})
+	return out, nil
+}
+
+// Claim hands over to consumer up to count entries of group pending for at
+// least minIdle, oldest first, and returns them
+// Claimed entries count one more delivery and are idle again from now
+func (s *Stream) Claim(group, consumer string, minIdle time.Duration, count int, now time.Time) (*Stream, []StreamEntry, error) {
+	if consumer == "" {
+		return s, nil, errs.ErrInvalidGroup
+	}
+	g, ok := s.group(group)
+	if !ok {
+		return s, nil, errs.ErrGroupNotFound
+	}
+
+	var claimed []PendingEntry
+	tscan(g.pending, 0, func(_ StreamID, p PendingEntry) bool {
+		if now.Sub(p.DeliveredAt) >= minIdle {
+			claimed = append(claimed, p)
+		}
+		return count == 0 || len(claimed) < count
+	})
+	if len(claimed) == 0 {
+		return s, nil, nil
+	}
+
+	next := &streamGroup{last: g.last, pending: g.pending}
+	out := make([]StreamEntry, 0, len(claimed))
+	var grown int64
+	for _, p := range claimed {
+		grown += int64(len(consumer) - len(p.Consumer))
+		p.Consumer, p.DeliveredAt = consumer, now
+		p.Deliveries++
+		next.pending, _ = tput(next.pending, p.ID, p, compareStreamIDs)
+
+		fields, _ := tget(s.entries, p.ID, compareStreamIDs)
+		out = append(out, StreamEntry{ID: p.ID, Fields: fields})
+	}
+	ns := s.withGroup(group, next)
+	ns.size += grown
+	return ns, out, nil
+}
+
+func entrySize(fields map[string][]byte) int64 {
+	size := int64(streamEntryOverhead)
+	for f, v := range fields {
+		size += int64(len(f) + len(v))
+	}
+	return size
+}
+
+func pendingSize(t *tnode[StreamID, PendingEntry]) int64 {
+	var size int64
+	twalk(t, 0, t.count(), func(_ StreamID, p PendingEntry) {
+		size += int64(len(p.Consumer)) + streamPendingOverhead
+	})
+	return size
+}
+
+// streamState is the encoded form of a stream, see Encode
+type streamState struct {
+	Last    StreamID           `json:"last_id"`
+	Entries []streamEntryState `json:"entries"`
+	Groups  []streamGroupState `json:"groups,omitempty"`
+}
+
+type streamEntryState struct {
+	ID     StreamID          `json:"id"`
+	Fields map[string][]byte `json:"fields"`
+}
+
+type streamGroupState struct {
+	Name          string         `json:"name"`
+	LastDelivered StreamID       `json:"last_delivered"`
+	Pending       []PendingEntry `json:"pending,omitempty"`
+}
+
+// Encode returns the whole state of the stream, groups and pending entries
+// included, as JSON read back by DecodeStream
+func (s *Stream) Encode() []byte {
+	state := streamState{Last: s.LastID(), Entries: []streamEntryState{}}
+	for _, e := range s.Range(StreamID{}, MaxStreamID, 0) {
+		state.Entries = append(state.Entries, streamEntryState{ID: e.ID, Fields: e.Fields})
+	}
+	for _, info := range s.Groups() {
+		g := s.groups[info.Name]
+		gs := streamGroupState{Name: info.Name, LastDelivered: g.last}
+		twalk(g.pending, 0, g.pending.count(), func(_ StreamID, p PendingEntry) {
+			gs.Pending = append(gs.Pending, p)
+		})
+		state.Groups = append(state.Groups, gs)
+	}
+	data, _ := json.Marshal(state)
+	return data
+}
+
+// DecodeStream rebuilds a stream from Encode
+// Entries must be in ID order and pending entries must refer to one of them
+func DecodeStream(data []byte) (*Stream, error) {
+	var state streamState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errs.ErrInvalidStream
+	}
+
+	s := &Stream{last: state.Last}
+	prev, first := StreamID{}, true
+	for _, e := range state.Entries {
+		if (!first && compareStreamIDs(e.ID, prev) <= 0) || compareStreamIDs(e.ID, state.Last) > 0 {
+			return nil, errs.ErrInvalidStream
+		}
+		s.entries, _ = tput(s.entries, e.ID, e.Fields, compareStreamIDs)
+		s.size += entrySize(e.Fields)
+		prev, first = e.ID, false
+	}
+
+	for _, gs := range state.Groups {
+		next, err := s.CreateGroup(gs.Name, gs.LastDelivered)
+		if err != nil {
+			return nil, errs.ErrInvalidStream
+		}
+		g := &streamGroup{last: gs.LastDelivered}
+		for _, p := range gs.Pending {
+			if _, ok := tget(s.entries, p.ID, compareStreamIDs); !ok || p.Consumer == "" {
+				return nil, errs.ErrInvalidStream
+			}
+			g.pending, _ = tput(g.pending, p.ID, p, compareStreamIDs)
+		}
+		s = next.withGroup(gs.Name, g)
+		s.size += pendingSize(g.pending)
+	}
+	return s, nil
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"errors"
+	"key-value-store/internal/errs"
+	"slices"
+	"testing"
+	"time"
+)
+
+var streamEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func entryIDs(entries []StreamEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.ID.String()
+	}
+	return out
+}
+
+func addEntries(s *Stream, now time.Time, n int) *Stream {
+	for range n {
+		s, _ = s.Add(now, map[string][]byte{"f": []byte("v")})
+	}
+	return s
+}
+
+func TestStreamIDsAndRange(t *testing.T) {
+	var s *Stream
+	s, a := s.Add(streamEpoch, map[string][]byte{"n": []byte("1")})
+	s, b := s.Add(streamEpoch, map[string][]byte{"n": []byte("2")})
+	// A clock going back still yields a later ID
+	s, c := s.Add(streamEpoch.Add(-time.Second), map[string][]byte{"n": []byte("3")})
+	s, d := s.Add(streamEpoch.Add(time.Second), nil)
+
+	ms := uint64(streamEpoch.UnixMilli())
+	want := []StreamID{{ms, 0}, {ms, 1}, {ms, 2}, {ms + 1000, 0}}
+	if got := []StreamID{a, b, c, d}; !slices.Equal(got, want) {
+		t.Fatalf("IDs %v, want %v", got, want)
+	}
+
+	if got := entryIDs(s.Range(b, MaxStreamID, 2)); !slices.Equal(got, []string{b.String(), c.String()}) {
+		t.Errorf("two entries from %v: %v", b, got)
+	}
+	if got := s.Range(StreamID{}, MaxStreamID, 0); len(got) != 4 || string(got[2].Fields["n"]) != "3" {
+		t.Errorf("full range %v", got)
+	}
+	if got := s.Range(d.Next(), MaxStreamID, 0); len(got) != 0 {
+		t.Errorf("entries after the last one: %v", got)
+	}
+
+	if id, err := ParseStreamID(c.String()); err != nil || id != c {
+		t.Errorf("ParseStreamID(%v) = %v, %v", c, id, err)
+	}
+	if id, err := ParseStreamID("42"); err != nil || id != (StreamID{Ms: 42}) {
+		t.Errorf("ParseStreamID(42) = %v, %v", id, err)
+	}
+	for _, bad := range []string{"", "-1", "1-", "x-1", "1-2-3"} {
+		if _, err := ParseStreamID(bad); !errors.Is(err, errs.ErrInvalidStreamID) {
+			t.Errorf("ParseStreamID(%q): %v", bad, err)
+		}
+	}
+}
+
+func TestStreamTrim(t *testing.T) {
+	s := addEntries(nil, streamEpoch, 3)
+	s = addEntries(s, streamEpoch.Add(time.Minute), 3)
+	full := s.Size()
+
+	s, removed := s.TrimLen(4)
+	if removed != 2 || s.Count() != 4 {
+		t.Fatalf("TrimLen(4) removed %d, %d left", removed, s.Count())
+	}
+	s, removed = s.TrimBefore(StreamID{Ms: uint64(streamEpoch.Add(time.Minute).UnixMilli())})
+	if removed != 1 || s.Count() != 3 {
+		t.Errorf("TrimBefore removed %d, %d left", removed, s.Count())
+	}
+
+	last := s.LastID()
+	s, _ = s.TrimLen(0)
+	if s.Count() != 0 || s.Len() != 1 || s.Size() != 0 || s.LastID() != last {
+		t.Errorf("trimmed to nothing: count %d, len %d, size %d of %d, last %v", s.Count(), s.Len(), s.Size(), full, s.LastID())
+	}
+	if _, id := s.Add(streamEpoch, nil); compareStreamIDs(id, last) <= 0 {
+		t.Errorf("ID %v after trimming does not follow %v", id, last)
+	}
+}
+
+func TestStreamGroups(t *testing.T) {
+	s := addEntries(nil, streamEpoch, 5)
+	s, err := s.CreateGroup("workers", StreamID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateGroup("workers", StreamID{}); !errors.Is(err, errs.ErrGroupExists) {
+		t.Errorf("second CreateGroup: %v", err)
+	}
+
+	s, first, _ := s.ReadGroup("workers", "a", 2, streamEpoch)
+	s, second, _ := s.ReadGroup("workers", "b", 0, streamEpoch.Add(time.Minute))
+	if len(first) != 2 || len(second) != 3 || second[0].ID != first[1].ID.Next() {
+		t.Fatalf("deliveries %v then %v", entryIDs(first), entryIDs(second))
+	}
+	if s2, none, _ := s.ReadGroup("workers", "a", 0, streamEpoch); len(none) != 0 || s2 != s {
+		t.Errorf("nothing left to deliver, got %v", entryIDs(none))
+	}
+
+	pending, _ := s.Pending("workers", "b", StreamID{}, 0)
+	if len(pending) != 3 || pending[0].Consumer != "b" {
+		t.Errorf("pending entries of b: %v", pending)
+	}
+
+	s, acked, _ := s.Ack("workers", first[0].ID, first[0].ID, second[0].ID)
+	if acked != 2 {
+		t.Errorf("acknowledged %d entries", acked)
+	}
+
+	// Only the entry of a idle for long enough is claimed
+	s, claimed, _ := s.Claim("workers", "c", 30*time.Second, 0, streamEpoch.Add(time.Minute))
+	if got := entryIDs(claimed); !slices.Equal(got, []string{first[1].ID.String()}) {
+		t.Errorf("claimed %v", got)
+	}
+	pending, _ = s.Pending("workers", "", StreamID{}, 1)
+	if len(pending) != 1 || pending[0].Consumer != "c" || pending[0].Deliveries != 2 {
+		t.Errorf("claimed entry %v", pending)
+	}
+
+	// Trimmed entries are no longer pending
+	s, _ = s.TrimLen(1)
+	if groups := s.Groups(); len(groups) != 1 || groups[0].Pending != 1 || groups[0].LastDelivered != s.LastID() {
+		t.Errorf("groups after trimming %+v", groups)
+	}
+
+	if _, _, err := s.ReadGroup("missing", "a", 0, streamEpoch); !errors.Is(err, errs.ErrGroupNotFound) {
+		t.Errorf("ReadGroup of a missing group: %v", err)
+	}
+	if _, _, err := s.ReadGroup("workers", "", 0, streamEpoch); !errors.Is(err, errs.ErrInvalidGroup) {
+		t.Errorf("ReadGroup without consumer: %v", err)
+	}
+	s, ok := s.DestroyGroup("workers")
+	if !ok || len(s.Groups()) != 0 || s.Size() != entrySize(map[string][]byte{"f": []byte("v")}) {
+		t.Errorf("DestroyGroup left %v, size %d", s.Groups(), s.Size())
+	}
+}
+
+func TestStreamEncodeRoundTrip(t *testing.T) {
+	s := addEntries(nil, streamEpoch, 4)
+	s, _ = s.CreateGroup("g", StreamID{})
+	s, _, _ = s.ReadGroup("g", "a", 3, streamEpoch)
+	s, _ = s.TrimLen(3)
+
+	got, err := DecodeStream(s.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Size() != s.Size() || got.LastID() != s.LastID() || got.Count() != 3 {
+		t.Errorf("decoded size %d, last %v, count %d; want %d, %v, 3", got.Size(), got.LastID(), got.Count(), s.Size(), s.LastID())
+	}
+	want, _ := s.Pending("g", "", StreamID{}, 0)
+	pending, _ := got.Pending("g", "", StreamID{}, 0)
+	if !slices.EqualFunc(pending, want, func(a, b PendingEntry) bool {
+		return a.ID == b.ID && a.Consumer == b.Consumer && a.DeliveredAt.Equal(b.DeliveredAt) && a.Deliveries == b.Deliveries
+	}) {
+		t.Errorf("pending %v, want %v", pending, want)
+	}
+
+	for _, bad := range []string{
+		`nope`,
+		`{"last_id":"1-0","entries":[{"id":"1-0"},{"id":"1-0"}]}`,
+		`{"last_id":"1-0","entries":[{"id":"2-0"}]}`,
+		`{"last_id":"1-0","entries":[],"groups":[{"name":"g","last_delivered":"0-0","pending":[{"id":"1-0","consumer":"a"}]}]}`,
+	} {
+		if _, err := DecodeStream([]byte(bad)); !errors.Is(err, errs.ErrInvalidStream) {
+			t.Errorf("DecodeStream(%s): %v", bad, err)
+		}
+	}
+}
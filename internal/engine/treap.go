@@ -0,0 +1,187 @@
+package engine
+
+import "math/rand/v2"
+
+// tnode is a node of a persistent treap ordered by key and heap ordered by prio
+// Nodes are never modified once linked, updates copy the path from the root to
+// the changed node and share every other subtree, older roots stay valid
+type tnode[K, V any] struct {
+	key         K
+	val         V
+	prio        uint32
+	size        int
+	left, right *tnode[K, V]
+}
+
+func (n *tnode[K, V]) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// with returns a copy of n with other children
+func (n *tnode[K, V]) with(left, right *tnode[K, V]) *tnode[K, V] {
+	c := *n
+	c.left, c.right = left, right
+	c.size = 1 + left.count() + right.count()
+	return &c
+}
+
+func tget[K, V any](t *tnode[K, V], key K, cmp func(K, K) int) (V, bool) {
+	for t != nil {
+		switch c := cmp(key, t.key); {
+		case c < 0:
+			t = t.left
+		case c > 0:
+			t = t.right
+		default:
+			return t.val, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// tput returns t with key set to val, and whether key is new
+func tput[K, V any](t *tnode[K, V], key K, val V, cmp func(K, K) int) (*tnode[K, V], bool) {
+	if _, ok := tget(t, key, cmp); ok {
+		return treplace(t, key, val, cmp), false
+	}
+	return tinsert(t, &tnode[K, V]{key: key, val: val, prio: rand.Uint32(), size: 1}, cmp), true
+}
+
+func treplace[K, V any](t *tnode[K, V], key K, val V, cmp func(K, K) int) *tnode[K, V] {
+	switch c := cmp(key, t.key); {
+	case c < 0:
+		return t.with(treplace(t.left, key, val, cmp), t.right)
+	case c > 0:
+		return t.with(t.left, treplace(t.right, key, val, cmp))
+	default:
+		n := t.with(t.left, t.right)
+		n.val = val
+		return n
+	}
+}
+
+func tinsert[K, V any](t, n *tnode[K, V], cmp func(K, K) int) *tnode[K, V] {
+	if t == nil {
+		return n
+	}
+	if n.prio > t.prio {
+		l, r := tsplit(t, n.key, cmp)
+		return n.with(l, r)
+	}
+	if cmp(n.key, t.key) < 0 {
+		return t.with(tinsert(t.left, n, cmp), t.right)
+	}
+	return t.with(t.left, tinsert(t.right, n, cmp))
+}
+
+// tdelete returns t without key, t itself when key is missing
+func tdelete[K, V any](t *tnode[K, V], key K, cmp func(K, K) int) *tnode[K, V] {
+	if _, ok := tget(t, key, cmp); !ok {
+		return t
+	}
+	return tremove(t, key, cmp)
+}
+
+func tremove[K, V any](t *tnode[K, V], key K, cmp func(K, K) int) *tnode[K, V] {
+	switch c := cmp(key, t.key); {
+	case c < 0:
+		return t.with(tremove(t.left, key, cmp), t.right)
+	case c > 0:
+		return t.with(t.left, tremove(t.right, key, cmp))
+	default:
+		return tmerge(t.left, t.right)
+	}
+}
+
+// tsplit splits t into the keys before key and the others
+func tsplit[K, V any](t *tnode[K, V], key K, cmp func(K, K) int) (*tnode[K, V], *tnode[K, V]) {
+	if t == nil {
+		return nil, nil
+	}
+	if cmp(t.key, key) < 0 {
+		l, r := tsplit(t.right, key, cmp)
+		return t.with(t.left, l), r
+	}
+	l, r := tsplit(t.left, key, cmp)
+	return l, t.with(r, t.right)
+}
+
+// tsplitAt splits t into its first i nodes and the others
+func tsplitAt[K, V any](t *tnode[K, V], i int) (*tnode[K, V], *tnode[K, V]) {
+	if t == nil {
+		return nil, nil
+	}
+	left := t.left.count()
+	if i <= left {
+		l, r := tsplitAt(t.left, i)
+		return l, t.with(r, t.right)
+	}
+	l, r := tsplitAt(t.right, i-left-1)
+	return t.with(t.left, l), r
+}
+
+// tmerge joins two treaps, every key of a sorts before the keys of b
+func tmerge[K, V any](a, b *tnode[K, V]) *tnode[K, V] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		return a.with(a.left, tmerge(a.right, b))
+	}
+	return b.with(tmerge(a, b.left), b.right)
+}
+
+// tcount returns how many leading keys satisfy before, which must hold for a
+// prefix of the keys only
+func tcount[K, V any](t *tnode[K, V], before func(K) bool) int {
+	n := 0
+	for t != nil {
+		if before(t.key) {
+			n += t.left.count() + 1
+			t = t.right
+		} else {
+			t = t.left
+		}
+	}
+	return n
+}
+
+// twalk calls fn for the nodes of rank from to to excluded, in order
+func twalk[K, V any](t *tnode[K, V], from, to int, fn func(K, V)) {
+	if t == nil || from >= to {
+		return
+	}
+	left := t.left.count()
+	if from < left {
+		twalk(t.left, from, min(to, left), fn)
+	}
+	if from <= left && left < to {
+		fn(t.key, t.val)
+	}
+	if to > left+1 {
+		twalk(t.right, max(from-left-1, 0), to-left-1, fn)
+	}
+}
+
+// tscan calls fn for the nodes of rank from onwards, in order, until fn
+// returns false, and reports whether it went through to the end
+func tscan[K, V any](t *tnode[K, V], from int, fn func(K, V) bool) bool {
+	if t == nil {
+		return true
+	}
+	left := t.left.count()
+	if from < left && !tscan(t.left, from, fn) {
+		return false
+	}
+	if from <= left && !fn(t.key, t.val) {
+		return false
+	}
+	return tscan(t.right, max(from-left-1, 0), fn)
+}
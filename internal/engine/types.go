@@ -1,17 +1,32 @@
 package engine
 
-import "time"
+import (
+	"key-value-store/internal/gc"
+	"time"
+)
 
 type Store interface {
 	Set(key string, entry StorageEntry)
 	Get(key string) (StorageEntry, bool)
 	Delete(key string)
 	Exists(key string) bool
+	Take(key string) (StorageEntry, bool)
+	Update(key string, fn UpdateFunc) error
 	Keys() []string
+	Range(fn func(key string, entry StorageEntry) bool)
+	// Snapshot captures the entries now, the returned func ranges over them like Range
+	Snapshot() func(fn func(key string, entry StorageEntry) bool)
+	Clone() Store
+	Flush() int64
+	GCStats() gc.Stats
 	StartGC(interval time.Duration)
 	StopGC()
+	Collect()
 	Usage() int64
 	Count() int64
+	AddIndex(def IndexDef) error
+	DropIndex(name string)
+	Lookup(name string, q IndexQuery) ([]IndexHit, error)
 }
 type StorageEntry struct {
 	Key          string
@@ -23,11 +38,78 @@ type StorageEntry struct {
 	SingleRead   bool
 	AccessCount  int32
 	LastAccess   int64
+	Data         Data // nil for plain values
 }
 
-func (e StorageEntry) IsExpired() bool {
+// Kind is the type of value held by an entry
+type Kind uint8
+
+const (
+	KindString Kind = iota
+	KindHash
+	KindList
+	KindSet
+	KindSortedSet
+	KindJSON
+	KindStream
+	KindHyperLogLog
+	KindBloom
+	KindLock
+	KindRateLimiter
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindHash:
+		return "hash"
+	case KindList:
+		return "list"
+	case KindSet:
+		return "set"
+	case KindSortedSet:
+		return "sorted set"
+	case KindJSON:
+		return "JSON"
+	case KindStream:
+		return "stream"
+	case KindHyperLogLog:
+		return "HyperLogLog"
+	case KindBloom:
+		return "Bloom filter"
+	case KindLock:
+		return "lock"
+	case KindRateLimiter:
+		return "rate limiter"
+	default:
+		return "unknown"
+	}
+}
+
+// Data is a structured value stored in place of Value
+// Implementations are immutable once stored, writers build a modified copy
+type Data interface {
+	Kind() Kind
+	// Size is the number of bytes accounted for the value
+	Size() int64
+}
+
+// UpdateFunc computes the next entry of a key from its current one
+// found is false when the key is missing, expired or consumed
+// Returning remove deletes the key, an error leaves it untouched
+type UpdateFunc func(cur StorageEntry, found bool) (next StorageEntry, remove bool, err error)
+
+func (e *StorageEntry) Kind() Kind {
+	if e.Data == nil {
+		return KindString
+	}
+	return e.Data.Kind()
+}
+
+func (e *StorageEntry) IsExpired(now time.Time) bool {
 	if e.ExpiresAt.IsZero() {
 		return false
 	}
-	return time.Now().After(e.ExpiresAt)
+	return now.After(e.ExpiresAt)
 }
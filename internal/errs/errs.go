@@ -1,6 +1,9 @@
 package errs
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrKeyNotFound      = errors.New("key not found")
@@ -9,14 +12,83 @@ var (
 	ErrKeyExpired       = errors.New("key expired")
 	ErrMemoryLimit      = errors.New("memory limit exceeded")
 	ErrDeletion         = errors.New("deletion error")
+	ErrKeyTooLong       = errors.New("key too long")
+	ErrValueTooLarge    = errors.New("value too large")
+	ErrTTLExceedsLimit  = errors.New("ttl exceeds bucket limit")
+	ErrKeyLimit         = errors.New("bucket key limit reached")
+	ErrSingleReadDenied = errors.New("single-read keys not allowed in bucket")
+	ErrWrongType        = errors.New("operation against a key holding the wrong kind of value")
+	ErrFieldNotFound    = errors.New("field not found")
+	ErrMemberNotFound   = errors.New("member not found")
+	ErrInvalidScore     = errors.New("score is not a finite number")
+	ErrNotInteger       = errors.New("value is not an integer or out of range")
+	ErrInvalidJSON      = errors.New("invalid JSON")
+	ErrInvalidPath      = errors.New("invalid path")
+	ErrPathNotFound     = errors.New("path not found")
+	ErrPathType         = errors.New("path holds a different JSON type")
+	ErrNumberOverflow   = errors.New("number out of range")
+	ErrIndexNotFound    = errors.New("index not found")
+	ErrIndexExists      = errors.New("index already exists")
+	ErrInvalidIndex     = errors.New("invalid index name")
+	ErrIndexValue       = errors.New("index values are JSON scalars")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrWaitTimeout      = errors.New("timed out waiting")
+	ErrInvalidStreamID  = errors.New("invalid stream ID")
+	ErrInvalidStream    = errors.New("invalid stream state")
+	ErrGroupNotFound    = errors.New("consumer group not found")
+	ErrGroupExists      = errors.New("consumer group already exists")
+	ErrInvalidGroup     = errors.New("invalid consumer group or consumer name")
+	ErrInvalidFilter    = errors.New("invalid filter capacity or error rate")
+	ErrFilterExists     = errors.New("filter already exists")
+	ErrLockHeld         = errors.New("lock held by another owner")
+	ErrLockNotOwned     = errors.New("lock not held by this owner")
+	ErrInvalidLock      = errors.New("invalid lock owner or lease")
+	ErrInvalidRateLimit = errors.New("invalid rate limit or cost")
+	ErrReplayMismatch   = errors.New("idempotency key used for a different request")
+	ErrReplayPending    = errors.New("request with this idempotency key in progress")
+	ErrNotExportable    = errors.New("value kind not supported by the archive format")
 )
 
+// WrongTypeError is returned by an operation against a key holding another kind
+// of value, it matches ErrWrongType
+type WrongTypeError struct {
+	Want, Have string
+}
+
+// WrongType reports an operation expecting want against a key holding have
+func WrongType(want, have fmt.Stringer) error {
+	return &WrongTypeError{Want: want.String(), Have: have.String()}
+}
+
+func (e *WrongTypeError) Error() string {
+	return fmt.Sprintf("key holds a %s value, not a %s", e.Have, e.Want)
+}
+
+func (e *WrongTypeError) Is(target error) bool { return target == ErrWrongType }
+
 var (
 	ErrBucketNotFound      = errors.New("bucket not found")
 	ErrBucketAlreadyExists = errors.New("bucket already exists")
 	ErrInvalidBucketName   = errors.New("invalid bucket name")
 	ErrUnauthorized        = errors.New("unauthorized")
 	ErrCannotDeleteDefault = errors.New("cannot delete default bucket")
+	ErrCannotRenameDefault = errors.New("cannot rename default bucket")
+	ErrInvalidSettings     = errors.New("invalid bucket settings")
+	ErrInvalidShardCount   = errors.New("invalid shard count")
+	ErrReshardInProgress   = errors.New("resharding already in progress")
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+	ErrActiveSigningKey   = errors.New("cannot remove active signing key")
+)
+
+var (
+	ErrListenerStart      = errors.New("listener failed to start")
+	ErrListenerFailed     = errors.New("listener stopped unexpectedly")
+	ErrShutdownIncomplete = errors.New("shutdown incomplete")
+	ErrInvalidConfig      = errors.New("invalid configuration")
 )
 
 var (
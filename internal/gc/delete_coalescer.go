@@ -1,7 +1,9 @@
 package gc
 
 import (
+	"key-value-store/internal/metrics"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,28 +12,26 @@ type DeleteCoalescer struct {
 	pending      map[string]struct{}
 	deleteBatchF func(keys []string)
 	stopCh       chan struct{}
+	doneCh       chan struct{}
+	started      atomic.Bool
+	size         atomic.Int64 // len(pending), readable without the lock
 	flushMs      int64
 }
 
 func NewDeleteCoalescer(deleteBatch func(keys []string), flushMs int64) *DeleteCoalescer {
-	if flushMs < 5 {
-		flushMs = 5
-	}
-	if flushMs > 50 {
-		flushMs = 50
-	}
-
 	return &DeleteCoalescer{
 		pending:      make(map[string]struct{}),
 		deleteBatchF: deleteBatch,
 		stopCh:       make(chan struct{}),
-		flushMs:      flushMs,
+		doneCh:       make(chan struct{}),
+		flushMs:      clampFlushMs(flushMs),
 	}
 }
 
 func (dc *DeleteCoalescer) Enqueue(key string) {
 	dc.mu.Lock()
 	dc.pending[key] = struct{}{}
+	dc.size.Store(int64(len(dc.pending)))
 	dc.mu.Unlock()
 }
 
@@ -44,17 +44,33 @@ func (dc *DeleteCoalescer) EnqueueBatch(keys []string) {
 	for _, k := range keys {
 		dc.pending[k] = struct{}{}
 	}
+	dc.size.Store(int64(len(dc.pending)))
+	dc.mu.Unlock()
+}
+
+func (dc *DeleteCoalescer) Len() int {
+	return int(dc.size.Load())
+}
+
+// Reset drops the pending deletes
+func (dc *DeleteCoalescer) Reset() {
+	dc.mu.Lock()
+	dc.pending = make(map[string]struct{})
+	dc.size.Store(0)
 	dc.mu.Unlock()
 }
 
 func (dc *DeleteCoalescer) Start() {
+	dc.started.Store(true)
 	go func() {
+		defer close(dc.doneCh)
 		ticker := time.NewTicker(time.Duration(dc.flushMs) * time.Millisecond)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
+				retick(ticker, &dc.flushMs, func(p Params) int64 { return clampFlushMs(p.FlushMs) })
 				dc.flush()
 			case <-dc.stopCh:
 				dc.flush()
@@ -64,6 +80,10 @@ func (dc *DeleteCoalescer) Start() {
 	}()
 }
 
+func clampFlushMs(ms int64) int64 {
+	return min(max(ms, 5), 50)
+}
+
 func (dc *DeleteCoalescer) flush() {
 	dc.mu.Lock()
 	if len(dc.pending) == 0 {
@@ -76,13 +96,21 @@ func (dc *DeleteCoalescer) flush() {
 		keys = append(keys, k)
 	}
 	dc.pending = make(map[string]struct{})
+	dc.size.Store(0)
 	dc.mu.Unlock()
 
+	metrics.GCFlushBatchSize.Observe(float64(len(keys)))
 	if dc.deleteBatchF != nil {
 		dc.deleteBatchF(keys)
 	}
 }
 
+// Stop flushes the pending deletes and waits for the flush to complete
 func (dc *DeleteCoalescer) Stop() {
 	close(dc.stopCh)
+	if dc.started.Load() {
+		<-dc.doneCh
+		return
+	}
+	dc.flush()
 }
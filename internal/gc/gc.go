@@ -1,7 +1,10 @@
 package gc
 
 import (
+	"key-value-store/internal/clock"
+	"key-value-store/internal/metrics"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,37 +14,63 @@ const (
 	defaultNearWindowMs = 2000
 	defaultCoalescerMs  = 10
 	defaultNearTickerMs = 50
+	defaultWorkers      = 4
 )
 
 type GarbageCollector struct {
-	wheel     *HashedWheel
+	wheel     *TimingWheel
 	nearQueue *NearQueue
 	coalescer *DeleteCoalescer
 	stopCh    chan struct{}
+	doneCh    chan struct{}
 	startOnce sync.Once
 	stopOnce  sync.Once
+	running   atomic.Bool
 	tickMs    int64
+	sched     *Scheduler
+	worker    *worker // set by sched
 }
 
-func NewGarbageCollector(deleteBatch func(keys []string)) *GarbageCollector {
+// Stats is a point-in-time view of the collector queues
+// Stats of several collectors can be summed, Collectors and Running count them
+type Stats struct {
+	Collectors     int
+	Running        int
+	WheelEntries   int
+	NearQueueSize  int
+	PendingDeletes int
+}
+
+// NewGarbageCollector creates a collector with the current Params
+// Expirations are due when clk reaches them
+func NewGarbageCollector(deleteBatch func(keys []string), clk clock.Clock) *GarbageCollector {
+	p := CurrentParams()
+	wheel := NewTimingWheel(p.WheelSlots, p.WheelTickMs, clk)
 	return &GarbageCollector{
-		wheel:     NewHashedWheel(defaultWheelSlots, defaultWheelTickMs),
-		nearQueue: NewNearQueue(defaultNearWindowMs),
-		coalescer: NewDeleteCoalescer(deleteBatch, defaultCoalescerMs),
+		wheel:     wheel,
+		nearQueue: NewNearQueue(p.NearWindowMs, clk),
+		coalescer: NewDeleteCoalescer(deleteBatch, p.FlushMs),
 		stopCh:    make(chan struct{}),
-		tickMs:    defaultWheelTickMs,
+		doneCh:    make(chan struct{}),
+		tickMs:    wheel.tickMs,
 	}
 }
 
-func (gc *GarbageCollector) Schedule(key string, ttl int64, createdAt time.Time) {
-	if ttl <= 0 {
+// Schedule expires key at expiresAt, replacing its previous expiration
+// A zero expiresAt only cancels it
+func (gc *GarbageCollector) Schedule(key string, expiresAt time.Time) {
+	if expiresAt.IsZero() {
 		gc.Cancel(key)
 		return
 	}
 
-	expireAt := createdAt.Add(time.Duration(ttl)).UnixNano()
+	expireAt := expiresAt.UnixNano()
 
-	if !gc.nearQueue.Add(key, expireAt) {
+	// A key lives in one of the two, a previous expiration may sit in the other
+	if gc.nearQueue.Add(key, expireAt) {
+		gc.wheel.Remove(key)
+	} else {
+		gc.nearQueue.Remove(key)
 		gc.wheel.Add(key, expireAt)
 	}
 }
@@ -55,35 +84,93 @@ func (gc *GarbageCollector) ScheduleDelete(key string) {
 	gc.coalescer.Enqueue(key)
 }
 
+func (gc *GarbageCollector) Stats() Stats {
+	var running int
+	if gc.running.Load() {
+		running = 1
+	}
+	return Stats{
+		Collectors:     1,
+		Running:        running,
+		WheelEntries:   gc.wheel.Len(),
+		NearQueueSize:  gc.nearQueue.Len(),
+		PendingDeletes: gc.coalescer.Len(),
+	}
+}
+
+// Reset forgets every scheduled expiration and pending delete
+func (gc *GarbageCollector) Reset() {
+	gc.nearQueue.Reset()
+	gc.wheel.Reset()
+	gc.coalescer.Reset()
+}
+
+// Start hands the collector to the shared scheduler, or starts its own
+// goroutines when Params.Workers is 0
 func (gc *GarbageCollector) Start(interval time.Duration) {
+	gc.startOn(sharedScheduler())
+}
+
+// startOn registers the collector with s, a nil s starts its own goroutines
+func (gc *GarbageCollector) startOn(s *Scheduler) {
 	gc.startOnce.Do(func() {
+		gc.running.Store(true)
+		if s != nil {
+			gc.sched = s
+			s.add(gc)
+			return
+		}
 		gc.coalescer.Start()
-
-		go func() {
-			nearTicker := time.NewTicker(defaultNearTickerMs * time.Millisecond)
-			wheelTicker := time.NewTicker(time.Duration(gc.tickMs) * time.Millisecond)
-			defer nearTicker.Stop()
-			defer wheelTicker.Stop()
-
-			for {
-				select {
-				case <-nearTicker.C:
-					expired := gc.nearQueue.DrainExpired()
-					gc.coalescer.EnqueueBatch(expired)
-				case <-wheelTicker.C:
-					expired := gc.wheel.Advance()
-					gc.coalescer.EnqueueBatch(expired)
-				case <-gc.stopCh:
-					return
-				}
-			}
-		}()
+		go gc.run()
 	})
 }
 
+func (gc *GarbageCollector) run() {
+	defer close(gc.doneCh)
+	nearMs := max(CurrentParams().NearTickMs, 1)
+	nearTicker := time.NewTicker(time.Duration(nearMs) * time.Millisecond)
+	wheelTicker := time.NewTicker(time.Duration(gc.tickMs) * time.Millisecond)
+	defer nearTicker.Stop()
+	defer wheelTicker.Stop()
+
+	for {
+		select {
+		case <-nearTicker.C:
+			retick(nearTicker, &nearMs, func(p Params) int64 { return p.NearTickMs })
+			gc.enqueueExpired(gc.nearQueue.DrainExpired())
+		case <-wheelTicker.C:
+			gc.enqueueExpired(gc.wheel.Advance())
+		case <-gc.stopCh:
+			return
+		}
+	}
+}
+
+// Stop ends the collector goroutines and applies the pending deletes before returning
 func (gc *GarbageCollector) Stop() {
 	gc.stopOnce.Do(func() {
 		close(gc.stopCh)
+		if gc.running.Swap(false) {
+			// The loop must be gone before the last flush, it still feeds the coalescer
+			if gc.sched != nil {
+				gc.sched.remove(gc)
+			} else {
+				<-gc.doneCh
+			}
+		}
 		gc.coalescer.Stop()
 	})
 }
+
+// Collect hands the expirations due now to the delete callback and waits for it
+// The collector does the same on its own, Collect lets callers not wait for it
+func (gc *GarbageCollector) Collect() {
+	gc.enqueueExpired(gc.nearQueue.DrainExpired())
+	gc.enqueueExpired(gc.wheel.Advance())
+	gc.coalescer.flush()
+}
+
+func (gc *GarbageCollector) enqueueExpired(keys []string) {
+	metrics.GCExpiredKeys.Add(uint64(len(keys)))
+	gc.coalescer.EnqueueBatch(keys)
+}
@@ -0,0 +1,155 @@
+package gc
+
+import (
+	"fmt"
+	"key-value-store/internal/clock"
+	"slices"
+	"testing"
+	"time"
+)
+
+// recorder collects the keys handed to the delete callback
+type recorder struct{ deleted []string }
+
+func (r *recorder) deleteBatch(keys []string) { r.deleted = append(r.deleted, keys...) }
+
+// take returns the sorted deleted keys and forgets them
+func (r *recorder) take() []string {
+	out := r.deleted
+	r.deleted = nil
+	slices.Sort(out)
+	return out
+}
+
+func newTestCollector() (*GarbageCollector, *clock.Fake, *recorder) {
+	clk := clock.NewFake(testEpoch)
+	r := &recorder{}
+	return NewGarbageCollector(r.deleteBatch, clk), clk, r
+}
+
+func TestCollectorExpiresNearAndFar(t *testing.T) {
+	gc, clk, r := newTestCollector()
+	created := clk.Now()
+
+	gc.Schedule("near", created.Add(time.Second))
+	gc.Schedule("far", created.Add(time.Hour))
+	if st := gc.Stats(); st.NearQueueSize != 1 || st.WheelEntries != 1 {
+		t.Fatalf("near queue %d, wheel %d, want 1 and 1", st.NearQueueSize, st.WheelEntries)
+	}
+
+	// Expired means strictly after the expiration
+	clk.Set(created.Add(time.Second))
+	gc.Collect()
+	if got := r.take(); len(got) != 0 {
+		t.Fatalf("deleted %v at the expiration instant", got)
+	}
+
+	clk.Advance(time.Nanosecond)
+	gc.Collect()
+	if got := r.take(); !slices.Equal(got, []string{"near"}) {
+		t.Fatalf("deleted %v after 1 s, want [near]", got)
+	}
+
+	clk.Set(created.Add(time.Hour - time.Second))
+	gc.Collect()
+	if got := r.take(); len(got) != 0 {
+		t.Fatalf("deleted %v before the expiration", got)
+	}
+
+	clk.Set(created.Add(time.Hour + time.Second))
+	gc.Collect()
+	if got := r.take(); !slices.Equal(got, []string{"far"}) {
+		t.Fatalf("deleted %v after 1 h, want [far]", got)
+	}
+	if st := gc.Stats(); st.NearQueueSize != 0 || st.WheelEntries != 0 || st.PendingDeletes != 0 {
+		t.Errorf("queues not empty after every key expired: %+v", st)
+	}
+}
+
+func TestCollectorRescheduleKeepsOneExpiration(t *testing.T) {
+	gc, clk, r := newTestCollector()
+
+	gc.Schedule("k", clk.Now().Add(time.Hour))
+	gc.Schedule("k", clk.Now().Add(time.Second))
+	if st := gc.Stats(); st.NearQueueSize != 1 || st.WheelEntries != 0 {
+		t.Fatalf("moved to the near queue: near queue %d, wheel %d", st.NearQueueSize, st.WheelEntries)
+	}
+	gc.Schedule("k", clk.Now().Add(time.Hour))
+	if st := gc.Stats(); st.NearQueueSize != 0 || st.WheelEntries != 1 {
+		t.Fatalf("moved to the wheel: near queue %d, wheel %d", st.NearQueueSize, st.WheelEntries)
+	}
+
+	clk.Advance(2 * time.Second)
+	gc.Collect()
+	if got := r.take(); len(got) != 0 {
+		t.Fatalf("deleted %v by a replaced expiration", got)
+	}
+
+	gc.Schedule("k", time.Time{})
+	clk.Advance(2 * time.Hour)
+	gc.Collect()
+	if got := r.take(); len(got) != 0 {
+		t.Errorf("deleted %v after the TTL was removed", got)
+	}
+}
+
+func TestCollectorCancelAndReset(t *testing.T) {
+	gc, clk, r := newTestCollector()
+
+	gc.Schedule("cancelled", clk.Now().Add(time.Second))
+	gc.Schedule("far", clk.Now().Add(10*time.Minute))
+	gc.Cancel("cancelled")
+	gc.Schedule("reset", clk.Now().Add(time.Second))
+	gc.ScheduleDelete("pending")
+	gc.Reset()
+	gc.Schedule("kept", clk.Now().Add(time.Second))
+
+	clk.Advance(time.Hour)
+	gc.Collect()
+	if got := r.take(); !slices.Equal(got, []string{"kept"}) {
+		t.Errorf("deleted %v, want [kept]", got)
+	}
+}
+
+func TestCollectorStopAppliesPendingDeletes(t *testing.T) {
+	gc, _, r := newTestCollector()
+
+	gc.Start(time.Second)
+	gc.ScheduleDelete("a")
+	gc.ScheduleDelete("b")
+	gc.Stop()
+	if got := r.take(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("deleted %v on Stop, want [a b]", got)
+	}
+	if st := gc.Stats(); st.Running != 0 {
+		t.Errorf("Running = %d after Stop", st.Running)
+	}
+}
+
+func TestNearQueueRemoveAfterReorder(t *testing.T) {
+	clk := clock.NewFake(testEpoch)
+	nq := NewNearQueue(defaultNearWindowMs, clk)
+	base := clk.Now().UnixNano()
+
+	// Pushes and removals move entries around the heap, the removed ones must never come out
+	removed := make(map[string]bool)
+	for i := range 200 {
+		key := fmt.Sprint(i)
+		nq.Add(key, base+int64((i*7919)%1000)*int64(time.Millisecond))
+		if i%3 == 0 {
+			nq.Remove(key)
+			removed[key] = true
+		}
+	}
+
+	clk.Advance(time.Second)
+	got := nq.DrainExpired()
+	for _, k := range got {
+		if removed[k] {
+			t.Fatalf("removed key %s expired", k)
+		}
+	}
+	if want := 200 - len(removed); len(got) != want || nq.Len() != 0 {
+		t.Errorf("drained %d keys, %d left, want %d and 0", len(got), nq.Len(), want)
+	}
+}
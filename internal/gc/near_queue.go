@@ -2,8 +2,9 @@ package gc
 
 import (
 	"container/heap"
-	"key-value-store/internal/util"
+	"key-value-store/internal/clock"
 	"sync"
+	"sync/atomic"
 )
 
 type nearEntry struct {
@@ -42,15 +43,18 @@ func (h *nearHeap) Pop() interface{} {
 type NearQueue struct {
 	mu       sync.Mutex
 	heap     nearHeap
-	index    map[string]int
+	index    map[string]*nearEntry
 	windowMs int64
+	size     atomic.Int64 // len(index), readable without the lock
+	clock    clock.Clock
 }
 
-func NewNearQueue(windowMs int64) *NearQueue {
+func NewNearQueue(windowMs int64, clk clock.Clock) *NearQueue {
 	nq := &NearQueue{
 		heap:     make(nearHeap, 0),
-		index:    make(map[string]int),
+		index:    make(map[string]*nearEntry),
 		windowMs: windowMs,
+		clock:    clk,
 	}
 	heap.Init(&nq.heap)
 	return nq
@@ -60,19 +64,19 @@ func (nq *NearQueue) Add(key string, expireAt int64) bool {
 	nq.mu.Lock()
 	defer nq.mu.Unlock()
 
-	now := util.CachedNow() / 1e6
+	now := nq.clock.Now().UnixMilli()
 	if expireAt/1e6-now > nq.windowMs {
 		return false
 	}
 
-	if idx, exists := nq.index[key]; exists {
-		e := nq.heap[idx]
+	if e, exists := nq.index[key]; exists {
 		e.expireAt = expireAt
 		heap.Fix(&nq.heap, e.index)
 	} else {
 		e := &nearEntry{key: key, expireAt: expireAt}
 		heap.Push(&nq.heap, e)
-		nq.index[key] = e.index
+		nq.index[key] = e
+		nq.size.Store(int64(len(nq.index)))
 	}
 	return true
 }
@@ -81,28 +85,45 @@ func (nq *NearQueue) Remove(key string) {
 	nq.mu.Lock()
 	defer nq.mu.Unlock()
 
-	if idx, ok := nq.index[key]; ok {
-		heap.Remove(&nq.heap, idx)
+	if e, ok := nq.index[key]; ok {
+		heap.Remove(&nq.heap, e.index)
 		delete(nq.index, key)
+		nq.size.Store(int64(len(nq.index)))
 	}
 }
 
+func (nq *NearQueue) Len() int {
+	return int(nq.size.Load())
+}
+
+// Reset removes every queued key
+func (nq *NearQueue) Reset() {
+	nq.mu.Lock()
+	defer nq.mu.Unlock()
+
+	nq.heap = make(nearHeap, 0)
+	nq.index = make(map[string]*nearEntry)
+	nq.size.Store(0)
+}
+
 func (nq *NearQueue) DrainExpired() []string {
 	nq.mu.Lock()
 	defer nq.mu.Unlock()
 
-	now := util.CachedNow() / 1e6
+	// Entries expire strictly after their time, like in the engine
+	now := nq.clock.Now().UnixNano()
 	var expired []string
 
 	for nq.heap.Len() > 0 {
 		e := nq.heap[0]
-		if e.expireAt/1e6 > now {
+		if e.expireAt >= now {
 			break
 		}
 		popped := heap.Pop(&nq.heap).(*nearEntry)
 		delete(nq.index, popped.key)
 		expired = append(expired, popped.key)
 	}
+	nq.size.Store(int64(len(nq.index)))
 
 	return expired
 }
@@ -0,0 +1,54 @@
+package gc
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Params tune every collector of the process
+// The wheel geometry and the near window are read when a collector is created,
+// Workers when the first collector starts, and the tick intervals are picked up
+// by running collectors on their next tick
+type Params struct {
+	Workers      int // shared scheduler workers, 0 = goroutines per collector
+	WheelSlots   int
+	WheelTickMs  int64
+	NearWindowMs int64
+	NearTickMs   int64
+	FlushMs      int64 // delete coalescer flush interval
+}
+
+func DefaultParams() Params {
+	return Params{
+		Workers:      defaultWorkers,
+		WheelSlots:   defaultWheelSlots,
+		WheelTickMs:  defaultWheelTickMs,
+		NearWindowMs: defaultNearWindowMs,
+		NearTickMs:   defaultNearTickerMs,
+		FlushMs:      defaultCoalescerMs,
+	}
+}
+
+var params atomic.Pointer[Params]
+
+func init() {
+	p := DefaultParams()
+	params.Store(&p)
+}
+
+// SetParams replaces the parameters of new and running collectors
+func SetParams(p Params) {
+	params.Store(&p)
+}
+
+func CurrentParams() Params {
+	return *params.Load()
+}
+
+// retick resets t when the interval picked from the current parameters changed
+func retick(t *time.Ticker, cur *int64, interval func(Params) int64) {
+	if ms := interval(*params.Load()); ms > 0 && ms != *cur {
+		*cur = ms
+		t.Reset(time.Duration(ms) * time.Millisecond)
+	}
+}
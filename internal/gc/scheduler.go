@@ -0,0 +1,145 @@
+package gc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler drives many collectors from a fixed number of worker goroutines
+// Each collector is owned by one worker, which drains its near queue, advances
+// its wheel and flushes its pending deletes when they are due
+// Collectors with nothing queued cost a few atomic loads per tick
+type Scheduler struct {
+	workers []*worker
+	next    atomic.Uint64
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+type worker struct {
+	mu         sync.Mutex
+	collectors map[*GarbageCollector]*dueTimes
+}
+
+// dueTimes pace the work on a collector, in Unix nanoseconds of the system clock
+// Whether a key is due is decided by the clock of the collector
+// Only the owning worker reads and writes them
+type dueTimes struct {
+	near  int64
+	wheel int64
+	flush int64
+}
+
+// NewScheduler starts a scheduler with the given number of workers
+func NewScheduler(workers int) *Scheduler {
+	s := &Scheduler{
+		workers: make([]*worker, max(workers, 1)),
+		stopCh:  make(chan struct{}),
+	}
+	for i := range s.workers {
+		w := &worker{collectors: make(map[*GarbageCollector]*dueTimes)}
+		s.workers[i] = w
+		s.wg.Add(1)
+		go s.run(w)
+	}
+	return s
+}
+
+var (
+	sharedOnce sync.Once
+	shared     *Scheduler
+)
+
+// sharedScheduler returns the process-wide scheduler, nil if Params.Workers is 0
+// It is created on first use, later changes of Params.Workers are ignored
+func sharedScheduler() *Scheduler {
+	sharedOnce.Do(func() {
+		if n := CurrentParams().Workers; n > 0 {
+			shared = NewScheduler(n)
+		}
+	})
+	return shared
+}
+
+func (s *Scheduler) add(gc *GarbageCollector) {
+	w := s.workers[s.next.Add(1)%uint64(len(s.workers))]
+	w.mu.Lock()
+	w.collectors[gc] = &dueTimes{}
+	w.mu.Unlock()
+	gc.worker = w
+}
+
+// remove returns once no worker is running gc
+func (s *Scheduler) remove(gc *GarbageCollector) {
+	w := gc.worker
+	w.mu.Lock()
+	delete(w.collectors, gc)
+	w.mu.Unlock()
+}
+
+// Len returns the number of collectors served
+func (s *Scheduler) Len() int {
+	n := 0
+	for _, w := range s.workers {
+		w.mu.Lock()
+		n += len(w.collectors)
+		w.mu.Unlock()
+	}
+	return n
+}
+
+// Stop ends the workers, the collectors still registered are no longer driven
+func (s *Scheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// tickMs is the worker period, the shortest interval a collector can be due at
+func tickMs(p Params) int64 {
+	return max(min(p.NearTickMs, clampFlushMs(p.FlushMs)), 1)
+}
+
+func (s *Scheduler) run(w *worker) {
+	defer s.wg.Done()
+
+	cur := tickMs(CurrentParams())
+	ticker := time.NewTicker(time.Duration(cur) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			retick(ticker, &cur, tickMs)
+			w.tick(time.Now().UnixNano(), CurrentParams())
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (w *worker) tick(now int64, p Params) {
+	nearEvery := p.NearTickMs * int64(time.Millisecond)
+	flushEvery := clampFlushMs(p.FlushMs) * int64(time.Millisecond)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for gc, due := range w.collectors {
+		if gc.nearQueue.Len() > 0 && now >= due.near {
+			due.near = now + nearEvery
+			gc.enqueueExpired(gc.nearQueue.DrainExpired())
+		}
+
+		// Advance catches up with the ticks a late worker missed
+		if gc.wheel.Len() > 0 && now >= due.wheel {
+			due.wheel = now + gc.tickMs*int64(time.Millisecond)
+			gc.enqueueExpired(gc.wheel.Advance())
+		}
+
+		if gc.coalescer.Len() > 0 && now >= due.flush {
+			due.flush = now + flushEvery
+			gc.coalescer.flush()
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package gc
+
+import (
+	"fmt"
+	"key-value-store/internal/clock"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func cpuTime(b *testing.B) time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		b.Fatal(err)
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+// withParams runs f with p as the process parameters
+func withParams(p Params, f func()) {
+	prev := CurrentParams()
+	SetParams(p)
+	defer SetParams(prev)
+	f()
+}
+
+// BenchmarkIdleCPU measures the CPU used by collectors with nothing to expire,
+// reported as a percentage of one core
+func BenchmarkIdleCPU(b *testing.B) {
+	for _, collectors := range []int{64, 1024} {
+		for _, workers := range []int{0, 4} {
+			name := fmt.Sprintf("collectors=%d/workers=%d", collectors, workers)
+			b.Run(name, func(b *testing.B) {
+				var s *Scheduler
+				if workers > 0 {
+					s = NewScheduler(workers)
+					defer s.Stop()
+				}
+				gcs := make([]*GarbageCollector, collectors)
+				for i := range gcs {
+					gcs[i] = NewGarbageCollector(nil, clock.Real())
+					gcs[i].startOn(s)
+				}
+				defer func() {
+					for _, gc := range gcs {
+						gc.Stop()
+					}
+				}()
+
+				const window = 100 * time.Millisecond
+				b.ResetTimer()
+				startCPU, start := cpuTime(b), time.Now()
+				for i := 0; i < b.N; i++ {
+					time.Sleep(window)
+				}
+				used, wall := cpuTime(b)-startCPU, time.Since(start)
+				b.ReportMetric(100*float64(used)/float64(wall), "cpu-%")
+			})
+		}
+	}
+}
+
+// BenchmarkExpiryLag measures how late keys are deleted after they expire,
+// through both the near queue and the wheel
+func BenchmarkExpiryLag(b *testing.B) {
+	const (
+		collectors = 64
+		keys       = 32 // per collector
+		spread     = 400 * time.Millisecond
+	)
+	p := DefaultParams()
+	p.NearWindowMs = 100
+	p.WheelTickMs = 100
+
+	for _, workers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			withParams(p, func() {
+				var (
+					mu       sync.Mutex
+					expireAt = make(map[string]time.Time)
+					lags     []time.Duration
+				)
+				record := func(deleted []string) {
+					now := time.Now()
+					mu.Lock()
+					for _, k := range deleted {
+						lags = append(lags, now.Sub(expireAt[k]))
+					}
+					mu.Unlock()
+				}
+
+				var s *Scheduler
+				if workers > 0 {
+					s = NewScheduler(workers)
+					defer s.Stop()
+				}
+				gcs := make([]*GarbageCollector, collectors)
+				for i := range gcs {
+					gcs[i] = NewGarbageCollector(record, clock.Real())
+					gcs[i].startOn(s)
+				}
+				defer func() {
+					for _, gc := range gcs {
+						gc.Stop()
+					}
+				}()
+
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					now := time.Now()
+					mu.Lock()
+					for i, gc := range gcs {
+						for j := 0; j < keys; j++ {
+							key := fmt.Sprintf("%d-%d-%d", n, i, j)
+							at := now.Add(spread * time.Duration(j) / keys)
+							expireAt[key] = at
+							gc.Schedule(key, at)
+						}
+					}
+					mu.Unlock()
+
+					want := (n + 1) * collectors * keys
+					for deadline := time.Now().Add(spread + 2*time.Second); ; time.Sleep(5 * time.Millisecond) {
+						mu.Lock()
+						done := len(lags) >= want
+						mu.Unlock()
+						if done {
+							break
+						}
+						if time.Now().After(deadline) {
+							b.Fatalf("only %d of %d keys expired", len(lags), want)
+						}
+					}
+				}
+				b.StopTimer()
+
+				slices.Sort(lags)
+				b.ReportMetric(float64(lags[len(lags)/2])/float64(time.Millisecond), "p50-lag-ms")
+				b.ReportMetric(float64(lags[len(lags)*99/100])/float64(time.Millisecond), "p99-lag-ms")
+			})
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package gc
+
+import (
+	"key-value-store/internal/clock"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// wheelLevels is the number of levels, each level ticks slotCount times slower
+// than the one below it, 64 slots of 100 ms reach 19 days and 2048 slots of
+// 500 ms reach far beyond any TTL
+const wheelLevels = 4
+
+const noEntry = -1
+
+// wheelEntry is linked into the list of its slot by positions in the entry slab
+type wheelEntry struct {
+	key      string
+	expireAt int64 // Unix nanoseconds
+	prev     int32
+	next     int32
+	slot     int32 // level*slotCount + slot, noEntry when free
+}
+
+// TimingWheel is a hierarchical timing wheel
+// Keys due within slotCount ticks sit in level 0, later keys sit in a higher
+// level and cascade down as their time approaches, so Advance only visits the
+// keys that expire or change level. Add and Remove are O(1)
+// Tick n falls at origin + n*tickMs on the clock, a key goes to the first tick
+// after its expiration
+type TimingWheel struct {
+	mu        sync.Mutex
+	slotCount int
+	tickMs    int64
+	origin    int64 // Unix nanoseconds of tick 0
+	tick      int64 // next tick to process
+	span      [wheelLevels]int64
+	maxTicks  int64
+	heads     []int32 // first entry of every slot, level after level
+	entries   []wheelEntry
+	free      int32
+	index     map[string]int32
+	size      atomic.Int64 // len(index), readable without the lock
+	clock     clock.Clock
+	smear     func(n int64) int64
+}
+
+func NewTimingWheel(slotCount int, tickMs int64, clk clock.Clock) *TimingWheel {
+	if slotCount < 64 {
+		slotCount = 64
+	}
+	if slotCount > 8192 {
+		slotCount = 8192
+	}
+	if tickMs < 100 {
+		tickMs = 100
+	}
+	if tickMs > 1000 {
+		tickMs = 1000
+	}
+
+	w := &TimingWheel{
+		slotCount: slotCount,
+		tickMs:    tickMs,
+		origin:    clk.Now().UnixNano(),
+		heads:     make([]int32, wheelLevels*slotCount),
+		free:      noEntry,
+		index:     make(map[string]int32),
+		clock:     clk,
+		smear:     rand.Int63n,
+	}
+	w.span[0] = 1
+	for k := 1; k < wheelLevels; k++ {
+		w.span[k] = w.span[k-1] * int64(slotCount)
+	}
+	w.maxTicks = w.span[wheelLevels-1]*int64(slotCount) - 1
+	for i := range w.heads {
+		w.heads[i] = noEntry
+	}
+	return w
+}
+
+// Add schedules key, replacing its previous expiration
+// A random delay of up to a quarter tick spreads the deletes of keys written
+// together. It is never negative: the engine skips keys that are not expired
+// yet, and a key handed over early would not be scheduled again
+func (w *TimingWheel) Add(key string, expireAt int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	expireAt += w.smear(w.tickMs/4) * int64(time.Millisecond)
+	if len(w.index) == 0 {
+		// Nobody advances an empty wheel, skip the ticks that passed meanwhile
+		w.tick = max(w.tick, w.tickAt(w.clock.Now().UnixNano()))
+	}
+
+	i, exists := w.index[key]
+	if exists {
+		w.unlink(i)
+	} else {
+		i = w.alloc()
+		w.index[key] = i
+		w.size.Store(int64(len(w.index)))
+	}
+	w.entries[i].key = key
+	w.entries[i].expireAt = expireAt
+	w.place(i, w.tick)
+}
+
+func (w *TimingWheel) Remove(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i, exists := w.index[key]
+	if !exists {
+		return
+	}
+	w.unlink(i)
+	w.release(i)
+	delete(w.index, key)
+	w.size.Store(int64(len(w.index)))
+}
+
+func (w *TimingWheel) Len() int {
+	return int(w.size.Load())
+}
+
+// Reset removes every scheduled key
+func (w *TimingWheel) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.heads {
+		w.heads[i] = noEntry
+	}
+	w.entries = nil
+	w.free = noEntry
+	w.index = make(map[string]int32)
+	w.size.Store(0)
+}
+
+// Advance processes the ticks that fell since the last call and returns the
+// keys that expired, calling it late or often changes nothing but the delay
+func (w *TimingWheel) Advance() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now().UnixNano()
+	last := w.tickAt(now)
+
+	var expired []string
+	for ; w.tick <= last; w.tick++ {
+		if len(w.index) == 0 {
+			w.tick = last + 1
+			break
+		}
+		for k := wheelLevels - 1; k > 0; k-- {
+			if w.tick%w.span[k] == 0 {
+				w.cascade(k*w.slotCount + int(w.tick/w.span[k]%int64(w.slotCount)))
+			}
+		}
+
+		s := int(w.tick % int64(w.slotCount))
+		i := w.heads[s]
+		w.heads[s] = noEntry
+		for i != noEntry {
+			e := &w.entries[i]
+			next := e.next
+			if e.expireAt < now {
+				expired = append(expired, e.key)
+				delete(w.index, e.key)
+				w.release(i)
+			} else {
+				// Only when the clock went back
+				w.place(i, w.tick+1)
+			}
+			i = next
+		}
+	}
+
+	if len(w.index) == 0 {
+		// Drop the slab grown by a burst of keys
+		w.entries = nil
+		w.free = noEntry
+	}
+	w.size.Store(int64(len(w.index)))
+	return expired
+}
+
+// tickAt returns the last tick at or before ns
+func (w *TimingWheel) tickAt(ns int64) int64 {
+	if ns < w.origin {
+		return -1
+	}
+	return (ns - w.origin) / w.tickNs()
+}
+
+func (w *TimingWheel) tickNs() int64 { return w.tickMs * int64(time.Millisecond) }
+
+// cascade moves the entries of a higher level slot down to the levels below
+func (w *TimingWheel) cascade(slot int) {
+	i := w.heads[slot]
+	w.heads[slot] = noEntry
+	for i != noEntry {
+		next := w.entries[i].next
+		w.place(i, w.tick)
+		i = next
+	}
+}
+
+// place links entry i into the slot of the first tick after its expiration,
+// and not before tick from
+func (w *TimingWheel) place(i int32, from int64) {
+	e := &w.entries[i]
+	at := from
+	if d := e.expireAt - w.origin; d >= 0 {
+		at = max(at, d/w.tickNs()+1)
+	}
+	ticks := min(at-w.tick, w.maxTicks)
+	at = w.tick + ticks
+
+	level := 0
+	for level < wheelLevels-1 && ticks >= w.span[level+1] {
+		level++
+	}
+	slot := int32(level*w.slotCount + int(at/w.span[level]%int64(w.slotCount)))
+
+	e.slot = slot
+	e.prev = noEntry
+	e.next = w.heads[slot]
+	if e.next != noEntry {
+		w.entries[e.next].prev = i
+	}
+	w.heads[slot] = i
+}
+
+func (w *TimingWheel) unlink(i int32) {
+	e := &w.entries[i]
+	if e.prev != noEntry {
+		w.entries[e.prev].next = e.next
+	} else {
+		w.heads[e.slot] = e.next
+	}
+	if e.next != noEntry {
+		w.entries[e.next].prev = e.prev
+	}
+}
+
+func (w *TimingWheel) alloc() int32 {
+	if i := w.free; i != noEntry {
+		w.free = w.entries[i].next
+		return i
+	}
+	w.entries = append(w.entries, wheelEntry{})
+	return int32(len(w.entries) - 1)
+}
+
+// release puts an unlinked entry on the free list
+func (w *TimingWheel) release(i int32) {
+	w.entries[i] = wheelEntry{next: w.free, slot: noEntry}
+	w.free = i
+}
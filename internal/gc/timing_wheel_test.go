@@ -0,0 +1,184 @@
+package gc
+
+import (
+	"fmt"
+	"key-value-store/internal/clock"
+	"testing"
+	"time"
+)
+
+const testTickMs = 1000
+
+var testEpoch = time.UnixMilli(1_700_000_000_000)
+
+// fakeWheel returns a wheel driven by the returned clock
+func fakeWheel(slots int, smear bool) (*TimingWheel, *clock.Fake) {
+	clk := clock.NewFake(testEpoch)
+	w := NewTimingWheel(slots, testTickMs, clk)
+	if !smear {
+		w.smear = func(int64) int64 { return 0 }
+	}
+	return w, clk
+}
+
+// ns converts Unix milliseconds to the nanoseconds taken by Add
+func ns(ms int64) int64 { return ms * int64(time.Millisecond) }
+
+// run advances the clock and the wheel by step until every key expired or limit
+// passed, and returns when each key came out, in Unix milliseconds
+func run(t *testing.T, w *TimingWheel, clk *clock.Fake, step, limit time.Duration) map[string]int64 {
+	t.Helper()
+	got := make(map[string]int64)
+	end := clk.Now().Add(limit)
+	for w.Len() > 0 && !clk.Now().After(end) {
+		now := clk.Advance(step).UnixMilli()
+		for _, k := range w.Advance() {
+			if _, dup := got[k]; dup {
+				t.Fatalf("key %s expired twice", k)
+			}
+			got[k] = now
+		}
+	}
+	return got
+}
+
+func TestTimingWheelExpiresWithinOneTick(t *testing.T) {
+	w, clk := fakeWheel(64, false)
+	now := clk.Now().UnixMilli()
+
+	// One delay per level, 64 slots of 1 s give levels of 64 s, 68 min and 3 days
+	delays := []time.Duration{
+		0,
+		400 * time.Millisecond,
+		3 * time.Second,
+		63 * time.Second,
+		64 * time.Second,
+		100 * time.Second,
+		2 * time.Hour,
+		5 * 24 * time.Hour,
+		10*24*time.Hour + 1500*time.Millisecond,
+	}
+	expireAt := make(map[string]int64)
+	for i, d := range delays {
+		key := fmt.Sprintf("k%d", i)
+		expireAt[key] = now + d.Milliseconds()
+		w.Add(key, ns(expireAt[key]))
+	}
+
+	got := run(t, w, clk, testTickMs*time.Millisecond, 11*24*time.Hour)
+	for key, at := range expireAt {
+		out, ok := got[key]
+		if !ok {
+			t.Fatalf("key %s due at +%d ms never expired", key, at-now)
+		}
+		if out <= at || out > at+testTickMs {
+			t.Errorf("key %s due at %d expired at %d, want within one tick", key, at, out)
+		}
+	}
+	if w.Len() != 0 {
+		t.Errorf("Len() = %d after every key expired", w.Len())
+	}
+}
+
+func TestTimingWheelAdvanceOftenOrLate(t *testing.T) {
+	w, clk := fakeWheel(64, false)
+	due := clk.Now().UnixMilli() + 5500
+	w.Add("k", ns(due))
+
+	// A caller ticking faster than the wheel does not see the key early
+	got := run(t, w, clk, 100*time.Millisecond, time.Minute)
+	if out := got["k"]; out <= due || out > due+testTickMs {
+		t.Errorf("key due at %d expired at %d", due, out)
+	}
+
+	// A caller that missed many ticks gets every key due meanwhile at once
+	start := clk.Now().UnixMilli()
+	for i := range 100 {
+		w.Add(fmt.Sprint(i), ns(start+int64(i)*10*testTickMs))
+	}
+	clk.Advance(time.Hour)
+	if n := len(w.Advance()); n != 100 {
+		t.Errorf("Advance after an hour returned %d keys, want 100", n)
+	}
+}
+
+func TestTimingWheelSmear(t *testing.T) {
+	w, clk := fakeWheel(64, true)
+	due := clk.Now().UnixMilli() + 10*testTickMs
+
+	const keys = 2000
+	for i := range keys {
+		w.Add(fmt.Sprint(i), ns(due))
+	}
+
+	offsets := make(map[int64]int)
+	for i := range w.entries {
+		e := w.entries[i]
+		off := e.expireAt/1e6 - due
+		if off < 0 || off >= testTickMs/4 {
+			t.Fatalf("smear %d ms out of [0, %d)", off, testTickMs/4)
+		}
+		offsets[off]++
+	}
+	if len(offsets) < 100 {
+		t.Errorf("only %d distinct smear values for %d keys", len(offsets), keys)
+	}
+
+	// The smear only delays, nothing expires before its time
+	got := run(t, w, clk, testTickMs*time.Millisecond, time.Minute)
+	if len(got) != keys {
+		t.Fatalf("%d of %d keys expired", len(got), keys)
+	}
+	for key, out := range got {
+		if out <= due || out > due+testTickMs+testTickMs/4 {
+			t.Errorf("key %s due at %d expired at %d", key, due, out)
+		}
+	}
+}
+
+func TestTimingWheelRemoveAndReschedule(t *testing.T) {
+	w, clk := fakeWheel(64, false)
+	start := clk.Now().UnixMilli()
+
+	w.Add("gone", ns(start+2*testTickMs))
+	w.Add("moved", ns(start+2*testTickMs))
+	w.Add("kept", ns(start+3*testTickMs-500))
+	w.Remove("gone")
+	w.Remove("missing")
+	w.Add("moved", ns(start+100*testTickMs-500))
+	if w.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", w.Len())
+	}
+
+	// Keys leave at the first tick after their expiration
+	got := run(t, w, clk, testTickMs*time.Millisecond, time.Hour)
+	if _, ok := got["gone"]; ok {
+		t.Error("removed key expired")
+	}
+	if got["kept"] != start+3*testTickMs {
+		t.Errorf("kept expired at +%d ms, want +%d", got["kept"]-start, 3*testTickMs)
+	}
+	if got["moved"] != start+100*testTickMs {
+		t.Errorf("moved expired at +%d ms, want the new expiration +%d", got["moved"]-start, 100*testTickMs)
+	}
+}
+
+func TestTimingWheelReusesEntries(t *testing.T) {
+	w, clk := fakeWheel(64, false)
+	start := clk.Now().UnixMilli()
+
+	w.Add("anchor", ns(start+time.Hour.Milliseconds()))
+	for i := range 1000 {
+		key := fmt.Sprint(i)
+		w.Add(key, ns(start+int64(i)*testTickMs))
+		w.Remove(key)
+	}
+	if len(w.entries) != 2 {
+		t.Errorf("slab holds %d entries for 1 key, removed entries are not reused", len(w.entries))
+	}
+
+	w.Reset()
+	if w.Len() != 0 || len(w.Advance()) != 0 {
+		t.Error("keys left after Reset")
+	}
+}
@@ -0,0 +1,111 @@
+// Package health tracks the startup and shutdown state reported by the probes
+package health
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Listener names
+const (
+	ListenerHTTP = "http"
+	ListenerTCP  = "tcp"
+)
+
+// ListenerState describes a single listener
+type ListenerState struct {
+	Name      string
+	Addr      string
+	Listening bool
+	Since     time.Time
+	Error     string // last failure, empty if none
+}
+
+type Monitor struct {
+	mu        sync.RWMutex
+	listeners map[string]ListenerState
+	required  []string
+	restored  atomic.Bool
+	draining  atomic.Bool
+	startedAt time.Time
+}
+
+// NewMonitor creates a monitor that is ready once the required listeners are up
+func NewMonitor(required ...string) *Monitor {
+	m := &Monitor{
+		listeners: make(map[string]ListenerState, len(required)),
+		required:  required,
+		startedAt: time.Now(),
+	}
+	for _, name := range required {
+		m.listeners[name] = ListenerState{Name: name}
+	}
+	return m
+}
+
+func (m *Monitor) StartedAt() time.Time { return m.startedAt }
+
+// MarkRestored records that the durable state has been loaded
+func (m *Monitor) MarkRestored() { m.restored.Store(true) }
+
+// StartDrain makes readiness fail for the rest of the process lifetime
+func (m *Monitor) StartDrain() { m.draining.Store(true) }
+
+func (m *Monitor) Draining() bool { return m.draining.Load() }
+
+func (m *Monitor) SetListening(name, addr string) {
+	m.mu.Lock()
+	m.listeners[name] = ListenerState{Name: name, Addr: addr, Listening: true, Since: time.Now()}
+	m.mu.Unlock()
+}
+
+// SetStopped records that a listener is down, err is nil for a clean stop
+func (m *Monitor) SetStopped(name string, err error) {
+	m.mu.Lock()
+	st := m.listeners[name]
+	st.Name = name
+	st.Listening = false
+	st.Since = time.Now()
+	if err != nil {
+		st.Error = err.Error()
+	}
+	m.listeners[name] = st
+	m.mu.Unlock()
+}
+
+// Listeners returns the state of every known listener ordered by name
+func (m *Monitor) Listeners() []ListenerState {
+	m.mu.RLock()
+	out := make([]ListenerState, 0, len(m.listeners))
+	for _, st := range m.listeners {
+		out = append(out, st)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Ready reports whether traffic can be routed to the process
+// The reasons explain a negative answer
+func (m *Monitor) Ready() (bool, []string) {
+	var reasons []string
+	if m.draining.Load() {
+		reasons = append(reasons, "shutting down")
+	}
+	if !m.restored.Load() {
+		reasons = append(reasons, "state not restored")
+	}
+
+	m.mu.RLock()
+	for _, name := range m.required {
+		if !m.listeners[name].Listening {
+			reasons = append(reasons, name+" listener not ready")
+		}
+	}
+	m.mu.RUnlock()
+
+	return len(reasons) == 0, reasons
+}
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+)
+
+// Memory pressure levels, relative to the runtime memory limit
+const (
+	PressureUnknown  = "unknown" // no memory limit is set
+	PressureOK       = "ok"
+	PressureElevated = "elevated"
+	PressureCritical = "critical"
+
+	elevatedRatio = 0.75
+	criticalRatio = 0.9
+)
+
+type MemoryStats struct {
+	HeapAlloc  uint64
+	HeapInuse  uint64
+	Sys        uint64
+	NumGC      uint32
+	Limit      int64 // GOMEMLIMIT, 0 = unlimited
+	LimitRatio float64
+	Pressure   string
+}
+
+// ReadMemory samples the runtime memory statistics
+// It stops the world briefly, only call it from on-demand reports
+func ReadMemory() MemoryStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	st := MemoryStats{
+		HeapAlloc: ms.HeapAlloc,
+		HeapInuse: ms.HeapInuse,
+		Sys:       ms.Sys,
+		NumGC:     ms.NumGC,
+		Pressure:  PressureUnknown,
+	}
+
+	// A negative input only reads the current limit
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		return st
+	}
+
+	st.Limit = limit
+	st.LimitRatio = float64(ms.Sys-ms.HeapReleased) / float64(limit)
+	switch {
+	case st.LimitRatio >= criticalRatio:
+		st.Pressure = PressureCritical
+	case st.LimitRatio >= elevatedRatio:
+		st.Pressure = PressureElevated
+	default:
+		st.Pressure = PressureOK
+	}
+	return st
+}
@@ -0,0 +1,175 @@
+// Package lifecycle starts the listeners, waits for a stop request or a
+// listener failure, then drains the listeners and releases the resources
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/health"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Listener is a server that binds and serves in separate steps, so that a bind
+// failure is reported synchronously
+type Listener interface {
+	Listen() error
+	// Serve blocks until Shutdown and returns nil after a clean stop
+	Serve() error
+	// Shutdown stops accepting and waits for in-flight requests until ctx is done
+	Shutdown(ctx context.Context) error
+	Addr() string
+}
+
+type listener struct {
+	name string
+	Listener
+}
+
+type hook struct {
+	name string
+	fn   func() error
+}
+
+type Server struct {
+	monitor   *health.Monitor
+	timeout   time.Duration
+	listeners []listener
+	hooks     []hook
+	drains    []hook
+	failed    chan error
+	started   []listener
+}
+
+// New creates a server whose shutdown drains the listeners for at most timeout
+func New(monitor *health.Monitor, timeout time.Duration) *Server {
+	return &Server{
+		monitor: monitor,
+		timeout: timeout,
+	}
+}
+
+// AddListener registers a listener, listeners start in registration order
+func (s *Server) AddListener(name string, l Listener) {
+	s.listeners = append(s.listeners, listener{name: name, Listener: l})
+}
+
+// OnStop registers fn to run once the listeners are drained, hooks run in registration order
+func (s *Server) OnStop(name string, fn func() error) {
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// OnDrain registers fn to run when the drain starts, before waiting for the
+// listeners; it releases requests that would otherwise hold the drain, like blocking reads
+func (s *Server) OnDrain(name string, fn func()) {
+	s.drains = append(s.drains, hook{name: name, fn: func() error { fn(); return nil }})
+}
+
+// Start binds and serves every listener
+// If one cannot bind, the listeners already started are shut down
+func (s *Server) Start() error {
+	s.failed = make(chan error, len(s.listeners))
+
+	for _, l := range s.listeners {
+		if err := l.Listen(); err != nil {
+			s.monitor.SetStopped(l.name, err)
+			startErr := fmt.Errorf("%w: %s on %s: %w", errs.ErrListenerStart, l.name, l.Addr(), err)
+			if err := s.drain(); err != nil {
+				return errors.Join(startErr, err)
+			}
+			return startErr
+		}
+		s.started = append(s.started, l)
+		s.monitor.SetListening(l.name, l.Addr())
+		slog.Info("Lifecycle: Listener started", "name", l.name, "address", l.Addr())
+
+		go func(l listener) {
+			if err := l.Serve(); err != nil {
+				s.monitor.SetStopped(l.name, err)
+				s.failed <- fmt.Errorf("%w: %s: %w", errs.ErrListenerFailed, l.name, err)
+			}
+		}(l)
+	}
+	return nil
+}
+
+// Run starts the server and shuts it down once ctx is done or a listener fails
+// The returned error wraps errs.ErrListenerStart, errs.ErrListenerFailed or
+// errs.ErrShutdownIncomplete
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.Start(); err != nil {
+		return err
+	}
+
+	var cause error
+	select {
+	case <-ctx.Done():
+		slog.Info("Lifecycle: Stop requested")
+	case cause = <-s.failed:
+		slog.Error("Lifecycle: Listener failed", "error", cause)
+	}
+
+	if err := s.Shutdown(); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// Shutdown fails readiness, drains the listeners in parallel, then runs the stop hooks
+// Hooks run even when the drain deadline is exceeded
+func (s *Server) Shutdown() error {
+	s.monitor.StartDrain()
+	slog.Info("Lifecycle: Shutting down", "timeout", s.timeout)
+
+	for _, h := range s.drains {
+		slog.Info("Lifecycle: Releasing blocked requests", "name", h.name)
+		_ = h.fn()
+	}
+
+	var failed []error
+	if err := s.drain(); err != nil {
+		failed = append(failed, err)
+	}
+	for _, h := range s.hooks {
+		if err := h.fn(); err != nil {
+			slog.Error("Lifecycle: Stop hook failed", "name", h.name, "error", err)
+			failed = append(failed, fmt.Errorf("%w: %s: %w", errs.ErrShutdownIncomplete, h.name, err))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// drain shuts down the started listeners under a shared deadline
+func (s *Server) drain() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []error
+	)
+	for _, l := range s.started {
+		wg.Add(1)
+		go func(l listener) {
+			defer wg.Done()
+			start := time.Now()
+			err := l.Shutdown(ctx)
+			s.monitor.SetStopped(l.name, nil)
+			if err != nil {
+				slog.Error("Lifecycle: Listener drain failed", "name", l.name, "error", err)
+				mu.Lock()
+				failed = append(failed, fmt.Errorf("%w: %s: %w", errs.ErrShutdownIncomplete, l.name, err))
+				mu.Unlock()
+				return
+			}
+			slog.Info("Lifecycle: Listener stopped", "name", l.name, "duration", time.Since(start))
+		}(l)
+	}
+	wg.Wait()
+	s.started = nil
+	return errors.Join(failed...)
+}
@@ -9,6 +9,8 @@ import (
 
 var (
 	once sync.Once
+	// level is shared by the handlers so that SetLevel applies immediately
+	level = new(slog.LevelVar)
 )
 
 type Config struct {
@@ -20,18 +22,16 @@ func Initialize(cfg Config) {
 	once.Do(func() {
 		var handler slog.Handler
 
-		//Dynamic
-		logLevel := &slog.LevelVar{}
-		logLevel.Set(getLogLevel(cfg.LogLevel))
+		level.Set(getLogLevel(cfg.LogLevel))
 
 		if cfg.Environment == "production" {
 			handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 				AddSource: false,
-				Level:     logLevel,
+				Level:     level,
 			})
 		} else {
 			handler = console.NewHandler(os.Stdout, &console.HandlerOptions{
-				Level:     logLevel,
+				Level:     level,
 				AddSource: true,
 			})
 		}
@@ -41,6 +41,11 @@ func Initialize(cfg Config) {
 	})
 }
 
+// SetLevel changes the level of the default logger at runtime
+func SetLevel(logLevel string) {
+	level.Set(getLogLevel(logLevel))
+}
+
 func getLogLevel(logLevel string) slog.Level {
 	switch logLevel {
 	case "debug":
@@ -0,0 +1,225 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text format
+// Updating an existing series never takes a lock: series are looked up in a
+// sync.Map and their values are atomics, so it is safe on the lock-free read path
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// labelSep joins label values into series keys, it cannot appear in valid UTF-8
+const labelSep = "\xff"
+
+// DefaultLatencyBuckets covers 50µs to 2.5s, in seconds
+var DefaultLatencyBuckets = []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// DefaultSizeBuckets covers batch sizes from 1 to 4096
+var DefaultSizeBuckets = []float64{1, 4, 16, 64, 256, 1024, 4096}
+
+type family struct {
+	name   string
+	help   string
+	labels []string
+	series sync.Map // label values joined by labelSep -> series
+}
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic("metrics: " + f.name + ": wrong number of label values")
+	}
+	return strings.Join(values, labelSep)
+}
+
+// sorted returns the series ordered by label values, for a stable output
+func (f *family) sorted() []any {
+	var keys []string
+	values := make(map[string]any)
+	f.series.Range(func(k, v any) bool {
+		keys = append(keys, k.(string))
+		values[k.(string)] = v
+		return true
+	})
+	sort.Strings(keys)
+
+	out := make([]any, len(keys))
+	for i, k := range keys {
+		out[i] = values[k]
+	}
+	return out
+}
+
+// pairs interleaves label names and values as expected by Writer.Sample
+func (f *family) pairs(values []string, extra ...string) []string {
+	out := make([]string, 0, 2*len(values)+len(extra))
+	for i, v := range values {
+		out = append(out, f.labels[i], v)
+	}
+	return append(out, extra...)
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	values []string
+	v      atomic.Uint64
+}
+
+func (c *Counter) Inc()          { c.v.Add(1) }
+func (c *Counter) Add(n uint64)  { c.v.Add(n) }
+func (c *Counter) Value() uint64 { return c.v.Load() }
+
+type CounterVec struct {
+	family
+}
+
+// NewCounterVec creates a counter family and registers it in Default
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family{name: name, help: help, labels: labels}}
+	Register(c)
+	return c
+}
+
+// NewCounter creates an unlabelled counter and registers it in Default
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help).With()
+}
+
+// With returns the counter of the given label values, creating it on first use
+func (c *CounterVec) With(values ...string) *Counter {
+	key := c.key(values)
+	if s, ok := c.series.Load(key); ok {
+		return s.(*Counter)
+	}
+	s, _ := c.series.LoadOrStore(key, &Counter{values: append([]string(nil), values...)})
+	return s.(*Counter)
+}
+
+func (c *CounterVec) Collect(w *Writer) {
+	w.Header(c.name, c.help, "counter")
+	for _, s := range c.sorted() {
+		counter := s.(*Counter)
+		w.Sample(c.name, float64(counter.Value()), c.pairs(counter.values)...)
+	}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	values []string
+	v      atomic.Int64
+}
+
+func (g *Gauge) Inc()         { g.v.Add(1) }
+func (g *Gauge) Dec()         { g.v.Add(-1) }
+func (g *Gauge) Set(v int64)  { g.v.Store(v) }
+func (g *Gauge) Value() int64 { return g.v.Load() }
+
+type GaugeVec struct {
+	family
+}
+
+// NewGaugeVec creates a gauge family and registers it in Default
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{family{name: name, help: help, labels: labels}}
+	Register(g)
+	return g
+}
+
+// NewGauge creates an unlabelled gauge and registers it in Default
+func NewGauge(name, help string) *Gauge {
+	return NewGaugeVec(name, help).With()
+}
+
+func (g *GaugeVec) With(values ...string) *Gauge {
+	key := g.key(values)
+	if s, ok := g.series.Load(key); ok {
+		return s.(*Gauge)
+	}
+	s, _ := g.series.LoadOrStore(key, &Gauge{values: append([]string(nil), values...)})
+	return s.(*Gauge)
+}
+
+func (g *GaugeVec) Collect(w *Writer) {
+	w.Header(g.name, g.help, "gauge")
+	for _, s := range g.sorted() {
+		gauge := s.(*Gauge)
+		w.Sample(g.name, float64(gauge.Value()), g.pairs(gauge.values)...)
+	}
+}
+
+// Histogram counts observations into fixed buckets
+type Histogram struct {
+	values  []string
+	bounds  []float64
+	counts  []atomic.Uint64 // per bucket, the last one is +Inf
+	sumBits atomic.Uint64
+	count   atomic.Uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	for {
+		old := h.sumBits.Load()
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// ObserveSince records the time elapsed since start, in seconds
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+type HistogramVec struct {
+	family
+	bounds []float64
+}
+
+// NewHistogramVec creates a histogram family and registers it in Default
+// bounds are the inclusive upper bounds of the buckets, in increasing order
+func NewHistogramVec(name, help string, bounds []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{family: family{name: name, help: help, labels: labels}, bounds: bounds}
+	Register(h)
+	return h
+}
+
+// NewHistogram creates an unlabelled histogram and registers it in Default
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	return NewHistogramVec(name, help, bounds).With()
+}
+
+func (h *HistogramVec) With(values ...string) *Histogram {
+	key := h.key(values)
+	if s, ok := h.series.Load(key); ok {
+		return s.(*Histogram)
+	}
+	s, _ := h.series.LoadOrStore(key, &Histogram{
+		values: append([]string(nil), values...),
+		bounds: h.bounds,
+		counts: make([]atomic.Uint64, len(h.bounds)+1),
+	})
+	return s.(*Histogram)
+}
+
+func (h *HistogramVec) Collect(w *Writer) {
+	w.Header(h.name, h.help, "histogram")
+	for _, s := range h.sorted() {
+		hist := s.(*Histogram)
+		var cumulative uint64
+		for i, bound := range hist.bounds {
+			cumulative += hist.counts[i].Load()
+			w.Sample(h.name+"_bucket", float64(cumulative), h.pairs(hist.values, "le", formatFloat(bound))...)
+		}
+		cumulative += hist.counts[len(hist.bounds)].Load()
+		w.Sample(h.name+"_bucket", float64(cumulative), h.pairs(hist.values, "le", "+Inf")...)
+		w.Sample(h.name+"_sum", math.Float64frombits(hist.sumBits.Load()), h.pairs(hist.values)...)
+		w.Sample(h.name+"_count", float64(hist.count.Load()), h.pairs(hist.values)...)
+	}
+}
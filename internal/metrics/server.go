@@ -0,0 +1,38 @@
+package metrics
+
+// Transport label values
+const (
+	TransportHTTP = "http"
+	TransportTCP  = "tcp"
+)
+
+var (
+	Operations = NewCounterVec("bukt_operations_total",
+		"Handled operations by bucket, transport, command and status",
+		"bucket", "transport", "command", "status")
+
+	CommandDuration = NewHistogramVec("bukt_command_duration_seconds",
+		"Command latency by transport and command",
+		DefaultLatencyBuckets, "transport", "command")
+
+	AuthFailures = NewCounterVec("bukt_auth_failures_total",
+		"Rejected bucket and admin tokens by transport",
+		"transport", "kind")
+
+	TCPConnections = NewGauge("bukt_tcp_connections",
+		"Open TCP connections")
+
+	TCPConnectionsAccepted = NewCounter("bukt_tcp_connections_accepted_total",
+		"Accepted TCP connections")
+
+	TCPFrameErrors = NewCounterVec("bukt_tcp_frame_errors_total",
+		"Malformed or rejected TCP frames by reason",
+		"reason")
+
+	GCExpiredKeys = NewCounter("bukt_gc_expired_keys_total",
+		"Keys found expired by the timing wheel and the near queue")
+
+	GCFlushBatchSize = NewHistogram("bukt_gc_flush_batch_size",
+		"Keys deleted per delete coalescer flush",
+		DefaultSizeBuckets)
+)
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"time"
+)
+
+type IAuthService interface {
+	ListKeys(ctx context.Context) []auth.KeyInfo
+	RotateKey(ctx context.Context, grace time.Duration) (auth.KeyInfo, error)
+	RemoveKey(ctx context.Context, id uint32) error
+	IssueToken(ctx context.Context, bucketName string, ttl int64) (string, error)
+	RevokeToken(ctx context.Context, bucketName, token string) error
+	RevokeTokenID(ctx context.Context, bucketName, tokenID string) error
+	RevokeIssuedBefore(ctx context.Context, bucketName string, t time.Time) error
+	Revocations(ctx context.Context, bucketName string) (auth.Revocation, error)
+}
+
+type authService struct {
+	bucketManager bucket.BucketManager
+}
+
+func NewAuthService(bucketManager bucket.BucketManager) IAuthService {
+	return &authService{
+		bucketManager: bucketManager,
+	}
+}
+
+func (s *authService) ListKeys(ctx context.Context) []auth.KeyInfo {
+	return auth.Manager().Keys()
+}
+
+func (s *authService) RotateKey(ctx context.Context, grace time.Duration) (auth.KeyInfo, error) {
+	info, err := auth.Manager().RotateKey(grace)
+	if err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("AuthService: Failed to rotate signing key", "crr-id", crrid, "error", err)
+		return auth.KeyInfo{}, err
+	}
+	return info, nil
+}
+
+func (s *authService) RemoveKey(ctx context.Context, id uint32) error {
+	return auth.Manager().RemoveKey(id)
+}
+
+func (s *authService) IssueToken(ctx context.Context, bucketName string, ttl int64) (string, error) {
+	if !s.bucketManager.BucketExists(bucketName) {
+		return "", errs.ErrBucketNotFound
+	}
+	return auth.Manager().GenerateToken(bucketName, ttl), nil
+}
+
+func (s *authService) RevokeToken(ctx context.Context, bucketName, token string) error {
+	claims, err := auth.Manager().ParseToken(token)
+	if err != nil {
+		return err
+	}
+	if auth.Manager().ResolveSubject(claims) != bucketName {
+		return errs.ErrInvalidToken
+	}
+
+	if _, err := auth.Manager().RevokeToken(token); err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("AuthService: Failed to revoke token", "crr-id", crrid, "bucket", bucketName, "error", err)
+		return err
+	}
+	slog.Info("AuthService: Revoked token", "bucket", bucketName, "token_id", claims.TokenID)
+	return nil
+}
+
+func (s *authService) RevokeTokenID(ctx context.Context, bucketName, tokenID string) error {
+	if err := auth.Manager().RevokeTokenID(bucketName, tokenID, 0); err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("AuthService: Failed to revoke token", "crr-id", crrid, "bucket", bucketName, "error", err)
+		return err
+	}
+	slog.Info("AuthService: Revoked token", "bucket", bucketName, "token_id", tokenID)
+	return nil
+}
+
+func (s *authService) RevokeIssuedBefore(ctx context.Context, bucketName string, t time.Time) error {
+	if err := auth.Manager().RevokeIssuedBefore(bucketName, t); err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("AuthService: Failed to revoke tokens", "crr-id", crrid, "bucket", bucketName, "error", err)
+		return err
+	}
+	slog.Info("AuthService: Revoked tokens issued before", "bucket", bucketName, "issued_before", t)
+	return nil
+}
+
+func (s *authService) Revocations(ctx context.Context, bucketName string) (auth.Revocation, error) {
+	return auth.Manager().Revocations(bucketName), nil
+}
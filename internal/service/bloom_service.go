@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+)
+
+// DefaultBloomCapacity and DefaultBloomErrorRate size the filters BFAdd creates
+const (
+	DefaultBloomCapacity  = 1000
+	DefaultBloomErrorRate = 0.01
+)
+
+// IBloomService tells whether items were seen, with false positives at a
+// rate chosen per filter but no false negatives
+type IBloomService interface {
+	// BFReserve creates an empty filter keeping false positives at errorRate
+	// until capacity items were added
+	BFReserve(ctx context.Context, bucketName, key string, capacity int64, errorRate float64, ttl int64) (engine.BloomInfo, error)
+	// BFAdd adds items and reports for each whether it was new, a missing
+	// filter is created with the default sizing and ttl
+	BFAdd(ctx context.Context, bucketName, key string, items []string, ttl int64) ([]bool, error)
+	// BFExists reports for each item whether it may have been added, a missing
+	// filter holds nothing
+	BFExists(ctx context.Context, bucketName, key string, items []string) ([]bool, error)
+	BFInfo(ctx context.Context, bucketName, key string) (engine.BloomInfo, error)
+}
+
+type bloomService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewBloomService creates the service, new filters are stamped with the time of clk
+func NewBloomService(bucketManager bucket.BucketManager, clk clock.Clock) IBloomService {
+	return &bloomService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *bloomService) BFReserve(ctx context.Context, bucketName, key string, capacity int64, errorRate float64, ttl int64) (engine.BloomInfo, error) {
+	var info engine.BloomInfo
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(f *engine.BloomFilter) (*engine.BloomFilter, error) {
+		if f != nil {
+			return nil, errs.ErrFilterExists
+		}
+		next, err := engine.NewBloomFilter(capacity, errorRate)
+		if err != nil {
+			return nil, err
+		}
+		info = next.Info()
+		return next, nil
+	})
+	return info, err
+}
+
+func (s *bloomService) BFAdd(ctx context.Context, bucketName, key string, items []string, ttl int64) ([]bool, error) {
+	var added []bool
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(f *engine.BloomFilter) (*engine.BloomFilter, error) {
+		if f == nil {
+			var err error
+			if f, err = engine.NewBloomFilter(DefaultBloomCapacity, DefaultBloomErrorRate); err != nil {
+				return nil, err
+			}
+		}
+		next, ok := f.Add(items...)
+		added = ok
+		return next, nil
+	})
+	return added, err
+}
+
+func (s *bloomService) BFExists(ctx context.Context, bucketName, key string, items []string) ([]bool, error) {
+	f, err := readData[*engine.BloomFilter](ctx, s.bucketManager, bucketName, key)
+	if err != nil && !errors.Is(err, errs.ErrKeyNotFound) {
+		return nil, err
+	}
+	return f.Has(items...), nil
+}
+
+func (s *bloomService) BFInfo(ctx context.Context, bucketName, key string) (engine.BloomInfo, error) {
+	f, err := readData[*engine.BloomFilter](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return engine.BloomInfo{}, err
+	}
+	return f.Info(), nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/errs"
+	"slices"
+	"testing"
+)
+
+func TestBloomReserveAddExists(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	f := NewBloomService(buckets, clk)
+	ctx := context.Background()
+
+	info, err := f.BFReserve(ctx, "b", "seen", 10000, 0.001, 0)
+	if err != nil || info.Capacity != 10000 || info.Hashes != 10 {
+		t.Fatalf("BFReserve = %+v, %v", info, err)
+	}
+	if _, err := f.BFReserve(ctx, "b", "seen", 100, 0.01, 0); !errors.Is(err, errs.ErrFilterExists) {
+		t.Errorf("second BFReserve: %v", err)
+	}
+	if _, err := f.BFReserve(ctx, "b", "bad", 100, 1.5, 0); !errors.Is(err, errs.ErrInvalidFilter) {
+		t.Errorf("BFReserve with an error rate of 1.5: %v", err)
+	}
+
+	if added, err := f.BFAdd(ctx, "b", "seen", []string{"id1", "id2", "id1"}, 0); err != nil || !slices.Equal(added, []bool{true, true, false}) {
+		t.Errorf("BFAdd = %v, %v", added, err)
+	}
+	if got, err := f.BFExists(ctx, "b", "seen", []string{"id1", "id3"}); err != nil || !slices.Equal(got, []bool{true, false}) {
+		t.Errorf("BFExists = %v, %v", got, err)
+	}
+	if got, err := f.BFExists(ctx, "b", "missing", []string{"id1"}); err != nil || got[0] {
+		t.Errorf("BFExists on a missing filter = %v, %v", got, err)
+	}
+	if info, _ := f.BFInfo(ctx, "b", "seen"); info.Count != 2 {
+		t.Errorf("count %d after adding 2 items", info.Count)
+	}
+
+	// Adding to a missing filter reserves one with the defaults
+	f.BFAdd(ctx, "b", "auto", []string{"x"}, 0)
+	if info, err := f.BFInfo(ctx, "b", "auto"); err != nil || info.Capacity != DefaultBloomCapacity || info.ErrorRate != DefaultBloomErrorRate {
+		t.Errorf("BFInfo of a filter created by BFAdd = %+v, %v", info, err)
+	}
+}
+
+func TestBloomReserveRespectsValueLimit(t *testing.T) {
+	settings := bucket.DefaultBucketSettings()
+	settings.MaxValueSize = 64 << 10
+	buckets, clk := newTestBucket(settings)
+	f := NewBloomService(buckets, clk)
+	ctx := context.Background()
+
+	// The whole bit array counts, although no page was written yet
+	if _, err := f.BFReserve(ctx, "b", "big", 1_000_000, 0.01, 0); !errors.Is(err, errs.ErrValueTooLarge) {
+		t.Errorf("BFReserve beyond the value limit: %v", err)
+	}
+	if _, err := f.BFReserve(ctx, "b", "small", 10_000, 0.01, 0); err != nil {
+		t.Errorf("BFReserve within the value limit: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
 	"key-value-store/internal/errs"
 	"key-value-store/internal/util"
 	"log/slog"
@@ -14,8 +15,14 @@ type CreateBucketResult struct {
 }
 
 type IBucketService interface {
-	CreateBucket(ctx context.Context, name, description string, shardCount int) (*CreateBucketResult, error)
+	CreateBucket(ctx context.Context, name, description string, shardCount int, settings bucket.BucketSettings) (*CreateBucketResult, error)
 	GetBucket(ctx context.Context, name string) (*bucket.BucketMetadata, error)
+	UpdateSettings(ctx context.Context, name string, patch bucket.SettingsPatch) (*bucket.BucketMetadata, error)
+	CloneBucket(ctx context.Context, source, target, description string) (*CreateBucketResult, error)
+	RenameBucket(ctx context.Context, name, newName string, reissue bool) (*CreateBucketResult, error)
+	FlushBucket(ctx context.Context, name string) (int64, error)
+	Reshard(ctx context.Context, name string, shardCount int) error
+	ReshardProgress(ctx context.Context, name string) (engine.ReshardProgress, bool, error)
 	DeleteBucket(ctx context.Context, name, token string) error
 	ListBuckets(ctx context.Context) ([]*bucket.BucketMetadata, error)
 }
@@ -30,8 +37,8 @@ func NewBucketService(bucketManager bucket.BucketManager) IBucketService {
 	}
 }
 
-func (s *bucketService) CreateBucket(ctx context.Context, name, description string, shardCount int) (*CreateBucketResult, error) {
-	tokenHex, err := s.bucketManager.CreateBucket(name, description, shardCount)
+func (s *bucketService) CreateBucket(ctx context.Context, name, description string, shardCount int, settings bucket.BucketSettings) (*CreateBucketResult, error) {
+	tokenHex, err := s.bucketManager.CreateBucket(name, description, shardCount, settings)
 	if err != nil {
 		crrid := util.GetCorrelationID(ctx)
 		slog.Error("BucketService: Failed to create bucket", "crr-id", crrid, "name", name, "error", err)
@@ -60,6 +67,81 @@ func (s *bucketService) GetBucket(ctx context.Context, name string) (*bucket.Buc
 	return meta, nil
 }
 
+func (s *bucketService) UpdateSettings(ctx context.Context, name string, patch bucket.SettingsPatch) (*bucket.BucketMetadata, error) {
+	meta, err := s.bucketManager.UpdateSettings(name, patch)
+	if err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("BucketService: Failed to update bucket settings", "crr-id", crrid, "name", name, "error", err)
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+func (s *bucketService) CloneBucket(ctx context.Context, source, target, description string) (*CreateBucketResult, error) {
+	token, err := s.bucketManager.CloneBucket(source, target, description)
+	if err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("BucketService: Failed to clone bucket", "crr-id", crrid, "source", source, "name", target, "error", err)
+		return nil, err
+	}
+
+	meta, ok := s.bucketManager.GetBucket(target)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+
+	return &CreateBucketResult{
+		Metadata:  meta,
+		AuthToken: token,
+	}, nil
+}
+
+func (s *bucketService) RenameBucket(ctx context.Context, name, newName string, reissue bool) (*CreateBucketResult, error) {
+	token, err := s.bucketManager.RenameBucket(name, newName, reissue)
+	if err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("BucketService: Failed to rename bucket", "crr-id", crrid, "name", name, "new_name", newName, "error", err)
+		return nil, err
+	}
+
+	meta, ok := s.bucketManager.GetBucket(newName)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+
+	return &CreateBucketResult{
+		Metadata:  meta,
+		AuthToken: token,
+	}, nil
+}
+
+func (s *bucketService) FlushBucket(ctx context.Context, name string) (int64, error) {
+	removed, err := s.bucketManager.FlushBucket(name)
+	if err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("BucketService: Failed to flush bucket", "crr-id", crrid, "name", name, "error", err)
+		return 0, err
+	}
+
+	return removed, nil
+}
+
+func (s *bucketService) Reshard(ctx context.Context, name string, shardCount int) error {
+	err := s.bucketManager.Reshard(name, shardCount)
+	if err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("BucketService: Failed to reshard bucket", "crr-id", crrid, "name", name, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *bucketService) ReshardProgress(ctx context.Context, name string) (engine.ReshardProgress, bool, error) {
+	return s.bucketManager.ReshardProgress(name)
+}
+
 func (s *bucketService) DeleteBucket(ctx context.Context, name, token string) error {
 	err := s.bucketManager.DeleteBucket(name, token)
 	if err != nil {
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/config"
+	"key-value-store/internal/errs"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Applier pushes the reloadable settings of cfg into a running component
+// It must ignore the settings that need a restart
+type Applier func(cfg *config.Configuration)
+
+type ReloadResult struct {
+	Applied         []config.Change
+	RestartRequired []config.Change
+	// Skipped are the quota fields removed from the file, the bucket keeps
+	// its current setting
+	Skipped  []config.Change
+	Warnings []string
+}
+
+type IConfigService interface {
+	Reload(ctx context.Context) (*ReloadResult, error)
+}
+
+type configService struct {
+	mu            sync.Mutex
+	current       *config.Configuration
+	bucketManager bucket.BucketManager
+	appliers      []Applier
+}
+
+// NewConfigService applies cfg through the appliers
+// Bucket quotas are left to the bucket manager, which applies them when a
+// bucket is created, so that a restart keeps the settings changed over the API
+func NewConfigService(cfg *config.Configuration, bucketManager bucket.BucketManager, appliers ...Applier) IConfigService {
+	s := &configService{
+		current:       cfg,
+		bucketManager: bucketManager,
+		appliers:      appliers,
+	}
+	for _, apply := range appliers {
+		apply(cfg)
+	}
+	return s
+}
+
+// Reload reads the configuration again and applies what can change at runtime
+// An invalid configuration is rejected as a whole and nothing is applied
+func (s *configService) Reload(ctx context.Context) (*ReloadResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, err := s.current.Reload()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errs.ErrInvalidConfig, err)
+	}
+
+	result := &ReloadResult{}
+	quotaChanged := make(map[string]bool)
+	for _, c := range config.Diff(s.current, next) {
+		if c.Restart {
+			result.RestartRequired = append(result.RestartRequired, c)
+			continue
+		}
+		name, isQuota := bucketOfQuotaKey(c.Key)
+		if isQuota && c.New == config.Unset {
+			result.Skipped = append(result.Skipped, c)
+			continue
+		}
+		result.Applied = append(result.Applied, c)
+		if isQuota {
+			quotaChanged[name] = true
+		}
+	}
+
+	for _, apply := range s.appliers {
+		apply(next)
+	}
+	var names []string
+	for _, name := range next.BucketNames() {
+		if quotaChanged[name] {
+			names = append(names, name)
+		}
+	}
+	result.Warnings = s.applyQuotas(next, names)
+	s.bucketManager.SetQuotas(next.Buckets)
+
+	next.KeepRestartSettings(s.current)
+	s.current = next
+
+	slog.Info("ConfigService: Configuration reloaded",
+		"applied", len(result.Applied),
+		"restart_required", len(result.RestartRequired),
+		"skipped", len(result.Skipped),
+		"warnings", len(result.Warnings))
+	for _, c := range result.RestartRequired {
+		slog.Warn("ConfigService: Setting changed, restart required", "key", c.Key, "old", c.Old, "new", c.New)
+	}
+	return result, nil
+}
+
+// applyQuotas sets the quotas of the named buckets and returns why some were not applied
+// Fields removed from the file leave the bucket settings as they are
+func (s *configService) applyQuotas(cfg *config.Configuration, names []string) []string {
+	var warnings []string
+	for _, name := range names {
+		if _, err := s.bucketManager.UpdateSettings(name, bucket.QuotaPatch(cfg.Buckets[name])); err != nil {
+			warnings = append(warnings, fmt.Sprintf("bucket %s: %v", name, err))
+		}
+	}
+	return warnings
+}
+
+// bucketOfQuotaKey extracts the bucket name of a "buckets.<name>.<field>" change
+func bucketOfQuotaKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, "buckets.")
+	if !ok {
+		return "", false
+	}
+	name, _, ok := strings.Cut(rest, ".")
+	return name, ok
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigQuotas(t *testing.T) {
+	if err := auth.Initialize([]byte("0123456789abcdef0123456789abcdef"), ""); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "kvstore.toml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+[store]
+data_dir = ""
+
+[buckets.orders]
+max_keys = 10
+max_value_size = 100
+`)
+	cfg, err := config.Load([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm, err := bucket.NewBucketManager(cfg, clock.Real())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bm.Shutdown()
+	settings := func(name string) bucket.BucketSettings {
+		b, ok := bm.GetBucket(name)
+		if !ok {
+			t.Fatalf("bucket %s missing", name)
+		}
+		return b.Settings
+	}
+
+	// The quota applies on create, a later PATCH survives the start of the service
+	if _, err := bm.CreateBucket("orders", "", 0, bucket.DefaultBucketSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if s := settings("orders"); s.MaxKeys != 10 || s.MaxValueSize != 100 {
+		t.Errorf("created with %+v, want the quota", s)
+	}
+	patched := int64(20)
+	if _, err := bm.UpdateSettings("orders", bucket.SettingsPatch{MaxKeys: &patched}); err != nil {
+		t.Fatal(err)
+	}
+	configs := NewConfigService(cfg, bm)
+	if s := settings("orders"); s.MaxKeys != 20 {
+		t.Errorf("max_keys = %d after start, want the patched 20", s.MaxKeys)
+	}
+
+	write(`
+[store]
+data_dir = ""
+
+[buckets.orders]
+max_keys = 30
+
+[buckets.users]
+max_keys = 5
+`)
+	result, err := configs.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range result.Applied {
+		if c.Key == "buckets.orders.max_value_size" {
+			t.Errorf("removed field reported as applied: %+v", c)
+		}
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Key != "buckets.orders.max_value_size" {
+		t.Errorf("skipped = %+v, want the removed max_value_size", result.Skipped)
+	}
+	if s := settings("orders"); s.MaxKeys != 30 || s.MaxValueSize != 100 {
+		t.Errorf("reloaded to %+v, want max_keys 30 and max_value_size kept", s)
+	}
+
+	// A quota added by the reload applies to buckets created afterwards
+	if _, err := bm.CreateBucket("users", "", 0, bucket.DefaultBucketSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if s := settings("users"); s.MaxKeys != 5 {
+		t.Errorf("max_keys = %d for a bucket created after the reload, want 5", s.MaxKeys)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"time"
+)
+
+// container is a structured value that is removed once empty
+// Its methods must accept the nil receiver, which stands for the empty value
+type container interface {
+	engine.Data
+	Len() int
+}
+
+// readData returns the structured value of kind T held by key
+func readData[T container](ctx context.Context, bm bucket.BucketManager, bucketName, key string) (T, error) {
+	var zero T
+	bucketStore, ok := bm.GetStore(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return zero, errs.ErrBucketNotFound
+	}
+
+	entry, exists := bucketStore.Get(key)
+	if !exists {
+		return zero, errs.ErrKeyNotFound
+	}
+	data, ok := entry.Data.(T)
+	if !ok {
+		return zero, errs.WrongType(zero.Kind(), entry.Kind())
+	}
+	return data, nil
+}
+
+// updateData applies fn to the structured value of kind T held by key, atomically
+// fn gets the zero T for a missing key, which is then created with ttl resolved
+// by the bucket settings; existing keys keep their expiration
+// Values left empty by fn are removed
+func updateData[T container](ctx context.Context, bm bucket.BucketManager, clk clock.Clock, bucketName, key string, ttl int64, fn func(cur T) (T, error)) error {
+	if ttl < 0 {
+		return errs.ErrInvalidTTL
+	}
+
+	bucketStore, settings, ok := bm.GetStoreAndSettings(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return errs.ErrBucketNotFound
+	}
+	if len(key) > settings.MaxKeyLength {
+		return errs.ErrKeyTooLong
+	}
+
+	return bucketStore.Update(key, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		var data T
+		if found {
+			var ok bool
+			if data, ok = cur.Data.(T); !ok {
+				return cur, false, errs.WrongType(data.Kind(), cur.Kind())
+			}
+		}
+
+		next, err := fn(data)
+		if err != nil {
+			return cur, false, err
+		}
+		if next.Len() == 0 {
+			return cur, true, nil
+		}
+		// Shrinking is always allowed, the limit may have been lowered since
+		if settings.MaxValueSize > 0 && next.Size() > settings.MaxValueSize && next.Size() > data.Size() {
+			return cur, false, errs.ErrValueTooLarge
+		}
+
+		if found {
+			cur.Data = next
+			return cur, false, nil
+		}
+		return newDataEntry(bucketStore, settings, clk, key, ttl, next)
+	})
+}
+
+// newDataEntry builds the entry of a new structured value
+func newDataEntry(store *engine.ShardContainer, settings bucket.BucketSettings, clk clock.Clock, key string, ttl int64, data engine.Data) (engine.StorageEntry, bool, error) {
+	ttl, err := settings.ResolveTTL(ttl)
+	if err != nil {
+		return engine.StorageEntry{}, false, err
+	}
+	if settings.MaxKeys > 0 && store.Count() >= settings.MaxKeys {
+		return engine.StorageEntry{}, false, errs.ErrKeyLimit
+	}
+
+	now := clk.Now()
+	entry := engine.StorageEntry{
+		Key:       key,
+		TTL:       ttl,
+		CreatedAt: now,
+		Data:      data,
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(time.Duration(ttl) * time.Second)
+	}
+	return entry, false, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"math"
+	"strconv"
+)
+
+type IHashService interface {
+	// HSet sets fields of a hash and returns how many were new
+	// ttl only applies when the hash is created
+	HSet(ctx context.Context, bucketName, key string, fields map[string][]byte, ttl int64) (int, error)
+	HGet(ctx context.Context, bucketName, key, field string) ([]byte, error)
+	// HDel removes fields and returns how many existed, the key goes with the last field
+	HDel(ctx context.Context, bucketName, key string, fields []string) (int, error)
+	// HIncrBy adds delta to the decimal integer of a field, a missing field counts as 0
+	HIncrBy(ctx context.Context, bucketName, key, field string, delta int64) (int64, error)
+	HGetAll(ctx context.Context, bucketName, key string) (map[string][]byte, error)
+}
+
+type hashService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewHashService creates the service, new hashes are stamped with the time of clk
+func NewHashService(bucketManager bucket.BucketManager, clk clock.Clock) IHashService {
+	return &hashService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *hashService) HSet(ctx context.Context, bucketName, key string, fields map[string][]byte, ttl int64) (int, error) {
+	var added int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(h *engine.Hash) (*engine.Hash, error) {
+		next, n := h.With(fields)
+		added = n
+		return next, nil
+	})
+	return added, err
+}
+
+func (s *hashService) HGet(ctx context.Context, bucketName, key, field string) ([]byte, error) {
+	h, err := readData[*engine.Hash](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := h.Get(field)
+	if !ok {
+		return nil, errs.ErrFieldNotFound
+	}
+	return v, nil
+}
+
+func (s *hashService) HDel(ctx context.Context, bucketName, key string, fields []string) (int, error) {
+	var removed int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(h *engine.Hash) (*engine.Hash, error) {
+		next, n := h.Without(fields...)
+		removed = n
+		return next, nil
+	})
+	return removed, err
+}
+
+func (s *hashService) HIncrBy(ctx context.Context, bucketName, key, field string, delta int64) (int64, error) {
+	var result int64
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(h *engine.Hash) (*engine.Hash, error) {
+		var cur int64
+		if v, ok := h.Get(field); ok {
+			n, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				return nil, errs.ErrNotInteger
+			}
+			cur = n
+		}
+		if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+			return nil, errs.ErrNotInteger
+		}
+
+		result = cur + delta
+		next, _ := h.With(map[string][]byte{field: strconv.AppendInt(nil, result, 10)})
+		return next, nil
+	})
+	return result, err
+}
+
+func (s *hashService) HGetAll(ctx context.Context, bucketName, key string) (map[string][]byte, error) {
+	h, err := readData[*engine.Hash](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	return h.Fields(), nil
+}
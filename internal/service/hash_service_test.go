@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/errs"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashFieldOperations(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	h := NewHashService(buckets, clk)
+	ctx := context.Background()
+
+	added, err := h.HSet(ctx, "b", "user", map[string][]byte{"name": []byte("ada"), "visits": []byte("1")}, 0)
+	if err != nil || added != 2 {
+		t.Fatalf("HSet added %d: %v", added, err)
+	}
+	if added, _ = h.HSet(ctx, "b", "user", map[string][]byte{"name": []byte("grace")}, 0); added != 0 {
+		t.Errorf("HSet of an existing field added %d", added)
+	}
+	if v, err := h.HGet(ctx, "b", "user", "name"); err != nil || string(v) != "grace" {
+		t.Errorf("HGet name = %q, %v", v, err)
+	}
+	if _, err := h.HGet(ctx, "b", "user", "missing"); !errors.Is(err, errs.ErrFieldNotFound) {
+		t.Errorf("HGet of a missing field: %v", err)
+	}
+
+	if n, err := h.HIncrBy(ctx, "b", "user", "visits", 41); err != nil || n != 42 {
+		t.Errorf("HIncrBy = %d, %v", n, err)
+	}
+	if _, err := h.HIncrBy(ctx, "b", "user", "name", 1); !errors.Is(err, errs.ErrNotInteger) {
+		t.Errorf("HIncrBy of a string field: %v", err)
+	}
+
+	fields, err := h.HGetAll(ctx, "b", "user")
+	if err != nil || len(fields) != 2 || string(fields["visits"]) != "42" {
+		t.Errorf("HGetAll = %q, %v", fields, err)
+	}
+
+	if n, _ := h.HDel(ctx, "b", "user", []string{"name", "visits", "missing"}); n != 2 {
+		t.Errorf("HDel removed %d, want 2", n)
+	}
+	if _, err := h.HGetAll(ctx, "b", "user"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("hash without fields still exists: %v", err)
+	}
+}
+
+func TestHashConcurrentIncrements(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	h := NewHashService(buckets, clk)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if _, err := h.HIncrBy(ctx, "b", "counters", "hits", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := h.HGet(ctx, "b", "counters", "hits"); string(v) != "1600" {
+		t.Errorf("hits = %s after 1600 increments", v)
+	}
+}
+
+func TestHashWrongType(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	h, kv := NewHashService(buckets, clk), NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	if _, err := kv.Set(ctx, "b", "plain", []byte("v"), 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.HSet(ctx, "b", "plain", map[string][]byte{"f": []byte("v")}, 0); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("HSet on a plain value: %v", err)
+	}
+	if v, _ := kv.Get(ctx, "b", "plain"); string(v.Value) != "v" {
+		t.Error("failed HSet changed the plain value")
+	}
+
+	if _, err := h.HSet(ctx, "b", "hash", map[string][]byte{"f": []byte("v")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get(ctx, "b", "hash"); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("Get on a hash: %v", err)
+	}
+	// A plain write does not replace the hash
+	var wrong *errs.WrongTypeError
+	if _, err := kv.Set(ctx, "b", "hash", []byte("v"), 0, false); !errors.As(err, &wrong) || wrong.Have != "hash" || wrong.Want != "string" {
+		t.Errorf("Set on a hash: %v", err)
+	}
+	if fields, err := h.HGetAll(ctx, "b", "hash"); err != nil || string(fields["f"]) != "v" {
+		t.Errorf("HGetAll after a failed Set: %v, %v", fields, err)
+	}
+}
+
+func TestHashTTLAndLimits(t *testing.T) {
+	settings := bucket.DefaultBucketSettings()
+	settings.DefaultTTL = 10
+	settings.MaxValueSize = 100
+	buckets, clk := newTestBucket(settings)
+	h := NewHashService(buckets, clk)
+	ctx := context.Background()
+
+	if _, err := h.HSet(ctx, "b", "k", map[string][]byte{"a": []byte("1")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Later writes keep the expiration set at creation
+	clk.Advance(8 * time.Second)
+	if _, err := h.HSet(ctx, "b", "k", map[string][]byte{"b": []byte("2")}, 60); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(3 * time.Second)
+	if _, err := h.HGetAll(ctx, "b", "k"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("hash readable after the TTL set at creation: %v", err)
+	}
+
+	big := map[string][]byte{"blob": make([]byte, 100)}
+	if _, err := h.HSet(ctx, "b", "big", big, 0); !errors.Is(err, errs.ErrValueTooLarge) {
+		t.Errorf("HSet above max_value_size: %v", err)
+	}
+}
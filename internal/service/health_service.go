@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/gc"
+	"key-value-store/internal/health"
+	"sort"
+	"time"
+)
+
+// Overall states of the detailed health report
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+type BucketHealth struct {
+	Name        string
+	Keys        int64
+	MemoryUsage int64
+	GC          gc.Stats
+}
+
+type HealthReport struct {
+	Status    string
+	Ready     bool
+	Reasons   []string
+	StartedAt time.Time
+	Listeners []health.ListenerState
+	Memory    health.MemoryStats
+	Buckets   []BucketHealth
+}
+
+type IHealthService interface {
+	Ready(ctx context.Context) (bool, []string)
+	Report(ctx context.Context) HealthReport
+}
+
+type healthService struct {
+	bucketManager bucket.BucketManager
+	monitor       *health.Monitor
+}
+
+func NewHealthService(bucketManager bucket.BucketManager, monitor *health.Monitor) IHealthService {
+	return &healthService{
+		bucketManager: bucketManager,
+		monitor:       monitor,
+	}
+}
+
+func (s *healthService) Ready(ctx context.Context) (bool, []string) {
+	return s.monitor.Ready()
+}
+
+// Report gathers listener, memory and per-bucket GC state
+// Not ready is reported as down, stopped collectors or critical memory pressure as degraded
+func (s *healthService) Report(ctx context.Context) HealthReport {
+	ready, reasons := s.monitor.Ready()
+	report := HealthReport{
+		Status:    HealthOK,
+		Ready:     ready,
+		Reasons:   reasons,
+		StartedAt: s.monitor.StartedAt(),
+		Listeners: s.monitor.Listeners(),
+		Memory:    health.ReadMemory(),
+	}
+
+	buckets := s.bucketManager.ListBuckets()
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	for _, b := range buckets {
+		store, ok := s.bucketManager.GetStore(b.Name)
+		if !ok {
+			continue
+		}
+		bh := BucketHealth{
+			Name:        b.Name,
+			Keys:        b.KeyCount,
+			MemoryUsage: b.MemoryUsage,
+			GC:          store.GCStats(),
+		}
+		if bh.GC.Running < bh.GC.Collectors {
+			report.Status = HealthDegraded
+		}
+		report.Buckets = append(report.Buckets, bh)
+	}
+
+	if report.Memory.Pressure == health.PressureCritical {
+		report.Status = HealthDegraded
+	}
+	if !ready {
+		report.Status = HealthDown
+	}
+	return report
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+)
+
+// IHyperLogLogService counts distinct members without keeping them
+type IHyperLogLogService interface {
+	// PFAdd adds members and reports whether the estimate may have changed
+	// ttl only applies when the HyperLogLog is created
+	PFAdd(ctx context.Context, bucketName, key string, members []string, ttl int64) (bool, error)
+	// PFCount estimates the distinct members added to any of the HyperLogLogs
+	// held by keys, missing ones count as empty
+	PFCount(ctx context.Context, bucketName string, keys []string) (int64, error)
+	// PFMerge adds the members of the HyperLogLogs held by sources to the one
+	// held by dest, creating it when missing, and returns its estimate
+	PFMerge(ctx context.Context, bucketName, dest string, sources []string) (int64, error)
+}
+
+type hyperLogLogService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewHyperLogLogService creates the service, new HyperLogLogs are stamped
+// with the time of clk
+func NewHyperLogLogService(bucketManager bucket.BucketManager, clk clock.Clock) IHyperLogLogService {
+	return &hyperLogLogService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *hyperLogLogService) PFAdd(ctx context.Context, bucketName, key string, members []string, ttl int64) (bool, error) {
+	var changed bool
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(h *engine.HyperLogLog) (*engine.HyperLogLog, error) {
+		next, ok := h.Add(members...)
+		changed = ok
+		return next, nil
+	})
+	return changed, err
+}
+
+func (s *hyperLogLogService) PFCount(ctx context.Context, bucketName string, keys []string) (int64, error) {
+	all, err := s.readAll(ctx, bucketName, keys)
+	if err != nil {
+		return 0, err
+	}
+	if len(all) == 1 {
+		return all[0].Count(), nil
+	}
+	var merged *engine.HyperLogLog
+	return merged.Merge(all...).Count(), nil
+}
+
+func (s *hyperLogLogService) PFMerge(ctx context.Context, bucketName, dest string, sources []string) (int64, error) {
+	all, err := s.readAll(ctx, bucketName, sources)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = updateData(ctx, s.bucketManager, s.clock, bucketName, dest, 0, func(h *engine.HyperLogLog) (*engine.HyperLogLog, error) {
+		next := h.Merge(all...)
+		count = next.Count()
+		return next, nil
+	})
+	return count, err
+}
+
+// readAll reads each key on its own, missing ones as nil
+func (s *hyperLogLogService) readAll(ctx context.Context, bucketName string, keys []string) ([]*engine.HyperLogLog, error) {
+	all := make([]*engine.HyperLogLog, len(keys))
+	for i, key := range keys {
+		h, err := readData[*engine.HyperLogLog](ctx, s.bucketManager, bucketName, key)
+		if err != nil && !errors.Is(err, errs.ErrKeyNotFound) {
+			return nil, err
+		}
+		all[i] = h
+	}
+	return all, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/errs"
+	"testing"
+)
+
+func TestHyperLogLogAddCountMerge(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	h, sets := NewHyperLogLogService(buckets, clk), NewSetService(buckets, clk)
+	ctx := context.Background()
+
+	if changed, err := h.PFAdd(ctx, "b", "mon", []string{"u1", "u2", "u3"}, 0); err != nil || !changed {
+		t.Fatalf("PFAdd = %v, %v", changed, err)
+	}
+	if changed, _ := h.PFAdd(ctx, "b", "mon", []string{"u2"}, 0); changed {
+		t.Errorf("PFAdd of a known member changed the estimate")
+	}
+	h.PFAdd(ctx, "b", "tue", []string{"u3", "u4"}, 0)
+
+	if n, err := h.PFCount(ctx, "b", []string{"mon"}); err != nil || n != 3 {
+		t.Errorf("PFCount(mon) = %d, %v", n, err)
+	}
+	// Counting several keys estimates their union without storing it
+	if n, err := h.PFCount(ctx, "b", []string{"mon", "tue", "missing"}); err != nil || n != 4 {
+		t.Errorf("PFCount of the union = %d, %v", n, err)
+	}
+
+	if n, err := h.PFMerge(ctx, "b", "week", []string{"mon", "tue"}); err != nil || n != 4 {
+		t.Errorf("PFMerge = %d, %v", n, err)
+	}
+	if n, _ := h.PFCount(ctx, "b", []string{"week"}); n != 4 {
+		t.Errorf("merged HyperLogLog counts %d", n)
+	}
+	if n, _ := h.PFCount(ctx, "b", []string{"mon"}); n != 3 {
+		t.Errorf("merging changed a source, it counts %d", n)
+	}
+
+	sets.SAdd(ctx, "b", "tags", []string{"go"}, 0)
+	if _, err := h.PFCount(ctx, "b", []string{"mon", "tags"}); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("PFCount over a set: %v", err)
+	}
+}
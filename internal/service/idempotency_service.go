@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"time"
+)
+
+// MaxIdempotencyKeyLength bounds the Idempotency-Key of a request
+const MaxIdempotencyKeyLength = 255
+
+// StoredResponse is a response replayed for a request sent again
+type StoredResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IIdempotencyService remembers the responses of requests by key and
+// fingerprint in the hidden idempotency bucket, for a window
+// Keys are scoped to a bucket, a bucket recreated under the same name starts
+// with none
+type IIdempotencyService interface {
+	// Begin claims key for a request, it returns the stored response when the
+	// key was answered for the same fingerprint already, ErrReplayMismatch
+	// when it was used for another fingerprint and ErrReplayPending while the
+	// request claiming it runs
+	Begin(ctx context.Context, bucketName, key, fingerprint string) (*StoredResponse, error)
+	// Complete stores the response of a claimed key for the window
+	Complete(ctx context.Context, bucketName, key string, resp StoredResponse) error
+	// Abandon releases a claimed key without a response, the request can be
+	// sent again
+	Abandon(ctx context.Context, bucketName, key string)
+}
+
+type idempotencyService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+	window        time.Duration
+}
+
+// idempotencyRecord is the value of a key in the idempotency bucket, Response
+// is nil while the request claiming the key runs
+type idempotencyRecord struct {
+	Fingerprint string          `json:"fingerprint"`
+	Response    *StoredResponse `json:"response,omitempty"`
+}
+
+// NewIdempotencyService creates the service, responses are kept for window
+// by the time of clk
+func NewIdempotencyService(bucketManager bucket.BucketManager, clk clock.Clock, window time.Duration) IIdempotencyService {
+	return &idempotencyService{
+		bucketManager: bucketManager,
+		clock:         clk,
+		window:        window,
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, bucketName, key, fingerprint string) (*StoredResponse, error) {
+	store, recordKey, err := s.locate(ctx, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var replay *StoredResponse
+	err = store.Update(recordKey, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		if found {
+			var rec idempotencyRecord
+			if err := json.Unmarshal(cur.Value, &rec); err != nil {
+				return cur, false, err
+			}
+			switch {
+			case rec.Fingerprint != fingerprint:
+				return cur, false, errs.ErrReplayMismatch
+			case rec.Response == nil:
+				return cur, false, errs.ErrReplayPending
+			}
+			replay = rec.Response
+			return cur, false, nil
+		}
+		return s.newRecord(recordKey, idempotencyRecord{Fingerprint: fingerprint})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, bucketName, key string, resp StoredResponse) error {
+	store, recordKey, err := s.locate(ctx, bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	return store.Update(recordKey, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		// The claim lapsed with the window, the response is not kept
+		if !found {
+			return cur, true, nil
+		}
+		var rec idempotencyRecord
+		if err := json.Unmarshal(cur.Value, &rec); err != nil {
+			return cur, false, err
+		}
+		rec.Response = &resp
+		return s.newRecord(recordKey, rec)
+	})
+}
+
+func (s *idempotencyService) Abandon(ctx context.Context, bucketName, key string) {
+	store, recordKey, err := s.locate(ctx, bucketName, key)
+	if err != nil {
+		return
+	}
+	store.Delete(recordKey)
+}
+
+// locate returns the idempotency bucket and the key of the record of key
+func (s *idempotencyService) locate(ctx context.Context, bucketName, key string) (*engine.ShardContainer, string, error) {
+	crrid := util.GetCorrelationID(ctx)
+	meta, ok := s.bucketManager.GetBucket(bucketName)
+	if !ok {
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return nil, "", errs.ErrBucketNotFound
+	}
+	store, ok := s.bucketManager.InternalStore(bucket.IdempotencyBucket)
+	if !ok {
+		slog.Error("Service: Idempotency bucket not found", "crr-id", crrid)
+		return nil, "", errs.ErrBucketNotFound
+	}
+	return store, meta.ID + "/" + key, nil
+}
+
+// newRecord builds the entry of rec, expiring a window from now
+func (s *idempotencyService) newRecord(key string, rec idempotencyRecord) (engine.StorageEntry, bool, error) {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return engine.StorageEntry{}, false, fmt.Errorf("encode idempotency record: %w", err)
+	}
+	now := s.clock.Now()
+	return engine.StorageEntry{
+		Key:          key,
+		Value:        value,
+		OriginalSize: int64(len(value)),
+		TTL:          int64(s.window / time.Second),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(s.window),
+	}, false, nil
+}
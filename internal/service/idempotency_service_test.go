@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"testing"
+	"time"
+)
+
+// testInternalBuckets adds the idempotency bucket to testBuckets, id tells
+// which bucket the name stands for
+type testInternalBuckets struct {
+	*testBuckets
+	id       string
+	internal *engine.ShardContainer
+}
+
+func (b *testInternalBuckets) GetBucket(name string) (*bucket.BucketMetadata, bool) {
+	return &bucket.BucketMetadata{ID: b.id, Name: name}, name == b.name
+}
+
+func (b *testInternalBuckets) InternalStore(name string) (*engine.ShardContainer, bool) {
+	return b.internal, name == bucket.IdempotencyBucket
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	b, clk := newTestBucket(bucket.DefaultBucketSettings())
+	s := NewIdempotencyService(&testInternalBuckets{testBuckets: b, id: "id-1", internal: engine.NewShardContainer(4, clk)}, clk, time.Hour)
+	ctx := context.Background()
+
+	if replay, err := s.Begin(ctx, "b", "k1", "fp"); err != nil || replay != nil {
+		t.Fatalf("first Begin = %v, %v", replay, err)
+	}
+	if _, err := s.Begin(ctx, "b", "k1", "fp"); !errors.Is(err, errs.ErrReplayPending) {
+		t.Errorf("Begin while running: %v", err)
+	}
+
+	resp := StoredResponse{Status: 201, ContentType: "application/json", Body: []byte(`{"key":"a"}`)}
+	if err := s.Complete(ctx, "b", "k1", resp); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := s.Begin(ctx, "b", "k1", "fp")
+	if err != nil || replay == nil || replay.Status != 201 || string(replay.Body) != `{"key":"a"}` {
+		t.Fatalf("Begin after Complete = %+v, %v", replay, err)
+	}
+	if _, err := s.Begin(ctx, "b", "k1", "other"); !errors.Is(err, errs.ErrReplayMismatch) {
+		t.Errorf("Begin with another fingerprint: %v", err)
+	}
+
+	// The key can be used again once the window passed
+	clk.Advance(time.Hour + time.Second)
+	if replay, err := s.Begin(ctx, "b", "k1", "other"); err != nil || replay != nil {
+		t.Errorf("Begin after the window = %v, %v", replay, err)
+	}
+	if _, err := s.Begin(ctx, "missing", "k1", "fp"); !errors.Is(err, errs.ErrBucketNotFound) {
+		t.Errorf("Begin in a missing bucket: %v", err)
+	}
+}
+
+func TestIdempotencyAbandonAndScope(t *testing.T) {
+	b, clk := newTestBucket(bucket.DefaultBucketSettings())
+	buckets := &testInternalBuckets{testBuckets: b, id: "id-1", internal: engine.NewShardContainer(4, clk)}
+	s := NewIdempotencyService(buckets, clk, time.Hour)
+	ctx := context.Background()
+
+	s.Begin(ctx, "b", "k1", "fp")
+	s.Abandon(ctx, "b", "k1")
+	if replay, err := s.Begin(ctx, "b", "k1", "other"); err != nil || replay != nil {
+		t.Errorf("Begin after Abandon = %v, %v", replay, err)
+	}
+
+	// A bucket recreated under the same name does not see the keys of the old one
+	s.Complete(ctx, "b", "k1", StoredResponse{Status: 200})
+	buckets.id = "id-2"
+	if replay, err := s.Begin(ctx, "b", "k1", "fp"); err != nil || replay != nil {
+		t.Errorf("Begin in a recreated bucket = %v, %v", replay, err)
+	}
+}
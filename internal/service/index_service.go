@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+)
+
+const (
+	// DefaultIndexLimit is the page size of a query without limit
+	DefaultIndexLimit = 100
+	MaxIndexLimit     = 1000
+)
+
+// IndexQuery is an equality or range lookup, values are encoded JSON scalars
+// Equal takes precedence over Min and Max, both included, nil values are unset
+// Limit is bounded by MaxIndexLimit, 0 means DefaultIndexLimit
+type IndexQuery struct {
+	Equal    []byte
+	Min, Max []byte
+	Cursor   string // returned by the previous page
+	Limit    int
+}
+
+// IndexPage holds the matches of a query in index order, Cursor is empty on
+// the last page
+type IndexPage struct {
+	Hits   []engine.IndexHit
+	Cursor string
+}
+
+// IIndexService manages the secondary indexes of a bucket and queries them
+// Indexes cover the JSON documents holding a scalar at their path
+type IIndexService interface {
+	CreateIndex(ctx context.Context, bucketName, name, path string) (engine.IndexDef, error)
+	DropIndex(ctx context.Context, bucketName, name string) error
+	ListIndexes(ctx context.Context, bucketName string) ([]engine.IndexDef, error)
+	Query(ctx context.Context, bucketName, name string, q IndexQuery) (*IndexPage, error)
+}
+
+type indexService struct {
+	bucketManager bucket.BucketManager
+}
+
+func NewIndexService(bucketManager bucket.BucketManager) IIndexService {
+	return &indexService{
+		bucketManager: bucketManager,
+	}
+}
+
+// indexCursor is the last hit of a page, encoded as base64 JSON
+type indexCursor struct {
+	Key   string            `json:"k"`
+	Value engine.IndexValue `json:"v"`
+}
+
+func (s *indexService) CreateIndex(ctx context.Context, bucketName, name, path string) (engine.IndexDef, error) {
+	def := engine.IndexDef{Name: name, Path: path}
+	if err := s.bucketManager.CreateIndex(bucketName, def); err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("IndexService: Failed to create index", "crr-id", crrid, "bucket", bucketName, "index", name, "error", err)
+		return engine.IndexDef{}, err
+	}
+	return def, nil
+}
+
+func (s *indexService) DropIndex(ctx context.Context, bucketName, name string) error {
+	if err := s.bucketManager.DropIndex(bucketName, name); err != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("IndexService: Failed to drop index", "crr-id", crrid, "bucket", bucketName, "index", name, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (s *indexService) ListIndexes(ctx context.Context, bucketName string) ([]engine.IndexDef, error) {
+	meta, ok := s.bucketManager.GetBucket(bucketName)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+	if meta.Indexes == nil {
+		return []engine.IndexDef{}, nil
+	}
+	return meta.Indexes, nil
+}
+
+func (s *indexService) Query(ctx context.Context, bucketName, name string, q IndexQuery) (*IndexPage, error) {
+	lookup, err := q.lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	bucketStore, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("IndexService: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return nil, errs.ErrBucketNotFound
+	}
+
+	// One more hit tells whether there is a next page
+	limit := lookup.Limit
+	lookup.Limit++
+	hits, err := bucketStore.Lookup(name, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &IndexPage{Hits: hits}
+	if len(hits) > limit {
+		page.Hits = hits[:limit]
+		last := page.Hits[limit-1]
+		page.Cursor = encodeCursor(indexCursor{Key: last.Key, Value: last.Value})
+	}
+	return page, nil
+}
+
+// lookup parses q into an engine query, with its limit bounded
+func (q IndexQuery) lookup() (engine.IndexQuery, error) {
+	out := engine.IndexQuery{Limit: min(q.Limit, MaxIndexLimit)}
+	if out.Limit <= 0 {
+		out.Limit = DefaultIndexLimit
+	}
+
+	var err error
+	if q.Equal != nil {
+		if out.Min, err = parseBound(q.Equal); err != nil {
+			return out, err
+		}
+		out.Max = out.Min
+	} else {
+		if out.Min, err = parseBound(q.Min); err != nil {
+			return out, err
+		}
+		if out.Max, err = parseBound(q.Max); err != nil {
+			return out, err
+		}
+	}
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return out, err
+		}
+		out.After = &engine.IndexHit{Key: c.Key, Value: c.Value}
+	}
+	return out, nil
+}
+
+func parseBound(data []byte) (*engine.IndexValue, error) {
+	if data == nil {
+		return nil, nil
+	}
+	v, err := engine.ParseIndexValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func encodeCursor(c indexCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (indexCursor, error) {
+	var c indexCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		return c, errs.ErrInvalidCursor
+	}
+	return c, nil
+}
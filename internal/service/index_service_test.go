@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"slices"
+	"testing"
+	"time"
+)
+
+// indexBuckets keeps the index definitions of the test bucket
+type indexBuckets struct {
+	*testBuckets
+	indexes []engine.IndexDef
+}
+
+func (b *indexBuckets) CreateIndex(name string, def engine.IndexDef) error {
+	if err := b.store.AddIndex(def); err != nil {
+		return err
+	}
+	b.indexes = append(b.indexes, def)
+	return nil
+}
+
+func (b *indexBuckets) DropIndex(name, index string) error {
+	b.store.DropIndex(index)
+	return nil
+}
+
+func (b *indexBuckets) GetBucket(name string) (*bucket.BucketMetadata, bool) {
+	return &bucket.BucketMetadata{Name: b.name, Indexes: b.indexes}, name == b.name
+}
+
+func queryKeys(t *testing.T, x IIndexService, q IndexQuery) []string {
+	t.Helper()
+	page, err := x.Query(context.Background(), "b", "by_user", q)
+	if err != nil {
+		t.Fatalf("Query(%+v): %v", q, err)
+	}
+	keys := make([]string, len(page.Hits))
+	for i, h := range page.Hits {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+func TestIndexFollowsDocuments(t *testing.T) {
+	b, clk := newTestBucket(bucket.DefaultBucketSettings())
+	buckets := &indexBuckets{testBuckets: b}
+	x, j, kv := NewIndexService(buckets), NewJSONService(buckets, clk), NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	if err := j.JSet(ctx, "b", "s1", "", []byte(`{"user_id":"u1","age":30}`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.CreateIndex(ctx, "b", "by_user", "$.user_id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.JSet(ctx, "b", "s2", "", []byte(`{"user_id":"u1","age":40}`), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.JSet(ctx, "b", "s3", "", []byte(`{"user_id":"u2"}`), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	u1 := IndexQuery{Equal: []byte(`"u1"`)}
+	if got := queryKeys(t, x, u1); !slices.Equal(got, []string{"s1", "s2"}) {
+		t.Fatalf("user_id = u1: %v", got)
+	}
+
+	// Path writes move the document in the index
+	if err := j.JSet(ctx, "b", "s1", "$.user_id", []byte(`"u2"`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryKeys(t, x, u1); !slices.Equal(got, []string{"s2"}) {
+		t.Errorf("after moving s1: %v", got)
+	}
+
+	clk.Advance(11 * time.Second)
+	if got := queryKeys(t, x, u1); len(got) != 0 {
+		t.Errorf("expired document still found: %v", got)
+	}
+
+	if err := kv.Delete(ctx, "b", "s3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryKeys(t, x, IndexQuery{Min: []byte(`"u"`), Max: []byte(`"u9"`)}); !slices.Equal(got, []string{"s1"}) {
+		t.Errorf("range after delete: %v", got)
+	}
+
+	if defs, _ := x.ListIndexes(ctx, "b"); len(defs) != 1 || defs[0].Path != "$.user_id" {
+		t.Errorf("ListIndexes = %v", defs)
+	}
+}
+
+func TestIndexQueryPages(t *testing.T) {
+	b, clk := newTestBucket(bucket.DefaultBucketSettings())
+	buckets := &indexBuckets{testBuckets: b}
+	x, j := NewIndexService(buckets), NewJSONService(buckets, clk)
+	ctx := context.Background()
+
+	if _, err := x.CreateIndex(ctx, "b", "by_user", "/user_id"); err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := range 25 {
+		key := fmt.Sprintf("s%02d", i)
+		if err := j.JSet(ctx, "b", key, "", []byte(`{"user_id":7}`), 0); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, key)
+	}
+
+	var got []string
+	q := IndexQuery{Equal: []byte("7"), Limit: 10}
+	pages := 0
+	for {
+		page, err := x.Query(ctx, "b", "by_user", q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, h := range page.Hits {
+			got = append(got, h.Key)
+		}
+		if page.Cursor == "" {
+			break
+		}
+		q.Cursor = page.Cursor
+	}
+	if pages != 3 || !slices.Equal(got, want) {
+		t.Errorf("%d pages of %v", pages, got)
+	}
+
+	for _, c := range []struct {
+		q    IndexQuery
+		want error
+	}{
+		{IndexQuery{Equal: []byte(`{"a":1}`)}, errs.ErrIndexValue},
+		{IndexQuery{Min: []byte(`nope`)}, errs.ErrInvalidJSON},
+		{IndexQuery{Cursor: "%%%"}, errs.ErrInvalidCursor},
+	} {
+		if _, err := x.Query(ctx, "b", "by_user", c.q); !errors.Is(err, c.want) {
+			t.Errorf("Query(%+v): %v, want %v", c.q, err, c.want)
+		}
+	}
+	if _, err := x.Query(ctx, "b", "missing", IndexQuery{}); !errors.Is(err, errs.ErrIndexNotFound) {
+		t.Errorf("Query of a missing index: %v", err)
+	}
+}
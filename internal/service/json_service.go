@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+)
+
+// IJSONService works on JSON documents, paths are JSON Pointers or a JSONPath
+// subset as accepted by engine.ParseJSONPath, the empty path is the whole document
+type IJSONService interface {
+	// JSet stores data at path, a missing key only accepts the whole document
+	// ttl only applies when the document is created
+	JSet(ctx context.Context, bucketName, key, path string, data []byte, ttl int64) error
+	// JGet returns the encoded value at path
+	JGet(ctx context.Context, bucketName, key, path string) ([]byte, error)
+	// JDel removes the value at path and reports whether it existed, the whole
+	// document goes with the key
+	JDel(ctx context.Context, bucketName, key, path string) (bool, error)
+	// JArrAppend adds values to the array at path and returns its new length
+	JArrAppend(ctx context.Context, bucketName, key, path string, values [][]byte) (int, error)
+	// JIncrBy adds delta to the number at path and returns the result
+	JIncrBy(ctx context.Context, bucketName, key, path string, delta json.Number) (json.Number, error)
+}
+
+type jsonService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewJSONService creates the service, new documents are stamped with the time of clk
+func NewJSONService(bucketManager bucket.BucketManager, clk clock.Clock) IJSONService {
+	return &jsonService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *jsonService) JSet(ctx context.Context, bucketName, key, path string, data []byte, ttl int64) error {
+	p, err := engine.ParseJSONPath(path)
+	if err != nil {
+		return err
+	}
+	return updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(doc *engine.JSON) (*engine.JSON, error) {
+		if doc == nil && len(p) > 0 {
+			return nil, errs.ErrKeyNotFound
+		}
+		return doc.Set(p, data)
+	})
+}
+
+func (s *jsonService) JGet(ctx context.Context, bucketName, key, path string) ([]byte, error) {
+	p, err := engine.ParseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readData[*engine.JSON](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Get(p)
+}
+
+func (s *jsonService) JDel(ctx context.Context, bucketName, key, path string) (bool, error) {
+	p, err := engine.ParseJSONPath(path)
+	if err != nil {
+		return false, err
+	}
+	var existed bool
+	err = updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(doc *engine.JSON) (*engine.JSON, error) {
+		next, ok, err := doc.Delete(p)
+		existed = ok
+		return next, err
+	})
+	return existed, err
+}
+
+func (s *jsonService) JArrAppend(ctx context.Context, bucketName, key, path string, values [][]byte) (int, error) {
+	p, err := engine.ParseJSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+	var length int
+	err = updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(doc *engine.JSON) (*engine.JSON, error) {
+		if doc == nil {
+			return nil, errs.ErrKeyNotFound
+		}
+		next, n, err := doc.Append(p, values)
+		length = n
+		return next, err
+	})
+	return length, err
+}
+
+func (s *jsonService) JIncrBy(ctx context.Context, bucketName, key, path string, delta json.Number) (json.Number, error) {
+	p, err := engine.ParseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	var result json.Number
+	err = updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(doc *engine.JSON) (*engine.JSON, error) {
+		if doc == nil {
+			return nil, errs.ErrKeyNotFound
+		}
+		next, n, err := doc.Incr(p, delta)
+		result = n
+		return next, err
+	})
+	return result, err
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/errs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONDocumentPaths(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	j := NewJSONService(buckets, clk)
+	ctx := context.Background()
+
+	if err := j.JSet(ctx, "b", "cart", "$.items", []byte(`[]`), 0); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("JSet of a path on a missing key: %v", err)
+	}
+	if err := j.JSet(ctx, "b", "cart", "", []byte(`{"items":[],"total":0}`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := j.JArrAppend(ctx, "b", "cart", "$.items", [][]byte{[]byte(`{"sku":"a1","qty":1}`)}); err != nil || n != 1 {
+		t.Errorf("JArrAppend = %d, %v", n, err)
+	}
+	if _, err := j.JIncrBy(ctx, "b", "cart", "/items/0/qty", "2"); err != nil {
+		t.Error(err)
+	}
+	if err := j.JSet(ctx, "b", "cart", "$.note", []byte(`"leave at door"`), 0); err != nil {
+		t.Error(err)
+	}
+
+	got, err := j.JGet(ctx, "b", "cart", "$")
+	if want := `{"items":[{"qty":3,"sku":"a1"}],"note":"leave at door","total":0}`; err != nil || string(got) != want {
+		t.Errorf("JGet = %s, %v, want %s", got, err, want)
+	}
+	if _, err := j.JGet(ctx, "b", "cart", "items"); !errors.Is(err, errs.ErrInvalidPath) {
+		t.Errorf("JGet with an invalid path: %v", err)
+	}
+	if err := j.JSet(ctx, "b", "cart", "$.note", []byte(`not json`), 0); !errors.Is(err, errs.ErrInvalidJSON) {
+		t.Errorf("JSet of invalid JSON: %v", err)
+	}
+
+	if ok, err := j.JDel(ctx, "b", "cart", "$.note"); err != nil || !ok {
+		t.Errorf("JDel = %v, %v", ok, err)
+	}
+	if ok, err := j.JDel(ctx, "b", "cart", "$"); err != nil || !ok {
+		t.Errorf("JDel of the document = %v, %v", ok, err)
+	}
+	if _, err := j.JGet(ctx, "b", "cart", ""); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("JGet after deleting the document: %v", err)
+	}
+}
+
+func TestJSONTypesAndLimits(t *testing.T) {
+	settings := bucket.DefaultBucketSettings()
+	settings.DefaultTTL = 10
+	settings.MaxValueSize = 200
+	buckets, clk := newTestBucket(settings)
+	j, h := NewJSONService(buckets, clk), NewHashService(buckets, clk)
+	ctx := context.Background()
+
+	h.HSet(ctx, "b", "hash", map[string][]byte{"f": []byte("v")}, 0)
+	if err := j.JSet(ctx, "b", "hash", "", []byte(`{}`), 0); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("JSet on a hash: %v", err)
+	}
+
+	if err := j.JSet(ctx, "b", "doc", "", []byte(`{"s":""}`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.JSet(ctx, "b", "doc", "$.s", []byte(`"`+strings.Repeat("x", 300)+`"`), 0); !errors.Is(err, errs.ErrValueTooLarge) {
+		t.Errorf("JSet past max_value_size: %v", err)
+	}
+	if _, err := j.JIncrBy(ctx, "b", "doc", "$.s", "1"); !errors.Is(err, errs.ErrPathType) {
+		t.Errorf("JIncrBy of a string: %v", err)
+	}
+
+	// Path updates keep the expiration set at creation
+	clk.Advance(8 * time.Second)
+	if err := j.JSet(ctx, "b", "doc", "$.s", []byte(`"x"`), 0); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(3 * time.Second)
+	if _, err := j.JGet(ctx, "b", "doc", ""); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("JGet after the default TTL: %v", err)
+	}
+}
+
+func TestJSONConcurrentIncr(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	j := NewJSONService(buckets, clk)
+	ctx := context.Background()
+	j.JSet(ctx, "b", "counter", "", []byte(`{"hits":0}`), 0)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				j.JIncrBy(ctx, "b", "counter", "$.hits", "1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := j.JGet(ctx, "b", "counter", "$.hits"); string(got) != "800" {
+		t.Errorf("hits = %s, want 800", got)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"time"
+)
+
+// ListEnd selects the end of a list an operation works on
+type ListEnd uint8
+
+const (
+	ListLeft ListEnd = iota
+	ListRight
+)
+
+func ParseListEnd(s string) (ListEnd, bool) {
+	switch s {
+	case "left":
+		return ListLeft, true
+	case "right":
+		return ListRight, true
+	default:
+		return 0, false
+	}
+}
+
+type IListService interface {
+	// Push adds values at end and returns the new length, ttl only applies when the list is created
+	// At the left end the values are pushed one after the other, the last one ends up first
+	Push(ctx context.Context, bucketName, key string, end ListEnd, values [][]byte, ttl int64) (int, error)
+	// Pop removes up to count values from end, a missing list fails with ErrKeyNotFound
+	// With a timeout it waits for a push instead, for at most MaxWait, and fails with ErrWaitTimeout
+	Pop(ctx context.Context, bucketName, key string, end ListEnd, count int, timeout time.Duration) ([][]byte, error)
+	// Range returns the values from start to stop included, negative indexes count from the end
+	Range(ctx context.Context, bucketName, key string, start, stop int64) ([][]byte, error)
+	// Trim keeps the values from start to stop included and returns the new length
+	Trim(ctx context.Context, bucketName, key string, start, stop int64) (int, error)
+	Len(ctx context.Context, bucketName, key string) (int, error)
+	// Close releases the blocked pops, later ones time out at once
+	Close()
+}
+
+type listService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+	waiters       *waiters
+}
+
+// NewListService creates the service, new lists are stamped with the time of clk
+func NewListService(bucketManager bucket.BucketManager, clk clock.Clock) IListService {
+	return &listService{
+		bucketManager: bucketManager,
+		clock:         clk,
+		waiters:       newWaiters(),
+	}
+}
+
+func (s *listService) Push(ctx context.Context, bucketName, key string, end ListEnd, values [][]byte, ttl int64) (int, error) {
+	var length int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(l *engine.List) (*engine.List, error) {
+		if end == ListLeft {
+			l = l.PushFront(values...)
+		} else {
+			l = l.PushBack(values...)
+		}
+		length = l.Len()
+		return l, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if store, ok := s.bucketManager.GetStore(bucketName); ok {
+		s.waiters.wake(waitKey{store: store, key: key}, len(values))
+	}
+	return length, nil
+}
+
+func (s *listService) Pop(ctx context.Context, bucketName, key string, end ListEnd, count int, timeout time.Duration) ([][]byte, error) {
+	count = max(count, 1)
+	if timeout <= 0 {
+		return s.pop(ctx, bucketName, key, end, count)
+	}
+
+	store, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+
+	var out [][]byte
+	err := s.waiters.wait(ctx, waitKey{store: store, key: key}, timeout, func() (bool, error) {
+		var err error
+		out, err = s.pop(ctx, bucketName, key, end, count)
+		if errors.Is(err, errs.ErrKeyNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return out, err
+}
+
+func (s *listService) pop(ctx context.Context, bucketName, key string, end ListEnd, count int) ([][]byte, error) {
+	var out [][]byte
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(l *engine.List) (*engine.List, error) {
+		if l.Len() == 0 {
+			return nil, errs.ErrKeyNotFound
+		}
+		if end == ListLeft {
+			l, out = l.PopFront(count)
+		} else {
+			l, out = l.PopBack(count)
+		}
+		return l, nil
+	})
+	return out, err
+}
+
+func (s *listService) Range(ctx context.Context, bucketName, key string, start, stop int64) ([][]byte, error) {
+	l, err := readData[*engine.List](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	from, to := span(start, stop, l.Len())
+	return l.Range(from, to), nil
+}
+
+func (s *listService) Trim(ctx context.Context, bucketName, key string, start, stop int64) (int, error) {
+	var length int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(l *engine.List) (*engine.List, error) {
+		from, to := span(start, stop, l.Len())
+		l = l.Trim(from, to)
+		length = l.Len()
+		return l, nil
+	})
+	return length, err
+}
+
+func (s *listService) Len(ctx context.Context, bucketName, key string) (int, error) {
+	l, err := readData[*engine.List](ctx, s.bucketManager, bucketName, key)
+	if errors.Is(err, errs.ErrKeyNotFound) {
+		return 0, nil
+	}
+	return l.Len(), err
+}
+
+func (s *listService) Close() { s.waiters.close() }
+
+// span converts inclusive start and stop indexes, negative ones counting from
+// the end, to the range [from, to) within [0, n]
+func span(start, stop int64, n int) (int, int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	start = max(start, 0)
+	stop = min(stop+1, int64(n))
+	if start >= stop {
+		return 0, 0
+	}
+	return int(start), int(stop)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/errs"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func strs(values [][]byte) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
+
+func TestListRangeAndTrim(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l := NewListService(buckets, clk)
+	ctx := context.Background()
+
+	if _, err := l.Push(ctx, "b", "q", ListRight, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		start, stop int64
+		want        []string
+	}{
+		{0, -1, []string{"a", "b", "c", "d"}},
+		{1, 2, []string{"b", "c"}},
+		{-2, -1, []string{"c", "d"}},
+		{-100, 0, []string{"a"}},
+		{3, 100, []string{"d"}},
+		{2, 1, []string{}},
+	} {
+		got, err := l.Range(ctx, "b", "q", c.start, c.stop)
+		if err != nil || !slices.Equal(strs(got), c.want) {
+			t.Errorf("Range(%d, %d) = %v, %v, want %v", c.start, c.stop, strs(got), err, c.want)
+		}
+	}
+
+	if n, err := l.Trim(ctx, "b", "q", 1, -2); err != nil || n != 2 {
+		t.Fatalf("Trim = %d, %v", n, err)
+	}
+	if n, _ := l.Trim(ctx, "b", "q", 5, 10); n != 0 {
+		t.Errorf("Trim out of range left %d values", n)
+	}
+	if _, err := l.Range(ctx, "b", "q", 0, -1); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("list trimmed to nothing still exists: %v", err)
+	}
+}
+
+func TestListBlockingPopWokenByPush(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l := NewListService(buckets, clk)
+	ctx := context.Background()
+
+	const workers = 4
+	results := make(chan string, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.Pop(ctx, "b", "jobs", ListLeft, 1, time.Minute)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- string(v[0])
+		}()
+	}
+
+	// Each push wakes one parked worker, none of them polls
+	time.Sleep(20 * time.Millisecond)
+	for _, job := range []string{"1", "2", "3", "4"} {
+		if _, err := l.Push(ctx, "b", "jobs", ListRight, [][]byte{[]byte(job)}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	close(results)
+
+	var got []string
+	for v := range results {
+		got = append(got, v)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"1", "2", "3", "4"}) {
+		t.Errorf("workers popped %v", got)
+	}
+	if n := len(l.(*listService).waiters.queues); n != 0 {
+		t.Errorf("%d wait queues left", n)
+	}
+}
+
+func TestListBlockingPopTimeoutAndClose(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l := NewListService(buckets, clk)
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := l.Pop(ctx, "b", "empty", ListLeft, 1, 30*time.Millisecond); !errors.Is(err, errs.ErrWaitTimeout) {
+		t.Fatalf("Pop on an empty list: %v, want ErrWaitTimeout", err)
+	}
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("Pop returned after %v, before its timeout", d)
+	}
+	if _, err := l.Pop(ctx, "b", "empty", ListLeft, 1, 0); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("Pop without timeout: %v, want ErrKeyNotFound", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Pop(ctx, "b", "empty", ListLeft, 1, time.Minute)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	l.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errs.ErrWaitTimeout) {
+			t.Errorf("blocked Pop after Close: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not release the blocked Pop")
+	}
+}
+
+func TestListTTLAndWrongType(t *testing.T) {
+	settings := bucket.DefaultBucketSettings()
+	settings.MaxTTL = 60
+	buckets, clk := newTestBucket(settings)
+	l := NewListService(buckets, clk)
+	ctx := context.Background()
+
+	if _, err := l.Push(ctx, "b", "q", ListRight, [][]byte{[]byte("a")}, 61); !errors.Is(err, errs.ErrTTLExceedsLimit) {
+		t.Errorf("Push with a TTL above the max: %v", err)
+	}
+	if _, err := l.Push(ctx, "b", "q", ListRight, [][]byte{[]byte("a")}, 10); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(11 * time.Second)
+	if n, _ := l.Len(ctx, "b", "q"); n != 0 {
+		t.Errorf("expired list holds %d values", n)
+	}
+
+	h := NewHashService(l.(*listService).bucketManager, clk)
+	if _, err := h.HSet(ctx, "b", "h", map[string][]byte{"f": []byte("v")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Pop(ctx, "b", "h", ListLeft, 1, time.Minute); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("blocking Pop on a hash: %v, want ErrWrongType at once", err)
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// LockInfo describes a held lock, Token fences the writes of its owner
+type LockInfo struct {
+	Owner      string
+	Token      uint64
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+// ILockService manages locks held under a lease, a lock whose lease lapses
+// is freed by the collector like any expiring key
+// Leases are in seconds and bounded by the max TTL of the bucket
+type ILockService interface {
+	// Acquire takes the lock for owner with a fresh fencing token, greater
+	// than any token handed out before; acquiring a lock owner already holds
+	// renews it with a new token
+	// A lock held by another owner fails with ErrLockHeld, with a timeout it
+	// waits for its release instead, for at most MaxWait
+	Acquire(ctx context.Context, bucketName, name, owner string, ttl int64, timeout time.Duration) (*LockInfo, error)
+	// Renew restarts the lease of a lock held by owner, keeping its token
+	Renew(ctx context.Context, bucketName, name, owner string, ttl int64) (*LockInfo, error)
+	// Release frees a lock held by owner and hands it to the next waiter
+	Release(ctx context.Context, bucketName, name, owner string) error
+	Info(ctx context.Context, bucketName, name string) (*LockInfo, error)
+	// Close releases the blocked acquires, later ones fail at once
+	Close()
+}
+
+type lockService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+	waiters       *waiters
+	lastToken     atomic.Uint64
+}
+
+// NewLockService creates the service, leases run on the time of clk
+func NewLockService(bucketManager bucket.BucketManager, clk clock.Clock) ILockService {
+	return &lockService{
+		bucketManager: bucketManager,
+		clock:         clk,
+		waiters:       newWaiters(),
+	}
+}
+
+func (s *lockService) Acquire(ctx context.Context, bucketName, name, owner string, ttl int64, timeout time.Duration) (*LockInfo, error) {
+	info, held, err := s.acquire(ctx, bucketName, name, owner, ttl)
+	if err != nil || !held {
+		return info, err
+	}
+	if timeout <= 0 {
+		return nil, errs.ErrLockHeld
+	}
+
+	store, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+
+	// The timeout runs on real time like the timer of the wait, the lease on
+	// the clock like every expiration
+	deadline := time.Now().Add(min(timeout, MaxWait))
+	for {
+		// A lapsing lease wakes nobody, the wait ends with it to try again
+		lease := max(info.ExpiresAt.Sub(s.clock.Now()), time.Millisecond)
+		wait := min(time.Until(deadline), lease)
+		err = s.waiters.wait(ctx, waitKey{store: store, key: name}, wait, func() (bool, error) {
+			var err error
+			info, held, err = s.acquire(ctx, bucketName, name, owner, ttl)
+			return !held, err
+		})
+		if !errors.Is(err, errs.ErrWaitTimeout) {
+			if err != nil {
+				return nil, err
+			}
+			return info, nil
+		}
+
+		select {
+		case <-s.waiters.done:
+			return nil, errs.ErrLockHeld
+		default:
+		}
+		if !time.Now().Before(deadline) {
+			return nil, errs.ErrLockHeld
+		}
+	}
+}
+
+// acquire takes the lock unless another owner holds it, in which case held is
+// set and info describes the holder
+func (s *lockService) acquire(ctx context.Context, bucketName, name, owner string, ttl int64) (info *LockInfo, held bool, err error) {
+	err = s.updateLock(ctx, bucketName, name, owner, ttl, func(cur *engine.Lock) (*engine.Lock, error) {
+		if cur != nil && cur.Owner != owner {
+			held = true
+			return nil, nil
+		}
+		return &engine.Lock{Owner: owner, Token: s.nextToken(), AcquiredAt: s.clock.Now()}, nil
+	}, func(e engine.StorageEntry) { info = lockInfo(e) })
+	return info, held, err
+}
+
+func (s *lockService) Renew(ctx context.Context, bucketName, name, owner string, ttl int64) (*LockInfo, error) {
+	var info *LockInfo
+	err := s.updateLock(ctx, bucketName, name, owner, ttl, func(cur *engine.Lock) (*engine.Lock, error) {
+		if cur == nil || cur.Owner != owner {
+			return nil, errs.ErrLockNotOwned
+		}
+		return cur, nil
+	}, func(e engine.StorageEntry) { info = lockInfo(e) })
+	return info, err
+}
+
+// updateLock applies fn to the lock held under name and restarts its lease,
+// a nil lock returned by fn leaves the entry as it is
+// done gets the resulting entry
+func (s *lockService) updateLock(ctx context.Context, bucketName, name, owner string, ttl int64, fn func(cur *engine.Lock) (*engine.Lock, error), done func(engine.StorageEntry)) error {
+	if owner == "" || ttl <= 0 {
+		return errs.ErrInvalidLock
+	}
+
+	bucketStore, settings, ok := s.bucketManager.GetStoreAndSettings(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return errs.ErrBucketNotFound
+	}
+	if len(name) > settings.MaxKeyLength {
+		return errs.ErrKeyTooLong
+	}
+	if len(owner) > settings.MaxKeyLength {
+		return errs.ErrInvalidLock
+	}
+	ttl, err := settings.ResolveTTL(ttl)
+	if err != nil {
+		return err
+	}
+
+	return bucketStore.Update(name, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		var lock *engine.Lock
+		if found {
+			var ok bool
+			if lock, ok = cur.Data.(*engine.Lock); !ok {
+				return cur, false, errs.WrongType(engine.KindLock, cur.Kind())
+			}
+		}
+
+		next, err := fn(lock)
+		if err != nil {
+			return cur, false, err
+		}
+		if next == nil {
+			done(cur)
+			return cur, false, nil
+		}
+		if !found && settings.MaxKeys > 0 && bucketStore.Count() >= settings.MaxKeys {
+			return cur, false, errs.ErrKeyLimit
+		}
+
+		now := s.clock.Now()
+		entry := engine.StorageEntry{
+			Key:       name,
+			TTL:       ttl,
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Duration(ttl) * time.Second),
+			Data:      next,
+		}
+		done(entry)
+		return entry, false, nil
+	})
+}
+
+// nextToken returns a token greater than the previous ones, starting from
+// the clock so that tokens keep growing across restarts
+func (s *lockService) nextToken() uint64 {
+	for {
+		last := s.lastToken.Load()
+		next := max(last+1, uint64(max(s.clock.Now().UnixMicro(), 0)))
+		if s.lastToken.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+func (s *lockService) Release(ctx context.Context, bucketName, name, owner string) error {
+	bucketStore, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return errs.ErrBucketNotFound
+	}
+
+	err := bucketStore.Update(name, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		if !found {
+			return cur, false, errs.ErrLockNotOwned
+		}
+		lock, ok := cur.Data.(*engine.Lock)
+		if !ok {
+			return cur, false, errs.WrongType(engine.KindLock, cur.Kind())
+		}
+		if lock.Owner != owner {
+			return cur, false, errs.ErrLockNotOwned
+		}
+		return cur, true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.waiters.wake(waitKey{store: bucketStore, key: name}, 1)
+	return nil
+}
+
+func (s *lockService) Info(ctx context.Context, bucketName, name string) (*LockInfo, error) {
+	bucketStore, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return nil, errs.ErrBucketNotFound
+	}
+
+	entry, exists := bucketStore.Get(name)
+	if !exists {
+		return nil, errs.ErrKeyNotFound
+	}
+	if _, ok := entry.Data.(*engine.Lock); !ok {
+		return nil, errs.WrongType(engine.KindLock, entry.Kind())
+	}
+	return lockInfo(entry), nil
+}
+
+func (s *lockService) Close() { s.waiters.close() }
+
+func lockInfo(e engine.StorageEntry) *LockInfo {
+	lock := e.Data.(*engine.Lock)
+	return &LockInfo{Owner: lock.Owner, Token: lock.Token, AcquiredAt: lock.AcquiredAt, ExpiresAt: e.ExpiresAt}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"testing"
+	"time"
+)
+
+func TestLockOwnershipAndFencing(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l := NewLockService(buckets, clk)
+	ctx := context.Background()
+
+	first, err := l.Acquire(ctx, "b", "job", "a", 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.ExpiresAt.Equal(clk.Now().Add(30 * time.Second)) {
+		t.Errorf("lease ends at %v", first.ExpiresAt)
+	}
+	if _, err := l.Acquire(ctx, "b", "job", "b", 30, 0); !errors.Is(err, errs.ErrLockHeld) {
+		t.Errorf("Acquire of a held lock: %v", err)
+	}
+	if _, err := l.Renew(ctx, "b", "job", "b", 30); !errors.Is(err, errs.ErrLockNotOwned) {
+		t.Errorf("Renew by another owner: %v", err)
+	}
+	if err := l.Release(ctx, "b", "job", "b"); !errors.Is(err, errs.ErrLockNotOwned) {
+		t.Errorf("Release by another owner: %v", err)
+	}
+
+	clk.Advance(20 * time.Second)
+	renewed, err := l.Renew(ctx, "b", "job", "a", 30)
+	if err != nil || renewed.Token != first.Token || !renewed.ExpiresAt.Equal(clk.Now().Add(30*time.Second)) {
+		t.Fatalf("Renew = %+v, %v", renewed, err)
+	}
+
+	// The lease lapses without a renewal and the lock goes to the next owner
+	clk.Advance(31 * time.Second)
+	if _, err := l.Info(ctx, "b", "job"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("Info of a lapsed lock: %v", err)
+	}
+	second, err := l.Acquire(ctx, "b", "job", "b", 30, 0)
+	if err != nil || second.Token <= first.Token {
+		t.Fatalf("Acquire after the lease = %+v, %v", second, err)
+	}
+	if _, err := l.Renew(ctx, "b", "job", "a", 30); !errors.Is(err, errs.ErrLockNotOwned) {
+		t.Errorf("Renew by the former owner: %v", err)
+	}
+	if again, err := l.Acquire(ctx, "b", "job", "b", 30, 0); err != nil || again.Token <= second.Token {
+		t.Errorf("Acquire by the owner = %+v, %v", again, err)
+	}
+
+	for _, ttl := range []int64{0, -1} {
+		if _, err := l.Acquire(ctx, "b", "other", "a", ttl, 0); !errors.Is(err, errs.ErrInvalidLock) {
+			t.Errorf("Acquire with lease %d: %v", ttl, err)
+		}
+	}
+	if _, err := l.Acquire(ctx, "b", "other", "", 30, 0); !errors.Is(err, errs.ErrInvalidLock) {
+		t.Errorf("Acquire without owner: %v", err)
+	}
+}
+
+func TestLockWrongType(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l := NewLockService(buckets, clk)
+	ctx := context.Background()
+	buckets.store.Set("plain", engine.StorageEntry{Key: "plain", Value: []byte("v"), CreatedAt: clk.Now()})
+
+	if _, err := l.Acquire(ctx, "b", "plain", "a", 30, 0); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("Acquire of a plain value: %v", err)
+	}
+	if err := l.Release(ctx, "b", "plain", "a"); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("Release of a plain value: %v", err)
+	}
+}
+
+func TestLockNotFreedByDelete(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l, kv := NewLockService(buckets, clk), NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	held, _ := l.Acquire(ctx, "b", "job", "a", 30, 0)
+	if err := kv.Delete(ctx, "b", "job"); !errors.Is(err, errs.ErrLockHeld) {
+		t.Errorf("Delete of a held lock: %v", err)
+	}
+	if info, err := l.Info(ctx, "b", "job"); err != nil || info.Owner != "a" || info.Token != held.Token {
+		t.Errorf("lock after Delete = %+v, %v", info, err)
+	}
+	if _, err := l.Acquire(ctx, "b", "job", "b", 30, 0); !errors.Is(err, errs.ErrLockHeld) {
+		t.Errorf("Acquire after Delete: %v", err)
+	}
+}
+
+func TestLockBlockingAcquire(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	l := NewLockService(buckets, clk)
+	ctx := context.Background()
+
+	// The timeout runs on real time, the frozen clock does not hold it up
+	held, _ := l.Acquire(ctx, "b", "job", "a", 30, 0)
+	timedOut := make(chan error)
+	start := time.Now()
+	go func() {
+		_, err := l.Acquire(ctx, "b", "job", "b", 30, 20*time.Millisecond)
+		timedOut <- err
+	}()
+	select {
+	case err := <-timedOut:
+		if !errors.Is(err, errs.ErrLockHeld) {
+			t.Errorf("timed out Acquire: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("Acquire ended after %v, before its deadline", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire outlived its timeout")
+	}
+
+	got := make(chan *LockInfo)
+	go func() {
+		info, err := l.Acquire(ctx, "b", "job", "b", 30, time.Minute)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- info
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := l.Release(ctx, "b", "job", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if info := <-got; info == nil || info.Owner != "b" || info.Token <= held.Token {
+		t.Errorf("waiter got %+v", info)
+	}
+
+	errc := make(chan error)
+	go func() {
+		_, err := l.Acquire(ctx, "b", "job", "c", 30, time.Minute)
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	l.Close()
+	if err := <-errc; !errors.Is(err, errs.ErrLockHeld) {
+		t.Errorf("Acquire released by Close: %v", err)
+	}
+}
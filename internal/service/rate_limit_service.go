@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"time"
+)
+
+// IRateLimitService enforces token bucket limits keyed by arbitrary strings,
+// the state of a limiter is an entry that expires once its bucket is full
+type IRateLimitService interface {
+	// Take removes cost tokens from the limiter held by key, a missing one
+	// being full, and reports whether they were available; a cost of 0 reads
+	// the limiter without changing it
+	// The time limit takes to refill must fit the max TTL of the bucket
+	Take(ctx context.Context, bucketName, key string, limit engine.RateLimit, cost int64) (engine.RateResult, error)
+}
+
+type rateLimitService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewRateLimitService creates the service, tokens refill on the time of clk
+func NewRateLimitService(bucketManager bucket.BucketManager, clk clock.Clock) IRateLimitService {
+	return &rateLimitService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *rateLimitService) Take(ctx context.Context, bucketName, key string, limit engine.RateLimit, cost int64) (engine.RateResult, error) {
+	if err := limit.Validate(); err != nil {
+		return engine.RateResult{}, err
+	}
+	if cost < 0 || cost > limit.Capacity {
+		return engine.RateResult{}, errs.ErrInvalidRateLimit
+	}
+
+	bucketStore, settings, ok := s.bucketManager.GetStoreAndSettings(bucketName)
+	if !ok {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
+		return engine.RateResult{}, errs.ErrBucketNotFound
+	}
+	if len(key) > settings.MaxKeyLength {
+		return engine.RateResult{}, errs.ErrKeyTooLong
+	}
+	if _, err := settings.ResolveTTL(ceilSeconds(limit.Refill())); err != nil {
+		return engine.RateResult{}, err
+	}
+
+	var res engine.RateResult
+	err := bucketStore.Update(key, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		var limiter *engine.RateLimiter
+		if found {
+			var ok bool
+			if limiter, ok = cur.Data.(*engine.RateLimiter); !ok {
+				return cur, false, errs.WrongType(engine.KindRateLimiter, cur.Kind())
+			}
+		}
+
+		now := s.clock.Now()
+		var next *engine.RateLimiter
+		next, res = limiter.Take(now, limit, cost)
+		if !res.Allowed || cost == 0 {
+			// Nothing to write, a missing limiter stays missing
+			return cur, !found, nil
+		}
+		if !found && settings.MaxKeys > 0 && bucketStore.Count() >= settings.MaxKeys {
+			return cur, false, errs.ErrKeyLimit
+		}
+
+		// The entry outlives the refill by less than a second, a full
+		// limiter is the same as a missing one
+		ttl := ceilSeconds(res.ResetAfter)
+		return engine.StorageEntry{
+			Key:       key,
+			TTL:       ttl,
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Duration(ttl) * time.Second),
+			Data:      next,
+		}, false, nil
+	})
+	if err != nil {
+		return engine.RateResult{}, err
+	}
+	return res, nil
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	s := int64(d / time.Second)
+	if d%time.Second != 0 {
+		s++
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTakeAndExpiry(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	r := NewRateLimitService(buckets, clk)
+	ctx := context.Background()
+	// 3 requests per 3 seconds
+	limit := engine.RateLimit{Capacity: 3, Rate: 1, Period: time.Second}
+
+	for i := range 3 {
+		res, err := r.Take(ctx, "b", "ip", limit, 1)
+		if err != nil || !res.Allowed || res.Remaining != int64(2-i) {
+			t.Fatalf("Take %d = %+v, %v", i, res, err)
+		}
+	}
+	res, err := r.Take(ctx, "b", "ip", limit, 1)
+	if err != nil || res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("Take of an empty limiter = %+v, %v", res, err)
+	}
+
+	entry, ok := buckets.store.Get("ip")
+	if !ok || entry.TTL != 3 || !entry.ExpiresAt.Equal(clk.Now().Add(3*time.Second)) {
+		t.Fatalf("limiter entry %+v", entry)
+	}
+
+	// The entry expires once the limiter is full again
+	clk.Advance(3*time.Second + time.Millisecond)
+	if _, ok := buckets.store.Get("ip"); ok {
+		t.Error("full limiter still stored")
+	}
+	if res, _ := r.Take(ctx, "b", "ip", limit, 0); !res.Allowed || res.Remaining != 3 {
+		t.Errorf("Take of nothing = %+v", res)
+	}
+	if _, ok := buckets.store.Get("other"); ok {
+		t.Error("Take of nothing stored a limiter")
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	settings := bucket.DefaultBucketSettings()
+	settings.MaxTTL = 60
+	buckets, clk := newTestBucket(settings)
+	r := NewRateLimitService(buckets, clk)
+	ctx := context.Background()
+	limit := engine.RateLimit{Capacity: 10, Rate: 1, Period: time.Second}
+
+	for _, cost := range []int64{-1, 11} {
+		if _, err := r.Take(ctx, "b", "k", limit, cost); !errors.Is(err, errs.ErrInvalidRateLimit) {
+			t.Errorf("Take of %d: %v", cost, err)
+		}
+	}
+	// An hour to refill does not fit the 60s max TTL
+	slow := engine.RateLimit{Capacity: 60, Rate: 1, Period: time.Minute}
+	if _, err := r.Take(ctx, "b", "k", slow, 1); !errors.Is(err, errs.ErrTTLExceedsLimit) {
+		t.Errorf("Take of a slow limit: %v", err)
+	}
+
+	buckets.store.Set("plain", engine.StorageEntry{Key: "plain", Value: []byte("v"), CreatedAt: clk.Now()})
+	if _, err := r.Take(ctx, "b", "plain", limit, 1); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("Take of a plain value: %v", err)
+	}
+}
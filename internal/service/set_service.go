@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"slices"
+)
+
+type ISetService interface {
+	// SAdd adds members to a set and returns how many were new
+	// ttl only applies when the set is created
+	SAdd(ctx context.Context, bucketName, key string, members []string, ttl int64) (int, error)
+	// SRem removes members and returns how many existed, the key goes with the last member
+	SRem(ctx context.Context, bucketName, key string, members []string) (int, error)
+	// SIsMember reports whether member is in the set, a missing set holds nothing
+	SIsMember(ctx context.Context, bucketName, key, member string) (bool, error)
+	// SMembers returns the members in lexicographic order
+	SMembers(ctx context.Context, bucketName, key string) ([]string, error)
+	// SUnion, SInter and SDiff combine the sets held by keys, missing ones count as empty
+	// SDiff returns the members of the first set that are in none of the others
+	SUnion(ctx context.Context, bucketName string, keys []string) ([]string, error)
+	SInter(ctx context.Context, bucketName string, keys []string) ([]string, error)
+	SDiff(ctx context.Context, bucketName string, keys []string) ([]string, error)
+}
+
+type setService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewSetService creates the service, new sets are stamped with the time of clk
+func NewSetService(bucketManager bucket.BucketManager, clk clock.Clock) ISetService {
+	return &setService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *setService) SAdd(ctx context.Context, bucketName, key string, members []string, ttl int64) (int, error) {
+	var added int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(set *engine.Set) (*engine.Set, error) {
+		next, n := set.Add(members...)
+		added = n
+		return next, nil
+	})
+	return added, err
+}
+
+func (s *setService) SRem(ctx context.Context, bucketName, key string, members []string) (int, error) {
+	var removed int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(set *engine.Set) (*engine.Set, error) {
+		next, n := set.Remove(members...)
+		removed = n
+		return next, nil
+	})
+	return removed, err
+}
+
+func (s *setService) SIsMember(ctx context.Context, bucketName, key, member string) (bool, error) {
+	set, err := s.read(ctx, bucketName, key)
+	return set.Has(member), err
+}
+
+func (s *setService) SMembers(ctx context.Context, bucketName, key string) ([]string, error) {
+	set, err := readData[*engine.Set](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	return set.Members(), nil
+}
+
+func (s *setService) SUnion(ctx context.Context, bucketName string, keys []string) ([]string, error) {
+	sets, err := s.readAll(ctx, bucketName, keys)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	for _, set := range sets {
+		for _, m := range set.Members() {
+			seen[m] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for m := range seen {
+		out = append(out, m)
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func (s *setService) SInter(ctx context.Context, bucketName string, keys []string) ([]string, error) {
+	sets, err := s.readAll(ctx, bucketName, keys)
+	if err != nil || len(sets) == 0 {
+		return []string{}, err
+	}
+	// Walking the smallest set bounds the lookups
+	slices.SortFunc(sets, func(a, b *engine.Set) int { return a.Len() - b.Len() })
+	return filterMembers(sets[0], sets[1:], true), nil
+}
+
+func (s *setService) SDiff(ctx context.Context, bucketName string, keys []string) ([]string, error) {
+	sets, err := s.readAll(ctx, bucketName, keys)
+	if err != nil || len(sets) == 0 {
+		return []string{}, err
+	}
+	return filterMembers(sets[0], sets[1:], false), nil
+}
+
+// filterMembers returns the members of set that are in all others when inAll
+// is set, or in none of them otherwise
+func filterMembers(set *engine.Set, others []*engine.Set, inAll bool) []string {
+	out := []string{}
+	for _, m := range set.Members() {
+		keep := true
+		for _, o := range others {
+			if o.Has(m) != inAll {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// read returns the set held by key, nil when it is missing
+func (s *setService) read(ctx context.Context, bucketName, key string) (*engine.Set, error) {
+	set, err := readData[*engine.Set](ctx, s.bucketManager, bucketName, key)
+	if errors.Is(err, errs.ErrKeyNotFound) {
+		return nil, nil
+	}
+	return set, err
+}
+
+// readAll reads each key on its own, the sets are not a snapshot of one instant
+func (s *setService) readAll(ctx context.Context, bucketName string, keys []string) ([]*engine.Set, error) {
+	sets := make([]*engine.Set, len(keys))
+	for i, key := range keys {
+		set, err := s.read(ctx, bucketName, key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
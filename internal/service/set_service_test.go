@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"slices"
+	"testing"
+)
+
+func TestSetMembership(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	s := NewSetService(buckets, clk)
+	ctx := context.Background()
+
+	if n, err := s.SAdd(ctx, "b", "tags", []string{"go", "kv", "go"}, 0); err != nil || n != 2 {
+		t.Fatalf("SAdd = %d, %v", n, err)
+	}
+	if ok, err := s.SIsMember(ctx, "b", "tags", "kv"); err != nil || !ok {
+		t.Errorf("SIsMember(kv) = %v, %v", ok, err)
+	}
+	if ok, err := s.SIsMember(ctx, "b", "missing", "kv"); err != nil || ok {
+		t.Errorf("SIsMember on a missing set = %v, %v", ok, err)
+	}
+
+	if n, err := s.SRem(ctx, "b", "tags", []string{"go", "kv", "rust"}); err != nil || n != 2 {
+		t.Errorf("SRem = %d, %v", n, err)
+	}
+	if _, err := s.SMembers(ctx, "b", "tags"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("SMembers after removing every member: %v", err)
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	s := NewSetService(buckets, clk)
+	ctx := context.Background()
+
+	s.SAdd(ctx, "b", "x", []string{"a", "b", "c"}, 0)
+	s.SAdd(ctx, "b", "y", []string{"b", "c", "d"}, 0)
+	s.SAdd(ctx, "b", "z", []string{"c", "e"}, 0)
+
+	for _, c := range []struct {
+		name string
+		op   func(context.Context, string, []string) ([]string, error)
+		keys []string
+		want []string
+	}{
+		{"union", s.SUnion, []string{"x", "y", "z"}, []string{"a", "b", "c", "d", "e"}},
+		{"inter", s.SInter, []string{"x", "y", "z"}, []string{"c"}},
+		{"inter with a missing key", s.SInter, []string{"x", "missing"}, []string{}},
+		{"diff", s.SDiff, []string{"x", "y"}, []string{"a"}},
+		{"diff with a missing key", s.SDiff, []string{"x", "missing"}, []string{"a", "b", "c"}},
+		{"union of missing keys", s.SUnion, []string{"missing"}, []string{}},
+	} {
+		got, err := c.op(ctx, "b", c.keys)
+		if err != nil || !slices.Equal(got, c.want) {
+			t.Errorf("%s = %v, %v, want %v", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestSetWrongType(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	s, z, kv := NewSetService(buckets, clk), NewSortedSetService(buckets, clk), NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	kv.Set(ctx, "b", "plain", []byte("v"), 0, false)
+	z.ZAdd(ctx, "b", "ranked", []engine.ScoredMember{{Member: "a", Score: 1}}, 0)
+
+	var wrong *errs.WrongTypeError
+	if _, err := s.SAdd(ctx, "b", "plain", []string{"a"}, 0); !errors.As(err, &wrong) || wrong.Have != "string" || wrong.Want != "set" {
+		t.Errorf("SAdd on a plain value: %v", err)
+	}
+	if _, err := s.SUnion(ctx, "b", []string{"ranked"}); !errors.As(err, &wrong) || wrong.Have != "sorted set" {
+		t.Errorf("SUnion over a sorted set: %v", err)
+	}
+	if _, err := z.ZRange(ctx, "b", "plain", 0, -1); !errors.Is(err, errs.ErrWrongType) {
+		t.Errorf("ZRange on a plain value: %v", err)
+	}
+}
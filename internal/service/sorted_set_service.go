@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"math"
+)
+
+type ISortedSetService interface {
+	// ZAdd sets the score of members and returns how many were new, scores must be finite
+	// ttl only applies when the sorted set is created
+	ZAdd(ctx context.Context, bucketName, key string, members []engine.ScoredMember, ttl int64) (int, error)
+	// ZIncrBy adds delta to the score of member, a missing member starts at 0
+	// It fails with ErrInvalidScore when the result is not finite
+	ZIncrBy(ctx context.Context, bucketName, key, member string, delta float64) (float64, error)
+	// ZRange returns the members ranked from start to stop included by ascending
+	// score, negative ranks count from the end
+	ZRange(ctx context.Context, bucketName, key string, start, stop int64) ([]engine.ScoredMember, error)
+	// ZRangeByScore returns the members scored from min to max included
+	ZRangeByScore(ctx context.Context, bucketName, key string, min, max float64) ([]engine.ScoredMember, error)
+	// ZRank returns the 0-based rank and the score of member
+	ZRank(ctx context.Context, bucketName, key, member string) (int, float64, error)
+	// ZRem removes members and returns how many existed, the key goes with the last member
+	ZRem(ctx context.Context, bucketName, key string, members []string) (int, error)
+	// ZRemRangeByRank and ZRemRangeByScore remove the members ZRange and
+	// ZRangeByScore would return and report how many they were
+	ZRemRangeByRank(ctx context.Context, bucketName, key string, start, stop int64) (int, error)
+	ZRemRangeByScore(ctx context.Context, bucketName, key string, min, max float64) (int, error)
+}
+
+type sortedSetService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewSortedSetService creates the service, new sorted sets are stamped with the time of clk
+func NewSortedSetService(bucketManager bucket.BucketManager, clk clock.Clock) ISortedSetService {
+	return &sortedSetService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+func (s *sortedSetService) ZAdd(ctx context.Context, bucketName, key string, members []engine.ScoredMember, ttl int64) (int, error) {
+	for _, m := range members {
+		if !finite(m.Score) {
+			return 0, errs.ErrInvalidScore
+		}
+	}
+
+	var added int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(z *engine.SortedSet) (*engine.SortedSet, error) {
+		n := 0
+		for _, m := range members {
+			var isNew bool
+			if z, isNew = z.Add(m.Member, m.Score); isNew {
+				n++
+			}
+		}
+		added = n
+		return z, nil
+	})
+	return added, err
+}
+
+func (s *sortedSetService) ZIncrBy(ctx context.Context, bucketName, key, member string, delta float64) (float64, error) {
+	var result float64
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(z *engine.SortedSet) (*engine.SortedSet, error) {
+		cur, _ := z.Score(member)
+		if result = cur + delta; !finite(result) {
+			return nil, errs.ErrInvalidScore
+		}
+		next, _ := z.Add(member, result)
+		return next, nil
+	})
+	return result, err
+}
+
+func (s *sortedSetService) ZRange(ctx context.Context, bucketName, key string, start, stop int64) ([]engine.ScoredMember, error) {
+	z, err := readData[*engine.SortedSet](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	from, to := span(start, stop, z.Len())
+	return z.Range(from, to), nil
+}
+
+func (s *sortedSetService) ZRangeByScore(ctx context.Context, bucketName, key string, min, max float64) ([]engine.ScoredMember, error) {
+	z, err := readData[*engine.SortedSet](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	from, to := z.ScoreSpan(min, max)
+	return z.Range(from, to), nil
+}
+
+func (s *sortedSetService) ZRank(ctx context.Context, bucketName, key, member string) (int, float64, error) {
+	z, err := readData[*engine.SortedSet](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	rank, ok := z.Rank(member)
+	if !ok {
+		return 0, 0, errs.ErrMemberNotFound
+	}
+	score, _ := z.Score(member)
+	return rank, score, nil
+}
+
+func (s *sortedSetService) ZRem(ctx context.Context, bucketName, key string, members []string) (int, error) {
+	var removed int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(z *engine.SortedSet) (*engine.SortedSet, error) {
+		next, n := z.Remove(members...)
+		removed = n
+		return next, nil
+	})
+	return removed, err
+}
+
+func (s *sortedSetService) ZRemRangeByRank(ctx context.Context, bucketName, key string, start, stop int64) (int, error) {
+	return s.removeRange(ctx, bucketName, key, func(z *engine.SortedSet) (int, int) {
+		return span(start, stop, z.Len())
+	})
+}
+
+func (s *sortedSetService) ZRemRangeByScore(ctx context.Context, bucketName, key string, min, max float64) (int, error) {
+	return s.removeRange(ctx, bucketName, key, func(z *engine.SortedSet) (int, int) {
+		return z.ScoreSpan(min, max)
+	})
+}
+
+// removeRange removes the members of the rank range spanOf returns for the current value
+func (s *sortedSetService) removeRange(ctx context.Context, bucketName, key string, spanOf func(*engine.SortedSet) (int, int)) (int, error) {
+	var removed int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(z *engine.SortedSet) (*engine.SortedSet, error) {
+		from, to := spanOf(z)
+		next, n := z.RemoveRange(from, to)
+		removed = n
+		return next, nil
+	})
+	return removed, err
+}
+
+func finite(f float64) bool { return !math.IsNaN(f) && !math.IsInf(f, 0) }
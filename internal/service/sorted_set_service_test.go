@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"math"
+	"slices"
+	"testing"
+)
+
+func scored(pairs ...any) []engine.ScoredMember {
+	out := make([]engine.ScoredMember, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, engine.ScoredMember{Member: pairs[i].(string), Score: pairs[i+1].(float64)})
+	}
+	return out
+}
+
+func TestSortedSetRanges(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	z := NewSortedSetService(buckets, clk)
+	ctx := context.Background()
+
+	if n, err := z.ZAdd(ctx, "b", "board", scored("ada", 30.0, "bob", 10.0, "cy", 20.0, "dee", 20.0), 0); err != nil || n != 4 {
+		t.Fatalf("ZAdd = %d, %v", n, err)
+	}
+	if n, _ := z.ZAdd(ctx, "b", "board", scored("bob", 40.0), 0); n != 0 {
+		t.Errorf("ZAdd of an existing member added %d", n)
+	}
+
+	got, err := z.ZRange(ctx, "b", "board", 0, -1)
+	if err != nil || !slices.Equal(got, scored("cy", 20.0, "dee", 20.0, "ada", 30.0, "bob", 40.0)) {
+		t.Errorf("ZRange(0, -1) = %v, %v", got, err)
+	}
+	if got, _ := z.ZRange(ctx, "b", "board", -2, -1); !slices.Equal(got, scored("ada", 30.0, "bob", 40.0)) {
+		t.Errorf("ZRange(-2, -1) = %v", got)
+	}
+	if got, _ := z.ZRangeByScore(ctx, "b", "board", 20, 30); !slices.Equal(got, scored("cy", 20.0, "dee", 20.0, "ada", 30.0)) {
+		t.Errorf("ZRangeByScore(20, 30) = %v", got)
+	}
+	if got, _ := z.ZRangeByScore(ctx, "b", "board", math.Inf(-1), 25); !slices.Equal(got, scored("cy", 20.0, "dee", 20.0)) {
+		t.Errorf("ZRangeByScore(-inf, 25) = %v", got)
+	}
+
+	if rank, score, err := z.ZRank(ctx, "b", "board", "ada"); err != nil || rank != 2 || score != 30 {
+		t.Errorf("ZRank(ada) = %d, %v, %v", rank, score, err)
+	}
+	if _, _, err := z.ZRank(ctx, "b", "board", "zed"); !errors.Is(err, errs.ErrMemberNotFound) {
+		t.Errorf("ZRank of a missing member: %v", err)
+	}
+}
+
+func TestSortedSetIncrAndRemove(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	z := NewSortedSetService(buckets, clk)
+	ctx := context.Background()
+
+	if score, err := z.ZIncrBy(ctx, "b", "hits", "home", 2.5); err != nil || score != 2.5 {
+		t.Fatalf("ZIncrBy on a missing key = %v, %v", score, err)
+	}
+	if score, _ := z.ZIncrBy(ctx, "b", "hits", "home", -1); score != 1.5 {
+		t.Errorf("ZIncrBy = %v", score)
+	}
+	z.ZIncrBy(ctx, "b", "hits", "max", math.MaxFloat64)
+	if _, err := z.ZIncrBy(ctx, "b", "hits", "max", math.MaxFloat64); !errors.Is(err, errs.ErrInvalidScore) {
+		t.Errorf("ZIncrBy past the largest float: %v", err)
+	}
+	for _, score := range []float64{math.NaN(), math.Inf(1)} {
+		if _, err := z.ZAdd(ctx, "b", "hits", scored("bad", score), 0); !errors.Is(err, errs.ErrInvalidScore) {
+			t.Errorf("ZAdd of %v: %v", score, err)
+		}
+	}
+
+	z.ZAdd(ctx, "b", "hits", scored("a", 1.0, "b", 2.0, "c", 3.0), 0)
+	if n, err := z.ZRemRangeByScore(ctx, "b", "hits", 1.5, 2); err != nil || n != 2 {
+		t.Errorf("ZRemRangeByScore(1.5, 2) = %d, %v", n, err)
+	}
+	if n, err := z.ZRemRangeByRank(ctx, "b", "hits", 0, 0); err != nil || n != 1 {
+		t.Errorf("ZRemRangeByRank(0, 0) = %d, %v", n, err)
+	}
+	if got, _ := z.ZRange(ctx, "b", "hits", 0, -1); !slices.Equal(got, scored("c", 3.0, "max", math.MaxFloat64)) {
+		t.Errorf("ZRange after the removals = %v", got)
+	}
+
+	if n, err := z.ZRem(ctx, "b", "hits", []string{"c", "max", "zed"}); err != nil || n != 2 {
+		t.Errorf("ZRem = %d, %v", n, err)
+	}
+	if _, err := z.ZRange(ctx, "b", "hits", 0, -1); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("ZRange after removing every member: %v", err)
+	}
+	if n, err := z.ZRemRangeByRank(ctx, "b", "hits", 0, -1); err != nil || n != 0 {
+		t.Errorf("ZRemRangeByRank on a missing key = %d, %v", n, err)
+	}
+}
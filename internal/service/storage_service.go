@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
 	"key-value-store/internal/config"
 	"key-value-store/internal/engine"
 	"key-value-store/internal/errs"
@@ -20,12 +21,15 @@ type IStorageService interface {
 type storageService struct {
 	bucketManager bucket.BucketManager
 	cfg           *config.Configuration
+	clock         clock.Clock
 }
 
-func NewStorageService(bucketManager bucket.BucketManager, cfg *config.Configuration) IStorageService {
+// NewStorageService creates the service, entries are stamped with the time of clk
+func NewStorageService(bucketManager bucket.BucketManager, cfg *config.Configuration, clk clock.Clock) IStorageService {
 	s := &storageService{
 		bucketManager: bucketManager,
 		cfg:           cfg,
+		clock:         clk,
 	}
 	return s
 }
@@ -35,14 +39,19 @@ func (s *storageService) Set(ctx context.Context, bucketName, key string, value
 		return engine.StorageEntry{}, errs.ErrInvalidTTL
 	}
 
-	bucketStore, ok := s.bucketManager.GetStore(bucketName)
+	bucketStore, settings, ok := s.bucketManager.GetStoreAndSettings(bucketName)
 	if !ok {
 		crrid := util.GetCorrelationID(ctx)
 		slog.Error("Service: Bucket not found", "crr-id", crrid, "bucket", bucketName)
 		return engine.StorageEntry{}, errs.ErrBucketNotFound
 	}
 
-	now := time.Now()
+	ttl, err := checkWrite(bucketStore, settings, key, value, ttl, singleRead)
+	if err != nil {
+		return engine.StorageEntry{}, err
+	}
+
+	now := s.clock.Now()
 	var exp time.Time
 	if ttl > 0 {
 		exp = now.Add(time.Duration(ttl) * time.Second)
@@ -58,7 +67,16 @@ func (s *storageService) Set(ctx context.Context, bucketName, key string, value
 		OriginalSize: int64(len(value)),
 	}
 
-	bucketStore.Set(key, entry)
+	// Structured values are never silently replaced by a plain one
+	err = bucketStore.Update(key, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		if found && cur.Kind() != engine.KindString {
+			return cur, false, errs.WrongType(engine.KindString, cur.Kind())
+		}
+		return entry, false, nil
+	})
+	if err != nil {
+		return engine.StorageEntry{}, err
+	}
 	return entry, nil
 }
 
@@ -74,6 +92,9 @@ func (s *storageService) Get(ctx context.Context, bucketName, key string) (engin
 	if !exists {
 		return engine.StorageEntry{}, errs.ErrKeyNotFound
 	}
+	if entry.Kind() != engine.KindString {
+		return engine.StorageEntry{}, errs.WrongType(engine.KindString, entry.Kind())
+	}
 
 	return entry, nil
 }
@@ -86,6 +107,36 @@ func (s *storageService) Delete(ctx context.Context, bucketName, key string) err
 		return errs.ErrBucketNotFound
 	}
 
-	bucketStore.Delete(key)
-	return nil
+	// A held lock is freed by its owner only, through the lock service
+	return bucketStore.Update(key, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		if found && cur.Kind() == engine.KindLock {
+			return cur, false, errs.ErrLockHeld
+		}
+		return cur, true, nil
+	})
+}
+
+// checkWrite enforces the bucket settings on a write and returns the effective TTL
+// The key limit is checked against the current count, so concurrent writers
+// of new keys may overshoot it slightly
+func checkWrite(store *engine.ShardContainer, settings bucket.BucketSettings, key string, value []byte, ttl int64, singleRead bool) (int64, error) {
+	if len(key) > settings.MaxKeyLength {
+		return 0, errs.ErrKeyTooLong
+	}
+	if settings.MaxValueSize > 0 && int64(len(value)) > settings.MaxValueSize {
+		return 0, errs.ErrValueTooLarge
+	}
+	if singleRead && !settings.AllowSingleRead {
+		return 0, errs.ErrSingleReadDenied
+	}
+
+	ttl, err := settings.ResolveTTL(ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	if settings.MaxKeys > 0 && store.Count() >= settings.MaxKeys && !store.Exists(key) {
+		return 0, errs.ErrKeyLimit
+	}
+	return ttl, nil
 }
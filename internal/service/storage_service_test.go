@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"testing"
+	"time"
+)
+
+// testBuckets serves a single bucket, the other BucketManager methods are not used
+type testBuckets struct {
+	bucket.BucketManager
+	name     string
+	store    *engine.ShardContainer
+	settings bucket.BucketSettings
+}
+
+func (b *testBuckets) GetStore(name string) (*engine.ShardContainer, bool) {
+	return b.store, name == b.name
+}
+
+func (b *testBuckets) GetStoreAndSettings(name string) (*engine.ShardContainer, bucket.BucketSettings, bool) {
+	return b.store, b.settings, name == b.name
+}
+
+// newTestBucket returns the bucket "b" with settings, keys expire on the
+// returned clock
+func newTestBucket(settings bucket.BucketSettings) (*testBuckets, *clock.Fake) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return &testBuckets{name: "b", store: engine.NewShardContainer(4, clk), settings: settings}, clk
+}
+
+func TestStorageSetStampsClockTime(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	s := NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	e, err := s.Set(ctx, "b", "k", []byte("v"), 30, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.CreatedAt.Equal(clk.Now()) || !e.ExpiresAt.Equal(clk.Now().Add(30*time.Second)) {
+		t.Fatalf("created %v, expires %v, want the clock time and 30 s later", e.CreatedAt, e.ExpiresAt)
+	}
+
+	clk.Advance(30 * time.Second)
+	if _, err := s.Get(ctx, "b", "k"); err != nil {
+		t.Fatalf("Get at the expiration instant: %v", err)
+	}
+	clk.Advance(time.Millisecond)
+	if _, err := s.Get(ctx, "b", "k"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Fatalf("Get after the TTL: %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestStorageBucketTTLSettings(t *testing.T) {
+	settings := bucket.DefaultBucketSettings()
+	settings.DefaultTTL = 5
+	settings.MaxTTL = 60
+	buckets, clk := newTestBucket(settings)
+	s := NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	if _, err := s.Set(ctx, "b", "default", []byte("v"), 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set(ctx, "b", "explicit", []byte("v"), 60, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set(ctx, "b", "too-long", []byte("v"), 61, false); !errors.Is(err, errs.ErrTTLExceedsLimit) {
+		t.Fatalf("TTL above the max: %v, want ErrTTLExceedsLimit", err)
+	}
+
+	clk.Advance(6 * time.Second)
+	if _, err := s.Get(ctx, "b", "default"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("key with the default TTL after 6 s: %v", err)
+	}
+	if _, err := s.Get(ctx, "b", "explicit"); err != nil {
+		t.Errorf("key with an explicit TTL after 6 s: %v", err)
+	}
+}
+
+func TestStorageSingleRead(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	s := NewStorageService(buckets, nil, clk)
+	ctx := context.Background()
+
+	if _, err := s.Set(ctx, "b", "k", []byte("v"), 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "b", "k"); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	if _, err := s.Get(ctx, "b", "k"); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Fatalf("second read: %v, want ErrKeyNotFound", err)
+	}
+
+	denied := bucket.DefaultBucketSettings()
+	denied.AllowSingleRead = false
+	buckets, clk = newTestBucket(denied)
+	s = NewStorageService(buckets, nil, clk)
+	if _, err := s.Set(ctx, "b", "k", []byte("v"), 0, true); !errors.Is(err, errs.ErrSingleReadDenied) {
+		t.Errorf("single-read in a bucket that denies it: %v", err)
+	}
+}
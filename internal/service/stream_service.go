@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"math"
+	"time"
+)
+
+// MaxStreamCount bounds the entries returned at once, a count of 0 stands for it
+const MaxStreamCount = 1000
+
+// ParseStreamStart reads the start of a range, "-" or "" standing for the first entry
+func ParseStreamStart(s string) (engine.StreamID, error) {
+	if s == "" || s == "-" {
+		return engine.StreamID{}, nil
+	}
+	return engine.ParseStreamID(s)
+}
+
+// ParseStreamEnd reads the end of a range, "+" or "" standing for the last entry
+func ParseStreamEnd(s string) (engine.StreamID, error) {
+	if s == "" || s == "+" {
+		return engine.MaxStreamID, nil
+	}
+	return engine.ParseStreamID(s)
+}
+
+// ParseStreamAfter reads the ID entries are read after, "$" or "" standing
+// for the last entry at the time of the read, returned as nil, and "-" for
+// the start of the stream
+func ParseStreamAfter(s string) (*engine.StreamID, error) {
+	switch s {
+	case "", "$":
+		return nil, nil
+	case "-":
+		return &engine.StreamID{}, nil
+	}
+	id, err := engine.ParseStreamID(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// StreamTrim bounds a stream by its number of entries and by their age, zero
+// values leave it unbounded
+type StreamTrim struct {
+	MaxLen int
+	MaxAge time.Duration
+}
+
+// StreamInfo describes a stream and its consumer groups
+type StreamInfo struct {
+	Length int
+	LastID engine.StreamID
+	Groups []engine.GroupInfo
+}
+
+// PendingQuery selects pending entries of a group from Start on, Consumer
+// empty for every consumer
+type PendingQuery struct {
+	Consumer string
+	Start    engine.StreamID
+	Count    int
+}
+
+// IStreamService manages append-only streams and the consumer groups sharing
+// their entries; counts are bounded by MaxStreamCount
+type IStreamService interface {
+	// Add appends an entry, trims the stream by trim and returns the entry ID
+	// ttl only applies when the stream is created
+	Add(ctx context.Context, bucketName, key string, fields map[string][]byte, trim StreamTrim, ttl int64) (engine.StreamID, error)
+	// Range returns up to count entries from start to end included
+	Range(ctx context.Context, bucketName, key string, start, end engine.StreamID, count int) ([]engine.StreamEntry, error)
+	// Read returns up to count entries after the ID after, nil standing for
+	// the last entry at the time of the call
+	// With a timeout it waits for an entry instead, for at most MaxWait, and
+	// fails with ErrWaitTimeout
+	Read(ctx context.Context, bucketName, key string, after *engine.StreamID, count int, timeout time.Duration) ([]engine.StreamEntry, error)
+	// Trim removes the entries beyond trim and returns how many
+	Trim(ctx context.Context, bucketName, key string, trim StreamTrim) (int, error)
+	Info(ctx context.Context, bucketName, key string) (*StreamInfo, error)
+	// CreateGroup adds a group delivering the entries after start, nil standing
+	// for the last entry, and creates the stream when missing
+	// It returns the ID the group starts after
+	CreateGroup(ctx context.Context, bucketName, key, group string, start *engine.StreamID) (engine.StreamID, error)
+	DestroyGroup(ctx context.Context, bucketName, key, group string) error
+	// ReadGroup delivers to consumer up to count entries the group did not
+	// deliver yet, they stay pending until acknowledged
+	// With a timeout it waits for an entry like Read
+	ReadGroup(ctx context.Context, bucketName, key, group, consumer string, count int, timeout time.Duration) ([]engine.StreamEntry, error)
+	// Ack acknowledges pending entries and returns how many were pending
+	Ack(ctx context.Context, bucketName, key, group string, ids []engine.StreamID) (int, error)
+	Pending(ctx context.Context, bucketName, key, group string, q PendingQuery) ([]engine.PendingEntry, error)
+	// Claim hands over to consumer up to count entries pending for at least
+	// minIdle, so that the work of a stalled consumer is taken up
+	Claim(ctx context.Context, bucketName, key, group, consumer string, minIdle time.Duration, count int) ([]engine.StreamEntry, error)
+	// Close releases the blocked reads, later ones time out at once
+	Close()
+}
+
+type streamService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+	waiters       *waiters
+}
+
+// NewStreamService creates the service, entry IDs and deliveries are stamped
+// with the time of clk
+func NewStreamService(bucketManager bucket.BucketManager, clk clock.Clock) IStreamService {
+	return &streamService{
+		bucketManager: bucketManager,
+		clock:         clk,
+		waiters:       newWaiters(),
+	}
+}
+
+func (s *streamService) Add(ctx context.Context, bucketName, key string, fields map[string][]byte, trim StreamTrim, ttl int64) (engine.StreamID, error) {
+	var id engine.StreamID
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, ttl, func(st *engine.Stream) (*engine.Stream, error) {
+		st, id = st.Add(s.clock.Now(), fields)
+		st, _ = s.trim(st, trim)
+		return st, nil
+	})
+	if err != nil {
+		return engine.StreamID{}, err
+	}
+
+	// Every reader may want the entry, group readers that miss it wait again
+	if store, ok := s.bucketManager.GetStore(bucketName); ok {
+		s.waiters.wake(waitKey{store: store, key: key}, math.MaxInt)
+	}
+	return id, nil
+}
+
+func (s *streamService) trim(st *engine.Stream, trim StreamTrim) (*engine.Stream, int) {
+	removed := 0
+	if trim.MaxLen > 0 {
+		var n int
+		st, n = st.TrimLen(trim.MaxLen)
+		removed += n
+	}
+	if trim.MaxAge > 0 {
+		cutoff := s.clock.Now().Add(-trim.MaxAge).UnixMilli()
+		var n int
+		st, n = st.TrimBefore(engine.StreamID{Ms: uint64(max(cutoff, 0))})
+		removed += n
+	}
+	return st, removed
+}
+
+func (s *streamService) Range(ctx context.Context, bucketName, key string, start, end engine.StreamID, count int) ([]engine.StreamEntry, error) {
+	st, err := readData[*engine.Stream](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	return st.Range(start, end, streamCount(count)), nil
+}
+
+func (s *streamService) Read(ctx context.Context, bucketName, key string, after *engine.StreamID, count int, timeout time.Duration) ([]engine.StreamEntry, error) {
+	st, err := readData[*engine.Stream](ctx, s.bucketManager, bucketName, key)
+	if err != nil && (timeout <= 0 || !errors.Is(err, errs.ErrKeyNotFound)) {
+		return nil, err
+	}
+	count = streamCount(count)
+	from := st.LastID().Next()
+	if after != nil {
+		from = after.Next()
+	}
+	if out := st.Range(from, engine.MaxStreamID, count); len(out) > 0 || timeout <= 0 {
+		return out, nil
+	}
+
+	store, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+
+	var out []engine.StreamEntry
+	err = s.waiters.wait(ctx, waitKey{store: store, key: key}, timeout, func() (bool, error) {
+		st, err := readData[*engine.Stream](ctx, s.bucketManager, bucketName, key)
+		if errors.Is(err, errs.ErrKeyNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		out = st.Range(from, engine.MaxStreamID, count)
+		return len(out) > 0, nil
+	})
+	return out, err
+}
+
+func (s *streamService) Trim(ctx context.Context, bucketName, key string, trim StreamTrim) (int, error) {
+	var removed int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(st *engine.Stream) (*engine.Stream, error) {
+		if st == nil {
+			return nil, errs.ErrKeyNotFound
+		}
+		st, removed = s.trim(st, trim)
+		return st, nil
+	})
+	return removed, err
+}
+
+func (s *streamService) Info(ctx context.Context, bucketName, key string) (*StreamInfo, error) {
+	st, err := readData[*engine.Stream](ctx, s.bucketManager, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamInfo{Length: st.Count(), LastID: st.LastID(), Groups: st.Groups()}, nil
+}
+
+func (s *streamService) CreateGroup(ctx context.Context, bucketName, key, group string, start *engine.StreamID) (engine.StreamID, error) {
+	var from engine.StreamID
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(st *engine.Stream) (*engine.Stream, error) {
+		from = st.LastID()
+		if start != nil {
+			from = *start
+		}
+		return st.CreateGroup(group, from)
+	})
+	return from, err
+}
+
+func (s *streamService) DestroyGroup(ctx context.Context, bucketName, key, group string) error {
+	return updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(st *engine.Stream) (*engine.Stream, error) {
+		st, ok := st.DestroyGroup(group)
+		if !ok {
+			return nil, errs.ErrGroupNotFound
+		}
+		return st, nil
+	})
+}
+
+func (s *streamService) ReadGroup(ctx context.Context, bucketName, key, group, consumer string, count int, timeout time.Duration) ([]engine.StreamEntry, error) {
+	if timeout <= 0 {
+		return s.readGroup(ctx, bucketName, key, group, consumer, count)
+	}
+
+	store, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		return nil, errs.ErrBucketNotFound
+	}
+
+	var out []engine.StreamEntry
+	err := s.waiters.wait(ctx, waitKey{store: store, key: key}, timeout, func() (bool, error) {
+		var err error
+		out, err = s.readGroup(ctx, bucketName, key, group, consumer, count)
+		return len(out) > 0, err
+	})
+	return out, err
+}
+
+func (s *streamService) readGroup(ctx context.Context, bucketName, key, group, consumer string, count int) ([]engine.StreamEntry, error) {
+	var out []engine.StreamEntry
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(st *engine.Stream) (*engine.Stream, error) {
+		var err error
+		st, out, err = st.ReadGroup(group, consumer, streamCount(count), s.clock.Now())
+		return st, err
+	})
+	return out, err
+}
+
+func (s *streamService) Ack(ctx context.Context, bucketName, key, group string, ids []engine.StreamID) (int, error) {
+	var acked int
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(st *engine.Stream) (*engine.Stream, error) {
+		var err error
+		st, acked, err = st.Ack(group, ids...)
+		return st, err
+	})
+	return acked, err
+}
+
+func (s *streamService) Pending(ctx context.Context, bucketName, key, group string, q PendingQuery) ([]engine.PendingEntry, error) {
+	st, err := readData[*engine.Stream](ctx, s.bucketManager, bucketName, key)
+	if errors.Is(err, errs.ErrKeyNotFound) {
+		return nil, errs.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st.Pending(group, q.Consumer, q.Start, streamCount(q.Count))
+}
+
+func (s *streamService) Claim(ctx context.Context, bucketName, key, group, consumer string, minIdle time.Duration, count int) ([]engine.StreamEntry, error) {
+	var out []engine.StreamEntry
+	err := updateData(ctx, s.bucketManager, s.clock, bucketName, key, 0, func(st *engine.Stream) (*engine.Stream, error) {
+		var err error
+		st, out, err = st.Claim(group, consumer, minIdle, streamCount(count), s.clock.Now())
+		return st, err
+	})
+	return out, err
+}
+
+func (s *streamService) Close() { s.waiters.close() }
+
+func streamCount(count int) int {
+	if count <= 0 || count > MaxStreamCount {
+		return MaxStreamCount
+	}
+	return count
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func job(n string) map[string][]byte { return map[string][]byte{"job": []byte(n)} }
+
+func jobs(entries []engine.StreamEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = string(e.Fields["job"])
+	}
+	return out
+}
+
+func TestStreamAddRangeAndTrim(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	x := NewStreamService(buckets, clk)
+	ctx := context.Background()
+
+	var ids []engine.StreamID
+	for _, n := range []string{"1", "2", "3", "4"} {
+		id, err := x.Add(ctx, "b", "log", job(n), StreamTrim{}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		clk.Advance(time.Minute)
+	}
+
+	got, err := x.Range(ctx, "b", "log", ids[1], engine.MaxStreamID, 2)
+	if err != nil || !slices.Equal(jobs(got), []string{"2", "3"}) {
+		t.Fatalf("Range = %v, %v", jobs(got), err)
+	}
+
+	// Entries older than 150s go, now being 4 minutes after the first one
+	if n, err := x.Trim(ctx, "b", "log", StreamTrim{MaxAge: 150 * time.Second}); err != nil || n != 2 {
+		t.Errorf("Trim by age = %d, %v", n, err)
+	}
+	last, err := x.Add(ctx, "b", "log", job("5"), StreamTrim{MaxLen: 2}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = x.Range(ctx, "b", "log", engine.StreamID{}, engine.MaxStreamID, 0)
+	if !slices.Equal(jobs(got), []string{"4", "5"}) {
+		t.Errorf("after trimming %v", jobs(got))
+	}
+
+	clk.Advance(time.Second)
+	if n, _ := x.Trim(ctx, "b", "log", StreamTrim{MaxAge: time.Millisecond}); n != 2 {
+		t.Errorf("trimmed %d entries to nothing", n)
+	}
+	info, err := x.Info(ctx, "b", "log")
+	if err != nil || info.Length != 0 || info.LastID != last {
+		t.Errorf("Info of an emptied stream = %+v, %v", info, err)
+	}
+
+	if _, err := x.Trim(ctx, "b", "missing", StreamTrim{MaxLen: 1}); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("Trim of a missing stream: %v", err)
+	}
+}
+
+func TestStreamGroupSharesWork(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	x := NewStreamService(buckets, clk)
+	ctx := context.Background()
+
+	if _, err := x.CreateGroup(ctx, "b", "jobs", "workers", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.CreateGroup(ctx, "b", "jobs", "workers", nil); !errors.Is(err, errs.ErrGroupExists) {
+		t.Errorf("second CreateGroup: %v", err)
+	}
+
+	const workers = 4
+	results := make(chan []engine.StreamEntry, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := x.ReadGroup(ctx, "b", "jobs", "workers", string(rune('a'+i)), 1, time.Minute)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- got
+		}()
+	}
+
+	// Each entry is delivered to a single worker
+	time.Sleep(20 * time.Millisecond)
+	for _, n := range []string{"1", "2", "3", "4"} {
+		if _, err := x.Add(ctx, "b", "jobs", job(n), StreamTrim{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	close(results)
+
+	var got []string
+	var delivered []engine.StreamID
+	for r := range results {
+		got = append(got, jobs(r)...)
+		delivered = append(delivered, r[0].ID)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"1", "2", "3", "4"}) {
+		t.Fatalf("workers read %v", got)
+	}
+
+	if n, err := x.Ack(ctx, "b", "jobs", "workers", delivered[:3]); err != nil || n != 3 {
+		t.Errorf("Ack = %d, %v", n, err)
+	}
+	pending, _ := x.Pending(ctx, "b", "jobs", "workers", PendingQuery{})
+	if len(pending) != 1 || pending[0].ID != delivered[3] {
+		t.Fatalf("pending %v", pending)
+	}
+
+	// The unacknowledged entry is taken up by another consumer once idle
+	if got, _ := x.Claim(ctx, "b", "jobs", "workers", "z", time.Minute, 0); len(got) != 0 {
+		t.Errorf("claimed %v before it was idle", jobs(got))
+	}
+	clk.Advance(time.Minute)
+	claimed, err := x.Claim(ctx, "b", "jobs", "workers", "z", time.Minute, 0)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != delivered[3] {
+		t.Errorf("Claim = %v, %v", jobs(claimed), err)
+	}
+	if pending, _ := x.Pending(ctx, "b", "jobs", "workers", PendingQuery{Consumer: "z"}); len(pending) != 1 || pending[0].Deliveries != 2 {
+		t.Errorf("pending of z %v", pending)
+	}
+
+	if _, err := x.ReadGroup(ctx, "b", "jobs", "missing", "a", 0, time.Minute); !errors.Is(err, errs.ErrGroupNotFound) {
+		t.Errorf("ReadGroup of a missing group: %v", err)
+	}
+	if err := x.DestroyGroup(ctx, "b", "jobs", "workers"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.Pending(ctx, "b", "jobs", "workers", PendingQuery{}); !errors.Is(err, errs.ErrGroupNotFound) {
+		t.Errorf("Pending of a destroyed group: %v", err)
+	}
+}
+
+func TestStreamBlockingReadTimeoutAndClose(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	x := NewStreamService(buckets, clk)
+	ctx := context.Background()
+
+	if _, err := x.Read(ctx, "b", "feed", nil, 0, 0); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("Read without timeout: %v, want ErrKeyNotFound", err)
+	}
+	if _, err := x.Read(ctx, "b", "feed", nil, 0, 20*time.Millisecond); !errors.Is(err, errs.ErrWaitTimeout) {
+		t.Errorf("Read of a missing stream: %v, want ErrWaitTimeout", err)
+	}
+
+	first, _ := x.Add(ctx, "b", "feed", job("1"), StreamTrim{}, 0)
+	done := make(chan []engine.StreamEntry)
+	go func() {
+		got, err := x.Read(ctx, "b", "feed", nil, 0, time.Minute)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- got
+	}()
+	time.Sleep(20 * time.Millisecond)
+	x.Add(ctx, "b", "feed", job("2"), StreamTrim{}, 0)
+	if got := <-done; !slices.Equal(jobs(got), []string{"2"}) {
+		t.Errorf("blocked Read got %v, want only the new entry", jobs(got))
+	}
+	if got, _ := x.Read(ctx, "b", "feed", &first, 0, time.Minute); !slices.Equal(jobs(got), []string{"2"}) {
+		t.Errorf("Read after the first entry = %v", jobs(got))
+	}
+
+	errc := make(chan error)
+	go func() {
+		_, err := x.Read(ctx, "b", "feed", nil, 0, time.Minute)
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	x.Close()
+	if err := <-errc; !errors.Is(err, errs.ErrWaitTimeout) {
+		t.Errorf("Read released by Close: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"key-value-store/internal/archive"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"time"
+)
+
+type ImportMode string
+
+const (
+	// ImportMerge writes every record, replacing existing keys
+	ImportMerge ImportMode = "merge"
+	// ImportOverwrite replaces the keys of the bucket once the whole archive is read
+	ImportOverwrite ImportMode = "overwrite"
+	// ImportSkipExisting only writes records whose key does not exist yet
+	ImportSkipExisting ImportMode = "skip-existing"
+)
+
+const maxImportErrors = 10
+
+type ImportResult struct {
+	Imported int64
+	Skipped  int64
+	Failed   int64
+	Errors   []string // first few per-record failures
+}
+
+type ITransferService interface {
+	Export(ctx context.Context, bucketName string, enc archive.Encoder) (int64, error)
+	Import(ctx context.Context, bucketName string, dec archive.Decoder, mode ImportMode) (ImportResult, error)
+}
+
+type transferService struct {
+	bucketManager bucket.BucketManager
+	clock         clock.Clock
+}
+
+// NewTransferService creates the service, remaining TTLs are counted on clk
+func NewTransferService(bucketManager bucket.BucketManager, clk clock.Clock) ITransferService {
+	return &transferService{
+		bucketManager: bucketManager,
+		clock:         clk,
+	}
+}
+
+// Export streams every live entry of a bucket
+// Each shard is read from a single index snapshot, writers are never blocked
+// A bucket holding values the archive format cannot hold fails with
+// ErrNotExportable before anything is written, instead of losing them
+func (s *transferService) Export(ctx context.Context, bucketName string, enc archive.Encoder) (int64, error) {
+	bucketStore, ok := s.bucketManager.GetStore(bucketName)
+	if !ok {
+		return 0, errs.ErrBucketNotFound
+	}
+
+	var writeErr error
+	bucketStore.Range(func(key string, entry engine.StorageEntry) bool {
+		writeErr = checkExportable(key, entry)
+		return writeErr == nil
+	})
+	if writeErr != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("TransferService: Export refused", "crr-id", crrid, "bucket", bucketName, "error", writeErr)
+		return 0, writeErr
+	}
+
+	if err := enc.WriteHeader(archive.NewHeader(bucketName)); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	now := s.clock.Now()
+	bucketStore.Range(func(key string, entry engine.StorageEntry) bool {
+		if count%1024 == 0 && ctx.Err() != nil {
+			writeErr = ctx.Err()
+			return false
+		}
+		// Written since the check, the archive is left without its end
+		if writeErr = checkExportable(key, entry); writeErr != nil {
+			return false
+		}
+		rec := archive.Record{
+			Key:        key,
+			Value:      entry.Value,
+			SingleRead: entry.SingleRead,
+			CreatedAt:  entry.CreatedAt,
+		}
+		if data, ok := entry.Data.(*engine.Stream); ok {
+			rec.Type = archive.TypeStream
+			rec.Value = data.Encode()
+		}
+		if !entry.ExpiresAt.IsZero() {
+			remaining := entry.ExpiresAt.Sub(now)
+			if remaining <= 0 {
+				return true
+			}
+			// Round up so an entry never outlives its original expiry by less than a second
+			rec.TTLRemaining = int64((remaining + time.Second - 1) / time.Second)
+		}
+
+		if writeErr = enc.WriteRecord(rec); writeErr != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if writeErr != nil {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("TransferService: Export aborted", "crr-id", crrid, "bucket", bucketName, "exported", count, "error", writeErr)
+		return count, writeErr
+	}
+
+	if err := enc.Close(); err != nil {
+		return count, err
+	}
+
+	slog.Info("TransferService: Exported bucket", "bucket", bucketName, "count", count)
+	return count, nil
+}
+
+// checkExportable accepts the kinds the archive format has a record type for
+func checkExportable(key string, entry engine.StorageEntry) error {
+	switch entry.Kind() {
+	case engine.KindString, engine.KindStream:
+		return nil
+	}
+	return fmt.Errorf("%w: key %s holds a %s value", errs.ErrNotExportable, key, entry.Kind())
+}
+
+// Import writes the records of an archive to a bucket
+// In overwrite mode the whole archive is decoded before the bucket is flushed,
+// a truncated or corrupt archive leaves the bucket as it was
+func (s *transferService) Import(ctx context.Context, bucketName string, dec archive.Decoder, mode ImportMode) (ImportResult, error) {
+	bucketStore, settings, ok := s.bucketManager.GetStoreAndSettings(bucketName)
+	if !ok {
+		return ImportResult{}, errs.ErrBucketNotFound
+	}
+
+	var result ImportResult
+	aborted := func(err error) (ImportResult, error) {
+		crrid := util.GetCorrelationID(ctx)
+		slog.Error("TransferService: Import aborted", "crr-id", crrid, "bucket", bucketName, "imported", result.Imported, "error", err)
+		return result, err
+	}
+
+	var staged []archive.Record
+	for {
+		rec, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return aborted(err)
+		}
+		if mode == ImportOverwrite {
+			staged = append(staged, rec)
+			continue
+		}
+		s.importRecord(bucketStore, settings, rec, mode, &result)
+	}
+
+	if mode == ImportOverwrite {
+		if _, err := bucketStore.Flush(); err != nil {
+			return aborted(err)
+		}
+		for _, rec := range staged {
+			s.importRecord(bucketStore, settings, rec, mode, &result)
+		}
+	}
+
+	slog.Info("TransferService: Imported bucket", "bucket", bucketName, "source", dec.Header().Bucket, "mode", mode,
+		"imported", result.Imported, "skipped", result.Skipped, "failed", result.Failed)
+	return result, nil
+}
+
+// importRecord writes a single record and counts it in result
+// Like every other write, it never replaces a value of another kind or a held lock
+func (s *transferService) importRecord(bucketStore *engine.ShardContainer, settings bucket.BucketSettings, rec archive.Record, mode ImportMode, result *ImportResult) {
+	fail := func(err error) {
+		result.Failed++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rec.Key, err))
+		}
+	}
+
+	ttl, err := checkWrite(bucketStore, settings, rec.Key, rec.Value, rec.TTLRemaining, rec.SingleRead)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	entry := restoredEntry(rec, ttl, s.clock.Now())
+	switch rec.Type {
+	case "":
+	case archive.TypeStream:
+		stream, err := engine.DecodeStream(rec.Value)
+		if err != nil {
+			fail(err)
+			return
+		}
+		entry.Value, entry.OriginalSize, entry.Data = nil, 0, stream
+	default:
+		fail(archive.ErrUnknownType)
+		return
+	}
+
+	skipped := false
+	err = bucketStore.Update(rec.Key, func(cur engine.StorageEntry, found bool) (engine.StorageEntry, bool, error) {
+		switch {
+		case !found:
+			return entry, false, nil
+		case mode == ImportSkipExisting:
+			skipped = true
+			return cur, false, nil
+		case cur.Kind() == engine.KindLock:
+			return cur, false, errs.ErrLockHeld
+		case cur.Kind() != entry.Kind():
+			return cur, false, errs.WrongType(entry.Kind(), cur.Kind())
+		}
+		return entry, false, nil
+	})
+	switch {
+	case err != nil:
+		fail(err)
+	case skipped:
+		result.Skipped++
+	default:
+		result.Imported++
+	}
+}
+
+// restoredEntry keeps the original creation time and expires the entry ttl seconds from now
+func restoredEntry(rec archive.Record, ttl int64, now time.Time) engine.StorageEntry {
+	createdAt := rec.CreatedAt
+	if createdAt.IsZero() || createdAt.After(now) {
+		createdAt = now
+	}
+
+	entry := engine.StorageEntry{
+		Key:          rec.Key,
+		Value:        rec.Value,
+		CreatedAt:    createdAt,
+		SingleRead:   rec.SingleRead,
+		OriginalSize: int64(len(rec.Value)),
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(time.Duration(ttl) * time.Second)
+		entry.TTL = int64(entry.ExpiresAt.Sub(createdAt) / time.Second)
+	}
+	return entry
+}
+
+func ParseImportMode(mode string) (ImportMode, bool) {
+	switch ImportMode(mode) {
+	case "", ImportMerge:
+		return ImportMerge, true
+	case ImportOverwrite, ImportSkipExisting:
+		return ImportMode(mode), true
+	}
+	return "", false
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"key-value-store/internal/archive"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoredEntryCountsFromNow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rec := archive.Record{Key: "k", Value: []byte("v"), CreatedAt: now.Add(-time.Hour)}
+	e := restoredEntry(rec, 30, now)
+	if !e.CreatedAt.Equal(rec.CreatedAt) || !e.ExpiresAt.Equal(now.Add(30*time.Second)) {
+		t.Errorf("created %v, expires %v, want the archived time and 30 s from now", e.CreatedAt, e.ExpiresAt)
+	}
+	if e.TTL != 3630 {
+		t.Errorf("TTL = %d, want the lifetime from creation 3630", e.TTL)
+	}
+
+	rec.CreatedAt = now.Add(time.Hour)
+	if e := restoredEntry(rec, 0, now); !e.CreatedAt.Equal(now) || !e.ExpiresAt.IsZero() {
+		t.Errorf("future creation time kept or expiration set: created %v, expires %v", e.CreatedAt, e.ExpiresAt)
+	}
+}
+
+func TestExportImportKeepsStreams(t *testing.T) {
+	for _, format := range []string{archive.FormatNDJSON, archive.FormatBinary} {
+		src, clk := newTestBucket(bucket.DefaultBucketSettings())
+		x := NewStreamService(src, clk)
+		ctx := context.Background()
+
+		for _, n := range []string{"1", "2", "3"} {
+			x.Add(ctx, "b", "jobs", job(n), StreamTrim{}, 0)
+		}
+		x.CreateGroup(ctx, "b", "jobs", "workers", &engine.StreamID{})
+		x.ReadGroup(ctx, "b", "jobs", "workers", "a", 2, 0)
+
+		var buf bytes.Buffer
+		enc, _ := archive.NewEncoder(format, &buf)
+		if n, err := NewTransferService(src, clk).Export(ctx, "b", enc); err != nil || n != 1 {
+			t.Fatalf("%s: Export = %d, %v", format, n, err)
+		}
+
+		dst, _ := newTestBucket(bucket.DefaultBucketSettings())
+		y := NewStreamService(dst, clk)
+		dec, err := archive.NewDecoder(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := NewTransferService(dst, clk).Import(ctx, "b", dec, ImportMerge)
+		if err != nil || res.Imported != 1 {
+			t.Fatalf("%s: Import = %+v, %v", format, res, err)
+		}
+
+		info, err := y.Info(ctx, "b", "jobs")
+		if err != nil || info.Length != 3 || len(info.Groups) != 1 || info.Groups[0].Pending != 2 {
+			t.Errorf("%s: imported stream %+v, %v", format, info, err)
+		}
+		// The group resumes where it stopped
+		if got, _ := y.ReadGroup(ctx, "b", "jobs", "workers", "b", 0, 0); !slices.Equal(jobs(got), []string{"3"}) {
+			t.Errorf("%s: imported group delivered %v", format, jobs(got))
+		}
+	}
+}
+
+func TestImportRejectsTruncatedNDJSON(t *testing.T) {
+	src, clk := newTestBucket(bucket.DefaultBucketSettings())
+	x := NewStreamService(src, clk)
+	ctx := context.Background()
+	for _, key := range []string{"a", "b"} {
+		x.Add(ctx, "b", key, job("1"), StreamTrim{}, 0)
+	}
+
+	var buf bytes.Buffer
+	enc, _ := archive.NewEncoder(archive.FormatNDJSON, &buf)
+	if _, err := NewTransferService(src, clk).Export(ctx, "b", enc); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if last := lines[len(lines)-1]; last != `{"end":true,"count":2}` {
+		t.Fatalf("archive ends with %q", last)
+	}
+
+	for name, archived := range map[string]string{
+		"without end":    strings.Join(lines[:len(lines)-1], ""),
+		"record missing": strings.Join(append(lines[:2:2], lines[len(lines)-1]), ""),
+		"cut in a line":  buf.String()[:buf.Len()-10],
+	} {
+		dst, _ := newTestBucket(bucket.DefaultBucketSettings())
+		dec, err := archive.NewDecoder(strings.NewReader(archived))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewTransferService(dst, clk).Import(ctx, "b", dec, ImportMerge)
+		if !errors.Is(err, archive.ErrInvalidArchive) {
+			t.Errorf("%s: Import error %v", name, err)
+		}
+	}
+}
+
+// writeArchive encodes recs as a complete NDJSON archive
+func writeArchive(t *testing.T, recs ...archive.Record) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, _ := archive.NewEncoder(archive.FormatNDJSON, &buf)
+	if err := enc.WriteHeader(archive.NewHeader("b")); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range recs {
+		if err := enc.WriteRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func importArchive(t *testing.T, s ITransferService, data []byte, mode ImportMode) (ImportResult, error) {
+	t.Helper()
+	dec, err := archive.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Import(context.Background(), "b", dec, mode)
+}
+
+func TestImportedTTLKeyCollected(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	created := clk.Now()
+	clk.Advance(10*time.Second + 500*time.Millisecond)
+
+	rec := archive.Record{Key: "k", Value: []byte("v"), TTLRemaining: 20, CreatedAt: created}
+	if res, err := importArchive(t, NewTransferService(buckets, clk), writeArchive(t, rec), ImportMerge); err != nil || res.Imported != 1 {
+		t.Fatalf("Import = %+v, %v", res, err)
+	}
+
+	// The lifetime from creation is 30.5 s, the key must not be collected
+	// by an expiration counted in whole seconds from its creation
+	clk.Advance(20 * time.Second)
+	buckets.store.Collect()
+	if !buckets.store.Exists("k") {
+		t.Fatal("imported key collected before its expiration")
+	}
+	// Collectors may hand a key over up to a tick late
+	clk.Advance(time.Second)
+	buckets.store.Collect()
+	if buckets.store.Count() != 0 {
+		t.Error("imported key not collected after its expiration")
+	}
+}
+
+func TestImportOverwriteKeepsBucketOnBadArchive(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	kv := NewStorageService(buckets, nil, clk)
+	x := NewTransferService(buckets, clk)
+	ctx := context.Background()
+	if _, err := kv.Set(ctx, "b", "old", []byte("v"), 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	data := writeArchive(t,
+		archive.Record{Key: "a", Value: []byte("1")},
+		archive.Record{Key: "b", Value: []byte("2")},
+	)
+	if _, err := importArchive(t, x, data[:len(data)-10], ImportOverwrite); !errors.Is(err, archive.ErrInvalidArchive) {
+		t.Fatalf("Import of a truncated archive: %v", err)
+	}
+	if keys := buckets.store.Keys(); !slices.Equal(keys, []string{"old"}) {
+		t.Fatalf("bucket holds %v after a failed overwrite, want [old]", keys)
+	}
+
+	if res, err := importArchive(t, x, data, ImportOverwrite); err != nil || res.Imported != 2 {
+		t.Fatalf("Import = %+v, %v", res, err)
+	}
+	keys := buckets.store.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("bucket holds %v after overwrite, want [a b]", keys)
+	}
+}
+
+func TestImportKeepsOtherKindsAndHeldLocks(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	ctx := context.Background()
+	h := NewHashService(buckets, clk)
+	if _, err := h.HSet(ctx, "b", "user", map[string][]byte{"name": []byte("ada")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLockService(buckets, clk).Acquire(ctx, "b", "job", "a", 30, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	data := writeArchive(t,
+		archive.Record{Key: "user", Value: []byte("plain")},
+		archive.Record{Key: "job", Value: []byte("plain")},
+		archive.Record{Key: "new", Value: []byte("plain")},
+	)
+	for _, mode := range []ImportMode{ImportMerge, ImportSkipExisting} {
+		res, err := importArchive(t, NewTransferService(buckets, clk), data, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode == ImportMerge && (res.Imported != 1 || res.Failed != 2) {
+			t.Errorf("merge: %+v, want 1 imported and 2 failed", res)
+		}
+		if mode == ImportSkipExisting && (res.Imported != 0 || res.Skipped != 3) {
+			t.Errorf("skip-existing: %+v, want 3 skipped", res)
+		}
+	}
+
+	if name, err := h.HGet(ctx, "b", "user", "name"); err != nil || string(name) != "ada" {
+		t.Errorf("hash replaced by an import: %q, %v", name, err)
+	}
+	if e, ok := buckets.store.Get("job"); !ok || e.Kind() != engine.KindLock {
+		t.Error("held lock replaced by an import")
+	}
+}
+
+func TestExportRefusesUnsupportedValues(t *testing.T) {
+	buckets, clk := newTestBucket(bucket.DefaultBucketSettings())
+	ctx := context.Background()
+	if _, err := NewStorageService(buckets, nil, clk).Set(ctx, "b", "plain", []byte("v"), 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHashService(buckets, clk).HSet(ctx, "b", "user", map[string][]byte{"name": []byte("ada")}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	enc, _ := archive.NewEncoder(archive.FormatNDJSON, &buf)
+	n, err := NewTransferService(buckets, clk).Export(ctx, "b", enc)
+	if !errors.Is(err, errs.ErrNotExportable) || !strings.Contains(err.Error(), "user") {
+		t.Fatalf("Export error %v, want ErrNotExportable naming the key", err)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Errorf("Export wrote %d records, %d bytes before refusing", n, buf.Len())
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"sync"
+	"time"
+)
+
+// MaxWait bounds the timeout of blocking operations
+const MaxWait = 5 * time.Minute
+
+type waitKey struct {
+	store *engine.ShardContainer
+	key   string
+}
+
+// waiters parks callers until a key changes, without polling
+// Wakes are handed to the parked callers in arrival order
+type waiters struct {
+	mu     sync.Mutex
+	queues map[waitKey][]chan struct{}
+	closed bool
+	done   chan struct{}
+}
+
+func newWaiters() *waiters {
+	return &waiters{
+		queues: make(map[waitKey][]chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// wait calls try until it reports done or fails, parking between attempts
+// until wake is called for k, timeout passes in real time or ctx is done
+// It returns errs.ErrWaitTimeout on timeout and once the waiters are closed
+func (w *waiters) wait(ctx context.Context, k waitKey, timeout time.Duration, try func() (bool, error)) error {
+	timer := time.NewTimer(min(timeout, MaxWait))
+	defer timer.Stop()
+
+	for {
+		ch, ok := w.park(k)
+		if !ok {
+			return errs.ErrWaitTimeout
+		}
+		// Tried after parking, a change in between still wakes ch
+		if done, err := try(); done || err != nil {
+			w.leave(k, ch)
+			return err
+		}
+
+		select {
+		case <-ch:
+		case <-timer.C:
+			w.leave(k, ch)
+			return errs.ErrWaitTimeout
+		case <-ctx.Done():
+			w.leave(k, ch)
+			return ctx.Err()
+		case <-w.done:
+			w.leave(k, ch)
+			return errs.ErrWaitTimeout
+		}
+	}
+}
+
+func (w *waiters) park(k waitKey) (chan struct{}, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, false
+	}
+	ch := make(chan struct{}, 1)
+	w.queues[k] = append(w.queues[k], ch)
+	return ch, true
+}
+
+// leave unparks ch, a wake it already received is passed on to the next caller
+func (w *waiters) leave(k waitKey, ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	q := w.queues[k]
+	for i, c := range q {
+		if c == ch {
+			w.set(k, append(q[:i:i], q[i+1:]...))
+			return
+		}
+	}
+	w.wakeLocked(k, 1)
+}
+
+// wake unparks up to n callers waiting on k
+func (w *waiters) wake(k waitKey, n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.wakeLocked(k, n)
+}
+
+func (w *waiters) wakeLocked(k waitKey, n int) {
+	q := w.queues[k]
+	n = min(n, len(q))
+	for _, ch := range q[:n] {
+		ch <- struct{}{}
+	}
+	w.set(k, q[n:])
+}
+
+func (w *waiters) set(k waitKey, q []chan struct{}) {
+	if len(q) == 0 {
+		delete(w.queues, k)
+		return
+	}
+	w.queues[k] = q
+}
+
+// close releases every parked caller and makes later waits time out at once
+func (w *waiters) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+	}
+}
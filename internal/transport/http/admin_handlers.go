@@ -0,0 +1,174 @@
+package http
+
+import (
+	"errors"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Signing key Handlers
+func (h *Handlers) ListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys := h.authService.ListKeys(r.Context())
+	util.WriteOK(w, signingKeyListResponse(keys))
+}
+
+func (h *Handlers) RotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	var req RotateKeyRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	key, err := h.authService.RotateKey(r.Context(), time.Duration(req.GraceSeconds)*time.Second)
+	if err != nil {
+		slog.Error("Handler: Failed to rotate signing key", "crr-id", crrid, "error", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteCreated(w, signingKeyResponse(key))
+}
+
+func (h *Handlers) RemoveSigningKey(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	kid, err := strconv.ParseUint(r.PathValue("kid"), 10, 32)
+	if err != nil {
+		util.WriteBadRequest(w, "Invalid key id")
+		return
+	}
+
+	err = h.authService.RemoveKey(r.Context(), uint32(kid))
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrSigningKeyNotFound):
+			util.WriteNotFound(w, "Signing key not found")
+		case errors.Is(err, errs.ErrActiveSigningKey):
+			util.WriteConflict(w, "Cannot remove the active signing key")
+		default:
+			slog.Error("Handler: Failed to remove signing key", "crr-id", crrid, "kid", kid, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	util.WriteNoContent(w, "Signing key removed successfully")
+}
+
+// Config Handlers
+func (h *Handlers) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	result, err := h.configService.Reload(r.Context())
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidConfig) {
+			util.JSONError(w, http.StatusBadRequest, configProblems(err))
+			return
+		}
+		slog.Error("Handler: Failed to reload configuration", "crr-id", crrid, "error", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteOK(w, reloadResponse(result))
+}
+
+// Bucket Handlers
+func (h *Handlers) AdminFlushBucket(w http.ResponseWriter, r *http.Request) {
+	h.flushBucket(w, r, r.PathValue("bucket"))
+}
+
+// Token Handlers
+func (h *Handlers) IssueToken(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	bucketName := r.PathValue("bucket")
+
+	var req IssueTokenRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	token, err := h.authService.IssueToken(r.Context(), bucketName, req.TTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		default:
+			slog.Error("Handler: Failed to issue token", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	util.WriteCreated(w, TokenResponse{Bucket: bucketName, AuthToken: token})
+}
+
+func (h *Handlers) RevokeTokens(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	bucketName := r.PathValue("bucket")
+
+	var req RevokeTokenRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	var err error
+	switch {
+	case req.Token != "":
+		err = h.authService.RevokeToken(r.Context(), bucketName, req.Token)
+	case req.TokenID != "":
+		err = h.authService.RevokeTokenID(r.Context(), bucketName, req.TokenID)
+	default:
+		err = h.authService.RevokeIssuedBefore(r.Context(), bucketName, req.issuedBefore)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalidToken):
+			util.WriteBadRequest(w, "Invalid token")
+		default:
+			slog.Error("Handler: Failed to revoke tokens", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	revocation, _ := h.authService.Revocations(r.Context(), bucketName)
+	util.WriteOK(w, revocationResponse(bucketName, revocation))
+}
+
+func (h *Handlers) ListRevocations(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	bucketName := r.PathValue("bucket")
+
+	revocation, err := h.authService.Revocations(r.Context(), bucketName)
+	if err != nil {
+		slog.Error("Handler: Failed to list revocations", "crr-id", crrid, "bucket", bucketName, "error", err)
+		util.WriteInternalError(w)
+		return
+	}
+
+	util.WriteOK(w, revocationResponse(bucketName, revocation))
+}
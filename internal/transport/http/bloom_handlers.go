@@ -0,0 +1,123 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// Bloom Filter Handlers
+func (h *Handlers) ReserveBloomFilter(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req BloomReserveRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	info, err := h.bloomService.BFReserve(r.Context(), bucketName, key, req.Capacity, req.ErrorRate, req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "reserve Bloom filter", err)
+		return
+	}
+
+	util.WriteCreated(w, bloomInfoResponse(key, info))
+}
+
+func (h *Handlers) GetBloomFilter(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	info, err := h.bloomService.BFInfo(r.Context(), bucketName, key)
+	if err != nil {
+		writeDataError(w, crrid, "describe Bloom filter", err)
+		return
+	}
+
+	util.WriteOK(w, bloomInfoResponse(key, info))
+}
+
+func (h *Handlers) AddBloomItems(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req BloomItemsRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	added, err := h.bloomService.BFAdd(r.Context(), bucketName, key, req.Items, req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "add Bloom filter items", err)
+		return
+	}
+
+	util.WriteOK(w, BloomItemsResponse{Key: key, Added: added})
+}
+
+// CheckBloomItems tells for each requested item whether it may have been
+// added, a missing filter holds nothing
+func (h *Handlers) CheckBloomItems(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req BloomItemsRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	exists, err := h.bloomService.BFExists(r.Context(), bucketName, key, req.Items)
+	if err != nil {
+		writeDataError(w, crrid, "check Bloom filter items", err)
+		return
+	}
+
+	util.WriteOK(w, BloomItemsResponse{Key: key, Exists: exists})
+}
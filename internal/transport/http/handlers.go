@@ -2,6 +2,7 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"key-value-store/internal/errs"
 	"key-value-store/internal/service"
 	"key-value-store/internal/util"
@@ -9,15 +10,67 @@ import (
 	"net/http"
 )
 
+// Services groups the services used by the HTTP handlers
+type Services struct {
+	Storage     service.IStorageService
+	Bucket      service.IBucketService
+	Auth        service.IAuthService
+	Transfer    service.ITransferService
+	Health      service.IHealthService
+	Config      service.IConfigService
+	Hash        service.IHashService
+	List        service.IListService
+	Set         service.ISetService
+	SortedSet   service.ISortedSetService
+	JSON        service.IJSONService
+	Index       service.IIndexService
+	Stream      service.IStreamService
+	HLL         service.IHyperLogLogService
+	Bloom       service.IBloomService
+	Lock        service.ILockService
+	RateLimit   service.IRateLimitService
+	Idempotency service.IIdempotencyService
+}
+
 type Handlers struct {
-	storageService service.IStorageService
-	bucketService  service.IBucketService
+	storageService  service.IStorageService
+	bucketService   service.IBucketService
+	authService     service.IAuthService
+	transferService service.ITransferService
+	healthService   service.IHealthService
+	configService   service.IConfigService
+	hashService     service.IHashService
+	listService     service.IListService
+	setService      service.ISetService
+	zsetService     service.ISortedSetService
+	jsonService     service.IJSONService
+	indexService    service.IIndexService
+	streamService   service.IStreamService
+	hllService      service.IHyperLogLogService
+	bloomService    service.IBloomService
+	lockService     service.ILockService
+	rateService     service.IRateLimitService
 }
 
-func NewHandlers(storageService service.IStorageService, bucketService service.IBucketService) *Handlers {
+func NewHandlers(services Services) *Handlers {
 	return &Handlers{
-		storageService: storageService,
-		bucketService:  bucketService,
+		storageService:  services.Storage,
+		bucketService:   services.Bucket,
+		authService:     services.Auth,
+		transferService: services.Transfer,
+		healthService:   services.Health,
+		configService:   services.Config,
+		hashService:     services.Hash,
+		listService:     services.List,
+		setService:      services.Set,
+		zsetService:     services.SortedSet,
+		jsonService:     services.JSON,
+		indexService:    services.Index,
+		streamService:   services.Stream,
+		hllService:      services.HLL,
+		bloomService:    services.Bloom,
+		lockService:     services.Lock,
+		rateService:     services.RateLimit,
 	}
 }
 
@@ -48,6 +101,18 @@ func (h *Handlers) CreateKV(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, errs.ErrInvalidTTL):
 			util.WriteBadRequest(w, "Invalid TTL")
+		case errors.Is(err, errs.ErrTTLExceedsLimit):
+			util.WriteBadRequest(w, "TTL exceeds bucket limit")
+		case errors.Is(err, errs.ErrKeyTooLong):
+			util.WriteBadRequest(w, "Key too long")
+		case errors.Is(err, errs.ErrSingleReadDenied):
+			util.WriteBadRequest(w, "Single-read keys are not allowed in this bucket")
+		case errors.Is(err, errs.ErrValueTooLarge):
+			util.WritePayloadTooLarge(w, "Value too large")
+		case errors.Is(err, errs.ErrKeyLimit):
+			util.WriteConflict(w, "Bucket key limit reached")
+		case errors.Is(err, errs.ErrWrongType):
+			util.WriteConflict(w, wrongTypeMessage(err))
 		case errors.Is(err, errs.ErrUnauthorized):
 			util.WriteUnauthorized(w, "Invalid bucket auth token")
 		case errors.Is(err, errs.ErrBucketNotFound):
@@ -86,6 +151,8 @@ func (h *Handlers) GetKV(w http.ResponseWriter, r *http.Request) {
 			util.WriteNotFound(w, "Key not found")
 		case errors.Is(err, errs.ErrKeyExpired):
 			util.WriteNotFound(w, "Key expired")
+		case errors.Is(err, errs.ErrWrongType):
+			util.WriteConflict(w, wrongTypeMessage(err))
 		case errors.Is(err, errs.ErrUnauthorized):
 			util.WriteUnauthorized(w, "Invalid bucket auth token")
 		case errors.Is(err, errs.ErrBucketNotFound):
@@ -121,6 +188,10 @@ func (h *Handlers) DeleteKV(w http.ResponseWriter, r *http.Request) {
 	err := h.storageService.Delete(r.Context(), bucketName, key)
 	if err != nil {
 		switch {
+		case errors.Is(err, errs.ErrWrongType):
+			util.WriteConflict(w, wrongTypeMessage(err))
+		case errors.Is(err, errs.ErrLockHeld):
+			util.WriteConflict(w, "Lock held by another owner")
 		case errors.Is(err, errs.ErrUnauthorized):
 			util.WriteUnauthorized(w, "Invalid bucket auth token")
 		case errors.Is(err, errs.ErrBucketNotFound):
@@ -135,6 +206,89 @@ func (h *Handlers) DeleteKV(w http.ResponseWriter, r *http.Request) {
 	util.WriteNoContent(w, "Key deleted successfully")
 }
 
+// writeDataError maps the errors of structured value operations, op names the
+// operation in the log of unexpected errors
+func writeDataError(w http.ResponseWriter, crrid, op string, err error) {
+	switch {
+	case errors.Is(err, errs.ErrKeyNotFound):
+		util.WriteNotFound(w, "Key not found")
+	case errors.Is(err, errs.ErrFieldNotFound):
+		util.WriteNotFound(w, "Field not found")
+	case errors.Is(err, errs.ErrMemberNotFound):
+		util.WriteNotFound(w, "Member not found")
+	case errors.Is(err, errs.ErrWrongType):
+		util.WriteConflict(w, wrongTypeMessage(err))
+	case errors.Is(err, errs.ErrNotInteger):
+		util.WriteBadRequest(w, "Value is not an integer or out of range")
+	case errors.Is(err, errs.ErrInvalidScore):
+		util.WriteBadRequest(w, "Score is not a finite number")
+	case errors.Is(err, errs.ErrInvalidJSON):
+		util.WriteBadRequest(w, "Invalid JSON")
+	case errors.Is(err, errs.ErrInvalidPath):
+		util.WriteBadRequest(w, "Invalid path")
+	case errors.Is(err, errs.ErrPathNotFound):
+		util.WriteNotFound(w, "Path not found")
+	case errors.Is(err, errs.ErrPathType):
+		util.WriteConflict(w, "Path holds a different JSON type")
+	case errors.Is(err, errs.ErrNumberOverflow):
+		util.WriteBadRequest(w, "Number out of range")
+	case errors.Is(err, errs.ErrIndexNotFound):
+		util.WriteNotFound(w, "Index not found")
+	case errors.Is(err, errs.ErrIndexExists):
+		util.WriteConflict(w, "Index already exists")
+	case errors.Is(err, errs.ErrInvalidIndex):
+		util.WriteBadRequest(w, "Invalid index name")
+	case errors.Is(err, errs.ErrIndexValue):
+		util.WriteBadRequest(w, "Index values are JSON scalars")
+	case errors.Is(err, errs.ErrInvalidCursor):
+		util.WriteBadRequest(w, "Invalid cursor")
+	case errors.Is(err, errs.ErrInvalidStreamID):
+		util.WriteBadRequest(w, "Invalid stream ID")
+	case errors.Is(err, errs.ErrGroupNotFound):
+		util.WriteNotFound(w, "Consumer group not found")
+	case errors.Is(err, errs.ErrGroupExists):
+		util.WriteConflict(w, "Consumer group already exists")
+	case errors.Is(err, errs.ErrInvalidGroup):
+		util.WriteBadRequest(w, "Invalid consumer group or consumer name")
+	case errors.Is(err, errs.ErrInvalidFilter):
+		util.WriteBadRequest(w, "Invalid filter capacity or error rate")
+	case errors.Is(err, errs.ErrFilterExists):
+		util.WriteConflict(w, "Filter already exists")
+	case errors.Is(err, errs.ErrLockHeld):
+		util.WriteConflict(w, "Lock held by another owner")
+	case errors.Is(err, errs.ErrLockNotOwned):
+		util.WriteConflict(w, "Lock not held by this owner")
+	case errors.Is(err, errs.ErrInvalidLock):
+		util.WriteBadRequest(w, "Invalid lock owner or lease")
+	case errors.Is(err, errs.ErrInvalidRateLimit):
+		util.WriteBadRequest(w, "Invalid rate limit or cost")
+	case errors.Is(err, errs.ErrInvalidTTL):
+		util.WriteBadRequest(w, "Invalid TTL")
+	case errors.Is(err, errs.ErrTTLExceedsLimit):
+		util.WriteBadRequest(w, "TTL exceeds bucket limit")
+	case errors.Is(err, errs.ErrKeyTooLong):
+		util.WriteBadRequest(w, "Key too long")
+	case errors.Is(err, errs.ErrValueTooLarge):
+		util.WritePayloadTooLarge(w, "Value too large")
+	case errors.Is(err, errs.ErrKeyLimit):
+		util.WriteConflict(w, "Bucket key limit reached")
+	case errors.Is(err, errs.ErrBucketNotFound):
+		util.WriteNotFound(w, "Bucket not found")
+	default:
+		slog.Error("Handler: Failed to "+op, "crr-id", crrid, "error", err)
+		util.WriteInternalError(w)
+	}
+}
+
+// wrongTypeMessage tells which kind of value the key holds when err says it
+func wrongTypeMessage(err error) string {
+	var wrong *errs.WrongTypeError
+	if errors.As(err, &wrong) {
+		return fmt.Sprintf("Key holds a %s value, not a %s", wrong.Have, wrong.Want)
+	}
+	return "Key holds a different kind of value"
+}
+
 // Bucket Handlers
 func (h *Handlers) CreateBucket(w http.ResponseWriter, r *http.Request) {
 	crrid := util.GetCorrelationID(r.Context())
@@ -150,13 +304,15 @@ func (h *Handlers) CreateBucket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.bucketService.CreateBucket(r.Context(), req.Name, req.Description, req.ShardCount)
+	result, err := h.bucketService.CreateBucket(r.Context(), req.Name, req.Description, req.ShardCount, req.BucketSettings())
 	if err != nil {
 		switch {
 		case errors.Is(err, errs.ErrBucketAlreadyExists):
 			util.WriteConflict(w, "Bucket already exists")
 		case errors.Is(err, errs.ErrInvalidBucketName):
 			util.WriteBadRequest(w, "Invalid bucket name")
+		case errors.Is(err, errs.ErrInvalidSettings):
+			util.WriteBadRequest(w, err.Error())
 		default:
 			slog.Error("Handler: Failed to create bucket", "crr-id", crrid, "error", err)
 			util.WriteInternalError(w)
@@ -194,6 +350,234 @@ func (h *Handlers) GetBucket(w http.ResponseWriter, r *http.Request) {
 	util.WriteOK(w, resp)
 }
 
+func (h *Handlers) UpdateBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req BucketSettingsRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	meta, err := h.bucketService.UpdateSettings(r.Context(), bucketName, req.Patch())
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		case errors.Is(err, errs.ErrInvalidSettings):
+			util.WriteBadRequest(w, err.Error())
+		default:
+			slog.Error("Handler: Failed to update bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	resp := bucketResponse(meta, "")
+	util.WriteOK(w, resp)
+}
+
+func (h *Handlers) CloneBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req CloneBucketRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	result, err := h.bucketService.CloneBucket(r.Context(), bucketName, req.Name, req.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		case errors.Is(err, errs.ErrBucketAlreadyExists):
+			util.WriteConflict(w, "Bucket already exists")
+		case errors.Is(err, errs.ErrInvalidBucketName):
+			util.WriteBadRequest(w, "Invalid bucket name")
+		case errors.Is(err, errs.ErrReshardInProgress):
+			util.WriteConflict(w, "Resharding in progress")
+		default:
+			slog.Error("Handler: Failed to clone bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	resp := bucketResponse(result.Metadata, result.AuthToken)
+	util.WriteCreated(w, resp)
+}
+
+func (h *Handlers) RenameBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req RenameBucketRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	result, err := h.bucketService.RenameBucket(r.Context(), bucketName, req.Name, req.ReissueTokens)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		case errors.Is(err, errs.ErrBucketAlreadyExists):
+			util.WriteConflict(w, "Bucket already exists")
+		case errors.Is(err, errs.ErrInvalidBucketName):
+			util.WriteBadRequest(w, "Invalid bucket name")
+		case errors.Is(err, errs.ErrCannotRenameDefault):
+			util.WriteBadRequest(w, "Cannot rename default bucket")
+		default:
+			slog.Error("Handler: Failed to rename bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	resp := bucketResponse(result.Metadata, result.AuthToken)
+	util.WriteOK(w, resp)
+}
+
+func (h *Handlers) FlushBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	h.flushBucket(w, r, bucketName)
+}
+
+func (h *Handlers) flushBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	removed, err := h.bucketService.FlushBucket(r.Context(), bucketName)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		case errors.Is(err, errs.ErrReshardInProgress):
+			util.WriteConflict(w, "Resharding in progress")
+		default:
+			slog.Error("Handler: Failed to flush bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	util.WriteOK(w, FlushResponse{Bucket: bucketName, Removed: removed})
+}
+
+func (h *Handlers) ReshardBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req ReshardRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	err := h.bucketService.Reshard(r.Context(), bucketName, req.ShardCount)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		case errors.Is(err, errs.ErrInvalidShardCount):
+			util.WriteBadRequest(w, "Invalid shard count")
+		case errors.Is(err, errs.ErrReshardInProgress):
+			util.WriteConflict(w, "Resharding already in progress")
+		default:
+			slog.Error("Handler: Failed to reshard bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+
+	progress, _, _ := h.bucketService.ReshardProgress(r.Context(), bucketName)
+	util.JSON(w, http.StatusAccepted, reshardProgressResponse(bucketName, progress))
+}
+
+func (h *Handlers) GetReshardProgress(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	progress, found, err := h.bucketService.ReshardProgress(r.Context(), bucketName)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		default:
+			slog.Error("Handler: Failed to get reshard progress", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteInternalError(w)
+		}
+		return
+	}
+	if !found {
+		util.WriteNotFound(w, "No resharding has been started")
+		return
+	}
+
+	util.WriteOK(w, reshardProgressResponse(bucketName, progress))
+}
+
 func (h *Handlers) DeleteBucket(w http.ResponseWriter, r *http.Request) {
 	crrid := util.GetCorrelationID(r.Context())
 	bucketName := r.PathValue("bucket")
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/config"
+	"key-value-store/internal/service"
+	"key-value-store/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeleteHeldLockConflict(t *testing.T) {
+	if err := auth.Initialize([]byte("0123456789abcdef0123456789abcdef"), ""); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Store.DataDir = ""
+	clk := clock.Real()
+	bm, err := bucket.NewBucketManager(cfg, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bm.Shutdown()
+
+	locks := service.NewLockService(bm, clk)
+	if _, err := locks.Acquire(context.Background(), "default", "job", "a", 30, 0); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlers(Services{Storage: service.NewStorageService(bm, cfg, clk)})
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/default/kv/job", nil)
+	r.SetPathValue("key", "job")
+	r = r.WithContext(util.SetBucketName(r.Context(), "default"))
+	w := httptest.NewRecorder()
+	h.DeleteKV(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete of a held lock: %d %s, want 409", w.Code, w.Body)
+	}
+	if info, err := locks.Info(context.Background(), "default", "job"); err != nil || info.Owner != "a" {
+		t.Errorf("lock after delete = %+v, %v", info, err)
+	}
+}
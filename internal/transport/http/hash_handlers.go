@@ -0,0 +1,125 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// Hash Handlers
+func (h *Handlers) SetHashFields(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req SetHashRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	added, err := h.hashService.HSet(r.Context(), bucketName, key, req.fieldValues(), req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "set hash fields", err)
+		return
+	}
+
+	util.WriteOK(w, HashCountResponse{Key: key, Count: added})
+}
+
+func (h *Handlers) GetHash(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	fields, err := h.hashService.HGetAll(r.Context(), bucketName, key)
+	if err != nil {
+		writeDataError(w, crrid, "get hash", err)
+		return
+	}
+
+	util.WriteOK(w, hashResponse(key, fields))
+}
+
+func (h *Handlers) GetHashField(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, field := r.PathValue("key"), r.PathValue("field")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	value, err := h.hashService.HGet(r.Context(), bucketName, key, field)
+	if err != nil {
+		writeDataError(w, crrid, "get hash field", err)
+		return
+	}
+
+	util.WriteOK(w, HashFieldResponse{Key: key, Field: field, Value: util.BytesToString(value)})
+}
+
+func (h *Handlers) DeleteHashField(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, field := r.PathValue("key"), r.PathValue("field")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	removed, err := h.hashService.HDel(r.Context(), bucketName, key, []string{field})
+	if err != nil {
+		writeDataError(w, crrid, "delete hash field", err)
+		return
+	}
+
+	util.WriteOK(w, HashCountResponse{Key: key, Count: removed})
+}
+
+func (h *Handlers) IncrHashField(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, field := r.PathValue("key"), r.PathValue("field")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req IncrRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	value, err := h.hashService.HIncrBy(r.Context(), bucketName, key, field, req.By)
+	if err != nil {
+		writeDataError(w, crrid, "increment hash field", err)
+		return
+	}
+
+	util.WriteOK(w, HashIncrResponse{Key: key, Field: field, Value: value})
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// HyperLogLog Handlers
+func (h *Handlers) AddHyperLogLogMembers(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req HLLAddRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	changed, err := h.hllService.PFAdd(r.Context(), bucketName, key, req.Members, req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "add HyperLogLog members", err)
+		return
+	}
+
+	util.WriteOK(w, HLLAddResponse{Key: key, Changed: changed})
+}
+
+func (h *Handlers) CountHyperLogLog(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	count, err := h.hllService.PFCount(r.Context(), bucketName, []string{key})
+	if err != nil {
+		writeDataError(w, crrid, "count HyperLogLog", err)
+		return
+	}
+
+	util.WriteOK(w, HLLCountResponse{Key: key, Count: count})
+}
+
+// CountHyperLogLogs estimates the distinct members of the HyperLogLogs held by
+// the requested keys together, missing keys count as empty
+func (h *Handlers) CountHyperLogLogs(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req HLLKeysRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	count, err := h.hllService.PFCount(r.Context(), bucketName, req.Keys)
+	if err != nil {
+		writeDataError(w, crrid, "count HyperLogLogs", err)
+		return
+	}
+
+	util.WriteOK(w, HLLCountResponse{Keys: req.Keys, Count: count})
+}
+
+// MergeHyperLogLogs adds the members of the HyperLogLogs held by the requested
+// keys to the one held by the path key
+func (h *Handlers) MergeHyperLogLogs(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req HLLKeysRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	count, err := h.hllService.PFMerge(r.Context(), bucketName, key, req.Keys)
+	if err != nil {
+		writeDataError(w, crrid, "merge HyperLogLogs", err)
+		return
+	}
+
+	util.WriteOK(w, HLLCountResponse{Key: key, Count: count})
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"key-value-store/internal/service"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// Index Handlers
+
+func (h *Handlers) ListIndexes(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	defs, err := h.indexService.ListIndexes(r.Context(), bucketName)
+	if err != nil {
+		writeDataError(w, crrid, "list indexes", err)
+		return
+	}
+
+	util.WriteOK(w, IndexListResponse{Bucket: bucketName, Indexes: indexResponses(defs)})
+}
+
+// CreateIndex indexes the JSON documents of the bucket by the value at the
+// requested path, existing documents included
+func (h *Handlers) CreateIndex(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req IndexRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	def, err := h.indexService.CreateIndex(r.Context(), bucketName, name, req.Path)
+	if err != nil {
+		writeDataError(w, crrid, "create index", err)
+		return
+	}
+
+	util.WriteCreated(w, IndexResponse{Name: def.Name, Path: def.Path})
+}
+
+func (h *Handlers) DropIndex(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	if err := h.indexService.DropIndex(r.Context(), bucketName, name); err != nil {
+		writeDataError(w, crrid, "drop index", err)
+		return
+	}
+
+	util.WriteNoContent(w, "Index dropped")
+}
+
+// QueryIndex looks up keys by the JSON value of the eq query, or between the
+// min and max queries, both included
+// Pages hold up to limit keys and are continued with the returned cursor
+func (h *Handlers) QueryIndex(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil || limit < 0 || limit > service.MaxIndexLimit {
+		util.WriteBadRequest(w, "Invalid limit")
+		return
+	}
+
+	query := r.URL.Query()
+	q := service.IndexQuery{
+		Equal:  queryBytes(query, "eq"),
+		Min:    queryBytes(query, "min"),
+		Max:    queryBytes(query, "max"),
+		Cursor: query.Get("cursor"),
+		Limit:  int(limit),
+	}
+
+	page, err := h.indexService.Query(r.Context(), bucketName, name, q)
+	if err != nil {
+		writeDataError(w, crrid, "query index", err)
+		return
+	}
+
+	util.WriteOK(w, indexQueryResponse(name, page))
+}
+
+// queryBytes returns the query parameter name, nil when it is absent
+func queryBytes(query url.Values, name string) []byte {
+	v, ok := query[name]
+	if !ok || len(v) == 0 {
+		return nil
+	}
+	return []byte(v[0])
+}
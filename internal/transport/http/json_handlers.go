@@ -0,0 +1,144 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// JSON Handlers
+
+// GetJSON returns the value at the path query, the whole document by default
+func (h *Handlers) GetJSON(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, path := r.PathValue("key"), r.URL.Query().Get("path")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	value, err := h.jsonService.JGet(r.Context(), bucketName, key, path)
+	if err != nil {
+		writeDataError(w, crrid, "get JSON", err)
+		return
+	}
+
+	util.WriteOK(w, JSONValueResponse{Key: key, Path: path, Value: value})
+}
+
+func (h *Handlers) SetJSON(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req JSONSetRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	if err := h.jsonService.JSet(r.Context(), bucketName, key, req.Path, req.Value, req.TTL); err != nil {
+		writeDataError(w, crrid, "set JSON", err)
+		return
+	}
+
+	util.WriteOK(w, JSONPathResponse{Key: key, Path: req.Path})
+}
+
+// DeleteJSON removes the value at the path query, the whole document and its
+// key by default
+func (h *Handlers) DeleteJSON(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, path := r.PathValue("key"), r.URL.Query().Get("path")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	deleted, err := h.jsonService.JDel(r.Context(), bucketName, key, path)
+	if err != nil {
+		writeDataError(w, crrid, "delete JSON", err)
+		return
+	}
+
+	util.WriteOK(w, JSONDeleteResponse{Key: key, Path: path, Deleted: deleted})
+}
+
+func (h *Handlers) AppendJSON(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req JSONAppendRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	length, err := h.jsonService.JArrAppend(r.Context(), bucketName, key, req.Path, req.rawValues())
+	if err != nil {
+		writeDataError(w, crrid, "append to JSON array", err)
+		return
+	}
+
+	util.WriteOK(w, JSONLengthResponse{Key: key, Path: req.Path, Length: length})
+}
+
+func (h *Handlers) IncrJSON(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req JSONIncrRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	value, err := h.jsonService.JIncrBy(r.Context(), bucketName, key, req.Path, req.By)
+	if err != nil {
+		writeDataError(w, crrid, "increment JSON number", err)
+		return
+	}
+
+	util.WriteOK(w, JSONValueResponse{Key: key, Path: req.Path, Value: []byte(value)})
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// List Handlers
+func (h *Handlers) PushList(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req PushRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	values := make([][]byte, len(req.Values))
+	for i, v := range req.Values {
+		values[i] = []byte(v)
+	}
+
+	length, err := h.listService.Push(r.Context(), bucketName, key, req.end, values, req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "push to list", err)
+		return
+	}
+
+	util.WriteOK(w, ListLengthResponse{Key: key, Length: length})
+}
+
+// PopList removes values from a list, with timeout_ms it is a long-poll that
+// answers 204 when nothing was pushed in time
+func (h *Handlers) PopList(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req PopRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	timeout := extendWriteDeadline(w, req.TimeoutMs)
+	values, err := h.listService.Pop(r.Context(), bucketName, key, req.end, req.Count, timeout)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrWaitTimeout), errors.Is(err, context.Canceled):
+			util.WriteNoContent(w, "Nothing to pop")
+		default:
+			writeDataError(w, crrid, "pop from list", err)
+		}
+		return
+	}
+
+	util.WriteOK(w, listResponse(key, values))
+}
+
+// GetList returns the values between the start and stop query indexes, both
+// included and negative ones counting from the end, the whole list by default
+func (h *Handlers) GetList(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	start, err := queryInt(r, "start", 0)
+	if err != nil {
+		util.WriteBadRequest(w, "start must be an integer")
+		return
+	}
+	stop, err := queryInt(r, "stop", -1)
+	if err != nil {
+		util.WriteBadRequest(w, "stop must be an integer")
+		return
+	}
+
+	values, err := h.listService.Range(r.Context(), bucketName, key, start, stop)
+	if err != nil {
+		writeDataError(w, crrid, "read list", err)
+		return
+	}
+
+	util.WriteOK(w, listResponse(key, values))
+}
+
+func (h *Handlers) GetListLength(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	length, err := h.listService.Len(r.Context(), bucketName, key)
+	if err != nil {
+		writeDataError(w, crrid, "read list length", err)
+		return
+	}
+
+	util.WriteOK(w, ListLengthResponse{Key: key, Length: length})
+}
+
+func (h *Handlers) TrimList(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req TrimRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	length, err := h.listService.Trim(r.Context(), bucketName, key, req.Start, req.Stop)
+	if err != nil {
+		writeDataError(w, crrid, "trim list", err)
+		return
+	}
+
+	util.WriteOK(w, ListLengthResponse{Key: key, Length: length})
+}
+
+func queryInt(r *http.Request, name string, def int64) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// extendWriteDeadline returns the timeout of a long-poll and pushes the write
+// deadline past it, the server write timeout would cut the long-poll short
+func extendWriteDeadline(w http.ResponseWriter, timeoutMs int64) time.Duration {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout > 0 {
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Now().Add(timeout + writeTimeout*time.Second))
+	}
+	return timeout
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// Lock Handlers
+
+// AcquireLock takes a lock, with timeout_ms it waits for the holder to
+// release it and answers 409 when it was not released in time
+func (h *Handlers) AcquireLock(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req LockAcquireRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	timeout := extendWriteDeadline(w, req.TimeoutMs)
+	info, err := h.lockService.Acquire(r.Context(), bucketName, name, req.Owner, req.TTL, timeout)
+	if err != nil {
+		writeDataError(w, crrid, "acquire lock", err)
+		return
+	}
+
+	util.WriteOK(w, lockResponse(name, info))
+}
+
+func (h *Handlers) RenewLock(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req LockRenewRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	info, err := h.lockService.Renew(r.Context(), bucketName, name, req.Owner, req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "renew lock", err)
+		return
+	}
+
+	util.WriteOK(w, lockResponse(name, info))
+}
+
+func (h *Handlers) ReleaseLock(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req LockReleaseRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	if err := h.lockService.Release(r.Context(), bucketName, name, req.Owner); err != nil {
+		writeDataError(w, crrid, "release lock", err)
+		return
+	}
+
+	util.WriteNoContent(w, "Lock released")
+}
+
+func (h *Handlers) GetLock(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	name := r.PathValue("name")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	info, err := h.lockService.Info(r.Context(), bucketName, name)
+	if err != nil {
+		writeDataError(w, crrid, "describe lock", err)
+		return
+	}
+
+	util.WriteOK(w, lockResponse(name, info))
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	AdminTokenHeader = "X-Admin-Token"
+)
+
+// Admin validates the admin token for management endpoints
+func Admin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		crrid := util.GetCorrelationID(r.Context())
+
+		tokenStr := r.Header.Get(AdminTokenHeader)
+		if tokenStr == "" {
+			slog.Debug("Middleware: Admin token not provided", "crr-id", crrid)
+			metrics.AuthFailures.With(metrics.TransportHTTP, "admin").Inc()
+			util.WriteUnauthorized(w, "X-Admin-Token header is required")
+			return
+		}
+
+		if !auth.Manager().ValidateToken(tokenStr, auth.AdminSubject) {
+			slog.Debug("Middleware: Invalid admin token", "crr-id", crrid)
+			metrics.AuthFailures.With(metrics.TransportHTTP, "admin").Inc()
+			util.WriteUnauthorized(w, "Invalid admin token")
+			return
+		}
+
+		slog.Debug("Middleware: Admin authenticated", "crr-id", crrid)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
 	"key-value-store/internal/util"
 	"log/slog"
 	"net/http"
@@ -24,6 +25,7 @@ func Auth(next http.Handler) http.Handler {
 		tokenStr := r.Header.Get("X-Bucket-Token")
 		if tokenStr == "" {
 			slog.Debug("Middleware: Bucket token not provided", "crr-id", crrid)
+			metrics.AuthFailures.With(metrics.TransportHTTP, "bucket").Inc()
 			util.WriteUnauthorized(w, "X-Bucket-Token header is required")
 			return
 		}
@@ -31,6 +33,7 @@ func Auth(next http.Handler) http.Handler {
 		// Validate token with bucket name
 		if !auth.Manager().ValidateToken(tokenStr, bucketName) {
 			slog.Debug("Middleware: Invalid bucket token", "crr-id", crrid, "bucket", bucketName)
+			metrics.AuthFailures.With(metrics.TransportHTTP, "bucket").Inc()
 			util.WriteUnauthorized(w, "Invalid bucket token")
 			return
 		}
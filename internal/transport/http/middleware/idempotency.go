@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/service"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed for a repeated Idempotency-Key
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Idempotency answers a write sent again with the same Idempotency-Key with
+// the response of the first one, without running it again
+// The request is fingerprinted by its method, URI and body, a key reused for
+// another request gets 422; 5xx responses are not kept, so the write can be
+// retried. It must run after authentication, keys are scoped to the bucket
+func Idempotency(svc service.IIdempotencyService) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			crrid := util.GetCorrelationID(r.Context())
+			if len(key) > service.MaxIdempotencyKeyLength {
+				util.WriteBadRequest(w, "Idempotency-Key too long")
+				return
+			}
+			bucketName, ok := util.GetBucketName(r.Context())
+			if !ok {
+				slog.Error("Middleware: Bucket name not found in context", "crr-id", crrid)
+				util.WriteUnauthorized(w, "Unauthorized")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, util.DefaultMaxBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					util.WritePayloadTooLarge(w, "Request body too large")
+					return
+				}
+				util.WriteBadRequest(w, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			replay, err := svc.Begin(r.Context(), bucketName, key, fingerprint(r, body))
+			switch {
+			case errors.Is(err, errs.ErrReplayMismatch):
+				util.WriteUnprocessable(w, "Idempotency-Key was used for a different request")
+				return
+			case errors.Is(err, errs.ErrReplayPending):
+				util.WriteConflict(w, "A request with this Idempotency-Key is in progress")
+				return
+			case errors.Is(err, errs.ErrBucketNotFound):
+				util.WriteNotFound(w, "Bucket not found")
+				return
+			case err != nil:
+				slog.Error("Middleware: Failed to claim idempotency key", "crr-id", crrid, "error", err)
+				util.WriteInternalError(w)
+				return
+			case replay != nil:
+				slog.Debug("Middleware: Replaying response", "crr-id", crrid, "bucket", bucketName)
+				if replay.ContentType != "" {
+					w.Header().Set("Content-Type", replay.ContentType)
+				}
+				w.Header().Set(ReplayedHeader, "true")
+				w.WriteHeader(replay.Status)
+				w.Write(replay.Body)
+				return
+			}
+
+			// A panicking or failing handler leaves the key free for a retry
+			completed := false
+			defer func() {
+				if !completed {
+					svc.Abandon(r.Context(), bucketName, key)
+				}
+			}()
+
+			rec := &recordingWriter{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			if rec.status >= http.StatusInternalServerError {
+				return
+			}
+
+			resp := service.StoredResponse{
+				Status:      rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			}
+			if err := svc.Complete(r.Context(), bucketName, key, resp); err != nil {
+				slog.Error("Middleware: Failed to store idempotent response", "crr-id", crrid, "error", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// fingerprint identifies a request by its method, URI and body
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.RequestURI())
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the status and body written through it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the bytes that reached the client
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.body.Write(p[:n])
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"io"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/service"
+	"key-value-store/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBuckets serves the bucket "b" and the idempotency bucket
+type testBuckets struct {
+	bucket.BucketManager
+	internal *engine.ShardContainer
+}
+
+func (b *testBuckets) GetBucket(name string) (*bucket.BucketMetadata, bool) {
+	return &bucket.BucketMetadata{ID: "id-b", Name: name}, name == "b"
+}
+
+func (b *testBuckets) InternalStore(name string) (*engine.ShardContainer, bool) {
+	return b.internal, name == bucket.IdempotencyBucket
+}
+
+// newTestIdempotency wraps handler in Recovery and Idempotency, as the router
+// does for writes
+func newTestIdempotency(handler http.HandlerFunc) http.HandlerFunc {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	svc := service.NewIdempotencyService(&testBuckets{internal: engine.NewShardContainer(4, clk)}, clk, time.Hour)
+	return ApplyMiddleware(handler, Recovery, Idempotency(svc))
+}
+
+func send(h http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/b/kv", strings.NewReader(body))
+	r = r.WithContext(util.SetBucketName(r.Context(), "b"))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	h := newTestIdempotency(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		util.JSON(w, http.StatusCreated, map[string]any{"call": calls.Add(1), "body": string(body)})
+	})
+
+	first := send(h, "k1", "a")
+	again := send(h, "k1", "a")
+	if again.Code != http.StatusCreated || again.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", again.Code, again.Body, first.Code, first.Body)
+	}
+	if again.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("replayed header %q on the replay, %q on the first", again.Header().Get(ReplayedHeader), first.Header().Get(ReplayedHeader))
+	}
+	if again.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("replayed content type %q", again.Header().Get("Content-Type"))
+	}
+
+	if w := send(h, "k1", "b"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body with the same key = %d", w.Code)
+	}
+	if w := send(h, "", "a"); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("request without key = %d, replayed %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+}
+
+func TestIdempotencyPending(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := newTestIdempotency(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send(h, "k1", "a") }()
+	<-entered
+	if w := send(h, "k1", "a"); w.Code != http.StatusConflict {
+		t.Errorf("request sent again while running = %d", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusNoContent {
+		t.Errorf("first request = %d", w.Code)
+	}
+	if w := send(h, "k1", "a"); w.Code != http.StatusNoContent || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("replay after completion = %d, replayed %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+}
+
+func TestIdempotencyFailuresNotStored(t *testing.T) {
+	var calls atomic.Int32
+	h := newTestIdempotency(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			util.WriteInternalError(w)
+		case 2:
+			panic("handler failed")
+		default:
+			util.JSON(w, http.StatusCreated, "ok")
+		}
+	})
+
+	if w := send(h, "k1", "a"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("failing request = %d", w.Code)
+	}
+	// A panic frees the key like a 5xx
+	if w := send(h, "k1", "a"); w.Code != http.StatusInternalServerError || w.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("retry after a 5xx = %d, replayed %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+	if w := send(h, "k1", "a"); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("retry after a panic = %d, replayed %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+	if w := send(h, "k1", "a"); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("replay of the success = %d, replayed %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("handler ran %d times, want 3", n)
+	}
+}
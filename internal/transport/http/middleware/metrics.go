@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"key-value-store/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics counts operations and records their latency by route pattern
+// It must run after authentication: the bucket label only ever holds names a
+// valid token was presented for, which keeps the label cardinality bounded
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		status := rw.statusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.Operations.With(r.PathValue("bucket"), metrics.TransportHTTP, r.Pattern, strconv.Itoa(status)).Inc()
+		metrics.CommandDuration.With(metrics.TransportHTTP, r.Pattern).ObserveSince(start)
+	})
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// Rate Limiter Handlers
+
+// TakeRateLimit answers 200 whether the tokens were taken or not, allowed
+// tells which
+func (h *Handlers) TakeRateLimit(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req RateLimitRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	res, err := h.rateService.Take(r.Context(), bucketName, key, req.limit(), req.cost)
+	if err != nil {
+		writeDataError(w, crrid, "take rate limit tokens", err)
+		return
+	}
+
+	util.WriteOK(w, rateLimitResponse(key, res))
+}
@@ -1,9 +1,13 @@
 package http
 
 import (
-	"key-value-store/internal/service"
+	"context"
+	"errors"
 	"key-value-store/internal/transport/http/middleware"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -13,51 +17,223 @@ const (
 	idleTimeout  = 15 // in seconds
 )
 
+const bucketsPrefix = "/api/buckets/"
+
 type Router struct {
-	server *http.Server
-	mux    *http.ServeMux
+	addr      string
+	server    *http.Server
+	ln        net.Listener
+	mux       *http.ServeMux
+	bucketMux *http.ServeMux
 }
 
-func NewRouter(storageService service.IStorageService, bucketService service.IBucketService) *Router {
+func NewRouter(addr string, services Services) *Router {
 	mux := http.NewServeMux()
-	handlers := NewHandlers(storageService, bucketService)
+	bucketMux := http.NewServeMux()
+	handlers := NewHandlers(services)
 
 	mw := []middleware.Middleware{
 		middleware.Recovery,
 		middleware.Correlation,
 		middleware.Logger,
 		middleware.Auth,
+		middleware.Metrics,
 	}
 
+	// Writes honor Idempotency-Key, after Metrics so replays are counted too
+	// Reads sent as POST keep mw, their results must not be replayed stale
+	writeMw := append(slices.Clone(mw), middleware.Idempotency(services.Idempotency))
+
 	noAuthMw := []middleware.Middleware{
 		middleware.Recovery,
 		middleware.Correlation,
 		middleware.Logger,
+		middleware.Metrics,
+	}
+
+	adminMw := []middleware.Middleware{
+		middleware.Recovery,
+		middleware.Correlation,
+		middleware.Logger,
+		middleware.Admin,
+		middleware.Metrics,
+	}
+
+	// Probes and scrapes are neither logged nor counted
+	probeMw := []middleware.Middleware{
+		middleware.Recovery,
 	}
 
 	// Bucket management endpoints
 	mux.HandleFunc("POST /api/buckets", middleware.ApplyMiddleware(handlers.CreateBucket, noAuthMw...))
 	mux.HandleFunc("GET /api/buckets", middleware.ApplyMiddleware(handlers.ListBuckets, mw...))
 	mux.HandleFunc("GET /api/buckets/{bucket}", middleware.ApplyMiddleware(handlers.GetBucket, mw...))
+	mux.HandleFunc("PATCH /api/buckets/{bucket}", middleware.ApplyMiddleware(handlers.UpdateBucket, mw...))
 	mux.HandleFunc("DELETE /api/buckets/{bucket}", middleware.ApplyMiddleware(handlers.DeleteBucket, mw...))
 
+	// Bucket sub-resources, see Router.ServeHTTP
+	bucketMux.HandleFunc("POST /api/buckets/{bucket}/clone", middleware.ApplyMiddleware(handlers.CloneBucket, mw...))
+	bucketMux.HandleFunc("POST /api/buckets/{bucket}/rename", middleware.ApplyMiddleware(handlers.RenameBucket, mw...))
+	bucketMux.HandleFunc("POST /api/buckets/{bucket}/flush", middleware.ApplyMiddleware(handlers.FlushBucket, mw...))
+	bucketMux.HandleFunc("POST /api/buckets/{bucket}/reshard", middleware.ApplyMiddleware(handlers.ReshardBucket, mw...))
+	bucketMux.HandleFunc("GET /api/buckets/{bucket}/reshard", middleware.ApplyMiddleware(handlers.GetReshardProgress, mw...))
+	bucketMux.HandleFunc("GET /api/buckets/{bucket}/export", middleware.ApplyMiddleware(handlers.ExportBucket, mw...))
+	bucketMux.HandleFunc("POST /api/buckets/{bucket}/import", middleware.ApplyMiddleware(handlers.ImportBucket, mw...))
+	bucketMux.HandleFunc("GET /api/buckets/{bucket}/indexes", middleware.ApplyMiddleware(handlers.ListIndexes, mw...))
+	bucketMux.HandleFunc("PUT /api/buckets/{bucket}/indexes/{name}", middleware.ApplyMiddleware(handlers.CreateIndex, mw...))
+	bucketMux.HandleFunc("DELETE /api/buckets/{bucket}/indexes/{name}", middleware.ApplyMiddleware(handlers.DropIndex, mw...))
+
 	// Key-value endpoints
-	mux.HandleFunc("POST /api/{bucket}/kv", middleware.ApplyMiddleware(handlers.CreateKV, mw...))
+	mux.HandleFunc("POST /api/{bucket}/kv", middleware.ApplyMiddleware(handlers.CreateKV, writeMw...))
 	mux.HandleFunc("GET /api/{bucket}/kv/{key}", middleware.ApplyMiddleware(handlers.GetKV, mw...))
-	mux.HandleFunc("DELETE /api/{bucket}/kv/{key}", middleware.ApplyMiddleware(handlers.DeleteKV, mw...))
+	mux.HandleFunc("DELETE /api/{bucket}/kv/{key}", middleware.ApplyMiddleware(handlers.DeleteKV, writeMw...))
+
+	// Hash endpoints
+	mux.HandleFunc("PUT /api/{bucket}/hash/{key}", middleware.ApplyMiddleware(handlers.SetHashFields, writeMw...))
+	mux.HandleFunc("GET /api/{bucket}/hash/{key}", middleware.ApplyMiddleware(handlers.GetHash, mw...))
+	mux.HandleFunc("GET /api/{bucket}/hash/{key}/{field}", middleware.ApplyMiddleware(handlers.GetHashField, mw...))
+	mux.HandleFunc("DELETE /api/{bucket}/hash/{key}/{field}", middleware.ApplyMiddleware(handlers.DeleteHashField, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/hash/{key}/{field}/incr", middleware.ApplyMiddleware(handlers.IncrHashField, writeMw...))
+
+	// List endpoints
+	mux.HandleFunc("GET /api/{bucket}/list/{key}", middleware.ApplyMiddleware(handlers.GetList, mw...))
+	mux.HandleFunc("GET /api/{bucket}/list/{key}/length", middleware.ApplyMiddleware(handlers.GetListLength, mw...))
+	mux.HandleFunc("POST /api/{bucket}/list/{key}/push", middleware.ApplyMiddleware(handlers.PushList, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/list/{key}/pop", middleware.ApplyMiddleware(handlers.PopList, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/list/{key}/trim", middleware.ApplyMiddleware(handlers.TrimList, writeMw...))
+
+	// Set endpoints
+	mux.HandleFunc("GET /api/{bucket}/set/{key}", middleware.ApplyMiddleware(handlers.GetSet, mw...))
+	mux.HandleFunc("GET /api/{bucket}/set/{key}/{member}", middleware.ApplyMiddleware(handlers.IsSetMember, mw...))
+	mux.HandleFunc("POST /api/{bucket}/set/{key}/add", middleware.ApplyMiddleware(handlers.AddSetMembers, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/set/{key}/remove", middleware.ApplyMiddleware(handlers.RemoveSetMembers, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/sets/{op}", middleware.ApplyMiddleware(handlers.CombineSets, mw...))
+
+	// Sorted set endpoints
+	mux.HandleFunc("GET /api/{bucket}/zset/{key}", middleware.ApplyMiddleware(handlers.GetSortedSet, mw...))
+	mux.HandleFunc("GET /api/{bucket}/zset/{key}/scores", middleware.ApplyMiddleware(handlers.GetSortedSetByScore, mw...))
+	mux.HandleFunc("GET /api/{bucket}/zset/{key}/rank/{member}", middleware.ApplyMiddleware(handlers.GetSortedSetRank, mw...))
+	mux.HandleFunc("POST /api/{bucket}/zset/{key}/add", middleware.ApplyMiddleware(handlers.AddSortedSetMembers, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/zset/{key}/incr", middleware.ApplyMiddleware(handlers.IncrSortedSetMember, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/zset/{key}/remove", middleware.ApplyMiddleware(handlers.RemoveSortedSetMembers, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/zset/{key}/remove-ranks", middleware.ApplyMiddleware(handlers.RemoveSortedSetRanks, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/zset/{key}/remove-scores", middleware.ApplyMiddleware(handlers.RemoveSortedSetScores, writeMw...))
+
+	// JSON endpoints
+	mux.HandleFunc("GET /api/{bucket}/json/{key}", middleware.ApplyMiddleware(handlers.GetJSON, mw...))
+	mux.HandleFunc("PUT /api/{bucket}/json/{key}", middleware.ApplyMiddleware(handlers.SetJSON, writeMw...))
+	mux.HandleFunc("DELETE /api/{bucket}/json/{key}", middleware.ApplyMiddleware(handlers.DeleteJSON, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/json/{key}/append", middleware.ApplyMiddleware(handlers.AppendJSON, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/json/{key}/incr", middleware.ApplyMiddleware(handlers.IncrJSON, writeMw...))
+
+	// Stream endpoints
+	mux.HandleFunc("GET /api/{bucket}/stream/{key}", middleware.ApplyMiddleware(handlers.GetStream, mw...))
+	mux.HandleFunc("GET /api/{bucket}/stream/{key}/info", middleware.ApplyMiddleware(handlers.GetStreamInfo, mw...))
+	mux.HandleFunc("POST /api/{bucket}/stream/{key}/add", middleware.ApplyMiddleware(handlers.AddStreamEntry, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/stream/{key}/read", middleware.ApplyMiddleware(handlers.ReadStream, mw...))
+	mux.HandleFunc("POST /api/{bucket}/stream/{key}/trim", middleware.ApplyMiddleware(handlers.TrimStream, writeMw...))
+	mux.HandleFunc("PUT /api/{bucket}/stream/{key}/groups/{group}", middleware.ApplyMiddleware(handlers.CreateStreamGroup, writeMw...))
+	mux.HandleFunc("DELETE /api/{bucket}/stream/{key}/groups/{group}", middleware.ApplyMiddleware(handlers.DeleteStreamGroup, writeMw...))
+	mux.HandleFunc("GET /api/{bucket}/stream/{key}/groups/{group}/pending", middleware.ApplyMiddleware(handlers.GetStreamPending, mw...))
+	mux.HandleFunc("POST /api/{bucket}/stream/{key}/groups/{group}/read", middleware.ApplyMiddleware(handlers.ReadStreamGroup, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/stream/{key}/groups/{group}/ack", middleware.ApplyMiddleware(handlers.AckStreamEntries, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/stream/{key}/groups/{group}/claim", middleware.ApplyMiddleware(handlers.ClaimStreamEntries, writeMw...))
+
+	// HyperLogLog endpoints
+	mux.HandleFunc("GET /api/{bucket}/hll/{key}", middleware.ApplyMiddleware(handlers.CountHyperLogLog, mw...))
+	mux.HandleFunc("POST /api/{bucket}/hll/{key}/add", middleware.ApplyMiddleware(handlers.AddHyperLogLogMembers, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/hll/{key}/merge", middleware.ApplyMiddleware(handlers.MergeHyperLogLogs, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/hlls/count", middleware.ApplyMiddleware(handlers.CountHyperLogLogs, mw...))
+
+	// Bloom filter endpoints
+	mux.HandleFunc("PUT /api/{bucket}/bloom/{key}", middleware.ApplyMiddleware(handlers.ReserveBloomFilter, writeMw...))
+	mux.HandleFunc("GET /api/{bucket}/bloom/{key}", middleware.ApplyMiddleware(handlers.GetBloomFilter, mw...))
+	mux.HandleFunc("POST /api/{bucket}/bloom/{key}/add", middleware.ApplyMiddleware(handlers.AddBloomItems, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/bloom/{key}/check", middleware.ApplyMiddleware(handlers.CheckBloomItems, mw...))
+
+	// Lock endpoints
+	mux.HandleFunc("GET /api/{bucket}/locks/{name}", middleware.ApplyMiddleware(handlers.GetLock, mw...))
+	mux.HandleFunc("POST /api/{bucket}/locks/{name}/acquire", middleware.ApplyMiddleware(handlers.AcquireLock, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/locks/{name}/renew", middleware.ApplyMiddleware(handlers.RenewLock, writeMw...))
+	mux.HandleFunc("POST /api/{bucket}/locks/{name}/release", middleware.ApplyMiddleware(handlers.ReleaseLock, writeMw...))
+
+	// Rate limiter endpoints
+	mux.HandleFunc("POST /api/{bucket}/ratelimit/{key}", middleware.ApplyMiddleware(handlers.TakeRateLimit, writeMw...))
+
+	// Secondary index queries
+	mux.HandleFunc("GET /api/{bucket}/index/{name}", middleware.ApplyMiddleware(handlers.QueryIndex, mw...))
+
+	// Probes and monitoring
+	mux.HandleFunc("GET /healthz", middleware.ApplyMiddleware(handlers.Healthz, probeMw...))
+	mux.HandleFunc("GET /readyz", middleware.ApplyMiddleware(handlers.Readyz, probeMw...))
+	mux.HandleFunc("GET /metrics", middleware.ApplyMiddleware(handlers.Metrics, probeMw...))
+
+	// Admin endpoints
+	mux.HandleFunc("POST /api/admin/config/reload", middleware.ApplyMiddleware(handlers.ReloadConfig, adminMw...))
+	mux.HandleFunc("GET /api/admin/health", middleware.ApplyMiddleware(handlers.AdminHealth, adminMw...))
+	mux.HandleFunc("GET /api/admin/keys", middleware.ApplyMiddleware(handlers.ListSigningKeys, adminMw...))
+	mux.HandleFunc("POST /api/admin/keys/rotate", middleware.ApplyMiddleware(handlers.RotateSigningKey, adminMw...))
+	mux.HandleFunc("DELETE /api/admin/keys/{kid}", middleware.ApplyMiddleware(handlers.RemoveSigningKey, adminMw...))
+	mux.HandleFunc("POST /api/admin/buckets/{bucket}/flush", middleware.ApplyMiddleware(handlers.AdminFlushBucket, adminMw...))
+	mux.HandleFunc("POST /api/admin/buckets/{bucket}/tokens", middleware.ApplyMiddleware(handlers.IssueToken, adminMw...))
+	mux.HandleFunc("GET /api/admin/buckets/{bucket}/revocations", middleware.ApplyMiddleware(handlers.ListRevocations, adminMw...))
+	mux.HandleFunc("POST /api/admin/buckets/{bucket}/revocations", middleware.ApplyMiddleware(handlers.RevokeTokens, adminMw...))
 
 	return &Router{
-		mux: mux,
+		addr:      addr,
+		mux:       mux,
+		bucketMux: bucketMux,
+	}
+}
+
+// ServeHTTP dispatches /api/buckets/{bucket}/... to the bucket mux
+// Those patterns overlap with /api/{bucket}/kv/{key} and cannot share a mux,
+// which is why "buckets" is a reserved bucket name
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if rest, ok := strings.CutPrefix(req.URL.Path, bucketsPrefix); ok && strings.Contains(strings.TrimSuffix(rest, "/"), "/") {
+		r.bucketMux.ServeHTTP(w, req)
+		return
 	}
+	r.mux.ServeHTTP(w, req)
 }
 
-func (r *Router) Run(addr string) error {
+// Listen binds the address, requests are accepted once Serve is called
+func (r *Router) Listen() error {
+	ln, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	r.ln = ln
 	r.server = &http.Server{
-		Addr:         addr,
-		Handler:      r.mux,
+		Addr:         r.addr,
+		Handler:      r,
 		ReadTimeout:  time.Duration(readTimeout) * time.Second,
 		WriteTimeout: time.Duration(writeTimeout) * time.Second,
 		IdleTimeout:  time.Duration(idleTimeout) * time.Second,
 	}
-	return r.server.ListenAndServe()
+	return nil
 }
+
+// Serve handles requests on the listener bound by Listen
+// It returns nil once Shutdown is called
+func (r *Router) Serve() error {
+	if err := r.server.Serve(r.ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests
+// Connections still open when ctx is done are closed
+func (r *Router) Shutdown(ctx context.Context) error {
+	if r.server == nil {
+		return nil
+	}
+	if err := r.server.Shutdown(ctx); err != nil {
+		_ = r.server.Close()
+		return err
+	}
+	return nil
+}
+
+func (r *Router) Addr() string { return r.addr }
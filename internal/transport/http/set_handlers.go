@@ -0,0 +1,161 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// setOps lists the combinations served by CombineSets
+var setOps = []string{"union", "intersection", "difference"}
+
+// Set Handlers
+func (h *Handlers) AddSetMembers(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req SetMembersRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	added, err := h.setService.SAdd(r.Context(), bucketName, key, req.Members, req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "add set members", err)
+		return
+	}
+
+	util.WriteOK(w, MemberCountResponse{Key: key, Count: added})
+}
+
+func (h *Handlers) RemoveSetMembers(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req SetMembersRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	removed, err := h.setService.SRem(r.Context(), bucketName, key, req.Members)
+	if err != nil {
+		writeDataError(w, crrid, "remove set members", err)
+		return
+	}
+
+	util.WriteOK(w, MemberCountResponse{Key: key, Count: removed})
+}
+
+func (h *Handlers) GetSet(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	members, err := h.setService.SMembers(r.Context(), bucketName, key)
+	if err != nil {
+		writeDataError(w, crrid, "get set", err)
+		return
+	}
+
+	util.WriteOK(w, SetResponse{Key: key, Members: members})
+}
+
+// IsSetMember answers 200 whether or not member is in the set, a missing set holds nothing
+func (h *Handlers) IsSetMember(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	isMember, err := h.setService.SIsMember(r.Context(), bucketName, key, member)
+	if err != nil {
+		writeDataError(w, crrid, "check set member", err)
+		return
+	}
+
+	util.WriteOK(w, SetMemberResponse{Key: key, Member: member, IsMember: isMember})
+}
+
+// CombineSets answers the union, intersection or difference of the sets held by
+// the requested keys, missing keys count as empty sets
+func (h *Handlers) CombineSets(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	op := r.PathValue("op")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	if !slices.Contains(setOps, op) {
+		util.WriteNotFound(w, "Unknown set operation")
+		return
+	}
+
+	var req SetOpRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	combine := h.setService.SUnion
+	switch op {
+	case "intersection":
+		combine = h.setService.SInter
+	case "difference":
+		combine = h.setService.SDiff
+	}
+
+	members, err := combine(r.Context(), bucketName, req.Keys)
+	if err != nil {
+		writeDataError(w, crrid, "compute set "+op, err)
+		return
+	}
+
+	util.WriteOK(w, SetOpResponse{Op: op, Keys: req.Keys, Members: members})
+}
@@ -0,0 +1,252 @@
+package http
+
+import (
+	"key-value-store/internal/util"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// Sorted Set Handlers
+func (h *Handlers) AddSortedSetMembers(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req ZAddRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	added, err := h.zsetService.ZAdd(r.Context(), bucketName, key, req.scoredMembers(), req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "add sorted set members", err)
+		return
+	}
+
+	util.WriteOK(w, MemberCountResponse{Key: key, Count: added})
+}
+
+func (h *Handlers) IncrSortedSetMember(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req ZIncrRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	score, err := h.zsetService.ZIncrBy(r.Context(), bucketName, key, req.Member, req.By)
+	if err != nil {
+		writeDataError(w, crrid, "increment sorted set member", err)
+		return
+	}
+
+	util.WriteOK(w, ZScoreResponse{Key: key, Member: req.Member, Score: score})
+}
+
+// GetSortedSet returns the members ranked between the start and stop query
+// indexes by ascending score, both included and negative ones counting from
+// the end, the whole sorted set by default
+func (h *Handlers) GetSortedSet(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	start, err := queryInt(r, "start", 0)
+	if err != nil {
+		util.WriteBadRequest(w, "start must be an integer")
+		return
+	}
+	stop, err := queryInt(r, "stop", -1)
+	if err != nil {
+		util.WriteBadRequest(w, "stop must be an integer")
+		return
+	}
+
+	members, err := h.zsetService.ZRange(r.Context(), bucketName, key, start, stop)
+	if err != nil {
+		writeDataError(w, crrid, "read sorted set", err)
+		return
+	}
+
+	util.WriteOK(w, zRangeResponse(key, members))
+}
+
+// GetSortedSetByScore returns the members scored between the min and max query
+// values included, which accept -inf and inf and default to them
+func (h *Handlers) GetSortedSetByScore(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	lo, err := queryFloat(r, "min", math.Inf(-1))
+	if err != nil {
+		util.WriteBadRequest(w, "min must be a number")
+		return
+	}
+	hi, err := queryFloat(r, "max", math.Inf(1))
+	if err != nil {
+		util.WriteBadRequest(w, "max must be a number")
+		return
+	}
+
+	members, err := h.zsetService.ZRangeByScore(r.Context(), bucketName, key, lo, hi)
+	if err != nil {
+		writeDataError(w, crrid, "read sorted set by score", err)
+		return
+	}
+
+	util.WriteOK(w, zRangeResponse(key, members))
+}
+
+func (h *Handlers) GetSortedSetRank(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	rank, score, err := h.zsetService.ZRank(r.Context(), bucketName, key, member)
+	if err != nil {
+		writeDataError(w, crrid, "rank sorted set member", err)
+		return
+	}
+
+	util.WriteOK(w, ZRankResponse{Key: key, Member: member, Rank: rank, Score: score})
+}
+
+func (h *Handlers) RemoveSortedSetMembers(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req ZRemoveRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	removed, err := h.zsetService.ZRem(r.Context(), bucketName, key, req.Members)
+	if err != nil {
+		writeDataError(w, crrid, "remove sorted set members", err)
+		return
+	}
+
+	util.WriteOK(w, MemberCountResponse{Key: key, Count: removed})
+}
+
+// RemoveSortedSetRanks removes the members ranked from start to stop included
+func (h *Handlers) RemoveSortedSetRanks(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req TrimRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	removed, err := h.zsetService.ZRemRangeByRank(r.Context(), bucketName, key, req.Start, req.Stop)
+	if err != nil {
+		writeDataError(w, crrid, "remove sorted set ranks", err)
+		return
+	}
+
+	util.WriteOK(w, MemberCountResponse{Key: key, Count: removed})
+}
+
+// RemoveSortedSetScores removes the members scored from min to max included
+func (h *Handlers) RemoveSortedSetScores(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req ZRemoveByScoreRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	removed, err := h.zsetService.ZRemRangeByScore(r.Context(), bucketName, key, req.Min, req.Max)
+	if err != nil {
+		writeDataError(w, crrid, "remove sorted set scores", err)
+		return
+	}
+
+	util.WriteOK(w, MemberCountResponse{Key: key, Count: removed})
+}
+
+func queryFloat(r *http.Request, name string, def float64) (float64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
@@ -0,0 +1,362 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/service"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Stream Handlers
+func (h *Handlers) AddStreamEntry(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamAddRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	id, err := h.streamService.Add(r.Context(), bucketName, key, req.fieldValues(), req.trim(), req.TTL)
+	if err != nil {
+		writeDataError(w, crrid, "add stream entry", err)
+		return
+	}
+
+	util.WriteOK(w, StreamAddResponse{Key: key, ID: id.String()})
+}
+
+// GetStream returns the entries between the start and end query IDs, both
+// included and "-" and "+" standing for the first and last entries
+func (h *Handlers) GetStream(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	start, err := service.ParseStreamStart(query.Get("start"))
+	if err != nil {
+		util.WriteBadRequest(w, "Invalid start")
+		return
+	}
+	end, err := service.ParseStreamEnd(query.Get("end"))
+	if err != nil {
+		util.WriteBadRequest(w, "Invalid end")
+		return
+	}
+	count, err := queryInt(r, "count", 0)
+	if err != nil || count < 0 || count > service.MaxStreamCount {
+		util.WriteBadRequest(w, "Invalid count")
+		return
+	}
+
+	entries, err := h.streamService.Range(r.Context(), bucketName, key, start, end, int(count))
+	if err != nil {
+		writeDataError(w, crrid, "read stream", err)
+		return
+	}
+
+	util.WriteOK(w, streamEntriesResponse(key, entries))
+}
+
+func (h *Handlers) GetStreamInfo(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	info, err := h.streamService.Info(r.Context(), bucketName, key)
+	if err != nil {
+		writeDataError(w, crrid, "describe stream", err)
+		return
+	}
+
+	util.WriteOK(w, streamInfoResponse(key, info))
+}
+
+// ReadStream returns the entries after an ID, with timeout_ms it is a
+// long-poll that answers 204 when nothing was added in time
+func (h *Handlers) ReadStream(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamReadRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	timeout := extendWriteDeadline(w, req.TimeoutMs)
+	entries, err := h.streamService.Read(r.Context(), bucketName, key, req.after, req.Count, timeout)
+	if err != nil {
+		writeStreamReadError(w, crrid, "read stream", err)
+		return
+	}
+
+	util.WriteOK(w, streamEntriesResponse(key, entries))
+}
+
+func (h *Handlers) TrimStream(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key := r.PathValue("key")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamTrimRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	removed, err := h.streamService.Trim(r.Context(), bucketName, key, req.trim())
+	if err != nil {
+		writeDataError(w, crrid, "trim stream", err)
+		return
+	}
+
+	util.WriteOK(w, StreamCountResponse{Key: key, Count: removed})
+}
+
+// CreateStreamGroup adds a consumer group, creating the stream when missing
+func (h *Handlers) CreateStreamGroup(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, group := r.PathValue("key"), r.PathValue("group")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamGroupRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	from, err := h.streamService.CreateGroup(r.Context(), bucketName, key, group, req.start)
+	if err != nil {
+		writeDataError(w, crrid, "create consumer group", err)
+		return
+	}
+
+	util.WriteCreated(w, StreamGroupResponse{Name: group, LastDelivered: from.String()})
+}
+
+func (h *Handlers) DeleteStreamGroup(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, group := r.PathValue("key"), r.PathValue("group")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	if err := h.streamService.DestroyGroup(r.Context(), bucketName, key, group); err != nil {
+		writeDataError(w, crrid, "delete consumer group", err)
+		return
+	}
+
+	util.WriteNoContent(w, "Consumer group deleted")
+}
+
+// ReadStreamGroup delivers entries of a group to a consumer, with timeout_ms
+// it is a long-poll that answers 204 when nothing was added in time
+func (h *Handlers) ReadStreamGroup(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, group := r.PathValue("key"), r.PathValue("group")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamReadGroupRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	timeout := extendWriteDeadline(w, req.TimeoutMs)
+	entries, err := h.streamService.ReadGroup(r.Context(), bucketName, key, group, req.Consumer, req.Count, timeout)
+	if err != nil {
+		writeStreamReadError(w, crrid, "read consumer group", err)
+		return
+	}
+
+	util.WriteOK(w, streamEntriesResponse(key, entries))
+}
+
+func (h *Handlers) AckStreamEntries(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, group := r.PathValue("key"), r.PathValue("group")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamAckRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	acked, err := h.streamService.Ack(r.Context(), bucketName, key, group, req.ids)
+	if err != nil {
+		writeDataError(w, crrid, "acknowledge stream entries", err)
+		return
+	}
+
+	util.WriteOK(w, StreamCountResponse{Key: key, Count: acked})
+}
+
+// GetStreamPending lists the entries delivered by a group and not acknowledged,
+// from the start query ID on, only those of the consumer query when set
+func (h *Handlers) GetStreamPending(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, group := r.PathValue("key"), r.PathValue("group")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	start, err := service.ParseStreamStart(query.Get("start"))
+	if err != nil {
+		util.WriteBadRequest(w, "Invalid start")
+		return
+	}
+	count, err := queryInt(r, "count", 0)
+	if err != nil || count < 0 || count > service.MaxStreamCount {
+		util.WriteBadRequest(w, "Invalid count")
+		return
+	}
+
+	q := service.PendingQuery{Consumer: query.Get("consumer"), Start: start, Count: int(count)}
+	pending, err := h.streamService.Pending(r.Context(), bucketName, key, group, q)
+	if err != nil {
+		writeDataError(w, crrid, "list pending entries", err)
+		return
+	}
+
+	util.WriteOK(w, streamPendingResponse(key, group, pending))
+}
+
+// ClaimStreamEntries hands the entries left pending by a stalled consumer over
+// to another one
+func (h *Handlers) ClaimStreamEntries(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+	key, group := r.PathValue("key"), r.PathValue("group")
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	var req StreamClaimRequest
+	if err := util.ReadJSONBody(r, &req, w); err != nil {
+		util.WriteBadRequest(w, "Invalid JSON")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		util.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	minIdle := time.Duration(req.MinIdleMs) * time.Millisecond
+	entries, err := h.streamService.Claim(r.Context(), bucketName, key, group, req.Consumer, minIdle, req.Count)
+	if err != nil {
+		writeDataError(w, crrid, "claim stream entries", err)
+		return
+	}
+
+	util.WriteOK(w, streamEntriesResponse(key, entries))
+}
+
+func writeStreamReadError(w http.ResponseWriter, crrid, op string, err error) {
+	switch {
+	case errors.Is(err, errs.ErrWaitTimeout), errors.Is(err, context.Canceled):
+		util.WriteNoContent(w, "Nothing to read")
+	default:
+		writeDataError(w, crrid, op, err)
+	}
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"key-value-store/internal/metrics"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+)
+
+// Healthz reports that the process is alive, it does not check dependencies
+func (h *Handlers) Healthz(w http.ResponseWriter, r *http.Request) {
+	util.WriteOK(w, ProbeResponse{Status: "ok"})
+}
+
+// Readyz reports whether the server accepts traffic
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	ready, reasons := h.healthService.Ready(r.Context())
+	if !ready {
+		util.JSON(w, http.StatusServiceUnavailable, ProbeResponse{Status: "not ready", Reasons: reasons})
+		return
+	}
+	util.WriteOK(w, ProbeResponse{Status: "ready"})
+}
+
+// AdminHealth returns the detailed health report
+func (h *Handlers) AdminHealth(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Report(r.Context())
+	util.WriteOK(w, healthResponse(report))
+}
+
+// Metrics serves every registered metric in the Prometheus text format
+func (h *Handlers) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := metrics.Default.WriteTo(w); err != nil {
+		crrid := util.GetCorrelationID(r.Context())
+		slog.Debug("Handler: Failed to write metrics", "crr-id", crrid, "error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"errors"
+	"key-value-store/internal/archive"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/service"
+	"key-value-store/internal/util"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxImportSize bounds an import body, the JSON body limit does not apply
+const maxImportSize = 1 << 30
+
+// Transfer Handlers
+func (h *Handlers) ExportBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	enc, err := archive.NewEncoder(format, w)
+	if err != nil {
+		util.WriteBadRequest(w, "Unknown export format")
+		return
+	}
+
+	// Large buckets take longer than the server write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	// The status goes out with the first archive bytes, the encoders buffer them
+	w.Header().Set("Content-Type", archive.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+bucketName+`.bukt"`)
+
+	n, err := h.transferService.Export(r.Context(), bucketName, enc)
+	switch {
+	case err == nil:
+	case errors.Is(err, errs.ErrNotExportable) && n == 0:
+		// Refused before the archive was started
+		w.Header().Del("Content-Disposition")
+		util.WriteConflict(w, err.Error())
+	default:
+		// Headers are already sent, the client sees a truncated archive without end marker
+		slog.Error("Handler: Failed to export bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+	}
+}
+
+func (h *Handlers) ImportBucket(w http.ResponseWriter, r *http.Request) {
+	crrid := util.GetCorrelationID(r.Context())
+
+	bucketName, ok := util.GetBucketName(r.Context())
+	if !ok {
+		slog.Error("Handler: Bucket name not found in context", "crr-id", crrid)
+		util.WriteUnauthorized(w, "Unauthorized")
+		return
+	}
+
+	mode, ok := service.ParseImportMode(r.URL.Query().Get("mode"))
+	if !ok {
+		util.WriteBadRequest(w, "mode must be merge, overwrite or skip-existing")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	dec, err := archive.NewDecoder(r.Body)
+	if err != nil {
+		slog.Debug("Handler: Invalid import archive", "crr-id", crrid, "error", err)
+		util.WriteBadRequest(w, "Invalid archive")
+		return
+	}
+
+	result, err := h.transferService.Import(r.Context(), bucketName, dec, mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrBucketNotFound):
+			util.WriteNotFound(w, "Bucket not found")
+		case errors.Is(err, archive.ErrInvalidArchive):
+			util.WriteBadRequest(w, "Invalid archive")
+		default:
+			slog.Error("Handler: Failed to import bucket", "crr-id", crrid, "bucket", bucketName, "error", err)
+			util.WriteBadRequest(w, "Import aborted: "+err.Error())
+		}
+		return
+	}
+
+	util.WriteOK(w, ImportResponse{
+		Bucket:   bucketName,
+		Mode:     string(mode),
+		Imported: result.Imported,
+		Skipped:  result.Skipped,
+		Failed:   result.Failed,
+		Errors:   result.Errors,
+	})
+}
@@ -1,14 +1,25 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"key-value-store/internal/auth"
 	"key-value-store/internal/bucket"
+	"key-value-store/internal/config"
 	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/service"
 	"key-value-store/internal/util"
+	"math"
+	"sort"
 	"strings"
 	"time"
 )
 
+// maxPopCount bounds the values removed by a single pop
+const maxPopCount = 1000
+
 // Request types
 type CreateKVRequest struct {
 	Key        string `json:"key"`
@@ -17,16 +28,252 @@ type CreateKVRequest struct {
 	SingleRead bool   `json:"single_read"`
 }
 
+// SetHashRequest sets fields of a hash, ttl only applies when the hash is created
+type SetHashRequest struct {
+	Fields map[string]string `json:"fields"`
+	TTL    int64             `json:"ttl"`
+}
+
+type IncrRequest struct {
+	By int64 `json:"by"`
+}
+
+// PushRequest adds values to a list, ttl only applies when the list is created
+type PushRequest struct {
+	Values []string `json:"values"`
+	End    string   `json:"end,omitempty"` // right by default
+	TTL    int64    `json:"ttl"`
+
+	end service.ListEnd
+}
+
+// PopRequest removes values from a list, waiting up to timeout_ms for a push
+type PopRequest struct {
+	End       string `json:"end,omitempty"` // left by default
+	Count     int    `json:"count,omitempty"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+
+	end service.ListEnd
+}
+
+type TrimRequest struct {
+	Start int64 `json:"start"`
+	Stop  int64 `json:"stop"`
+}
+
+// SetMembersRequest adds or removes members of a set, ttl only applies when the set is created
+type SetMembersRequest struct {
+	Members []string `json:"members"`
+	TTL     int64    `json:"ttl"`
+}
+
+// SetOpRequest combines the sets held by keys
+type SetOpRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ZAddRequest sets member scores, ttl only applies when the sorted set is created
+type ZAddRequest struct {
+	Members []ScoredMember `json:"members"`
+	TTL     int64          `json:"ttl"`
+}
+
+type ZIncrRequest struct {
+	Member string  `json:"member"`
+	By     float64 `json:"by"`
+}
+
+type ZRemoveRequest struct {
+	Members []string `json:"members"`
+}
+
+// ZRemoveByScoreRequest removes the members scored from min to max included
+type ZRemoveByScoreRequest struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// JSONSetRequest stores value at path, ttl only applies when the document is created
+type JSONSetRequest struct {
+	Path  string          `json:"path,omitempty"` // the whole document by default
+	Value json.RawMessage `json:"value"`
+	TTL   int64           `json:"ttl"`
+}
+
+type JSONAppendRequest struct {
+	Path   string            `json:"path"`
+	Values []json.RawMessage `json:"values"`
+}
+
+type JSONIncrRequest struct {
+	Path string      `json:"path"`
+	By   json.Number `json:"by"`
+}
+
+// StreamAddRequest appends an entry, max_len and max_age_ms trim the stream
+// afterwards and ttl only applies when the stream is created
+type StreamAddRequest struct {
+	Fields   map[string]string `json:"fields"`
+	MaxLen   int               `json:"max_len,omitempty"`
+	MaxAgeMs int64             `json:"max_age_ms,omitempty"`
+	TTL      int64             `json:"ttl"`
+}
+
+// StreamTrimRequest removes the entries beyond max_len and those older than max_age_ms
+type StreamTrimRequest struct {
+	MaxLen   int   `json:"max_len,omitempty"`
+	MaxAgeMs int64 `json:"max_age_ms,omitempty"`
+}
+
+// StreamReadRequest reads the entries after an ID, the new ones by default,
+// waiting up to timeout_ms for one
+type StreamReadRequest struct {
+	After     string `json:"after,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+
+	after *engine.StreamID
+}
+
+// StreamGroupRequest creates a consumer group delivering the entries after
+// start, the new ones by default
+type StreamGroupRequest struct {
+	Start string `json:"start,omitempty"`
+
+	start *engine.StreamID
+}
+
+// StreamReadGroupRequest delivers entries of a group to consumer, waiting up
+// to timeout_ms for one
+type StreamReadGroupRequest struct {
+	Consumer  string `json:"consumer"`
+	Count     int    `json:"count,omitempty"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+}
+
+type StreamAckRequest struct {
+	IDs []string `json:"ids"`
+
+	ids []engine.StreamID
+}
+
+// StreamClaimRequest hands the entries pending for at least min_idle_ms over to consumer
+type StreamClaimRequest struct {
+	Consumer  string `json:"consumer"`
+	MinIdleMs int64  `json:"min_idle_ms"`
+	Count     int    `json:"count,omitempty"`
+}
+
+// HLLAddRequest adds members to a HyperLogLog, ttl only applies when it is created
+type HLLAddRequest struct {
+	Members []string `json:"members"`
+	TTL     int64    `json:"ttl"`
+}
+
+// HLLKeysRequest names the HyperLogLogs counted or merged together
+type HLLKeysRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// BloomReserveRequest sizes a filter for capacity items at error_rate false positives
+type BloomReserveRequest struct {
+	Capacity  int64   `json:"capacity"`
+	ErrorRate float64 `json:"error_rate"`
+	TTL       int64   `json:"ttl"`
+}
+
+// BloomItemsRequest adds or checks items, ttl only applies when an add creates the filter
+type BloomItemsRequest struct {
+	Items []string `json:"items"`
+	TTL   int64    `json:"ttl"`
+}
+
+// LockAcquireRequest takes a lock for owner with a lease of ttl seconds,
+// waiting up to timeout_ms for its release
+type LockAcquireRequest struct {
+	Owner     string `json:"owner"`
+	TTL       int64  `json:"ttl"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+}
+
+// LockRenewRequest restarts the lease of a lock for ttl seconds
+type LockRenewRequest struct {
+	Owner string `json:"owner"`
+	TTL   int64  `json:"ttl"`
+}
+
+type LockReleaseRequest struct {
+	Owner string `json:"owner"`
+}
+
+// RateLimitRequest takes cost tokens, 1 by default, from a bucket of capacity
+// tokens refilled by rate tokens every period_ms
+type RateLimitRequest struct {
+	Capacity int64  `json:"capacity"`
+	Rate     int64  `json:"rate"`
+	PeriodMs int64  `json:"period_ms"`
+	Cost     *int64 `json:"cost,omitempty"`
+
+	cost int64
+}
+
 type CreateBucketRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	ShardCount  int                    `json:"shard_count,omitempty"`
+	Settings    *BucketSettingsRequest `json:"settings,omitempty"`
+}
+
+// BucketSettingsRequest is used at creation and as the PATCH body, omitted fields are unchanged
+type BucketSettingsRequest struct {
+	DefaultTTL      *int64 `json:"default_ttl,omitempty"`
+	MaxTTL          *int64 `json:"max_ttl,omitempty"`
+	MaxKeyLength    *int   `json:"max_key_length,omitempty"`
+	MaxValueSize    *int64 `json:"max_value_size,omitempty"`
+	MaxKeys         *int64 `json:"max_keys,omitempty"`
+	AllowSingleRead *bool  `json:"allow_single_read,omitempty"`
+}
+
+type CloneBucketRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
-	ShardCount  int    `json:"shard_count,omitempty"`
+}
+
+type RenameBucketRequest struct {
+	Name          string `json:"name"`
+	ReissueTokens bool   `json:"reissue_tokens,omitempty"`
+}
+
+type ReshardRequest struct {
+	ShardCount int `json:"shard_count"`
 }
 
 type DeleteBucketRequest struct {
 	AuthToken string `json:"auth_token"`
 }
 
+type RotateKeyRequest struct {
+	GraceSeconds int64 `json:"grace_seconds,omitempty"`
+}
+
+type IssueTokenRequest struct {
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+type RevokeTokenRequest struct {
+	Token        string `json:"token,omitempty"`
+	TokenID      string `json:"token_id,omitempty"`
+	IssuedBefore string `json:"issued_before,omitempty"`
+	All          bool   `json:"all,omitempty"`
+
+	issuedBefore time.Time
+}
+
 // Response types
 type KVResponse struct {
 	Key       string `json:"key,omitempty"`
@@ -35,15 +282,325 @@ type KVResponse struct {
 	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
-type BucketResponse struct {
+type HashResponse struct {
+	Key    string            `json:"key"`
+	Fields map[string]string `json:"fields"`
+}
+
+type HashFieldResponse struct {
+	Key   string `json:"key"`
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type HashIncrResponse struct {
+	Key   string `json:"key"`
+	Field string `json:"field"`
+	Value int64  `json:"value"`
+}
+
+// HashCountResponse holds the number of added or removed fields
+type HashCountResponse struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type ListResponse struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+type ListLengthResponse struct {
+	Key    string `json:"key"`
+	Length int    `json:"length"`
+}
+
+type SetResponse struct {
+	Key     string   `json:"key"`
+	Members []string `json:"members"`
+}
+
+type SetMemberResponse struct {
+	Key      string `json:"key"`
+	Member   string `json:"member"`
+	IsMember bool   `json:"is_member"`
+}
+
+type SetOpResponse struct {
+	Op      string   `json:"op"`
+	Keys    []string `json:"keys"`
+	Members []string `json:"members"`
+}
+
+// MemberCountResponse holds the number of added or removed members
+type MemberCountResponse struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type ZRangeResponse struct {
+	Key     string         `json:"key"`
+	Members []ScoredMember `json:"members"`
+}
+
+type ZScoreResponse struct {
+	Key    string  `json:"key"`
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+type ZRankResponse struct {
+	Key    string  `json:"key"`
+	Member string  `json:"member"`
+	Rank   int     `json:"rank"`
+	Score  float64 `json:"score"`
+}
+
+type JSONValueResponse struct {
+	Key   string          `json:"key"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type JSONPathResponse struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+}
+
+type JSONDeleteResponse struct {
+	Key     string `json:"key"`
+	Path    string `json:"path"`
+	Deleted bool   `json:"deleted"`
+}
+
+type JSONLengthResponse struct {
+	Key    string `json:"key"`
+	Path   string `json:"path"`
+	Length int    `json:"length"`
+}
+
+// IndexRequest is the PUT body of a secondary index
+type IndexRequest struct {
+	Path string `json:"path"`
+}
+
+type IndexResponse struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type IndexListResponse struct {
+	Bucket  string          `json:"bucket"`
+	Indexes []IndexResponse `json:"indexes"`
+}
+
+type IndexHitResponse struct {
+	Key   string            `json:"key"`
+	Value engine.IndexValue `json:"value"`
+}
+
+// IndexQueryResponse is a page of matches, cursor is omitted on the last page
+type IndexQueryResponse struct {
+	Index  string             `json:"index"`
+	Hits   []IndexHitResponse `json:"hits"`
+	Cursor string             `json:"cursor,omitempty"`
+}
+
+type StreamEntryResponse struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+type StreamEntriesResponse struct {
+	Key     string                `json:"key"`
+	Entries []StreamEntryResponse `json:"entries"`
+}
+
+type StreamAddResponse struct {
+	Key string `json:"key"`
+	ID  string `json:"id"`
+}
+
+// StreamCountResponse holds the number of trimmed or acknowledged entries
+type StreamCountResponse struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type StreamGroupResponse struct {
+	Name          string `json:"name"`
+	LastDelivered string `json:"last_delivered"`
+	Pending       int    `json:"pending"`
+}
+
+type StreamInfoResponse struct {
+	Key    string                `json:"key"`
+	Length int                   `json:"length"`
+	LastID string                `json:"last_id"`
+	Groups []StreamGroupResponse `json:"groups"`
+}
+
+type PendingEntryResponse struct {
 	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CreatedAt   string `json:"created_at"`
-	ShardCount  int    `json:"shard_count"`
-	KeyCount    int64  `json:"key_count"`
-	MemoryUsage int64  `json:"memory_usage"`
-	AuthToken   string `json:"auth_token,omitempty"`
+	Consumer    string `json:"consumer"`
+	DeliveredAt string `json:"delivered_at"`
+	Deliveries  int    `json:"deliveries"`
+}
+
+type StreamPendingResponse struct {
+	Key     string                 `json:"key"`
+	Group   string                 `json:"group"`
+	Pending []PendingEntryResponse `json:"pending"`
+}
+
+type HLLAddResponse struct {
+	Key     string `json:"key"`
+	Changed bool   `json:"changed"`
+}
+
+// HLLCountResponse holds the estimate of a HyperLogLog, or of the union of several
+type HLLCountResponse struct {
+	Key   string   `json:"key,omitempty"`
+	Keys  []string `json:"keys,omitempty"`
+	Count int64    `json:"count"`
+}
+
+type BloomInfoResponse struct {
+	Key       string  `json:"key"`
+	Capacity  int64   `json:"capacity"`
+	ErrorRate float64 `json:"error_rate"`
+	Bits      uint64  `json:"bits"`
+	Hashes    int     `json:"hashes"`
+	Count     int64   `json:"count"`
+}
+
+// BloomItemsResponse holds a result per requested item, in order
+type BloomItemsResponse struct {
+	Key    string `json:"key"`
+	Added  []bool `json:"added,omitempty"`
+	Exists []bool `json:"exists,omitempty"`
+}
+
+type RateLimitResponse struct {
+	Key          string `json:"key"`
+	Allowed      bool   `json:"allowed"`
+	Remaining    int64  `json:"remaining"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
+	ResetAfterMs int64  `json:"reset_after_ms"`
+}
+
+type LockResponse struct {
+	Name       string `json:"name"`
+	Owner      string `json:"owner"`
+	Token      uint64 `json:"token"`
+	AcquiredAt string `json:"acquired_at"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+type BucketResponse struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	CreatedAt   string                 `json:"created_at"`
+	ShardCount  int                    `json:"shard_count"`
+	Settings    BucketSettingsResponse `json:"settings"`
+	Indexes     []IndexResponse        `json:"indexes"`
+	KeyCount    int64                  `json:"key_count"`
+	MemoryUsage int64                  `json:"memory_usage"`
+	AuthToken   string                 `json:"auth_token,omitempty"`
+}
+
+type BucketSettingsResponse struct {
+	DefaultTTL      int64 `json:"default_ttl"`
+	MaxTTL          int64 `json:"max_ttl"`
+	MaxKeyLength    int   `json:"max_key_length"`
+	MaxValueSize    int64 `json:"max_value_size"`
+	MaxKeys         int64 `json:"max_keys"`
+	AllowSingleRead bool  `json:"allow_single_read"`
+}
+
+type ReshardProgressResponse struct {
+	Bucket     string  `json:"bucket"`
+	From       int     `json:"from"`
+	To         int     `json:"to"`
+	Total      int64   `json:"total"`
+	Moved      int64   `json:"moved"`
+	Progress   float64 `json:"progress"`
+	Done       bool    `json:"done"`
+	StartedAt  string  `json:"started_at"`
+	FinishedAt string  `json:"finished_at,omitempty"`
+}
+
+type FlushResponse struct {
+	Bucket  string `json:"bucket"`
+	Removed int64  `json:"removed"`
+}
+
+type ImportResponse struct {
+	Bucket   string   `json:"bucket"`
+	Mode     string   `json:"mode"`
+	Imported int64    `json:"imported"`
+	Skipped  int64    `json:"skipped"`
+	Failed   int64    `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type ConfigChangeResponse struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type ReloadResponse struct {
+	Applied         []ConfigChangeResponse `json:"applied"`
+	RestartRequired []ConfigChangeResponse `json:"restart_required"`
+	Skipped         []ConfigChangeResponse `json:"skipped,omitempty"`
+	Warnings        []string               `json:"warnings,omitempty"`
+}
+
+type ProbeResponse struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type HealthResponse struct {
+	Status    string                   `json:"status"`
+	Ready     bool                     `json:"ready"`
+	Reasons   []string                 `json:"reasons,omitempty"`
+	StartedAt string                   `json:"started_at"`
+	Uptime    int64                    `json:"uptime_seconds"`
+	Listeners []ListenerHealthResponse `json:"listeners"`
+	Memory    MemoryHealthResponse     `json:"memory"`
+	Buckets   []BucketHealthResponse   `json:"buckets"`
+}
+
+type ListenerHealthResponse struct {
+	Name      string `json:"name"`
+	Addr      string `json:"addr,omitempty"`
+	Listening bool   `json:"listening"`
+	Since     string `json:"since,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type MemoryHealthResponse struct {
+	HeapAlloc  uint64  `json:"heap_alloc_bytes"`
+	HeapInuse  uint64  `json:"heap_inuse_bytes"`
+	Sys        uint64  `json:"sys_bytes"`
+	NumGC      uint32  `json:"num_gc"`
+	Limit      int64   `json:"limit_bytes,omitempty"`
+	LimitRatio float64 `json:"limit_ratio,omitempty"`
+	Pressure   string  `json:"pressure"`
+}
+
+type BucketHealthResponse struct {
+	Name           string `json:"name"`
+	Keys           int64  `json:"keys"`
+	MemoryUsage    int64  `json:"memory_usage"`
+	GCCollectors   int    `json:"gc_collectors"`
+	GCRunning      int    `json:"gc_running"`
+	WheelEntries   int    `json:"wheel_entries"`
+	NearQueueSize  int    `json:"near_queue_size"`
+	PendingDeletes int    `json:"pending_deletes"`
 }
 
 type BucketListResponse struct {
@@ -51,15 +608,145 @@ type BucketListResponse struct {
 	Count   int              `json:"count"`
 }
 
+type SigningKeyResponse struct {
+	ID        uint32 `json:"id"`
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+	RetireAt  string `json:"retire_at,omitempty"`
+}
+
+type SigningKeyListResponse struct {
+	Keys  []SigningKeyResponse `json:"keys"`
+	Count int                  `json:"count"`
+}
+
+type TokenResponse struct {
+	Bucket    string `json:"bucket"`
+	AuthToken string `json:"auth_token"`
+}
+
+type RevocationResponse struct {
+	Bucket       string   `json:"bucket"`
+	IssuedBefore string   `json:"issued_before,omitempty"`
+	TokenIDs     []string `json:"token_ids"`
+}
+
 // Validation methods
 func (r *CreateKVRequest) Validate() error {
 	r.Key = strings.TrimSpace(r.Key)
 	if r.Key == "" {
 		return errors.New("key is required")
 	}
-	if len(r.Key) > 255 {
-		return errors.New("key too long (max 255)")
+	if len(r.Value) == 0 {
+		return errors.New("value is required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *SetHashRequest) Validate() error {
+	if len(r.Fields) == 0 {
+		return errors.New("fields are required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *SetHashRequest) fieldValues() map[string][]byte {
+	out := make(map[string][]byte, len(r.Fields))
+	for f, v := range r.Fields {
+		out[f] = []byte(v)
+	}
+	return out
+}
+
+func (r *PushRequest) Validate() error {
+	if len(r.Values) == 0 {
+		return errors.New("values are required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return parseListEnd(r.End, service.ListRight, &r.end)
+}
+
+func (r *PopRequest) Validate() error {
+	if r.Count < 0 || r.Count > maxPopCount {
+		return fmt.Errorf("count must be between 0 and %d", maxPopCount)
+	}
+	if r.TimeoutMs < 0 || r.TimeoutMs > service.MaxWait.Milliseconds() {
+		return fmt.Errorf("timeout_ms must be between 0 and %d", service.MaxWait.Milliseconds())
+	}
+	return parseListEnd(r.End, service.ListLeft, &r.end)
+}
+
+func parseListEnd(s string, def service.ListEnd, end *service.ListEnd) error {
+	if s == "" {
+		*end = def
+		return nil
+	}
+	e, ok := service.ParseListEnd(s)
+	if !ok {
+		return errors.New("end must be left or right")
 	}
+	*end = e
+	return nil
+}
+
+func (r *SetMembersRequest) Validate() error {
+	if len(r.Members) == 0 {
+		return errors.New("members are required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *SetOpRequest) Validate() error {
+	if len(r.Keys) == 0 {
+		return errors.New("keys are required")
+	}
+	return nil
+}
+
+func (r *ZAddRequest) Validate() error {
+	if len(r.Members) == 0 {
+		return errors.New("members are required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *ZAddRequest) scoredMembers() []engine.ScoredMember {
+	out := make([]engine.ScoredMember, len(r.Members))
+	for i, m := range r.Members {
+		out[i] = engine.ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	return out
+}
+
+func (r *ZIncrRequest) Validate() error {
+	if r.Member == "" {
+		return errors.New("member is required")
+	}
+	return nil
+}
+
+func (r *ZRemoveRequest) Validate() error {
+	if len(r.Members) == 0 {
+		return errors.New("members are required")
+	}
+	return nil
+}
+
+func (r *JSONSetRequest) Validate() error {
 	if len(r.Value) == 0 {
 		return errors.New("value is required")
 	}
@@ -69,15 +756,232 @@ func (r *CreateKVRequest) Validate() error {
 	return nil
 }
 
+func (r *IndexRequest) Validate() error {
+	if r.Path == "" {
+		return errors.New("path is required")
+	}
+	return nil
+}
+
+func (r *JSONAppendRequest) Validate() error {
+	if len(r.Values) == 0 {
+		return errors.New("values are required")
+	}
+	return nil
+}
+
+func (r *JSONAppendRequest) rawValues() [][]byte {
+	out := make([][]byte, len(r.Values))
+	for i, v := range r.Values {
+		out[i] = v
+	}
+	return out
+}
+
+func (r *JSONIncrRequest) Validate() error {
+	if r.By == "" {
+		return errors.New("by is required")
+	}
+	return nil
+}
+
+func (r *StreamAddRequest) Validate() error {
+	if len(r.Fields) == 0 {
+		return errors.New("fields are required")
+	}
+	if r.MaxLen < 0 || r.MaxAgeMs < 0 {
+		return errors.New("max_len and max_age_ms must be non-negative")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *StreamAddRequest) fieldValues() map[string][]byte {
+	out := make(map[string][]byte, len(r.Fields))
+	for f, v := range r.Fields {
+		out[f] = []byte(v)
+	}
+	return out
+}
+
+func (r *StreamAddRequest) trim() service.StreamTrim {
+	return service.StreamTrim{MaxLen: r.MaxLen, MaxAge: time.Duration(r.MaxAgeMs) * time.Millisecond}
+}
+
+func (r *StreamTrimRequest) Validate() error {
+	if r.MaxLen < 0 || r.MaxAgeMs < 0 {
+		return errors.New("max_len and max_age_ms must be non-negative")
+	}
+	if r.MaxLen == 0 && r.MaxAgeMs == 0 {
+		return errors.New("max_len or max_age_ms is required")
+	}
+	return nil
+}
+
+func (r *StreamTrimRequest) trim() service.StreamTrim {
+	return service.StreamTrim{MaxLen: r.MaxLen, MaxAge: time.Duration(r.MaxAgeMs) * time.Millisecond}
+}
+
+func (r *StreamReadRequest) Validate() error {
+	if err := validateStreamRead(r.Count, r.TimeoutMs); err != nil {
+		return err
+	}
+	var err error
+	r.after, err = service.ParseStreamAfter(r.After)
+	return err
+}
+
+func (r *StreamGroupRequest) Validate() error {
+	var err error
+	r.start, err = service.ParseStreamAfter(r.Start)
+	return err
+}
+
+func (r *StreamReadGroupRequest) Validate() error {
+	if r.Consumer == "" {
+		return errors.New("consumer is required")
+	}
+	return validateStreamRead(r.Count, r.TimeoutMs)
+}
+
+func validateStreamRead(count int, timeoutMs int64) error {
+	if count < 0 || count > service.MaxStreamCount {
+		return fmt.Errorf("count must be between 0 and %d", service.MaxStreamCount)
+	}
+	if timeoutMs < 0 || timeoutMs > service.MaxWait.Milliseconds() {
+		return fmt.Errorf("timeout_ms must be between 0 and %d", service.MaxWait.Milliseconds())
+	}
+	return nil
+}
+
+func (r *StreamAckRequest) Validate() error {
+	if len(r.IDs) == 0 {
+		return errors.New("ids are required")
+	}
+	r.ids = make([]engine.StreamID, len(r.IDs))
+	for i, s := range r.IDs {
+		id, err := engine.ParseStreamID(s)
+		if err != nil {
+			return err
+		}
+		r.ids[i] = id
+	}
+	return nil
+}
+
+func (r *StreamClaimRequest) Validate() error {
+	if r.Consumer == "" {
+		return errors.New("consumer is required")
+	}
+	if r.MinIdleMs < 0 {
+		return errors.New("min_idle_ms must be non-negative")
+	}
+	if r.Count < 0 || r.Count > service.MaxStreamCount {
+		return fmt.Errorf("count must be between 0 and %d", service.MaxStreamCount)
+	}
+	return nil
+}
+
+func (r *HLLAddRequest) Validate() error {
+	if len(r.Members) == 0 {
+		return errors.New("members are required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *HLLKeysRequest) Validate() error {
+	if len(r.Keys) == 0 {
+		return errors.New("keys are required")
+	}
+	return nil
+}
+
+func (r *BloomReserveRequest) Validate() error {
+	if r.Capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	if !(r.ErrorRate > 0 && r.ErrorRate < 1) {
+		return errors.New("error_rate must be between 0 and 1, both excluded")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *BloomItemsRequest) Validate() error {
+	if len(r.Items) == 0 {
+		return errors.New("items are required")
+	}
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *LockAcquireRequest) Validate() error {
+	if err := validateLock(r.Owner, r.TTL); err != nil {
+		return err
+	}
+	if r.TimeoutMs < 0 || r.TimeoutMs > service.MaxWait.Milliseconds() {
+		return fmt.Errorf("timeout_ms must be between 0 and %d", service.MaxWait.Milliseconds())
+	}
+	return nil
+}
+
+func (r *LockRenewRequest) Validate() error {
+	return validateLock(r.Owner, r.TTL)
+}
+
+func (r *LockReleaseRequest) Validate() error {
+	if r.Owner == "" {
+		return errors.New("owner is required")
+	}
+	return nil
+}
+
+func validateLock(owner string, ttl int64) error {
+	if owner == "" {
+		return errors.New("owner is required")
+	}
+	if ttl <= 0 {
+		return errors.New("ttl must be positive")
+	}
+	return nil
+}
+
+func (r *RateLimitRequest) Validate() error {
+	if r.Capacity <= 0 || r.Rate <= 0 || r.PeriodMs <= 0 {
+		return errors.New("capacity, rate and period_ms must be positive")
+	}
+	if r.PeriodMs > math.MaxInt64/int64(time.Millisecond) {
+		return errors.New("period_ms out of range")
+	}
+	r.cost = 1
+	if r.Cost != nil {
+		r.cost = *r.Cost
+	}
+	if r.cost < 0 || r.cost > r.Capacity {
+		return errors.New("cost must be between 0 and capacity")
+	}
+	return nil
+}
+
+func (r *RateLimitRequest) limit() engine.RateLimit {
+	return engine.RateLimit{Capacity: r.Capacity, Rate: r.Rate, Period: time.Duration(r.PeriodMs) * time.Millisecond}
+}
+
 func (r *CreateBucketRequest) Validate() error {
 	r.Name = strings.TrimSpace(strings.ToLower(r.Name))
 	r.Description = strings.TrimSpace(r.Description)
 
-	if r.Name == "" {
-		return errors.New("bucket name is required")
-	}
-	if len(r.Name) > 63 {
-		return errors.New("bucket name too long (max 63)")
+	if err := validateBucketName(r.Name); err != nil {
+		return err
 	}
 	if len(r.Description) > 256 {
 		return errors.New("description too long (max 256)")
@@ -85,14 +989,93 @@ func (r *CreateBucketRequest) Validate() error {
 	if r.ShardCount < 0 {
 		return errors.New("shard count must be non-negative")
 	}
+	if r.Settings != nil {
+		if err := r.Settings.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *CloneBucketRequest) Validate() error {
+	r.Name = strings.TrimSpace(strings.ToLower(r.Name))
+	r.Description = strings.TrimSpace(r.Description)
+
+	if err := validateBucketName(r.Name); err != nil {
+		return err
+	}
+	if len(r.Description) > 256 {
+		return errors.New("description too long (max 256)")
+	}
+	return nil
+}
+
+func (r *RenameBucketRequest) Validate() error {
+	r.Name = strings.TrimSpace(strings.ToLower(r.Name))
+	return validateBucketName(r.Name)
+}
+
+func validateBucketName(name string) error {
+	if name == "" {
+		return errors.New("bucket name is required")
+	}
+	if len(name) > 63 {
+		return errors.New("bucket name too long (max 63)")
+	}
 
 	// Simple validation: lowercase, numbers, hyphens
-	for _, c := range r.Name {
+	for _, c := range name {
 		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
 			return errors.New("bucket name must contain only lowercase letters, numbers, and hyphens")
 		}
 	}
+	return nil
+}
 
+func (r *BucketSettingsRequest) Validate() error {
+	if r.DefaultTTL != nil && *r.DefaultTTL < 0 {
+		return errors.New("default_ttl must be non-negative")
+	}
+	if r.MaxTTL != nil && *r.MaxTTL < 0 {
+		return errors.New("max_ttl must be non-negative")
+	}
+	if r.MaxKeyLength != nil && *r.MaxKeyLength < 1 {
+		return errors.New("max_key_length must be positive")
+	}
+	if r.MaxValueSize != nil && *r.MaxValueSize < 0 {
+		return errors.New("max_value_size must be non-negative")
+	}
+	if r.MaxKeys != nil && *r.MaxKeys < 0 {
+		return errors.New("max_keys must be non-negative")
+	}
+	return nil
+}
+
+func (r *BucketSettingsRequest) Patch() bucket.SettingsPatch {
+	return bucket.SettingsPatch{
+		DefaultTTL:      r.DefaultTTL,
+		MaxTTL:          r.MaxTTL,
+		MaxKeyLength:    r.MaxKeyLength,
+		MaxValueSize:    r.MaxValueSize,
+		MaxKeys:         r.MaxKeys,
+		AllowSingleRead: r.AllowSingleRead,
+	}
+}
+
+// BucketSettings returns the defaults with the requested overrides applied
+func (r *CreateBucketRequest) BucketSettings() bucket.BucketSettings {
+	settings := bucket.DefaultBucketSettings()
+	if r.Settings != nil {
+		settings = settings.Apply(r.Settings.Patch())
+	}
+	return settings
+}
+
+func (r *ReshardRequest) Validate() error {
+	if r.ShardCount < 1 || r.ShardCount > 4096 {
+		return errors.New("shard count must be between 1 and 4096")
+	}
 	return nil
 }
 
@@ -103,6 +1086,46 @@ func (r *DeleteBucketRequest) Validate() error {
 	return nil
 }
 
+func (r *RotateKeyRequest) Validate() error {
+	if r.GraceSeconds < 0 {
+		return errors.New("grace_seconds must be non-negative")
+	}
+	return nil
+}
+
+func (r *IssueTokenRequest) Validate() error {
+	if r.TTL < 0 {
+		return errors.New("ttl must be non-negative")
+	}
+	return nil
+}
+
+func (r *RevokeTokenRequest) Validate() error {
+	set := 0
+	if r.Token != "" {
+		set++
+	}
+	if r.TokenID != "" {
+		set++
+	}
+	if r.IssuedBefore != "" {
+		t, err := time.Parse(time.RFC3339, r.IssuedBefore)
+		if err != nil {
+			return errors.New("issued_before must be an RFC3339 timestamp")
+		}
+		r.issuedBefore = t
+		set++
+	}
+	if r.All {
+		r.issuedBefore = time.Now()
+		set++
+	}
+	if set != 1 {
+		return errors.New("exactly one of token, token_id, issued_before or all is required")
+	}
+	return nil
+}
+
 // Response helpers
 func kvResponseFromEntry(entry engine.StorageEntry) KVResponse {
 	return KVResponse{
@@ -113,6 +1136,30 @@ func kvResponseFromEntry(entry engine.StorageEntry) KVResponse {
 	}
 }
 
+func hashResponse(key string, fields map[string][]byte) HashResponse {
+	out := make(map[string]string, len(fields))
+	for f, v := range fields {
+		out[f] = string(v)
+	}
+	return HashResponse{Key: key, Fields: out}
+}
+
+func listResponse(key string, values [][]byte) ListResponse {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return ListResponse{Key: key, Values: out}
+}
+
+func zRangeResponse(key string, members []engine.ScoredMember) ZRangeResponse {
+	out := make([]ScoredMember, len(members))
+	for i, m := range members {
+		out[i] = ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	return ZRangeResponse{Key: key, Members: out}
+}
+
 func bucketResponse(meta *bucket.BucketMetadata, token string) BucketResponse {
 	return BucketResponse{
 		ID:          meta.ID,
@@ -120,12 +1167,214 @@ func bucketResponse(meta *bucket.BucketMetadata, token string) BucketResponse {
 		Description: meta.Description,
 		CreatedAt:   meta.CreatedAt.Format(time.RFC3339),
 		ShardCount:  meta.ShardCount,
+		Settings: BucketSettingsResponse{
+			DefaultTTL:      meta.Settings.DefaultTTL,
+			MaxTTL:          meta.Settings.MaxTTL,
+			MaxKeyLength:    meta.Settings.MaxKeyLength,
+			MaxValueSize:    meta.Settings.MaxValueSize,
+			MaxKeys:         meta.Settings.MaxKeys,
+			AllowSingleRead: meta.Settings.AllowSingleRead,
+		},
+		Indexes:     indexResponses(meta.Indexes),
 		KeyCount:    meta.KeyCount,
 		MemoryUsage: meta.MemoryUsage,
 		AuthToken:   token,
 	}
 }
 
+func indexResponses(defs []engine.IndexDef) []IndexResponse {
+	out := make([]IndexResponse, len(defs))
+	for i, d := range defs {
+		out[i] = IndexResponse{Name: d.Name, Path: d.Path}
+	}
+	return out
+}
+
+func indexQueryResponse(name string, page *service.IndexPage) IndexQueryResponse {
+	hits := make([]IndexHitResponse, len(page.Hits))
+	for i, h := range page.Hits {
+		hits[i] = IndexHitResponse{Key: h.Key, Value: h.Value}
+	}
+	return IndexQueryResponse{Index: name, Hits: hits, Cursor: page.Cursor}
+}
+
+func streamEntriesResponse(key string, entries []engine.StreamEntry) StreamEntriesResponse {
+	out := make([]StreamEntryResponse, len(entries))
+	for i, e := range entries {
+		fields := make(map[string]string, len(e.Fields))
+		for f, v := range e.Fields {
+			fields[f] = string(v)
+		}
+		out[i] = StreamEntryResponse{ID: e.ID.String(), Fields: fields}
+	}
+	return StreamEntriesResponse{Key: key, Entries: out}
+}
+
+func streamInfoResponse(key string, info *service.StreamInfo) StreamInfoResponse {
+	groups := make([]StreamGroupResponse, len(info.Groups))
+	for i, g := range info.Groups {
+		groups[i] = StreamGroupResponse{Name: g.Name, LastDelivered: g.LastDelivered.String(), Pending: g.Pending}
+	}
+	return StreamInfoResponse{Key: key, Length: info.Length, LastID: info.LastID.String(), Groups: groups}
+}
+
+func streamPendingResponse(key, group string, pending []engine.PendingEntry) StreamPendingResponse {
+	out := make([]PendingEntryResponse, len(pending))
+	for i, p := range pending {
+		out[i] = PendingEntryResponse{
+			ID:          p.ID.String(),
+			Consumer:    p.Consumer,
+			DeliveredAt: p.DeliveredAt.Format(time.RFC3339Nano),
+			Deliveries:  p.Deliveries,
+		}
+	}
+	return StreamPendingResponse{Key: key, Group: group, Pending: out}
+}
+
+func bloomInfoResponse(key string, info engine.BloomInfo) BloomInfoResponse {
+	return BloomInfoResponse{
+		Key:       key,
+		Capacity:  info.Capacity,
+		ErrorRate: info.ErrorRate,
+		Bits:      info.Bits,
+		Hashes:    info.Hashes,
+		Count:     info.Count,
+	}
+}
+
+// rateLimitResponse rounds durations up, a client retrying after them is
+// not denied again
+func rateLimitResponse(key string, res engine.RateResult) RateLimitResponse {
+	return RateLimitResponse{
+		Key:          key,
+		Allowed:      res.Allowed,
+		Remaining:    res.Remaining,
+		RetryAfterMs: ceilMillis(res.RetryAfter),
+		ResetAfterMs: ceilMillis(res.ResetAfter),
+	}
+}
+
+func ceilMillis(d time.Duration) int64 {
+	ms := d.Milliseconds()
+	if d%time.Millisecond != 0 {
+		ms++
+	}
+	return ms
+}
+
+func lockResponse(name string, info *service.LockInfo) LockResponse {
+	return LockResponse{
+		Name:       name,
+		Owner:      info.Owner,
+		Token:      info.Token,
+		AcquiredAt: info.AcquiredAt.Format(time.RFC3339Nano),
+		ExpiresAt:  info.ExpiresAt.Format(time.RFC3339Nano),
+	}
+}
+
+func reshardProgressResponse(bucketName string, p engine.ReshardProgress) ReshardProgressResponse {
+	resp := ReshardProgressResponse{
+		Bucket:    bucketName,
+		From:      p.From,
+		To:        p.To,
+		Total:     p.Total,
+		Moved:     p.Moved,
+		Progress:  1,
+		Done:      p.Done,
+		StartedAt: p.StartedAt.Format(time.RFC3339),
+	}
+	if p.Total > 0 && !p.Done {
+		resp.Progress = min(float64(p.Moved)/float64(p.Total), 1)
+	}
+	if p.Done {
+		resp.FinishedAt = p.FinishedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func reloadResponse(r *service.ReloadResult) ReloadResponse {
+	changes := func(in []config.Change) []ConfigChangeResponse {
+		out := make([]ConfigChangeResponse, len(in))
+		for i, c := range in {
+			out[i] = ConfigChangeResponse{Key: c.Key, Old: c.Old, New: c.New}
+		}
+		return out
+	}
+	resp := ReloadResponse{
+		Applied:         changes(r.Applied),
+		RestartRequired: changes(r.RestartRequired),
+		Warnings:        r.Warnings,
+	}
+	if len(r.Skipped) > 0 {
+		resp.Skipped = changes(r.Skipped)
+	}
+	return resp
+}
+
+// configProblems lists the problems joined in a configuration error, one per entry
+func configProblems(err error) []string {
+	var problems []string
+	var walk func(err error)
+	walk = func(err error) {
+		if j, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range j.Unwrap() {
+				walk(e)
+			}
+			return
+		}
+		if err != errs.ErrInvalidConfig {
+			problems = append(problems, err.Error())
+		}
+	}
+	walk(err)
+	return problems
+}
+
+func healthResponse(r service.HealthReport) HealthResponse {
+	resp := HealthResponse{
+		Status:    r.Status,
+		Ready:     r.Ready,
+		Reasons:   r.Reasons,
+		StartedAt: r.StartedAt.Format(time.RFC3339),
+		Uptime:    int64(time.Since(r.StartedAt) / time.Second),
+		Listeners: make([]ListenerHealthResponse, len(r.Listeners)),
+		Memory: MemoryHealthResponse{
+			HeapAlloc:  r.Memory.HeapAlloc,
+			HeapInuse:  r.Memory.HeapInuse,
+			Sys:        r.Memory.Sys,
+			NumGC:      r.Memory.NumGC,
+			Limit:      r.Memory.Limit,
+			LimitRatio: r.Memory.LimitRatio,
+			Pressure:   r.Memory.Pressure,
+		},
+		Buckets: make([]BucketHealthResponse, len(r.Buckets)),
+	}
+	for i, l := range r.Listeners {
+		resp.Listeners[i] = ListenerHealthResponse{
+			Name:      l.Name,
+			Addr:      l.Addr,
+			Listening: l.Listening,
+			Error:     l.Error,
+		}
+		if !l.Since.IsZero() {
+			resp.Listeners[i].Since = l.Since.Format(time.RFC3339)
+		}
+	}
+	for i, b := range r.Buckets {
+		resp.Buckets[i] = BucketHealthResponse{
+			Name:           b.Name,
+			Keys:           b.Keys,
+			MemoryUsage:    b.MemoryUsage,
+			GCCollectors:   b.GC.Collectors,
+			GCRunning:      b.GC.Running,
+			WheelEntries:   b.GC.WheelEntries,
+			NearQueueSize:  b.GC.NearQueueSize,
+			PendingDeletes: b.GC.PendingDeletes,
+		}
+	}
+	return resp
+}
+
 func bucketListResponse(buckets []*bucket.BucketMetadata) BucketListResponse {
 	responses := make([]BucketResponse, len(buckets))
 	for i, b := range buckets {
@@ -136,3 +1385,41 @@ func bucketListResponse(buckets []*bucket.BucketMetadata) BucketListResponse {
 		Count:   len(buckets),
 	}
 }
+
+func signingKeyListResponse(keys []auth.KeyInfo) SigningKeyListResponse {
+	responses := make([]SigningKeyResponse, len(keys))
+	for i, k := range keys {
+		responses[i] = signingKeyResponse(k)
+	}
+	return SigningKeyListResponse{
+		Keys:  responses,
+		Count: len(keys),
+	}
+}
+
+func signingKeyResponse(k auth.KeyInfo) SigningKeyResponse {
+	resp := SigningKeyResponse{
+		ID:        k.ID,
+		Active:    k.Active,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+	}
+	if !k.RetireAt.IsZero() {
+		resp.RetireAt = k.RetireAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func revocationResponse(bucketName string, r auth.Revocation) RevocationResponse {
+	resp := RevocationResponse{
+		Bucket:   bucketName,
+		TokenIDs: make([]string, 0, len(r.TokenIDs)),
+	}
+	if r.IssuedBefore > 0 {
+		resp.IssuedBefore = time.Unix(0, r.IssuedBefore).UTC().Format(time.RFC3339Nano)
+	}
+	for id := range r.TokenIDs {
+		resp.TokenIDs = append(resp.TokenIDs, id)
+	}
+	sort.Strings(resp.TokenIDs)
+	return resp
+}
@@ -0,0 +1,92 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"log/slog"
+)
+
+func (h *Handler) handleBFReserve(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, capacity, errorRate, err := DecodeBFReservePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode BFRESERVE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for BFRESERVE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	info, err := h.bloomService.BFReserve(ctx, bucket, key, capacity, errorRate, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusCreated, EncodeBloomInfoResponse(info))
+}
+
+func (h *Handler) handleBFAdd(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, items, err := DecodeBFAddPayload(frame.Payload)
+	if err != nil || len(items) == 0 {
+		slog.Debug("TCP: Failed to decode BFADD payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for BFADD", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	added, err := h.bloomService.BFAdd(ctx, bucket, key, items, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBoolsResponse(added))
+}
+
+func (h *Handler) handleBFExists(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, items, err := DecodeBFExistsPayload(frame.Payload)
+	if err != nil || len(items) == 0 {
+		slog.Debug("TCP: Failed to decode BFEXISTS payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for BFEXISTS", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	exists, err := h.bloomService.BFExists(ctx, bucket, key, items)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBoolsResponse(exists))
+}
+
+func (h *Handler) handleBFInfo(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, err := DecodeGetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode BFINFO payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for BFINFO", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	info, err := h.bloomService.BFInfo(ctx, bucket, key)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBloomInfoResponse(info))
+}
@@ -2,7 +2,10 @@ package tcp
 
 import (
 	"encoding/binary"
+	"key-value-store/internal/engine"
 	"key-value-store/internal/util"
+	"math"
+	"time"
 )
 
 func writeString(buf []byte, offset int, s string) int {
@@ -137,6 +140,31 @@ func DecodeDeletePayload(data []byte) (token, bucket, key string, err error) {
 	return DecodeGetPayload(data)
 }
 
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket]
+// The token is either a bucket token or an admin token
+func EncodeFlushPayload(token, bucket string) []byte {
+	buf := make([]byte, 2+len(token)+2+len(bucket))
+	offset := writeString(buf, 0, token)
+	writeString(buf, offset, bucket)
+	return buf
+}
+
+func DecodeFlushPayload(data []byte) (token, bucket string, err error) {
+	token, offset, err := readString(data, 0)
+	if err != nil {
+		return
+	}
+	bucket, _, err = readString(data, offset)
+	return
+}
+
+// Format: [Removed(8)]
+func EncodeFlushResponse(removed int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(removed))
+	return buf
+}
+
 // Format: [KeyLen(2)][Key][TTL(8)][CreatedAt(8)][ExpiresAt(8)][SingleRead(1)][ValueLen(4)][Value]
 func EncodeValueResponse(key string, ttl int64, createdAt, expiresAt int64, singleRead bool, value []byte) []byte {
 	size := 2 + len(key) + 8 + 8 + 8 + 1 + 4 + len(value)
@@ -165,3 +193,1356 @@ func EncodeValueResponse(key string, ttl int64, createdAt, expiresAt int64, sing
 
 	return buf
 }
+
+func writeBytes(buf []byte, offset int, b []byte) int {
+	binary.BigEndian.PutUint32(buf[offset:], uint32(len(b)))
+	offset += 4
+	copy(buf[offset:], b)
+	return offset + len(b)
+}
+
+// readBytes returns a copy, frame payloads are reused by the connection
+func readBytes(data []byte, offset int) ([]byte, int, error) {
+	if len(data) < offset+4 {
+		return nil, offset, ErrInvalidFrame
+	}
+	n := int(binary.BigEndian.Uint32(data[offset:]))
+	offset += 4
+	if len(data) < offset+n {
+		return nil, offset, ErrInvalidFrame
+	}
+	return append([]byte(nil), data[offset:offset+n]...), offset + n, nil
+}
+
+// decodeTarget reads the token, bucket and key every key command starts with
+func decodeTarget(data []byte) (token, bucket, key string, offset int, err error) {
+	if token, offset, err = readString(data, 0); err != nil {
+		return
+	}
+	if bucket, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	key, offset, err = readString(data, offset)
+	return
+}
+
+func encodeTarget(size int, token, bucket, key string) ([]byte, int) {
+	buf := make([]byte, 2+len(token)+2+len(bucket)+2+len(key)+size)
+	offset := writeString(buf, 0, token)
+	offset = writeString(buf, offset, bucket)
+	return buf, writeString(buf, offset, key)
+}
+
+func fieldsSize(fields map[string][]byte) int {
+	size := 0
+	for f, v := range fields {
+		size += 2 + len(f) + 4 + len(v)
+	}
+	return size
+}
+
+func writeFields(buf []byte, offset int, fields map[string][]byte) int {
+	for f, v := range fields {
+		offset = writeString(buf, offset, f)
+		offset = writeBytes(buf, offset, v)
+	}
+	return offset
+}
+
+func readFields(data []byte, offset, count int) (map[string][]byte, int, error) {
+	// A field takes at least 6 bytes, the count alone must not size the map
+	fields := make(map[string][]byte, min(count, (len(data)-offset)/6))
+	for range count {
+		f, next, err := readString(data, offset)
+		if err != nil {
+			return nil, next, err
+		}
+		v, next, err := readBytes(data, next)
+		if err != nil {
+			return nil, next, err
+		}
+		fields[f] = v
+		offset = next
+	}
+	return fields, offset, nil
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][Count(2)]
+// followed by Count times [FieldLen(2)][Field][ValueLen(4)][Value]
+func EncodeHSetPayload(token, bucket, key string, ttl int64, fields map[string][]byte) []byte {
+	buf, offset := encodeTarget(8+2+fieldsSize(fields), token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	offset += 8
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(fields)))
+	writeFields(buf, offset+2, fields)
+	return buf
+}
+
+func DecodeHSetPayload(data []byte) (token, bucket, key string, ttl int64, fields map[string][]byte, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+10 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	count := int(binary.BigEndian.Uint16(data[offset+8:]))
+	fields, _, err = readFields(data, offset+10, count)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][FieldLen(2)][Field]
+func EncodeHGetPayload(token, bucket, key, field string) []byte {
+	buf, offset := encodeTarget(2+len(field), token, bucket, key)
+	writeString(buf, offset, field)
+	return buf
+}
+
+func DecodeHGetPayload(data []byte) (token, bucket, key, field string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	field, _, err = readString(data, offset)
+	return
+}
+
+func stringsSize(items []string) int {
+	size := 0
+	for _, s := range items {
+		size += 2 + len(s)
+	}
+	return size
+}
+
+func writeStrings(buf []byte, offset int, items []string) int {
+	for _, s := range items {
+		offset = writeString(buf, offset, s)
+	}
+	return offset
+}
+
+// readStrings reads count strings, which stay valid only as long as data
+func readStrings(data []byte, offset, count int) ([]string, int, error) {
+	items := make([]string, 0, min(count, (len(data)-offset)/2))
+	for range count {
+		s, next, err := readString(data, offset)
+		if err != nil {
+			return nil, next, err
+		}
+		items = append(items, s)
+		offset = next
+	}
+	return items, offset, nil
+}
+
+// readCount reads a 2-byte count and the strings that follow
+func readCount(data []byte, offset int) ([]string, error) {
+	if len(data) < offset+2 {
+		return nil, ErrInvalidFrame
+	}
+	items, _, err := readStrings(data, offset+2, int(binary.BigEndian.Uint16(data[offset:])))
+	return items, err
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Count(2)]
+// followed by Count times [FieldLen(2)][Field]
+func EncodeHDelPayload(token, bucket, key string, fields []string) []byte {
+	return EncodeMembersPayload(token, bucket, key, fields)
+}
+
+func DecodeHDelPayload(data []byte) (token, bucket, key string, fields []string, err error) {
+	return DecodeMembersPayload(data)
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][FieldLen(2)][Field][Delta(8)]
+func EncodeHIncrByPayload(token, bucket, key, field string, delta int64) []byte {
+	buf, offset := encodeTarget(2+len(field)+8, token, bucket, key)
+	offset = writeString(buf, offset, field)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(delta))
+	return buf
+}
+
+func DecodeHIncrByPayload(data []byte) (token, bucket, key, field string, delta int64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if field, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+8 {
+		err = ErrInvalidFrame
+		return
+	}
+	delta = int64(binary.BigEndian.Uint64(data[offset:]))
+	return
+}
+
+// Format: [Count(4)], the number of added, removed or returned items
+func EncodeCountResponse(n int) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	return buf
+}
+
+// Format: [Value(8)]
+func EncodeIntResponse(v int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(v))
+	return buf
+}
+
+// Format: [ValueLen(4)][Value]
+func EncodeBytesResponse(value []byte) []byte {
+	buf := make([]byte, 4+len(value))
+	writeBytes(buf, 0, value)
+	return buf
+}
+
+// Format: [Count(4)] followed by Count times [FieldLen(2)][Field][ValueLen(4)][Value]
+func EncodeFieldsResponse(fields map[string][]byte) []byte {
+	buf := make([]byte, 4+fieldsSize(fields))
+	binary.BigEndian.PutUint32(buf, uint32(len(fields)))
+	writeFields(buf, 4, fields)
+	return buf
+}
+
+func DecodeFieldsResponse(data []byte) (map[string][]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidFrame
+	}
+	fields, _, err := readFields(data, 4, int(binary.BigEndian.Uint32(data)))
+	return fields, err
+}
+
+func valuesSize(values [][]byte) int {
+	size := 0
+	for _, v := range values {
+		size += 4 + len(v)
+	}
+	return size
+}
+
+func writeValues(buf []byte, offset int, values [][]byte) int {
+	for _, v := range values {
+		offset = writeBytes(buf, offset, v)
+	}
+	return offset
+}
+
+func readValues(data []byte, offset, count int) ([][]byte, int, error) {
+	values := make([][]byte, 0, min(count, (len(data)-offset)/4))
+	for range count {
+		v, next, err := readBytes(data, offset)
+		if err != nil {
+			return nil, next, err
+		}
+		values = append(values, v)
+		offset = next
+	}
+	return values, offset, nil
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][End(1)][TTL(8)][Count(2)]
+// followed by Count times [ValueLen(4)][Value], End is 0 for left and 1 for right
+func EncodePushPayload(token, bucket, key string, end byte, ttl int64, values [][]byte) []byte {
+	buf, offset := encodeTarget(1+8+2+valuesSize(values), token, bucket, key)
+	buf[offset] = end
+	binary.BigEndian.PutUint64(buf[offset+1:], uint64(ttl))
+	binary.BigEndian.PutUint16(buf[offset+9:], uint16(len(values)))
+	writeValues(buf, offset+11, values)
+	return buf
+}
+
+func DecodePushPayload(data []byte) (token, bucket, key string, end byte, ttl int64, values [][]byte, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+11 {
+		err = ErrInvalidFrame
+		return
+	}
+	end = data[offset]
+	ttl = int64(binary.BigEndian.Uint64(data[offset+1:]))
+	count := int(binary.BigEndian.Uint16(data[offset+9:]))
+	values, _, err = readValues(data, offset+11, count)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][End(1)][Count(2)][TimeoutMs(4)]
+func EncodePopPayload(token, bucket, key string, end byte, count int, timeoutMs uint32) []byte {
+	buf, offset := encodeTarget(1+2+4, token, bucket, key)
+	buf[offset] = end
+	binary.BigEndian.PutUint16(buf[offset+1:], uint16(count))
+	binary.BigEndian.PutUint32(buf[offset+3:], timeoutMs)
+	return buf
+}
+
+func DecodePopPayload(data []byte) (token, bucket, key string, end byte, count int, timeoutMs uint32, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+7 {
+		err = ErrInvalidFrame
+		return
+	}
+	end = data[offset]
+	count = int(binary.BigEndian.Uint16(data[offset+1:]))
+	timeoutMs = binary.BigEndian.Uint32(data[offset+3:])
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Start(8)][Stop(8)]
+func EncodeRangePayload(token, bucket, key string, start, stop int64) []byte {
+	buf, offset := encodeTarget(16, token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(start))
+	binary.BigEndian.PutUint64(buf[offset+8:], uint64(stop))
+	return buf
+}
+
+func DecodeRangePayload(data []byte) (token, bucket, key string, start, stop int64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+16 {
+		err = ErrInvalidFrame
+		return
+	}
+	start = int64(binary.BigEndian.Uint64(data[offset:]))
+	stop = int64(binary.BigEndian.Uint64(data[offset+8:]))
+	return
+}
+
+// Format: [Count(4)] followed by Count times [ValueLen(4)][Value]
+func EncodeValuesResponse(values [][]byte) []byte {
+	buf := make([]byte, 4+valuesSize(values))
+	binary.BigEndian.PutUint32(buf, uint32(len(values)))
+	writeValues(buf, 4, values)
+	return buf
+}
+
+func DecodeValuesResponse(data []byte) ([][]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidFrame
+	}
+	values, _, err := readValues(data, 4, int(binary.BigEndian.Uint32(data)))
+	return values, err
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Count(2)]
+// followed by Count times [MemberLen(2)][Member], used by SREM and ZREM
+func EncodeMembersPayload(token, bucket, key string, members []string) []byte {
+	buf, offset := encodeTarget(2+stringsSize(members), token, bucket, key)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(members)))
+	writeStrings(buf, offset+2, members)
+	return buf
+}
+
+func DecodeMembersPayload(data []byte) (token, bucket, key string, members []string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	members, err = readCount(data, offset)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][MemberLen(2)][Member]
+// used by SISMEMBER and ZRANK
+func EncodeMemberPayload(token, bucket, key, member string) []byte {
+	buf, offset := encodeTarget(2+len(member), token, bucket, key)
+	writeString(buf, offset, member)
+	return buf
+}
+
+func DecodeMemberPayload(data []byte) (token, bucket, key, member string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	member, _, err = readString(data, offset)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][Count(2)]
+// followed by Count times [MemberLen(2)][Member]
+func EncodeSAddPayload(token, bucket, key string, ttl int64, members []string) []byte {
+	buf, offset := encodeTarget(8+2+stringsSize(members), token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	binary.BigEndian.PutUint16(buf[offset+8:], uint16(len(members)))
+	writeStrings(buf, offset+10, members)
+	return buf
+}
+
+func DecodeSAddPayload(data []byte) (token, bucket, key string, ttl int64, members []string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+8 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	members, err = readCount(data, offset+8)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][Count(2)] followed by Count times [KeyLen(2)][Key]
+func EncodeSetOpPayload(token, bucket string, keys []string) []byte {
+	buf := make([]byte, 2+len(token)+2+len(bucket)+2+stringsSize(keys))
+	offset := writeString(buf, 0, token)
+	offset = writeString(buf, offset, bucket)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(keys)))
+	writeStrings(buf, offset+2, keys)
+	return buf
+}
+
+func DecodeSetOpPayload(data []byte) (token, bucket string, keys []string, err error) {
+	token, offset, err := readString(data, 0)
+	if err != nil {
+		return
+	}
+	if bucket, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	keys, err = readCount(data, offset)
+	return
+}
+
+// Format: [IsMember(1)]
+func EncodeBoolResponse(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Format: [Count(4)] followed by Count times [Len(2)][String]
+func EncodeStringsResponse(items []string) []byte {
+	buf := make([]byte, 4+stringsSize(items))
+	binary.BigEndian.PutUint32(buf, uint32(len(items)))
+	writeStrings(buf, 4, items)
+	return buf
+}
+
+func DecodeStringsResponse(data []byte) ([]string, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidFrame
+	}
+	items, _, err := readStrings(data, 4, int(binary.BigEndian.Uint32(data)))
+	return items, err
+}
+
+func scoredSize(members []engine.ScoredMember) int {
+	size := 0
+	for _, m := range members {
+		size += 2 + len(m.Member) + 8
+	}
+	return size
+}
+
+func writeScored(buf []byte, offset int, members []engine.ScoredMember) int {
+	for _, m := range members {
+		offset = writeString(buf, offset, m.Member)
+		binary.BigEndian.PutUint64(buf[offset:], math.Float64bits(m.Score))
+		offset += 8
+	}
+	return offset
+}
+
+func readScored(data []byte, offset, count int) ([]engine.ScoredMember, int, error) {
+	members := make([]engine.ScoredMember, 0, min(count, (len(data)-offset)/10))
+	for range count {
+		m, next, err := readString(data, offset)
+		if err != nil {
+			return nil, next, err
+		}
+		if len(data) < next+8 {
+			return nil, next, ErrInvalidFrame
+		}
+		members = append(members, engine.ScoredMember{Member: m, Score: math.Float64frombits(binary.BigEndian.Uint64(data[next:]))})
+		offset = next + 8
+	}
+	return members, offset, nil
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][Count(2)]
+// followed by Count times [MemberLen(2)][Member][Score(8)], scores are IEEE 754 doubles
+func EncodeZAddPayload(token, bucket, key string, ttl int64, members []engine.ScoredMember) []byte {
+	buf, offset := encodeTarget(8+2+scoredSize(members), token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	binary.BigEndian.PutUint16(buf[offset+8:], uint16(len(members)))
+	writeScored(buf, offset+10, members)
+	return buf
+}
+
+func DecodeZAddPayload(data []byte) (token, bucket, key string, ttl int64, members []engine.ScoredMember, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+10 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	count := int(binary.BigEndian.Uint16(data[offset+8:]))
+	members, _, err = readScored(data, offset+10, count)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][MemberLen(2)][Member][Delta(8)]
+func EncodeZIncrByPayload(token, bucket, key, member string, delta float64) []byte {
+	buf, offset := encodeTarget(2+len(member)+8, token, bucket, key)
+	writeScored(buf, offset, []engine.ScoredMember{{Member: member, Score: delta}})
+	return buf
+}
+
+func DecodeZIncrByPayload(data []byte) (token, bucket, key, member string, delta float64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	scored, _, err := readScored(data, offset, 1)
+	if err != nil {
+		return
+	}
+	return token, bucket, key, scored[0].Member, scored[0].Score, nil
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Min(8)][Max(8)], both included
+func EncodeScoreRangePayload(token, bucket, key string, lo, hi float64) []byte {
+	buf, offset := encodeTarget(16, token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], math.Float64bits(lo))
+	binary.BigEndian.PutUint64(buf[offset+8:], math.Float64bits(hi))
+	return buf
+}
+
+func DecodeScoreRangePayload(data []byte) (token, bucket, key string, lo, hi float64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+16 {
+		err = ErrInvalidFrame
+		return
+	}
+	lo = math.Float64frombits(binary.BigEndian.Uint64(data[offset:]))
+	hi = math.Float64frombits(binary.BigEndian.Uint64(data[offset+8:]))
+	return
+}
+
+// Format: [Score(8)]
+func EncodeScoreResponse(score float64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(score))
+	return buf
+}
+
+// Format: [Rank(4)][Score(8)]
+func EncodeRankResponse(rank int, score float64) []byte {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint32(buf, uint32(rank))
+	binary.BigEndian.PutUint64(buf[4:], math.Float64bits(score))
+	return buf
+}
+
+// Format: [Count(4)] followed by Count times [MemberLen(2)][Member][Score(8)]
+func EncodeScoredResponse(members []engine.ScoredMember) []byte {
+	buf := make([]byte, 4+scoredSize(members))
+	binary.BigEndian.PutUint32(buf, uint32(len(members)))
+	writeScored(buf, 4, members)
+	return buf
+}
+
+func DecodeScoredResponse(data []byte) ([]engine.ScoredMember, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidFrame
+	}
+	members, _, err := readScored(data, 4, int(binary.BigEndian.Uint32(data)))
+	return members, err
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][PathLen(2)][Path][TTL(8)][ValueLen(4)][Value]
+func EncodeJSetPayload(token, bucket, key, path string, ttl int64, value []byte) []byte {
+	buf, offset := encodeTarget(2+len(path)+8+4+len(value), token, bucket, key)
+	offset = writeString(buf, offset, path)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	writeBytes(buf, offset+8, value)
+	return buf
+}
+
+func DecodeJSetPayload(data []byte) (token, bucket, key, path string, ttl int64, value []byte, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if path, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+8 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	value, _, err = readBytes(data, offset+8)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][PathLen(2)][Path], used by JGET and JDEL
+func EncodeJPathPayload(token, bucket, key, path string) []byte {
+	return EncodeMemberPayload(token, bucket, key, path)
+}
+
+func DecodeJPathPayload(data []byte) (token, bucket, key, path string, err error) {
+	return DecodeMemberPayload(data)
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][PathLen(2)][Path][Count(2)]
+// followed by Count times [ValueLen(4)][Value]
+func EncodeJArrAppendPayload(token, bucket, key, path string, values [][]byte) []byte {
+	buf, offset := encodeTarget(2+len(path)+2+valuesSize(values), token, bucket, key)
+	offset = writeString(buf, offset, path)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(values)))
+	writeValues(buf, offset+2, values)
+	return buf
+}
+
+func DecodeJArrAppendPayload(data []byte) (token, bucket, key, path string, values [][]byte, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if path, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+2 {
+		err = ErrInvalidFrame
+		return
+	}
+	count := int(binary.BigEndian.Uint16(data[offset:]))
+	values, _, err = readValues(data, offset+2, count)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][PathLen(2)][Path][DeltaLen(2)][Delta]
+// Delta is a JSON number, the response holds the result the same way
+func EncodeJIncrByPayload(token, bucket, key, path, delta string) []byte {
+	buf, offset := encodeTarget(2+len(path)+2+len(delta), token, bucket, key)
+	offset = writeString(buf, offset, path)
+	writeString(buf, offset, delta)
+	return buf
+}
+
+func DecodeJIncrByPayload(data []byte) (token, bucket, key, path, delta string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if path, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	delta, _, err = readString(data, offset)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][IndexLen(2)][Index][MinLen(2)][Min][MaxLen(2)][Max]
+// [CursorLen(2)][Cursor][Limit(2)], Min and Max are JSON scalars, both included, empty when open
+// Equality lookups send the same value twice, Limit 0 is the default page size
+func EncodeIQueryPayload(token, bucket, index, lo, hi, cursor string, limit int) []byte {
+	buf, offset := encodeTarget(2+len(lo)+2+len(hi)+2+len(cursor)+2, token, bucket, index)
+	offset = writeString(buf, offset, lo)
+	offset = writeString(buf, offset, hi)
+	offset = writeString(buf, offset, cursor)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(limit))
+	return buf
+}
+
+func DecodeIQueryPayload(data []byte) (token, bucket, index, lo, hi, cursor string, limit int, err error) {
+	token, bucket, index, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if lo, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if hi, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if cursor, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+2 {
+		err = ErrInvalidFrame
+		return
+	}
+	limit = int(binary.BigEndian.Uint16(data[offset:]))
+	return
+}
+
+// Format: [Count(4)] followed by Count times [KeyLen(2)][Key][ValueLen(4)][Value], then
+// [CursorLen(2)][Cursor], values are JSON scalars and the cursor is empty on the last page
+func EncodeIndexHitsResponse(hits []engine.IndexHit, cursor string) []byte {
+	values := make([][]byte, len(hits))
+	size := 4 + 2 + len(cursor)
+	for i, h := range hits {
+		values[i], _ = h.Value.MarshalJSON()
+		size += 2 + len(h.Key) + 4 + len(values[i])
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(hits)))
+	offset := 4
+	for i, h := range hits {
+		offset = writeString(buf, offset, h.Key)
+		offset = writeBytes(buf, offset, values[i])
+	}
+	writeString(buf, offset, cursor)
+	return buf
+}
+
+func DecodeIndexHitsResponse(data []byte) (keys []string, values [][]byte, cursor string, err error) {
+	if len(data) < 4 {
+		return nil, nil, "", ErrInvalidFrame
+	}
+	count := int(binary.BigEndian.Uint32(data))
+	offset := 4
+	for range count {
+		var key string
+		var value []byte
+		if key, offset, err = readString(data, offset); err != nil {
+			return
+		}
+		if value, offset, err = readBytes(data, offset); err != nil {
+			return
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	cursor, _, err = readString(data, offset)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][MaxLen(4)][MaxAgeMs(8)][Count(2)]
+// followed by Count times [FieldLen(2)][Field][ValueLen(4)][Value]
+// MaxLen and MaxAgeMs trim the stream after the append, 0 leaves it unbounded
+func EncodeXAddPayload(token, bucket, key string, ttl int64, maxLen uint32, maxAgeMs uint64, fields map[string][]byte) []byte {
+	buf, offset := encodeTarget(8+4+8+2+fieldsSize(fields), token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	binary.BigEndian.PutUint32(buf[offset+8:], maxLen)
+	binary.BigEndian.PutUint64(buf[offset+12:], maxAgeMs)
+	binary.BigEndian.PutUint16(buf[offset+20:], uint16(len(fields)))
+	writeFields(buf, offset+22, fields)
+	return buf
+}
+
+func DecodeXAddPayload(data []byte) (token, bucket, key string, ttl int64, maxLen uint32, maxAgeMs uint64, fields map[string][]byte, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+22 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	maxLen = binary.BigEndian.Uint32(data[offset+8:])
+	maxAgeMs = binary.BigEndian.Uint64(data[offset+12:])
+	count := int(binary.BigEndian.Uint16(data[offset+20:]))
+	fields, _, err = readFields(data, offset+22, count)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][StartLen(2)][Start][EndLen(2)][End][Count(2)]
+// Start and End are included, "-" and "+" stand for the first and last entries
+func EncodeXRangePayload(token, bucket, key, start, end string, count int) []byte {
+	buf, offset := encodeTarget(2+len(start)+2+len(end)+2, token, bucket, key)
+	offset = writeString(buf, offset, start)
+	offset = writeString(buf, offset, end)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(count))
+	return buf
+}
+
+func DecodeXRangePayload(data []byte) (token, bucket, key, start, end string, count int, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if start, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if end, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+2 {
+		err = ErrInvalidFrame
+		return
+	}
+	count = int(binary.BigEndian.Uint16(data[offset:]))
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][AfterLen(2)][After][Count(2)][TimeoutMs(4)]
+// "$" or an empty After stands for the last entry at the time of the read
+func EncodeXReadPayload(token, bucket, key, after string, count int, timeoutMs uint32) []byte {
+	buf, offset := encodeTarget(2+len(after)+2+4, token, bucket, key)
+	offset = writeString(buf, offset, after)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(count))
+	binary.BigEndian.PutUint32(buf[offset+2:], timeoutMs)
+	return buf
+}
+
+func DecodeXReadPayload(data []byte) (token, bucket, key, after string, count int, timeoutMs uint32, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if after, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+6 {
+		err = ErrInvalidFrame
+		return
+	}
+	count = int(binary.BigEndian.Uint16(data[offset:]))
+	timeoutMs = binary.BigEndian.Uint32(data[offset+2:])
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][MaxLen(4)][MaxAgeMs(8)]
+func EncodeXTrimPayload(token, bucket, key string, maxLen uint32, maxAgeMs uint64) []byte {
+	buf, offset := encodeTarget(4+8, token, bucket, key)
+	binary.BigEndian.PutUint32(buf[offset:], maxLen)
+	binary.BigEndian.PutUint64(buf[offset+4:], maxAgeMs)
+	return buf
+}
+
+func DecodeXTrimPayload(data []byte) (token, bucket, key string, maxLen uint32, maxAgeMs uint64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+12 {
+		err = ErrInvalidFrame
+		return
+	}
+	maxLen = binary.BigEndian.Uint32(data[offset:])
+	maxAgeMs = binary.BigEndian.Uint64(data[offset+4:])
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][GroupLen(2)][Group][StartLen(2)][Start]
+// The group delivers the entries after Start, "$" or empty for the last entry
+func EncodeXGroupCreatePayload(token, bucket, key, group, start string) []byte {
+	buf, offset := encodeTarget(2+len(group)+2+len(start), token, bucket, key)
+	offset = writeString(buf, offset, group)
+	writeString(buf, offset, start)
+	return buf
+}
+
+func DecodeXGroupCreatePayload(data []byte) (token, bucket, key, group, start string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if group, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	start, _, err = readString(data, offset)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][GroupLen(2)][Group]
+// [ConsumerLen(2)][Consumer][Count(2)][TimeoutMs(4)]
+func EncodeXReadGroupPayload(token, bucket, key, group, consumer string, count int, timeoutMs uint32) []byte {
+	buf, offset := encodeTarget(2+len(group)+2+len(consumer)+2+4, token, bucket, key)
+	offset = writeString(buf, offset, group)
+	offset = writeString(buf, offset, consumer)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(count))
+	binary.BigEndian.PutUint32(buf[offset+2:], timeoutMs)
+	return buf
+}
+
+func DecodeXReadGroupPayload(data []byte) (token, bucket, key, group, consumer string, count int, timeoutMs uint32, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if group, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if consumer, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+6 {
+		err = ErrInvalidFrame
+		return
+	}
+	count = int(binary.BigEndian.Uint16(data[offset:]))
+	timeoutMs = binary.BigEndian.Uint32(data[offset+2:])
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][GroupLen(2)][Group][Count(2)]
+// followed by Count times [IDLen(2)][ID]
+func EncodeXAckPayload(token, bucket, key, group string, ids []string) []byte {
+	buf, offset := encodeTarget(2+len(group)+2+stringsSize(ids), token, bucket, key)
+	offset = writeString(buf, offset, group)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(ids)))
+	writeStrings(buf, offset+2, ids)
+	return buf
+}
+
+func DecodeXAckPayload(data []byte) (token, bucket, key, group string, ids []string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if group, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	ids, err = readCount(data, offset)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][GroupLen(2)][Group]
+// [ConsumerLen(2)][Consumer][StartLen(2)][Start][Count(2)], an empty Consumer stands for all of them
+func EncodeXPendingPayload(token, bucket, key, group, consumer, start string, count int) []byte {
+	buf, offset := encodeTarget(2+len(group)+2+len(consumer)+2+len(start)+2, token, bucket, key)
+	offset = writeString(buf, offset, group)
+	offset = writeString(buf, offset, consumer)
+	offset = writeString(buf, offset, start)
+	binary.BigEndian.PutUint16(buf[offset:], uint16(count))
+	return buf
+}
+
+func DecodeXPendingPayload(data []byte) (token, bucket, key, group, consumer, start string, count int, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if group, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if consumer, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if start, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+2 {
+		err = ErrInvalidFrame
+		return
+	}
+	count = int(binary.BigEndian.Uint16(data[offset:]))
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][GroupLen(2)][Group]
+// [ConsumerLen(2)][Consumer][MinIdleMs(8)][Count(2)]
+func EncodeXClaimPayload(token, bucket, key, group, consumer string, minIdleMs uint64, count int) []byte {
+	buf, offset := encodeTarget(2+len(group)+2+len(consumer)+8+2, token, bucket, key)
+	offset = writeString(buf, offset, group)
+	offset = writeString(buf, offset, consumer)
+	binary.BigEndian.PutUint64(buf[offset:], minIdleMs)
+	binary.BigEndian.PutUint16(buf[offset+8:], uint16(count))
+	return buf
+}
+
+func DecodeXClaimPayload(data []byte) (token, bucket, key, group, consumer string, minIdleMs uint64, count int, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if group, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if consumer, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+10 {
+		err = ErrInvalidFrame
+		return
+	}
+	minIdleMs = binary.BigEndian.Uint64(data[offset:])
+	count = int(binary.BigEndian.Uint16(data[offset+8:]))
+	return
+}
+
+// Format: [Count(4)] followed by Count times [IDLen(2)][ID][FieldCount(2)]
+// and FieldCount times [FieldLen(2)][Field][ValueLen(4)][Value]
+func EncodeEntriesResponse(entries []engine.StreamEntry) []byte {
+	ids := make([]string, len(entries))
+	size := 4
+	for i, e := range entries {
+		ids[i] = e.ID.String()
+		size += 2 + len(ids[i]) + 2 + fieldsSize(e.Fields)
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(entries)))
+	offset := 4
+	for i, e := range entries {
+		offset = writeString(buf, offset, ids[i])
+		binary.BigEndian.PutUint16(buf[offset:], uint16(len(e.Fields)))
+		offset = writeFields(buf, offset+2, e.Fields)
+	}
+	return buf
+}
+
+func DecodeEntriesResponse(data []byte) ([]engine.StreamEntry, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidFrame
+	}
+	count := int(binary.BigEndian.Uint32(data))
+	offset := 4
+	var entries []engine.StreamEntry
+	for range count {
+		s, next, err := readString(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		id, err := engine.ParseStreamID(s)
+		if err != nil || len(data) < next+2 {
+			return nil, ErrInvalidFrame
+		}
+		fields, next, err := readFields(data, next+2, int(binary.BigEndian.Uint16(data[next:])))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, engine.StreamEntry{ID: id, Fields: fields})
+		offset = next
+	}
+	return entries, nil
+}
+
+// Format: [Count(4)] followed by Count times [IDLen(2)][ID][ConsumerLen(2)][Consumer]
+// [DeliveredAt(8)][Deliveries(4)], DeliveredAt in Unix milliseconds
+func EncodePendingResponse(pending []engine.PendingEntry) []byte {
+	ids := make([]string, len(pending))
+	size := 4
+	for i, p := range pending {
+		ids[i] = p.ID.String()
+		size += 2 + len(ids[i]) + 2 + len(p.Consumer) + 8 + 4
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(pending)))
+	offset := 4
+	for i, p := range pending {
+		offset = writeString(buf, offset, ids[i])
+		offset = writeString(buf, offset, p.Consumer)
+		binary.BigEndian.PutUint64(buf[offset:], uint64(p.DeliveredAt.UnixMilli()))
+		binary.BigEndian.PutUint32(buf[offset+8:], uint32(p.Deliveries))
+		offset += 12
+	}
+	return buf
+}
+
+func DecodePendingResponse(data []byte) ([]engine.PendingEntry, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidFrame
+	}
+	count := int(binary.BigEndian.Uint32(data))
+	offset := 4
+	var pending []engine.PendingEntry
+	for range count {
+		s, next, err := readString(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		id, err := engine.ParseStreamID(s)
+		if err != nil {
+			return nil, ErrInvalidFrame
+		}
+		consumer, next, err := readString(data, next)
+		if err != nil || len(data) < next+12 {
+			return nil, ErrInvalidFrame
+		}
+		pending = append(pending, engine.PendingEntry{
+			ID:          id,
+			Consumer:    consumer,
+			DeliveredAt: time.UnixMilli(int64(binary.BigEndian.Uint64(data[next:]))),
+			Deliveries:  int(binary.BigEndian.Uint32(data[next+8:])),
+		})
+		offset = next + 12
+	}
+	return pending, nil
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][Count(2)]
+// followed by Count times [MemberLen(2)][Member]
+func EncodePFAddPayload(token, bucket, key string, ttl int64, members []string) []byte {
+	return EncodeSAddPayload(token, bucket, key, ttl, members)
+}
+
+func DecodePFAddPayload(data []byte) (token, bucket, key string, ttl int64, members []string, err error) {
+	return DecodeSAddPayload(data)
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][Count(2)] followed by Count times [KeyLen(2)][Key]
+func EncodePFCountPayload(token, bucket string, keys []string) []byte {
+	return EncodeSetOpPayload(token, bucket, keys)
+}
+
+func DecodePFCountPayload(data []byte) (token, bucket string, keys []string, err error) {
+	return DecodeSetOpPayload(data)
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Count(2)]
+// followed by Count times [SourceLen(2)][Source], Key is the destination
+func EncodePFMergePayload(token, bucket, key string, sources []string) []byte {
+	return EncodeMembersPayload(token, bucket, key, sources)
+}
+
+func DecodePFMergePayload(data []byte) (token, bucket, key string, sources []string, err error) {
+	return DecodeMembersPayload(data)
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][Capacity(8)][ErrorRate(8)]
+// ErrorRate is an IEEE 754 double
+func EncodeBFReservePayload(token, bucket, key string, ttl, capacity int64, errorRate float64) []byte {
+	buf, offset := encodeTarget(24, token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	binary.BigEndian.PutUint64(buf[offset+8:], uint64(capacity))
+	binary.BigEndian.PutUint64(buf[offset+16:], math.Float64bits(errorRate))
+	return buf
+}
+
+func DecodeBFReservePayload(data []byte) (token, bucket, key string, ttl, capacity int64, errorRate float64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+24 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	capacity = int64(binary.BigEndian.Uint64(data[offset+8:]))
+	errorRate = math.Float64frombits(binary.BigEndian.Uint64(data[offset+16:]))
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][TTL(8)][Count(2)]
+// followed by Count times [ItemLen(2)][Item]
+func EncodeBFAddPayload(token, bucket, key string, ttl int64, items []string) []byte {
+	return EncodeSAddPayload(token, bucket, key, ttl, items)
+}
+
+func DecodeBFAddPayload(data []byte) (token, bucket, key string, ttl int64, items []string, err error) {
+	return DecodeSAddPayload(data)
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Count(2)]
+// followed by Count times [ItemLen(2)][Item]
+func EncodeBFExistsPayload(token, bucket, key string, items []string) []byte {
+	return EncodeMembersPayload(token, bucket, key, items)
+}
+
+func DecodeBFExistsPayload(data []byte) (token, bucket, key string, items []string, err error) {
+	return DecodeMembersPayload(data)
+}
+
+// Format: [Count(4)] followed by Count times [Result(1)], one per requested item
+func EncodeBoolsResponse(results []bool) []byte {
+	buf := make([]byte, 4+len(results))
+	binary.BigEndian.PutUint32(buf, uint32(len(results)))
+	for i, v := range results {
+		if v {
+			buf[4+i] = 1
+		}
+	}
+	return buf
+}
+
+func DecodeBoolsResponse(data []byte) ([]bool, error) {
+	if len(data) < 4 || len(data)-4 != int(binary.BigEndian.Uint32(data)) {
+		return nil, ErrInvalidFrame
+	}
+	out := make([]bool, len(data)-4)
+	for i, b := range data[4:] {
+		out[i] = b != 0
+	}
+	return out, nil
+}
+
+// Format: [Capacity(8)][ErrorRate(8)][Bits(8)][Hashes(4)][Count(8)]
+func EncodeBloomInfoResponse(info engine.BloomInfo) []byte {
+	buf := make([]byte, 36)
+	binary.BigEndian.PutUint64(buf, uint64(info.Capacity))
+	binary.BigEndian.PutUint64(buf[8:], math.Float64bits(info.ErrorRate))
+	binary.BigEndian.PutUint64(buf[16:], info.Bits)
+	binary.BigEndian.PutUint32(buf[24:], uint32(info.Hashes))
+	binary.BigEndian.PutUint64(buf[28:], uint64(info.Count))
+	return buf
+}
+
+func DecodeBloomInfoResponse(data []byte) (engine.BloomInfo, error) {
+	if len(data) < 36 {
+		return engine.BloomInfo{}, ErrInvalidFrame
+	}
+	return engine.BloomInfo{
+		Capacity:  int64(binary.BigEndian.Uint64(data)),
+		ErrorRate: math.Float64frombits(binary.BigEndian.Uint64(data[8:])),
+		Bits:      binary.BigEndian.Uint64(data[16:]),
+		Hashes:    int(binary.BigEndian.Uint32(data[24:])),
+		Count:     int64(binary.BigEndian.Uint64(data[28:])),
+	}, nil
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][OwnerLen(2)][Owner][TTL(8)][TimeoutMs(4)]
+// Key is the lock name
+func EncodeLockPayload(token, bucket, key, owner string, ttl int64, timeoutMs uint32) []byte {
+	buf, offset := encodeTarget(2+len(owner)+8+4, token, bucket, key)
+	offset = writeString(buf, offset, owner)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	binary.BigEndian.PutUint32(buf[offset+8:], timeoutMs)
+	return buf
+}
+
+func DecodeLockPayload(data []byte) (token, bucket, key, owner string, ttl int64, timeoutMs uint32, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if owner, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+12 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	timeoutMs = binary.BigEndian.Uint32(data[offset+8:])
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][OwnerLen(2)][Owner][TTL(8)]
+func EncodeRenewPayload(token, bucket, key, owner string, ttl int64) []byte {
+	buf, offset := encodeTarget(2+len(owner)+8, token, bucket, key)
+	offset = writeString(buf, offset, owner)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(ttl))
+	return buf
+}
+
+func DecodeRenewPayload(data []byte) (token, bucket, key, owner string, ttl int64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if owner, offset, err = readString(data, offset); err != nil {
+		return
+	}
+	if len(data) < offset+8 {
+		err = ErrInvalidFrame
+		return
+	}
+	ttl = int64(binary.BigEndian.Uint64(data[offset:]))
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][OwnerLen(2)][Owner]
+func EncodeUnlockPayload(token, bucket, key, owner string) []byte {
+	buf, offset := encodeTarget(2+len(owner), token, bucket, key)
+	writeString(buf, offset, owner)
+	return buf
+}
+
+func DecodeUnlockPayload(data []byte) (token, bucket, key, owner string, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	owner, _, err = readString(data, offset)
+	return
+}
+
+// Format: [Token(8)][AcquiredAt(8)][ExpiresAt(8)][OwnerLen(2)][Owner]
+// Times are unix milliseconds, Token is the fencing token of the owner
+func EncodeLockResponse(owner string, token uint64, acquiredAt, expiresAt time.Time) []byte {
+	buf := make([]byte, 24+2+len(owner))
+	binary.BigEndian.PutUint64(buf, token)
+	binary.BigEndian.PutUint64(buf[8:], uint64(acquiredAt.UnixMilli()))
+	binary.BigEndian.PutUint64(buf[16:], uint64(expiresAt.UnixMilli()))
+	writeString(buf, 24, owner)
+	return buf
+}
+
+func DecodeLockResponse(data []byte) (owner string, token uint64, acquiredAt, expiresAt time.Time, err error) {
+	if len(data) < 24 {
+		err = ErrInvalidFrame
+		return
+	}
+	token = binary.BigEndian.Uint64(data)
+	acquiredAt = time.UnixMilli(int64(binary.BigEndian.Uint64(data[8:])))
+	expiresAt = time.UnixMilli(int64(binary.BigEndian.Uint64(data[16:])))
+	owner, _, err = readString(data, 24)
+	return
+}
+
+// Format: [TokenLen(2)][Token][BucketLen(2)][Bucket][KeyLen(2)][Key][Capacity(8)][Rate(8)][PeriodMs(8)][Cost(8)]
+func EncodeRateLimitPayload(token, bucket, key string, capacity, rate, periodMs, cost int64) []byte {
+	buf, offset := encodeTarget(32, token, bucket, key)
+	binary.BigEndian.PutUint64(buf[offset:], uint64(capacity))
+	binary.BigEndian.PutUint64(buf[offset+8:], uint64(rate))
+	binary.BigEndian.PutUint64(buf[offset+16:], uint64(periodMs))
+	binary.BigEndian.PutUint64(buf[offset+24:], uint64(cost))
+	return buf
+}
+
+func DecodeRateLimitPayload(data []byte) (token, bucket, key string, capacity, rate, periodMs, cost int64, err error) {
+	token, bucket, key, offset, err := decodeTarget(data)
+	if err != nil {
+		return
+	}
+	if len(data) < offset+32 {
+		err = ErrInvalidFrame
+		return
+	}
+	capacity = int64(binary.BigEndian.Uint64(data[offset:]))
+	rate = int64(binary.BigEndian.Uint64(data[offset+8:]))
+	periodMs = int64(binary.BigEndian.Uint64(data[offset+16:]))
+	cost = int64(binary.BigEndian.Uint64(data[offset+24:]))
+	return
+}
+
+// Format: [Allowed(1)][Remaining(8)][RetryAfterMs(8)][ResetAfterMs(8)]
+// Durations are rounded up to the millisecond
+func EncodeRateLimitResponse(res engine.RateResult) []byte {
+	buf := make([]byte, 25)
+	if res.Allowed {
+		buf[0] = 1
+	}
+	binary.BigEndian.PutUint64(buf[1:], uint64(res.Remaining))
+	binary.BigEndian.PutUint64(buf[9:], uint64(ceilMillis(res.RetryAfter)))
+	binary.BigEndian.PutUint64(buf[17:], uint64(ceilMillis(res.ResetAfter)))
+	return buf
+}
+
+func DecodeRateLimitResponse(data []byte) (engine.RateResult, error) {
+	if len(data) < 25 {
+		return engine.RateResult{}, ErrInvalidFrame
+	}
+	return engine.RateResult{
+		Allowed:    data[0] != 0,
+		Remaining:  int64(binary.BigEndian.Uint64(data[1:])),
+		RetryAfter: time.Duration(binary.BigEndian.Uint64(data[9:])) * time.Millisecond,
+		ResetAfter: time.Duration(binary.BigEndian.Uint64(data[17:])) * time.Millisecond,
+	}, nil
+}
+
+func ceilMillis(d time.Duration) int64 {
+	ms := d.Milliseconds()
+	if d%time.Millisecond != 0 {
+		ms++
+	}
+	return ms
+}
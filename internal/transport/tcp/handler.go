@@ -3,27 +3,63 @@ package tcp
 import (
 	"context"
 	"errors"
+	"fmt"
 	"key-value-store/internal/auth"
 	"key-value-store/internal/errs"
+	"key-value-store/internal/metrics"
 	"key-value-store/internal/service"
 	"log/slog"
+	"time"
+)
+
+const (
+	msgInvalidPayload = "Invalid payload"
+	msgUnknownCommand = "Unknown command"
 )
 
 type Handler struct {
 	storageService service.IStorageService
-	ctx            context.Context
+	bucketService  service.IBucketService
+	hashService    service.IHashService
+	listService    service.IListService
+	setService     service.ISetService
+	zsetService    service.ISortedSetService
+	jsonService    service.IJSONService
+	indexService   service.IIndexService
+	streamService  service.IStreamService
+	hllService     service.IHyperLogLogService
+	bloomService   service.IBloomService
+	lockService    service.ILockService
+	rateService    service.IRateLimitService
 }
 
-func NewHandler(storageService service.IStorageService) *Handler {
+func NewHandler(storageService service.IStorageService, bucketService service.IBucketService, hashService service.IHashService, listService service.IListService, setService service.ISetService, zsetService service.ISortedSetService, jsonService service.IJSONService, indexService service.IIndexService, streamService service.IStreamService, hllService service.IHyperLogLogService, bloomService service.IBloomService, lockService service.ILockService, rateService service.IRateLimitService) *Handler {
 	return &Handler{
 		storageService: storageService,
-		ctx:            context.Background(),
+		bucketService:  bucketService,
+		hashService:    hashService,
+		listService:    listService,
+		setService:     setService,
+		zsetService:    zsetService,
+		jsonService:    jsonService,
+		indexService:   indexService,
+		streamService:  streamService,
+		hllService:     hllService,
+		bloomService:   bloomService,
+		lockService:    lockService,
+		rateService:    rateService,
 	}
 }
 
-func (h *Handler) HandleFrame(frame *Frame) *Frame {
-	ctx := h.ctx
+// HandleFrame runs a single frame, ctx is cancelled once its connection is gone
+func (h *Handler) HandleFrame(ctx context.Context, frame *Frame) *Frame {
+	start := time.Now()
+	resp := h.dispatch(ctx, frame)
+	observe(frame, resp, start)
+	return resp
+}
 
+func (h *Handler) dispatch(ctx context.Context, frame *Frame) *Frame {
 	switch frame.Command {
 	case CmdSet:
 		return h.handleSet(ctx, frame)
@@ -31,8 +67,111 @@ func (h *Handler) HandleFrame(frame *Frame) *Frame {
 		return h.handleGet(ctx, frame)
 	case CmdDelete:
 		return h.handleDelete(ctx, frame)
+	case CmdFlush:
+		return h.handleFlush(ctx, frame)
+	case CmdHSet:
+		return h.handleHSet(ctx, frame)
+	case CmdHGet:
+		return h.handleHGet(ctx, frame)
+	case CmdHDel:
+		return h.handleHDel(ctx, frame)
+	case CmdHIncrBy:
+		return h.handleHIncrBy(ctx, frame)
+	case CmdHGetAll:
+		return h.handleHGetAll(ctx, frame)
+	case CmdPush:
+		return h.handlePush(ctx, frame)
+	case CmdPop:
+		return h.handlePop(ctx, frame)
+	case CmdLRange:
+		return h.handleLRange(ctx, frame)
+	case CmdLTrim:
+		return h.handleLTrim(ctx, frame)
+	case CmdLLen:
+		return h.handleLLen(ctx, frame)
+	case CmdSAdd:
+		return h.handleSAdd(ctx, frame)
+	case CmdSRem:
+		return h.handleSRem(ctx, frame)
+	case CmdSIsMember:
+		return h.handleSIsMember(ctx, frame)
+	case CmdSMembers:
+		return h.handleSMembers(ctx, frame)
+	case CmdSUnion, CmdSInter, CmdSDiff:
+		return h.handleSetOp(ctx, frame)
+	case CmdZAdd:
+		return h.handleZAdd(ctx, frame)
+	case CmdZIncrBy:
+		return h.handleZIncrBy(ctx, frame)
+	case CmdZRange:
+		return h.handleZRange(ctx, frame)
+	case CmdZRangeByScore:
+		return h.handleZRangeByScore(ctx, frame)
+	case CmdZRank:
+		return h.handleZRank(ctx, frame)
+	case CmdZRem:
+		return h.handleZRem(ctx, frame)
+	case CmdZRemRangeByRank:
+		return h.handleZRemRangeByRank(ctx, frame)
+	case CmdZRemRangeByScore:
+		return h.handleZRemRangeByScore(ctx, frame)
+	case CmdJSet:
+		return h.handleJSet(ctx, frame)
+	case CmdJGet:
+		return h.handleJGet(ctx, frame)
+	case CmdJDel:
+		return h.handleJDel(ctx, frame)
+	case CmdJArrAppend:
+		return h.handleJArrAppend(ctx, frame)
+	case CmdJIncrBy:
+		return h.handleJIncrBy(ctx, frame)
+	case CmdIQuery:
+		return h.handleIQuery(ctx, frame)
+	case CmdXAdd:
+		return h.handleXAdd(ctx, frame)
+	case CmdXRange:
+		return h.handleXRange(ctx, frame)
+	case CmdXRead:
+		return h.handleXRead(ctx, frame)
+	case CmdXTrim:
+		return h.handleXTrim(ctx, frame)
+	case CmdXGroupCreate:
+		return h.handleXGroupCreate(ctx, frame)
+	case CmdXReadGroup:
+		return h.handleXReadGroup(ctx, frame)
+	case CmdXAck:
+		return h.handleXAck(ctx, frame)
+	case CmdXPending:
+		return h.handleXPending(ctx, frame)
+	case CmdXClaim:
+		return h.handleXClaim(ctx, frame)
+	case CmdPFAdd:
+		return h.handlePFAdd(ctx, frame)
+	case CmdPFCount:
+		return h.handlePFCount(ctx, frame)
+	case CmdPFMerge:
+		return h.handlePFMerge(ctx, frame)
+	case CmdBFReserve:
+		return h.handleBFReserve(ctx, frame)
+	case CmdBFAdd:
+		return h.handleBFAdd(ctx, frame)
+	case CmdBFExists:
+		return h.handleBFExists(ctx, frame)
+	case CmdBFInfo:
+		return h.handleBFInfo(ctx, frame)
+	case CmdLock:
+		return h.handleLock(ctx, frame)
+	case CmdRenew:
+		return h.handleRenew(ctx, frame)
+	case CmdUnlock:
+		return h.handleUnlock(ctx, frame)
+	case CmdLockInfo:
+		return h.handleLockInfo(ctx, frame)
+	case CmdRateLimit:
+		return h.handleRateLimit(ctx, frame)
 	default:
-		return NewErrorFrame(frame.RequestID, StatusBadRequest, "Unknown command")
+		metrics.TCPFrameErrors.With("unknown_command").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgUnknownCommand)
 	}
 }
 
@@ -40,7 +179,8 @@ func (h *Handler) handleSet(ctx context.Context, frame *Frame) *Frame {
 	token, bucket, key, ttl, singleRead, value, err := DecodeSetPayload(frame.Payload)
 	if err != nil {
 		slog.Debug("TCP: Failed to decode SET payload", "error", err)
-		return NewErrorFrame(frame.RequestID, StatusBadRequest, "Invalid payload")
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
 	}
 
 	if !auth.Manager().ValidateToken(token, bucket) {
@@ -60,7 +200,8 @@ func (h *Handler) handleGet(ctx context.Context, frame *Frame) *Frame {
 	token, bucket, key, err := DecodeGetPayload(frame.Payload)
 	if err != nil {
 		slog.Debug("TCP: Failed to decode GET payload", "error", err)
-		return NewErrorFrame(frame.RequestID, StatusBadRequest, "Invalid payload")
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
 	}
 
 	if !auth.Manager().ValidateToken(token, bucket) {
@@ -89,7 +230,8 @@ func (h *Handler) handleDelete(ctx context.Context, frame *Frame) *Frame {
 	token, bucket, key, err := DecodeDeletePayload(frame.Payload)
 	if err != nil {
 		slog.Debug("TCP: Failed to decode DELETE payload", "error", err)
-		return NewErrorFrame(frame.RequestID, StatusBadRequest, "Invalid payload")
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
 	}
 
 	if !auth.Manager().ValidateToken(token, bucket) {
@@ -105,6 +247,27 @@ func (h *Handler) handleDelete(ctx context.Context, frame *Frame) *Frame {
 	return NewResponseFrame(frame.RequestID, StatusNoContent, nil)
 }
 
+func (h *Handler) handleFlush(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, err := DecodeFlushPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode FLUSH payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) && !auth.Manager().ValidateToken(token, auth.AdminSubject) {
+		slog.Debug("TCP: Invalid token for FLUSH", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.bucketService.FlushBucket(ctx, bucket)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeFlushResponse(removed))
+}
+
 func (h *Handler) handleServiceError(requestID uint64, err error) *Frame {
 	var status byte
 	var message string
@@ -113,12 +276,106 @@ func (h *Handler) handleServiceError(requestID uint64, err error) *Frame {
 	case errors.Is(err, errs.ErrInvalidTTL):
 		status = StatusInvalidTTL
 		message = "Invalid TTL"
+	case errors.Is(err, errs.ErrTTLExceedsLimit):
+		status = StatusInvalidTTL
+		message = "TTL exceeds bucket limit"
+	case errors.Is(err, errs.ErrKeyTooLong):
+		status = StatusBadRequest
+		message = "Key too long"
+	case errors.Is(err, errs.ErrSingleReadDenied):
+		status = StatusBadRequest
+		message = "Single-read keys are not allowed in this bucket"
+	case errors.Is(err, errs.ErrValueTooLarge):
+		status = StatusValueTooLarge
+		message = "Value too large"
+	case errors.Is(err, errs.ErrKeyLimit):
+		status = StatusLimitExceeded
+		message = "Bucket key limit reached"
 	case errors.Is(err, errs.ErrKeyNotFound):
 		status = StatusNotFound
 		message = "Key not found"
+	case errors.Is(err, errs.ErrFieldNotFound):
+		status = StatusNotFound
+		message = "Field not found"
+	case errors.Is(err, errs.ErrMemberNotFound):
+		status = StatusNotFound
+		message = "Member not found"
+	case errors.Is(err, errs.ErrWrongType):
+		status = StatusWrongType
+		message = "Key holds a different kind of value"
+		var wrong *errs.WrongTypeError
+		if errors.As(err, &wrong) {
+			message = fmt.Sprintf("Key holds a %s value, not a %s", wrong.Have, wrong.Want)
+		}
+	case errors.Is(err, errs.ErrNotInteger):
+		status = StatusBadRequest
+		message = "Value is not an integer or out of range"
+	case errors.Is(err, errs.ErrInvalidScore):
+		status = StatusBadRequest
+		message = "Score is not a finite number"
+	case errors.Is(err, errs.ErrInvalidJSON):
+		status = StatusBadRequest
+		message = "Invalid JSON"
+	case errors.Is(err, errs.ErrInvalidPath):
+		status = StatusBadRequest
+		message = "Invalid path"
+	case errors.Is(err, errs.ErrPathNotFound):
+		status = StatusNotFound
+		message = "Path not found"
+	case errors.Is(err, errs.ErrPathType):
+		status = StatusConflict
+		message = "Path holds a different JSON type"
+	case errors.Is(err, errs.ErrNumberOverflow):
+		status = StatusBadRequest
+		message = "Number out of range"
+	case errors.Is(err, errs.ErrIndexNotFound):
+		status = StatusNotFound
+		message = "Index not found"
+	case errors.Is(err, errs.ErrIndexValue):
+		status = StatusBadRequest
+		message = "Index values are JSON scalars"
+	case errors.Is(err, errs.ErrInvalidCursor):
+		status = StatusBadRequest
+		message = "Invalid cursor"
+	case errors.Is(err, errs.ErrInvalidStreamID):
+		status = StatusBadRequest
+		message = "Invalid stream ID"
+	case errors.Is(err, errs.ErrGroupNotFound):
+		status = StatusNotFound
+		message = "Consumer group not found"
+	case errors.Is(err, errs.ErrGroupExists):
+		status = StatusConflict
+		message = "Consumer group already exists"
+	case errors.Is(err, errs.ErrInvalidGroup):
+		status = StatusBadRequest
+		message = "Invalid consumer group or consumer name"
+	case errors.Is(err, errs.ErrInvalidFilter):
+		status = StatusBadRequest
+		message = "Invalid filter capacity or error rate"
+	case errors.Is(err, errs.ErrFilterExists):
+		status = StatusConflict
+		message = "Filter already exists"
+	case errors.Is(err, errs.ErrLockHeld):
+		status = StatusConflict
+		message = "Lock held by another owner"
+	case errors.Is(err, errs.ErrLockNotOwned):
+		status = StatusConflict
+		message = "Lock not held by this owner"
+	case errors.Is(err, errs.ErrInvalidLock):
+		status = StatusBadRequest
+		message = "Invalid lock owner or lease"
+	case errors.Is(err, errs.ErrInvalidRateLimit):
+		status = StatusBadRequest
+		message = "Invalid rate limit or cost"
+	case errors.Is(err, errs.ErrBucketNotFound):
+		status = StatusNotFound
+		message = "Bucket not found"
 	case errors.Is(err, errs.ErrKeyExpired):
 		status = StatusKeyExpired
 		message = "Key expired"
+	case errors.Is(err, errs.ErrReshardInProgress):
+		status = StatusConflict
+		message = "Resharding in progress"
 	case errors.Is(err, errs.ErrUnauthorized):
 		status = StatusUnauthorized
 		message = "Unauthorized"
@@ -0,0 +1,113 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"log/slog"
+)
+
+func (h *Handler) handleHSet(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, fields, err := DecodeHSetPayload(frame.Payload)
+	if err != nil || len(fields) == 0 {
+		slog.Debug("TCP: Failed to decode HSET payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for HSET", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	added, err := h.hashService.HSet(ctx, bucket, key, fields, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(added))
+}
+
+func (h *Handler) handleHGet(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, field, err := DecodeHGetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode HGET payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for HGET", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	value, err := h.hashService.HGet(ctx, bucket, key, field)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBytesResponse(value))
+}
+
+func (h *Handler) handleHDel(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, fields, err := DecodeHDelPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode HDEL payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for HDEL", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.hashService.HDel(ctx, bucket, key, fields)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(removed))
+}
+
+func (h *Handler) handleHIncrBy(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, field, delta, err := DecodeHIncrByPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode HINCRBY payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for HINCRBY", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	value, err := h.hashService.HIncrBy(ctx, bucket, key, field, delta)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeIntResponse(value))
+}
+
+func (h *Handler) handleHGetAll(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, err := DecodeGetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode HGETALL payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for HGETALL", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	fields, err := h.hashService.HGetAll(ctx, bucket, key)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeFieldsResponse(fields))
+}
@@ -0,0 +1,71 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"log/slog"
+)
+
+func (h *Handler) handlePFAdd(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, members, err := DecodePFAddPayload(frame.Payload)
+	if err != nil || len(members) == 0 {
+		slog.Debug("TCP: Failed to decode PFADD payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for PFADD", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	changed, err := h.hllService.PFAdd(ctx, bucket, key, members, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBoolResponse(changed))
+}
+
+func (h *Handler) handlePFCount(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, keys, err := DecodePFCountPayload(frame.Payload)
+	if err != nil || len(keys) == 0 {
+		slog.Debug("TCP: Failed to decode PFCOUNT payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for PFCOUNT", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	count, err := h.hllService.PFCount(ctx, bucket, keys)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeIntResponse(count))
+}
+
+func (h *Handler) handlePFMerge(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, sources, err := DecodePFMergePayload(frame.Payload)
+	if err != nil || len(sources) == 0 {
+		slog.Debug("TCP: Failed to decode PFMERGE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for PFMERGE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	count, err := h.hllService.PFMerge(ctx, bucket, key, sources)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeIntResponse(count))
+}
@@ -0,0 +1,37 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"key-value-store/internal/service"
+	"log/slog"
+)
+
+func (h *Handler) handleIQuery(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, index, lo, hi, cursor, limit, err := DecodeIQueryPayload(frame.Payload)
+	if err != nil || limit > service.MaxIndexLimit {
+		slog.Debug("TCP: Failed to decode IQUERY payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for IQUERY", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	q := service.IndexQuery{Cursor: cursor, Limit: limit}
+	if lo != "" {
+		q.Min = []byte(lo)
+	}
+	if hi != "" {
+		q.Max = []byte(hi)
+	}
+	page, err := h.indexService.Query(ctx, bucket, index, q)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeIndexHitsResponse(page.Hits, page.Cursor))
+}
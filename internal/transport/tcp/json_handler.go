@@ -0,0 +1,113 @@
+package tcp
+
+import (
+	"context"
+	"encoding/json"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"log/slog"
+)
+
+func (h *Handler) handleJSet(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, path, ttl, value, err := DecodeJSetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode JSET payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for JSET", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	if err := h.jsonService.JSet(ctx, bucket, key, path, value, ttl); err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, nil)
+}
+
+func (h *Handler) handleJGet(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, path, err := DecodeJPathPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode JGET payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for JGET", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	value, err := h.jsonService.JGet(ctx, bucket, key, path)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBytesResponse(value))
+}
+
+func (h *Handler) handleJDel(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, path, err := DecodeJPathPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode JDEL payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for JDEL", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	deleted, err := h.jsonService.JDel(ctx, bucket, key, path)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBoolResponse(deleted))
+}
+
+func (h *Handler) handleJArrAppend(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, path, values, err := DecodeJArrAppendPayload(frame.Payload)
+	if err != nil || len(values) == 0 {
+		slog.Debug("TCP: Failed to decode JARRAPPEND payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for JARRAPPEND", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	length, err := h.jsonService.JArrAppend(ctx, bucket, key, path, values)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(length))
+}
+
+func (h *Handler) handleJIncrBy(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, path, delta, err := DecodeJIncrByPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode JINCRBY payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for JINCRBY", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	value, err := h.jsonService.JIncrBy(ctx, bucket, key, path, json.Number(delta))
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBytesResponse([]byte(value)))
+}
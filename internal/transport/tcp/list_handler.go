@@ -0,0 +1,138 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/metrics"
+	"key-value-store/internal/service"
+	"log/slog"
+	"time"
+)
+
+// maxPopCount bounds the values removed by a single POP
+const maxPopCount = 1000
+
+func listEnd(end byte) (service.ListEnd, bool) {
+	switch end {
+	case EndLeft:
+		return service.ListLeft, true
+	case EndRight:
+		return service.ListRight, true
+	default:
+		return 0, false
+	}
+}
+
+func (h *Handler) handlePush(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, endByte, ttl, values, err := DecodePushPayload(frame.Payload)
+	end, ok := listEnd(endByte)
+	if err != nil || !ok || len(values) == 0 {
+		slog.Debug("TCP: Failed to decode PUSH payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for PUSH", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	length, err := h.listService.Push(ctx, bucket, key, end, values, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(length))
+}
+
+// handlePop blocks the connection for up to the requested timeout, frames
+// pipelined behind it wait, and answers StatusNoContent when nothing was pushed
+func (h *Handler) handlePop(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, endByte, count, timeoutMs, err := DecodePopPayload(frame.Payload)
+	end, ok := listEnd(endByte)
+	if err != nil || !ok || count > maxPopCount {
+		slog.Debug("TCP: Failed to decode POP payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for POP", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	values, err := h.listService.Pop(ctx, bucket, key, end, count, time.Duration(timeoutMs)*time.Millisecond)
+	if errors.Is(err, errs.ErrWaitTimeout) {
+		return NewResponseFrame(frame.RequestID, StatusNoContent, nil)
+	}
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeValuesResponse(values))
+}
+
+func (h *Handler) handleLRange(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, start, stop, err := DecodeRangePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode LRANGE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for LRANGE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	values, err := h.listService.Range(ctx, bucket, key, start, stop)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeValuesResponse(values))
+}
+
+func (h *Handler) handleLTrim(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, start, stop, err := DecodeRangePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode LTRIM payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for LTRIM", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	length, err := h.listService.Trim(ctx, bucket, key, start, stop)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(length))
+}
+
+func (h *Handler) handleLLen(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, err := DecodeGetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode LLEN payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for LLEN", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	length, err := h.listService.Len(ctx, bucket, key)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(length))
+}
@@ -0,0 +1,99 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"key-value-store/internal/service"
+	"log/slog"
+	"time"
+)
+
+// handleLock blocks the connection for up to the requested timeout while the
+// lock is held, frames pipelined behind it wait
+func (h *Handler) handleLock(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, name, owner, ttl, timeoutMs, err := DecodeLockPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode LOCK payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for LOCK", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	info, err := h.lockService.Acquire(ctx, bucket, name, owner, ttl, time.Duration(timeoutMs)*time.Millisecond)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, encodeLockInfo(info))
+}
+
+func (h *Handler) handleRenew(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, name, owner, ttl, err := DecodeRenewPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode RENEW payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for RENEW", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	info, err := h.lockService.Renew(ctx, bucket, name, owner, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, encodeLockInfo(info))
+}
+
+func (h *Handler) handleUnlock(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, name, owner, err := DecodeUnlockPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode UNLOCK payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for UNLOCK", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	if err := h.lockService.Release(ctx, bucket, name, owner); err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusNoContent, nil)
+}
+
+func (h *Handler) handleLockInfo(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, name, err := DecodeGetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode LOCKINFO payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for LOCKINFO", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	info, err := h.lockService.Info(ctx, bucket, name)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, encodeLockInfo(info))
+}
+
+func encodeLockInfo(info *service.LockInfo) []byte {
+	return EncodeLockResponse(info.Owner, info.Token, info.AcquiredAt, info.ExpiresAt)
+}
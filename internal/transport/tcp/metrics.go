@@ -0,0 +1,125 @@
+package tcp
+
+import (
+	"key-value-store/internal/metrics"
+	"strconv"
+	"time"
+)
+
+var commandNames = map[byte]string{
+	CmdSet:              "SET",
+	CmdGet:              "GET",
+	CmdDelete:           "DELETE",
+	CmdFlush:            "FLUSH",
+	CmdHSet:             "HSET",
+	CmdHGet:             "HGET",
+	CmdHDel:             "HDEL",
+	CmdHIncrBy:          "HINCRBY",
+	CmdHGetAll:          "HGETALL",
+	CmdPush:             "PUSH",
+	CmdPop:              "POP",
+	CmdLRange:           "LRANGE",
+	CmdLTrim:            "LTRIM",
+	CmdLLen:             "LLEN",
+	CmdSAdd:             "SADD",
+	CmdSRem:             "SREM",
+	CmdSIsMember:        "SISMEMBER",
+	CmdSMembers:         "SMEMBERS",
+	CmdSUnion:           "SUNION",
+	CmdSInter:           "SINTER",
+	CmdSDiff:            "SDIFF",
+	CmdZAdd:             "ZADD",
+	CmdZIncrBy:          "ZINCRBY",
+	CmdZRange:           "ZRANGE",
+	CmdZRangeByScore:    "ZRANGEBYSCORE",
+	CmdZRank:            "ZRANK",
+	CmdZRem:             "ZREM",
+	CmdZRemRangeByRank:  "ZREMRANGEBYRANK",
+	CmdZRemRangeByScore: "ZREMRANGEBYSCORE",
+	CmdJSet:             "JSET",
+	CmdJGet:             "JGET",
+	CmdJDel:             "JDEL",
+	CmdJArrAppend:       "JARRAPPEND",
+	CmdJIncrBy:          "JINCRBY",
+	CmdIQuery:           "IQUERY",
+	CmdXAdd:             "XADD",
+	CmdXRange:           "XRANGE",
+	CmdXRead:            "XREAD",
+	CmdXTrim:            "XTRIM",
+	CmdXGroupCreate:     "XGROUPCREATE",
+	CmdXReadGroup:       "XREADGROUP",
+	CmdXAck:             "XACK",
+	CmdXPending:         "XPENDING",
+	CmdXClaim:           "XCLAIM",
+	CmdPFAdd:            "PFADD",
+	CmdPFCount:          "PFCOUNT",
+	CmdPFMerge:          "PFMERGE",
+	CmdBFReserve:        "BFRESERVE",
+	CmdBFAdd:            "BFADD",
+	CmdBFExists:         "BFEXISTS",
+	CmdBFInfo:           "BFINFO",
+	CmdLock:             "LOCK",
+	CmdRenew:            "RENEW",
+	CmdUnlock:           "UNLOCK",
+	CmdLockInfo:         "LOCKINFO",
+	CmdRateLimit:        "RATELIMIT",
+}
+
+var statusNames = map[byte]string{
+	StatusOK:            "ok",
+	StatusCreated:       "created",
+	StatusNoContent:     "no_content",
+	StatusBadRequest:    "bad_request",
+	StatusUnauthorized:  "unauthorized",
+	StatusNotFound:      "not_found",
+	StatusConflict:      "conflict",
+	StatusWrongType:     "wrong_type",
+	StatusInternalError: "internal_error",
+	StatusInvalidTTL:    "invalid_ttl",
+	StatusKeyExpired:    "key_expired",
+	StatusValueTooLarge: "value_too_large",
+	StatusLimitExceeded: "limit_exceeded",
+}
+
+// observe records a handled frame
+// Rejected tokens are only counted as auth failures, like the HTTP transport,
+// so unauthenticated clients cannot create bucket label values
+func observe(frame, resp *Frame, start time.Time) {
+	command, ok := commandNames[frame.Command]
+	if !ok {
+		command = "unknown"
+	}
+	metrics.CommandDuration.With(metrics.TransportTCP, command).ObserveSince(start)
+
+	status, _, err := ParseResponsePayload(resp.Payload)
+	if err != nil {
+		return
+	}
+	if status == StatusUnauthorized {
+		metrics.AuthFailures.With(metrics.TransportTCP, "bucket").Inc()
+		return
+	}
+
+	name, ok := statusNames[status]
+	if !ok {
+		name = strconv.Itoa(int(status))
+	}
+
+	// Every command payload starts with the token and the bucket
+	// Malformed frames are rejected before authentication, their bucket is not trusted
+	var bucket string
+	if !rejectedFrame(resp) {
+		if _, offset, err := readString(frame.Payload, 0); err == nil {
+			bucket, _, _ = readString(frame.Payload, offset)
+		}
+	}
+	metrics.Operations.With(bucket, metrics.TransportTCP, command, name).Inc()
+}
+
+func rejectedFrame(resp *Frame) bool {
+	if resp.Command != CmdError || len(resp.Payload) < 1 || resp.Payload[0] != StatusBadRequest {
+		return false
+	}
+	msg := string(resp.Payload[1:])
+	return msg == msgInvalidPayload || msg == msgUnknownCommand
+}
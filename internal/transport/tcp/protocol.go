@@ -12,12 +12,71 @@ const (
 )
 
 const (
-	CmdSet      byte = 0x01
-	CmdGet      byte = 0x02
-	CmdDelete   byte = 0x03
-	CmdAuth     byte = 0x20
-	CmdResponse byte = 0xF0
-	CmdError    byte = 0xFF
+	CmdSet              byte = 0x01
+	CmdGet              byte = 0x02
+	CmdDelete           byte = 0x03
+	CmdFlush            byte = 0x04
+	CmdAuth             byte = 0x20
+	CmdHSet             byte = 0x30
+	CmdHGet             byte = 0x31
+	CmdHDel             byte = 0x32
+	CmdHIncrBy          byte = 0x33
+	CmdHGetAll          byte = 0x34
+	CmdPush             byte = 0x38
+	CmdPop              byte = 0x39
+	CmdLRange           byte = 0x3A
+	CmdLTrim            byte = 0x3B
+	CmdLLen             byte = 0x3C
+	CmdSAdd             byte = 0x40
+	CmdSRem             byte = 0x41
+	CmdSIsMember        byte = 0x42
+	CmdSMembers         byte = 0x43
+	CmdSUnion           byte = 0x44
+	CmdSInter           byte = 0x45
+	CmdSDiff            byte = 0x46
+	CmdZAdd             byte = 0x48
+	CmdZIncrBy          byte = 0x49
+	CmdZRange           byte = 0x4A
+	CmdZRangeByScore    byte = 0x4B
+	CmdZRank            byte = 0x4C
+	CmdZRem             byte = 0x4D
+	CmdZRemRangeByRank  byte = 0x4E
+	CmdZRemRangeByScore byte = 0x4F
+	CmdJSet             byte = 0x50
+	CmdJGet             byte = 0x51
+	CmdJDel             byte = 0x52
+	CmdJArrAppend       byte = 0x53
+	CmdJIncrBy          byte = 0x54
+	CmdIQuery           byte = 0x58
+	CmdXAdd             byte = 0x60
+	CmdXRange           byte = 0x61
+	CmdXRead            byte = 0x62
+	CmdXTrim            byte = 0x63
+	CmdXGroupCreate     byte = 0x64
+	CmdXReadGroup       byte = 0x65
+	CmdXAck             byte = 0x66
+	CmdXPending         byte = 0x67
+	CmdXClaim           byte = 0x68
+	CmdPFAdd            byte = 0x70
+	CmdPFCount          byte = 0x71
+	CmdPFMerge          byte = 0x72
+	CmdBFReserve        byte = 0x74
+	CmdBFAdd            byte = 0x75
+	CmdBFExists         byte = 0x76
+	CmdBFInfo           byte = 0x77
+	CmdLock             byte = 0x80
+	CmdRenew            byte = 0x81
+	CmdUnlock           byte = 0x82
+	CmdLockInfo         byte = 0x83
+	CmdRateLimit        byte = 0x88
+	CmdResponse         byte = 0xF0
+	CmdError            byte = 0xFF
+)
+
+// List ends of CmdPush and CmdPop
+const (
+	EndLeft  byte = 0x00
+	EndRight byte = 0x01
 )
 
 const (
@@ -28,9 +87,12 @@ const (
 	StatusUnauthorized  byte = 0x11
 	StatusNotFound      byte = 0x12
 	StatusConflict      byte = 0x13
+	StatusWrongType     byte = 0x14
 	StatusInternalError byte = 0x20
 	StatusInvalidTTL    byte = 0x21
 	StatusKeyExpired    byte = 0x22
+	StatusValueTooLarge byte = 0x23
+	StatusLimitExceeded byte = 0x24
 )
 
 var (
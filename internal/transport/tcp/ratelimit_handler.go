@@ -0,0 +1,35 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/metrics"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// handleRateLimit answers StatusOK whether the tokens were taken or not, the
+// response tells which
+func (h *Handler) handleRateLimit(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, capacity, rate, periodMs, cost, err := DecodeRateLimitPayload(frame.Payload)
+	if err != nil || periodMs <= 0 || periodMs > math.MaxInt64/int64(time.Millisecond) {
+		slog.Debug("TCP: Failed to decode RATELIMIT payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for RATELIMIT", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	limit := engine.RateLimit{Capacity: capacity, Rate: rate, Period: time.Duration(periodMs) * time.Millisecond}
+	res, err := h.rateService.Take(ctx, bucket, key, limit, cost)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeRateLimitResponse(res))
+}
@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"key-value-store/internal/metrics"
 	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,22 +16,34 @@ const (
 	MaxConnectionBuffSize = 17 << 20
 	readChunkSize         = 64 << 10 // 64 kb
 	readerBufSize         = 32 << 10
+	// closeWatchDelay is how long a frame runs before the connection is
+	// watched for a disconnect, see watchClose
+	closeWatchDelay = 50 * time.Millisecond
 )
 
 type StdServer struct {
 	addr           string
 	handler        *Handler
 	ln             net.Listener
+	conns          map[net.Conn]struct{}
 	wg             sync.WaitGroup
 	mu             sync.Mutex
-	stop           bool
-	AcceptDeadline time.Duration
-	ReadTimeout    time.Duration
-	WriteTimeout   time.Duration
+	stopping       atomic.Bool
+	acceptDeadline atomic.Int64 // time.Duration, 0 = none
+	readTimeout    atomic.Int64
+	writeTimeout   atomic.Int64
 }
 
 func NewServer(addr string, h *Handler) *StdServer {
-	return &StdServer{addr: addr, handler: h}
+	return &StdServer{addr: addr, handler: h, conns: make(map[net.Conn]struct{})}
+}
+
+// SetTimeouts changes the deadlines of the next accept, read and write, 0 disables one
+// It is safe to call while serving
+func (s *StdServer) SetTimeouts(read, write, accept time.Duration) {
+	s.readTimeout.Store(int64(read))
+	s.writeTimeout.Store(int64(write))
+	s.acceptDeadline.Store(int64(accept))
 }
 
 var tmpPool = sync.Pool{
@@ -39,7 +53,8 @@ var bufPool = sync.Pool{
 	New: func() any { b := make([]byte, 0, readerBufSize); return &b },
 }
 
-func (s *StdServer) Start() error {
+// Listen binds the address, connections are accepted once Serve is called
+func (s *StdServer) Listen() error {
 	ln, err := net.Listen("tcp", s.addr)
 	if err != nil {
 		return err
@@ -48,66 +63,103 @@ func (s *StdServer) Start() error {
 	s.ln = ln
 	s.mu.Unlock()
 
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		tl, _ := ln.(*net.TCPListener)
-		for {
-			if s.AcceptDeadline > 0 && tl != nil {
-				_ = tl.SetDeadline(time.Now().Add(s.AcceptDeadline))
-			}
-			conn, err := ln.Accept()
-			if err != nil {
-				var ne net.Error
-				if errors.As(err, &ne) && ne.Timeout() {
-					continue
-				}
-				s.mu.Lock()
-				stopped := s.stop
-				s.mu.Unlock()
-				if stopped {
-					return
-				}
-				slog.Error("stdtcp: accept error", "error", err)
-				return
-			}
-			s.wg.Add(1)
-			go func(c net.Conn) {
-				defer s.wg.Done()
-				s.handleConn(c)
-			}(conn)
-		}
-	}()
-
 	slog.Info("stdtcp: listening", "addr", s.addr)
 	return nil
 }
 
-func (s *StdServer) Stop(ctx context.Context) error {
+// Serve accepts connections on the listener bound by Listen
+// It returns nil once Shutdown is called
+func (s *StdServer) Serve() error {
 	s.mu.Lock()
-	if s.stop {
+	ln := s.ln
+	s.mu.Unlock()
+
+	tl, _ := ln.(*net.TCPListener)
+	for {
+		if d := time.Duration(s.acceptDeadline.Load()); d > 0 && tl != nil {
+			_ = tl.SetDeadline(time.Now().Add(d))
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			if s.stopping.Load() {
+				return nil
+			}
+			slog.Error("stdtcp: accept error", "error", err)
+			return err
+		}
+
+		// Registering under mu orders the connection before or after Shutdown
+		s.mu.Lock()
+		if s.stopping.Load() {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
 		s.mu.Unlock()
+
+		go func(c net.Conn) {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, c)
+				s.mu.Unlock()
+			}()
+			s.handleConn(c)
+		}(conn)
+	}
+}
+
+// Shutdown stops accepting connections and lets every connection finish the
+// frames it already received, connections still open when ctx is done are closed
+func (s *StdServer) Shutdown(ctx context.Context) error {
+	if s.stopping.Swap(true) {
 		return nil
 	}
-	s.stop = true
-	ln := s.ln
-	s.mu.Unlock()
-	if ln != nil {
-		_ = ln.Close()
+
+	s.mu.Lock()
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	// Wake up connections blocked in a read, handleConn sees stopping and returns
+	for c := range s.conns {
+		_ = c.SetReadDeadline(time.Now())
 	}
+	s.mu.Unlock()
+
 	done := make(chan struct{})
 	go func() { s.wg.Wait(); close(done) }()
 	select {
 	case <-done:
 		return nil
 	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			_ = c.Close()
+		}
+		s.mu.Unlock()
 		return ctx.Err()
 	}
 }
 
+func (s *StdServer) Addr() string { return s.addr }
+
 func (s *StdServer) handleConn(c net.Conn) {
 	defer c.Close()
 
+	// Blocking operations stop waiting once the connection is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	metrics.TCPConnectionsAccepted.Inc()
+	metrics.TCPConnections.Inc()
+	defer metrics.TCPConnections.Dec()
+
 	if tc, ok := c.(*net.TCPConn); ok {
 		_ = tc.SetKeepAlive(true)
 		_ = tc.SetKeepAlivePeriod(2 * time.Minute)
@@ -124,9 +176,19 @@ func (s *StdServer) handleConn(c net.Conn) {
 		tmpPool.Put(tmpPtr)
 	}()
 
+	// Timeouts can be disabled by a reload, a deadline set earlier must then be cleared
+	var readDeadline, writeDeadline bool
 	for {
-		if s.ReadTimeout > 0 {
-			_ = c.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		if d := time.Duration(s.readTimeout.Load()); d > 0 {
+			_ = c.SetReadDeadline(time.Now().Add(d))
+			readDeadline = true
+		} else if readDeadline {
+			_ = c.SetReadDeadline(time.Time{})
+			readDeadline = false
+		}
+		// Checked after the deadline update, Shutdown may have set its own before it
+		if s.stopping.Load() {
+			return
 		}
 		n, err := br.Read(tmp)
 		if err != nil {
@@ -138,6 +200,7 @@ func (s *StdServer) handleConn(c net.Conn) {
 		}
 		if len(buf)+n > MaxConnectionBuffSize {
 			slog.Warn("stdtcp: buffer overflow", "remote", c.RemoteAddr().String())
+			metrics.TCPFrameErrors.With("buffer_overflow").Inc()
 			return
 		}
 		buf = append(buf, tmp[:n]...)
@@ -149,6 +212,7 @@ func (s *StdServer) handleConn(c net.Conn) {
 			frameLen := int(binaryBEUint32(buf[0:4]))
 			if frameLen > HeaderSize+MaxPayloadSize {
 				slog.Error("stdtcp: oversized frame", "remote", c.RemoteAddr().String())
+				metrics.TCPFrameErrors.With("oversized").Inc()
 				return
 			}
 			if len(buf) < frameLen {
@@ -160,9 +224,18 @@ func (s *StdServer) handleConn(c net.Conn) {
 
 			f := &Frame{Length: uint32(frameLen), Command: cmd, RequestID: reqID, Payload: payload}
 
-			resp := s.handler.HandleFrame(f)
-			if s.WriteTimeout > 0 {
-				_ = c.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+			stopWatch := watchClose(c, br, cancel)
+			resp := s.handler.HandleFrame(ctx, f)
+			if stopWatch() {
+				// The watch moved the read deadline, the next read sets or clears it
+				readDeadline = true
+			}
+			if d := time.Duration(s.writeTimeout.Load()); d > 0 {
+				_ = c.SetWriteDeadline(time.Now().Add(d))
+				writeDeadline = true
+			} else if writeDeadline {
+				_ = c.SetWriteDeadline(time.Time{})
+				writeDeadline = false
 			}
 			if _, err := c.Write(resp.Encode()); err != nil {
 				return
@@ -176,6 +249,49 @@ func (s *StdServer) handleConn(c net.Conn) {
 	}
 }
 
+// watchClose cancels the connection context when the peer closes the
+// connection while a frame is handled, the read loop is not reading then
+// The watch starts after closeWatchDelay so quick frames never pay for it, and
+// only peeks: bytes of pipelined frames stay in br for the read loop
+// stop ends the watch and reports whether it had started
+func watchClose(c net.Conn, br *bufio.Reader, cancel context.CancelFunc) (stop func() bool) {
+	var mu sync.Mutex
+	var started, stopped bool
+	done := make(chan struct{})
+
+	t := time.AfterFunc(closeWatchDelay, func() {
+		mu.Lock()
+		if stopped {
+			mu.Unlock()
+			return
+		}
+		started = true
+		mu.Unlock()
+		defer close(done)
+
+		if _, err := br.Peek(1); err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				cancel()
+			}
+		}
+	})
+
+	return func() bool {
+		t.Stop()
+		mu.Lock()
+		stopped = true
+		wasStarted := started
+		mu.Unlock()
+		if wasStarted {
+			// Wakes up the peek, it returns at once when bytes arrived meanwhile
+			_ = c.SetReadDeadline(time.Now())
+			<-done
+		}
+		return wasStarted
+	}
+}
+
 func binaryBEUint32(b []byte) uint32 {
 	_ = b[3]
 	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
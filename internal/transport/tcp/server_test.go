@@ -0,0 +1,131 @@
+package tcp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/bucket"
+	"key-value-store/internal/clock"
+	"key-value-store/internal/config"
+	"key-value-store/internal/service"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (addr, token string) {
+	t.Helper()
+	if err := auth.Initialize([]byte("0123456789abcdef0123456789abcdef"), ""); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Store.DataDir = ""
+
+	clk := clock.Real()
+	bm, err := bucket.NewBucketManager(cfg, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err = bm.CreateBucket("jobs", "", 0, bucket.DefaultBucketSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(service.NewStorageService(bm, cfg, clk), service.NewBucketService(bm),
+		service.NewHashService(bm, clk), service.NewListService(bm, clk), service.NewSetService(bm, clk),
+		service.NewSortedSetService(bm, clk), service.NewJSONService(bm, clk), service.NewIndexService(bm),
+		service.NewStreamService(bm, clk), service.NewHyperLogLogService(bm, clk), service.NewBloomService(bm, clk),
+		service.NewLockService(bm, clk), service.NewRateLimitService(bm, clk))
+	s := NewServer("127.0.0.1:0", h)
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = s.Shutdown(ctx)
+		_ = bm.Shutdown()
+	})
+	return s.ln.Addr().String(), token
+}
+
+func roundTrip(t *testing.T, c net.Conn, frame *Frame) (status byte, data []byte) {
+	t.Helper()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write(frame.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	buf = append(buf, make([]byte, binary.BigEndian.Uint32(buf[0:4])-HeaderSize)...)
+	if _, err := io.ReadFull(c, buf[HeaderSize:]); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := DecodeFrame(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, data, err = ParseResponsePayload(resp.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, data
+}
+
+func TestBlockingPopCancelledOnClose(t *testing.T) {
+	addr, token := newTestServer(t)
+
+	waiter, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pop := NewFrame(CmdPop, 1, EncodePopPayload(token, "jobs", "queue", EndLeft, 1, 10_000))
+	if _, err := waiter.Write(pop.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	_ = waiter.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	push := NewFrame(CmdPush, 2, EncodePushPayload(token, "jobs", "queue", EndLeft, 0, [][]byte{[]byte("a")}))
+	if status, data := roundTrip(t, c, push); status != StatusOK {
+		t.Fatalf("push status %#x: %s", status, data)
+	}
+
+	// The abandoned pop must not take the element
+	pop = NewFrame(CmdPop, 3, EncodePopPayload(token, "jobs", "queue", EndLeft, 1, 0))
+	if status, data := roundTrip(t, c, pop); status != StatusOK {
+		t.Fatalf("pop status %#x: %s, the closed connection consumed the element", status, data)
+	}
+}
+
+func TestDeleteHeldLockConflict(t *testing.T) {
+	addr, token := newTestServer(t)
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	lock := NewFrame(CmdLock, 1, EncodeLockPayload(token, "jobs", "job", "a", 30, 0))
+	if status, data := roundTrip(t, c, lock); status != StatusOK {
+		t.Fatalf("lock status %#x: %s", status, data)
+	}
+	del := NewFrame(CmdDelete, 2, EncodeDeletePayload(token, "jobs", "job"))
+	if status, data := roundTrip(t, c, del); status != StatusConflict {
+		t.Errorf("delete of a held lock: status %#x %s, want %#x", status, data, StatusConflict)
+	}
+}
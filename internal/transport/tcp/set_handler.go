@@ -0,0 +1,123 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"log/slog"
+)
+
+func (h *Handler) handleSAdd(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, members, err := DecodeSAddPayload(frame.Payload)
+	if err != nil || len(members) == 0 {
+		slog.Debug("TCP: Failed to decode SADD payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for SADD", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	added, err := h.setService.SAdd(ctx, bucket, key, members, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(added))
+}
+
+func (h *Handler) handleSRem(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, members, err := DecodeMembersPayload(frame.Payload)
+	if err != nil || len(members) == 0 {
+		slog.Debug("TCP: Failed to decode SREM payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for SREM", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.setService.SRem(ctx, bucket, key, members)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(removed))
+}
+
+func (h *Handler) handleSIsMember(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, member, err := DecodeMemberPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode SISMEMBER payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for SISMEMBER", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	isMember, err := h.setService.SIsMember(ctx, bucket, key, member)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBoolResponse(isMember))
+}
+
+func (h *Handler) handleSMembers(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, err := DecodeGetPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode SMEMBERS payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for SMEMBERS", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	members, err := h.setService.SMembers(ctx, bucket, key)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeStringsResponse(members))
+}
+
+// handleSetOp serves SUNION, SINTER and SDIFF, which share their payload
+func (h *Handler) handleSetOp(ctx context.Context, frame *Frame) *Frame {
+	name := commandNames[frame.Command]
+	token, bucket, keys, err := DecodeSetOpPayload(frame.Payload)
+	if err != nil || len(keys) == 0 {
+		slog.Debug("TCP: Failed to decode "+name+" payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for "+name, "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	combine := h.setService.SUnion
+	switch frame.Command {
+	case CmdSInter:
+		combine = h.setService.SInter
+	case CmdSDiff:
+		combine = h.setService.SDiff
+	}
+
+	members, err := combine(ctx, bucket, keys)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeStringsResponse(members))
+}
@@ -0,0 +1,176 @@
+package tcp
+
+import (
+	"context"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/metrics"
+	"log/slog"
+)
+
+func (h *Handler) handleZAdd(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, members, err := DecodeZAddPayload(frame.Payload)
+	if err != nil || len(members) == 0 {
+		slog.Debug("TCP: Failed to decode ZADD payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZADD", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	added, err := h.zsetService.ZAdd(ctx, bucket, key, members, ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(added))
+}
+
+func (h *Handler) handleZIncrBy(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, member, delta, err := DecodeZIncrByPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode ZINCRBY payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZINCRBY", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	score, err := h.zsetService.ZIncrBy(ctx, bucket, key, member, delta)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeScoreResponse(score))
+}
+
+func (h *Handler) handleZRange(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, start, stop, err := DecodeRangePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode ZRANGE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZRANGE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	members, err := h.zsetService.ZRange(ctx, bucket, key, start, stop)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeScoredResponse(members))
+}
+
+func (h *Handler) handleZRangeByScore(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, lo, hi, err := DecodeScoreRangePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode ZRANGEBYSCORE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZRANGEBYSCORE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	members, err := h.zsetService.ZRangeByScore(ctx, bucket, key, lo, hi)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeScoredResponse(members))
+}
+
+func (h *Handler) handleZRank(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, member, err := DecodeMemberPayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode ZRANK payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZRANK", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	rank, score, err := h.zsetService.ZRank(ctx, bucket, key, member)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeRankResponse(rank, score))
+}
+
+func (h *Handler) handleZRem(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, members, err := DecodeMembersPayload(frame.Payload)
+	if err != nil || len(members) == 0 {
+		slog.Debug("TCP: Failed to decode ZREM payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZREM", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.zsetService.ZRem(ctx, bucket, key, members)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(removed))
+}
+
+func (h *Handler) handleZRemRangeByRank(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, start, stop, err := DecodeRangePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode ZREMRANGEBYRANK payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZREMRANGEBYRANK", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.zsetService.ZRemRangeByRank(ctx, bucket, key, start, stop)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(removed))
+}
+
+func (h *Handler) handleZRemRangeByScore(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, lo, hi, err := DecodeScoreRangePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode ZREMRANGEBYSCORE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for ZREMRANGEBYSCORE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.zsetService.ZRemRangeByScore(ctx, bucket, key, lo, hi)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(removed))
+}
@@ -0,0 +1,249 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"key-value-store/internal/auth"
+	"key-value-store/internal/engine"
+	"key-value-store/internal/errs"
+	"key-value-store/internal/metrics"
+	"key-value-store/internal/service"
+	"log/slog"
+	"math"
+	"time"
+)
+
+func (h *Handler) handleXAdd(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, ttl, maxLen, maxAgeMs, fields, err := DecodeXAddPayload(frame.Payload)
+	if err != nil || len(fields) == 0 || ttl < 0 {
+		slog.Debug("TCP: Failed to decode XADD payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XADD", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	id, err := h.streamService.Add(ctx, bucket, key, fields, streamTrim(maxLen, maxAgeMs), ttl)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBytesResponse([]byte(id.String())))
+}
+
+func (h *Handler) handleXRange(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, startID, endID, count, err := DecodeXRangePayload(frame.Payload)
+	if err != nil || count > service.MaxStreamCount {
+		slog.Debug("TCP: Failed to decode XRANGE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XRANGE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	start, err := service.ParseStreamStart(startID)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+	end, err := service.ParseStreamEnd(endID)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	entries, err := h.streamService.Range(ctx, bucket, key, start, end, count)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeEntriesResponse(entries))
+}
+
+func (h *Handler) handleXRead(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, afterID, count, timeoutMs, err := DecodeXReadPayload(frame.Payload)
+	if err != nil || count > service.MaxStreamCount {
+		slog.Debug("TCP: Failed to decode XREAD payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XREAD", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	after, err := service.ParseStreamAfter(afterID)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	entries, err := h.streamService.Read(ctx, bucket, key, after, count, time.Duration(timeoutMs)*time.Millisecond)
+	if errors.Is(err, errs.ErrWaitTimeout) {
+		return NewResponseFrame(frame.RequestID, StatusNoContent, nil)
+	}
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeEntriesResponse(entries))
+}
+
+func (h *Handler) handleXTrim(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, maxLen, maxAgeMs, err := DecodeXTrimPayload(frame.Payload)
+	if err != nil || (maxLen == 0 && maxAgeMs == 0) {
+		slog.Debug("TCP: Failed to decode XTRIM payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XTRIM", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	removed, err := h.streamService.Trim(ctx, bucket, key, streamTrim(maxLen, maxAgeMs))
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(removed))
+}
+
+func (h *Handler) handleXGroupCreate(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, group, startID, err := DecodeXGroupCreatePayload(frame.Payload)
+	if err != nil {
+		slog.Debug("TCP: Failed to decode XGROUPCREATE payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XGROUPCREATE", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	start, err := service.ParseStreamAfter(startID)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	from, err := h.streamService.CreateGroup(ctx, bucket, key, group, start)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeBytesResponse([]byte(from.String())))
+}
+
+func (h *Handler) handleXReadGroup(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, group, consumer, count, timeoutMs, err := DecodeXReadGroupPayload(frame.Payload)
+	if err != nil || count > service.MaxStreamCount {
+		slog.Debug("TCP: Failed to decode XREADGROUP payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XREADGROUP", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	entries, err := h.streamService.ReadGroup(ctx, bucket, key, group, consumer, count, time.Duration(timeoutMs)*time.Millisecond)
+	if errors.Is(err, errs.ErrWaitTimeout) {
+		return NewResponseFrame(frame.RequestID, StatusNoContent, nil)
+	}
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeEntriesResponse(entries))
+}
+
+func (h *Handler) handleXAck(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, group, rawIDs, err := DecodeXAckPayload(frame.Payload)
+	if err != nil || len(rawIDs) == 0 {
+		slog.Debug("TCP: Failed to decode XACK payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XACK", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	ids := make([]engine.StreamID, len(rawIDs))
+	for i, s := range rawIDs {
+		if ids[i], err = engine.ParseStreamID(s); err != nil {
+			return h.handleServiceError(frame.RequestID, err)
+		}
+	}
+
+	acked, err := h.streamService.Ack(ctx, bucket, key, group, ids)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeCountResponse(acked))
+}
+
+func (h *Handler) handleXPending(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, group, consumer, startID, count, err := DecodeXPendingPayload(frame.Payload)
+	if err != nil || count > service.MaxStreamCount {
+		slog.Debug("TCP: Failed to decode XPENDING payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XPENDING", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	start, err := service.ParseStreamStart(startID)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	q := service.PendingQuery{Consumer: consumer, Start: start, Count: count}
+	pending, err := h.streamService.Pending(ctx, bucket, key, group, q)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodePendingResponse(pending))
+}
+
+func (h *Handler) handleXClaim(ctx context.Context, frame *Frame) *Frame {
+	token, bucket, key, group, consumer, minIdleMs, count, err := DecodeXClaimPayload(frame.Payload)
+	if err != nil || count > service.MaxStreamCount {
+		slog.Debug("TCP: Failed to decode XCLAIM payload", "error", err)
+		metrics.TCPFrameErrors.With("invalid_payload").Inc()
+		return NewErrorFrame(frame.RequestID, StatusBadRequest, msgInvalidPayload)
+	}
+
+	if !auth.Manager().ValidateToken(token, bucket) {
+		slog.Debug("TCP: Invalid token for XCLAIM", "bucket", bucket)
+		return NewErrorFrame(frame.RequestID, StatusUnauthorized, "Invalid token")
+	}
+
+	minIdle := time.Duration(min(minIdleMs, uint64(math.MaxInt64/time.Millisecond))) * time.Millisecond
+	entries, err := h.streamService.Claim(ctx, bucket, key, group, consumer, minIdle, count)
+	if err != nil {
+		return h.handleServiceError(frame.RequestID, err)
+	}
+
+	return NewResponseFrame(frame.RequestID, StatusOK, EncodeEntriesResponse(entries))
+}
+
+func streamTrim(maxLen uint32, maxAgeMs uint64) service.StreamTrim {
+	return service.StreamTrim{
+		MaxLen: int(maxLen),
+		MaxAge: time.Duration(min(maxAgeMs, uint64(math.MaxInt64/time.Millisecond))) * time.Millisecond,
+	}
+}
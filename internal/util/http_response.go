@@ -53,6 +53,16 @@ func WriteConflict(w http.ResponseWriter, message string) {
 	JSONError(w, http.StatusConflict, message)
 }
 
+// WritePayloadTooLarge writes a 413 response with the given message
+func WritePayloadTooLarge(w http.ResponseWriter, message string) {
+	JSONError(w, http.StatusRequestEntityTooLarge, message)
+}
+
+// WriteUnprocessable writes a 422 response with the given message
+func WriteUnprocessable(w http.ResponseWriter, message string) {
+	JSONError(w, http.StatusUnprocessableEntity, message)
+}
+
 // WriteInternalError writes a 500 response with the given message
 func WriteInternalError(w http.ResponseWriter) {
 	JSONError(w, http.StatusInternalServerError, "Internal server error")